@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/backup"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/repositories"
+)
+
+// Usage errors of backup and restore commands.
+var (
+	errBackupUsage  = errors.New("usage: shortener [flags] backup <file|->")
+	errRestoreUsage = errors.New("usage: shortener [flags] restore [-chunk size] [-verify-only] <file>")
+)
+
+// runBackup dumps all links of repo to file or stdout.
+func runBackup(repo repositories.IRepository, args []string) error {
+	flagSet := flag.NewFlagSet("backup", flag.ContinueOnError)
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 1 {
+		return errBackupUsage
+	}
+	path := flagSet.Arg(0)
+
+	var output io.Writer = os.Stdout
+	if path != "-" {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		output = file
+	}
+
+	footer, err := backup.Write(context.Background(), repo, output)
+	if err != nil {
+		if path != "-" {
+			os.Remove(path)
+		}
+		return err
+	}
+	log.Printf("Backed up %d links, sha256 %s", footer.Count, footer.SHA256)
+	return nil
+}
+
+// runRestore verifies backup file and loads its links into repo.
+func runRestore(repo repositories.IRepository, args []string) error {
+	flagSet := flag.NewFlagSet("restore", flag.ContinueOnError)
+	chunkSize := flagSet.Int("chunk", backup.DefaultChunkSize, "Number of links written at once")
+	verifyOnly := flagSet.Bool("verify-only", false, "Only check the backup, load nothing")
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 1 {
+		return errRestoreUsage
+	}
+
+	// File is read twice, nothing is loaded unless the whole backup is intact.
+	file, err := os.Open(flagSet.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	footer, err := backup.Verify(file)
+	if err != nil {
+		return err
+	}
+	if *verifyOnly {
+		log.Printf("Backup of %d links is valid, sha256 %s", footer.Count, footer.SHA256)
+		return nil
+	}
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if footer, err = backup.Restore(context.Background(), repo, file, *chunkSize); err != nil {
+		return err
+	}
+	log.Printf("Restored %d links", footer.Count)
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/importer"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/repositories"
+	"github.com/google/uuid"
+)
+
+// errImportUsage returned when import command is called with wrong arguments.
+var errImportUsage = errors.New("usage: shortener [flags] import [-format csv|jsonl] [-chunk size] [-owner user_id] <file|->")
+
+// runImport imports links from file or stdin into repo and prints report to stdout.
+func runImport(repo repositories.IRepository, args []string) error {
+	flagSet := flag.NewFlagSet("import", flag.ContinueOnError)
+	formatName := flagSet.String("format", "", "Input format csv or jsonl, guessed by file extension if empty")
+	chunkSize := flagSet.Int("chunk", importer.DefaultChunkSize, "Number of rows written at once")
+	ownerID := flagSet.String("owner", "", "User id given to rows without user_id, such rows are rejected if empty")
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 1 {
+		return errImportUsage
+	}
+	var owner uuid.UUID
+	if *ownerID != "" {
+		var err error
+		if owner, err = uuid.Parse(*ownerID); err != nil {
+			return errImportUsage
+		}
+	}
+	path := flagSet.Arg(0)
+	if *formatName == "" {
+		*formatName = filepath.Ext(path)
+		if len(*formatName) > 0 {
+			*formatName = (*formatName)[1:]
+		}
+	}
+	format, err := importer.ParseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	var input io.Reader = os.Stdin
+	if path != "-" {
+		file, errOpen := os.Open(path)
+		if errOpen != nil {
+			return errOpen
+		}
+		defer file.Close()
+		input = file
+	}
+
+	imp := importer.Importer{Repo: repo, ChunkSize: *chunkSize, Owner: owner}
+	report, err := imp.Import(context.Background(), input, format)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", " ")
+	return encoder.Encode(report)
+}
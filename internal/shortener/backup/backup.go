@@ -0,0 +1,197 @@
+// Package backup dumps links of any repository to portable archive and loads them back.
+//
+// Archive is gzip compressed JSON-lines stream: header line first, then one line per link
+// and footer line with count of links and SHA-256 of all uncompressed lines preceding it.
+package backup
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"time"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/repositories"
+)
+
+// Archive identification.
+const (
+	FormatName = "go-url-shortener-backup"
+	Version    = 1
+)
+
+// DefaultChunkSize number of links loaded into repository at once.
+const DefaultChunkSize = 1000
+
+// Errors of reading archive.
+var (
+	ErrNotBackup          = errors.New("input is not a shortener backup")
+	ErrUnsupportedVersion = errors.New("unsupported backup version")
+	ErrChecksumMismatch   = errors.New("backup checksum mismatch")
+	ErrTruncated          = errors.New("backup is truncated")
+)
+
+// Header first line of archive.
+type Header struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Footer last line of archive.
+type Footer struct {
+	Count  int64  `json:"count"`
+	SHA256 string `json:"sha256"`
+}
+
+// line single line of archive, exactly one of fields is set. Links keep password hashes,
+// so protected ones stay protected after restore.
+type line struct {
+	Header *Header                  `json:"header,omitempty"`
+	Link   *entities.StoredShortURL `json:"link,omitempty"`
+	Footer *Footer                  `json:"footer,omitempty"`
+}
+
+// Write dumps all links of repo to w, links are never held in memory all at once.
+func Write(ctx context.Context, repo repositories.IBackupRepository, w io.Writer) (Footer, error) {
+	zw := gzip.NewWriter(w)
+	checksum := sha256.New()
+	encoder := json.NewEncoder(io.MultiWriter(zw, checksum))
+
+	header := Header{Format: FormatName, Version: Version, CreatedAt: time.Now().UTC()}
+	if err := encoder.Encode(line{Header: &header}); err != nil {
+		return Footer{}, err
+	}
+	var footer Footer
+	err := repo.IterateAll(ctx, func(shortURL entities.ShortURL) error {
+		footer.Count++
+		stored := shortURL.ToStored()
+		return encoder.Encode(line{Link: &stored})
+	})
+	if err != nil {
+		return Footer{}, err
+	}
+	footer.SHA256 = hex.EncodeToString(checksum.Sum(nil))
+	if err = json.NewEncoder(zw).Encode(line{Footer: &footer}); err != nil {
+		return Footer{}, err
+	}
+	return footer, zw.Close()
+}
+
+// Verify reads the whole archive and checks its checksum without loading it anywhere.
+func Verify(r io.Reader) (Footer, error) {
+	return read(r, func(entities.ShortURL) error { return nil })
+}
+
+// Restore loads links from archive into repo in chunks, links with the same id are replaced.
+//
+// Checksum is known only at the end of archive, so input has to be checked with Verify first
+// if nothing should be loaded from a corrupted one.
+func Restore(ctx context.Context, repo repositories.IBackupRepository, r io.Reader, chunkSize int) (Footer, error) {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	chunk := make([]entities.ShortURL, 0, chunkSize)
+	footer, err := read(r, func(shortURL entities.ShortURL) error {
+		chunk = append(chunk, shortURL)
+		if len(chunk) < chunkSize {
+			return nil
+		}
+		err := repo.LoadBackup(ctx, chunk)
+		chunk = chunk[:0]
+		return err
+	})
+	if err != nil {
+		return footer, err
+	}
+	if len(chunk) > 0 {
+		err = repo.LoadBackup(ctx, chunk)
+	}
+	return footer, err
+}
+
+// read calls fn for every link of archive and checks header, footer and checksum.
+func read(r io.Reader, fn func(entities.ShortURL) error) (Footer, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return Footer{}, fmt.Errorf("%w: %v", ErrNotBackup, err)
+	}
+	defer zr.Close()
+	lines := &lineReader{reader: bufio.NewReader(zr), checksum: sha256.New()}
+
+	first, err := lines.next()
+	if err != nil {
+		return Footer{}, err
+	}
+	if first.Header == nil || first.Header.Format != FormatName {
+		return Footer{}, ErrNotBackup
+	}
+	if first.Header.Version > Version {
+		return Footer{}, fmt.Errorf("%w: %d", ErrUnsupportedVersion, first.Header.Version)
+	}
+
+	var count int64
+	for {
+		current, errNext := lines.next()
+		if errNext != nil {
+			return Footer{}, errNext
+		}
+		if current.Footer != nil {
+			return checkFooter(lines, *current.Footer, count)
+		}
+		if current.Link == nil || current.Link.ID == "" {
+			return Footer{}, fmt.Errorf("%w: line %d has no link", ErrNotBackup, lines.number)
+		}
+		count++
+		if err = fn(current.Link.ToShortURL()); err != nil {
+			return Footer{}, err
+		}
+	}
+}
+
+// checkFooter compares footer with the data read, nothing may follow the footer.
+func checkFooter(lines *lineReader, footer Footer, count int64) (Footer, error) {
+	if footer.Count != count || footer.SHA256 != hex.EncodeToString(lines.checksum.Sum(nil)) {
+		return footer, ErrChecksumMismatch
+	}
+	if _, err := lines.reader.ReadByte(); !errors.Is(err, io.EOF) {
+		if err != nil {
+			return footer, err
+		}
+		return footer, fmt.Errorf("%w: data after footer", ErrNotBackup)
+	}
+	return footer, nil
+}
+
+// lineReader decodes lines of archive and hashes them.
+type lineReader struct {
+	reader   *bufio.Reader
+	checksum hash.Hash
+	number   int
+}
+
+func (lr *lineReader) next() (line, error) {
+	raw, err := lr.reader.ReadBytes('\n')
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return line{}, ErrTruncated
+	}
+	if err != nil {
+		return line{}, err
+	}
+	lr.number++
+	var current line
+	if err = json.Unmarshal(raw, &current); err != nil {
+		return line{}, fmt.Errorf("%w: line %d: %v", ErrNotBackup, lr.number, err)
+	}
+	if current.Footer == nil {
+		lr.checksum.Write(raw)
+	}
+	return current, nil
+}
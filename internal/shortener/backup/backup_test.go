@@ -0,0 +1,150 @@
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/repositories"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func backupFixture() map[string]entities.ShortURL {
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	deletedAt := createdAt.Add(time.Hour)
+	urls := []entities.ShortURL{
+		{
+			ID: "active", Short: "http://localhost:8080/active", Original: "https://ya.ru",
+			UserID: uuid.New(), CorrelationID: "first", IsActive: true, CreatedAt: createdAt, PasswordHash: "$2a$10$hash",
+		},
+		{
+			ID: "deleted", Short: "http://localhost:8080/deleted", Original: "https://mail.ru",
+			UserID: uuid.New(), IsActive: false, DeletedAt: &deletedAt, CreatedAt: createdAt.Add(time.Minute),
+		},
+		{
+			ID: "inactive", Short: "http://localhost:8080/inactive", Original: "https://go.dev",
+			UserID: uuid.New(), CorrelationID: "third", IsActive: false, CreatedAt: createdAt.Add(time.Minute),
+		},
+	}
+	storage := make(map[string]entities.ShortURL, len(urls))
+	for _, url := range urls {
+		storage[url.ID] = url
+	}
+	return storage
+}
+
+func writeBackup(t *testing.T, storage map[string]entities.ShortURL) []byte {
+	var archive bytes.Buffer
+	footer, err := Write(context.Background(), &repositories.InMemoryRepository{Storage: storage}, &archive)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(storage)), footer.Count)
+	return archive.Bytes()
+}
+
+func gunzip(t *testing.T, archive []byte) string {
+	zr, err := gzip.NewReader(bytes.NewReader(archive))
+	require.NoError(t, err)
+	content, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	return string(content)
+}
+
+func gzipString(t *testing.T, content string) []byte {
+	var archive bytes.Buffer
+	zw := gzip.NewWriter(&archive)
+	_, err := zw.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return archive.Bytes()
+}
+
+func TestRoundTripBetweenBackends(t *testing.T) {
+	source := backupFixture()
+	archive := writeBackup(t, source)
+
+	footer, err := Verify(bytes.NewReader(archive))
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), footer.Count)
+
+	target := &repositories.FileRepository{
+		Storage:  map[string]entities.ShortURL{"active": {ID: "active", Original: "https://stale.ru"}},
+		FilePath: filepath.Join(t.TempDir(), "storage.json"),
+	}
+	_, err = Restore(context.Background(), target, bytes.NewReader(archive), 2)
+	require.NoError(t, err)
+	assert.Equal(t, source, target.Storage)
+
+	reloaded := &repositories.FileRepository{FilePath: target.FilePath}
+	require.NoError(t, reloaded.Restore())
+	assert.Equal(t, source, reloaded.Storage)
+}
+
+func TestEmptyBackup(t *testing.T) {
+	archive := writeBackup(t, map[string]entities.ShortURL{})
+	target := &repositories.InMemoryRepository{Storage: map[string]entities.ShortURL{}}
+	footer, err := Restore(context.Background(), target, bytes.NewReader(archive), 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), footer.Count)
+	assert.Empty(t, target.Storage)
+}
+
+func TestVerifyRejectsDamagedArchive(t *testing.T) {
+	content := gunzip(t, writeBackup(t, backupFixture()))
+	lines := strings.SplitAfter(content, "\n")
+
+	tests := []struct {
+		name    string
+		archive []byte
+		err     error
+	}{
+		{
+			name:    "not gzip",
+			archive: []byte("id,original_url\n"),
+			err:     ErrNotBackup,
+		},
+		{
+			name:    "changed link",
+			archive: gzipString(t, strings.Replace(content, "https://ya.ru", "https://evil.ru", 1)),
+			err:     ErrChecksumMismatch,
+		},
+		{
+			name:    "dropped link",
+			archive: gzipString(t, lines[0]+lines[2]+lines[3]+lines[4]),
+			err:     ErrChecksumMismatch,
+		},
+		{
+			name:    "no footer",
+			archive: gzipString(t, strings.Join(lines[:4], "")),
+			err:     ErrTruncated,
+		},
+		{
+			name:    "no header",
+			archive: gzipString(t, strings.Join(lines[1:], "")),
+			err:     ErrNotBackup,
+		},
+		{
+			name:    "newer version",
+			archive: gzipString(t, strings.Replace(content, `"version":1`, `"version":2`, 1)),
+			err:     ErrUnsupportedVersion,
+		},
+		{
+			name:    "data after footer",
+			archive: gzipString(t, content+lines[1]),
+			err:     ErrNotBackup,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Verify(bytes.NewReader(tt.archive))
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/caarlos0/env/v6"
 )
@@ -19,22 +20,79 @@ const (
 	NoURLFoundByID                 = "No url found by id"
 	NoUserIDProvided               = "No user ID has been provided"
 	NoConnectionToDatabase         = "Error while connecting to database"
+	InvalidAPIKey                  = "Invalid API key"
+	InsufficientAPIKeyScope        = "API key scope does not allow this action"
+	APIKeyNotAllowed               = "Action is not allowed with API key"
+	NoAPIKeyFoundByID              = "No API key found by id"
+	WrongCredentials               = "Wrong username or password"
+	UsernameAlreadyTaken           = "Username is already taken"
+	IdentityIsAlreadyAccount       = "Current user is already registered, nothing to claim"
+	AdminPrivilegesRequired        = "Admin privileges required"
+	InvalidURL                     = "URL has to be an absolute http(s) url"
+	NoDeleteJobFoundByID           = "No deletion job found by id"
+	UnsupportedExportFormat        = "Export format has to be csv, json or ndjson"
+	NoMigrationInProgress          = "No storage migration in progress"
+	InvalidQRCodeSize              = "QR code size has to be a number of pixels"
+	WrongLinkPassword              = "Wrong link password"
+	TooManyPasswordAttempts        = "Too many password attempts, try again later"
+	UnknownDomain                  = "Domain is not one of configured short domains"
+	NoWorkspaceFoundByID           = "No workspace found by id"
+	NoWorkspaceMemberFoundByID     = "No workspace member found by user id"
+	InsufficientWorkspaceRole      = "Workspace role does not allow this action"
+	LastWorkspaceOwner             = "Workspace has to keep at least one owner"
 )
 
+// Kinds of storage to be chosen as primary or secondary one.
+const (
+	StorageMemory   = "memory"
+	StorageFile     = "file"
+	StorageDatabase = "database"
+)
+
+// DefaultSecretAuthKey insecure secret used when no signing keys are configured.
+const DefaultSecretAuthKey = "super_secret"
+
 // AppSettings struct to handle application settings parsed from environment variables.
 type AppSettings struct {
-	ServerAddress   string `env:"SERVER_ADDRESS"`
-	BaseURL         string `env:"BASE_URL"`
-	FileStoragePath string `env:"FILE_STORAGE_PATH"`
-	SecretAuthKey   string `env:"AUTH_SECRET_KEY"   default:"super_secret"`
-	DatabaseDSN     string `env:"DATABASE_DSN"`
-	IsTestMode      bool   `env:"IS_TEST"           default:"false"`
-	EnableHTTPS     bool   `env:"ENABLE_HTTPS"`
+	ServerAddress       string        `env:"SERVER_ADDRESS"`
+	BaseURL             string        `env:"BASE_URL"`
+	FileStoragePath     string        `env:"FILE_STORAGE_PATH"`
+	SecretAuthKey       string        `env:"AUTH_SECRET_KEY"           envDefault:"super_secret"`
+	AuthKeysFile        string        `env:"AUTH_KEYS_FILE"`
+	AuthKeys            string        `env:"AUTH_KEYS"`
+	CookieLifetime      time.Duration `env:"AUTH_COOKIE_LIFETIME"      envDefault:"24h"`
+	LegacyCookiesFor    time.Duration `env:"AUTH_LEGACY_COOKIES_FOR"   envDefault:"720h"`
+	LegacyCookiesUntil  time.Time
+	AdminUserIDs        []string      `env:"ADMIN_USER_IDS"            envSeparator:","`
+	AdminAPIKey         string        `env:"ADMIN_API_KEY"`
+	DeletedRetention    time.Duration `env:"DELETED_RETENTION"`
+	PurgeInterval       time.Duration `env:"PURGE_INTERVAL"            envDefault:"1h"`
+	DeleteFlushInterval time.Duration `env:"DELETE_FLUSH_INTERVAL"     envDefault:"500ms"`
+	DeleteMaxBatchSize  int           `env:"DELETE_MAX_BATCH_SIZE"     envDefault:"1000"`
+	DatabaseDSN         string        `env:"DATABASE_DSN"`
+	MigrationTimeout    time.Duration `env:"DATABASE_MIGRATION_TIMEOUT" envDefault:"5m"`
+	PrimaryStorage      string        `env:"PRIMARY_STORAGE"`
+	SecondaryStorage    string        `env:"SECONDARY_STORAGE"`
+	ReadFallback        bool          `env:"MIGRATION_READ_FALLBACK"`
+	BackfillChunkSize   int           `env:"BACKFILL_CHUNK_SIZE"       envDefault:"1000"`
+	InterstitialAllowed []string      `env:"INTERSTITIAL_ALLOWED_DOMAINS" envSeparator:","`
+	RedirectType        int           `env:"DEFAULT_REDIRECT_TYPE"     envDefault:"307"`
+	PermanentCacheAge   time.Duration `env:"PERMANENT_REDIRECT_CACHE_AGE" envDefault:"24h"`
+	Domains             []string      `env:"SHORT_DOMAINS"             envSeparator:","`
+	GeoIPDatabase       string        `env:"GEOIP_DATABASE"`
+	PasswordLinkLimit   int           `env:"LINK_PASSWORD_ATTEMPTS_PER_LINK" envDefault:"20"`
+	PasswordIPLimit     int           `env:"LINK_PASSWORD_ATTEMPTS_PER_IP" envDefault:"10"`
+	PasswordLimitWindow time.Duration `env:"LINK_PASSWORD_ATTEMPTS_WINDOW" envDefault:"15m"`
+	IsTestMode          bool          `env:"IS_TEST"                   default:"false"`
+	EnableHTTPS         bool          `env:"ENABLE_HTTPS"`
 }
 
 // Settings singleton with application configuration, initializes in `init()` method.
 var Settings AppSettings
 
+// Args command line arguments left after flags, the first one names command to run instead of server.
+var Args []string
+
 func init() {
 	flagSet := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
 	flagSet.Usage = func() {
@@ -46,10 +104,23 @@ func init() {
 	flagSet.StringVar(&Settings.FileStoragePath, "f", "", "File storage path")
 	flagSet.StringVar(&Settings.DatabaseDSN, "d", "", "Database DSN url")
 	flagSet.BoolVar(&Settings.EnableHTTPS, "s", false, "Enable HTTPs")
+	flagSet.StringVar(&Settings.AuthKeysFile, "k", "", "Auth cookie signing keys file path")
 	flagSet.Parse(os.Args[1:])
+	Args = flagSet.Args()
 
 	err := env.Parse(&Settings)
 	if err != nil {
 		log.Fatal(err)
 	}
+	// Legacy cookies window starts on deploy, not on the date the code was written.
+	Settings.LegacyCookiesUntil = time.Now().Add(Settings.LegacyCookiesFor)
+	requirePositive("PURGE_INTERVAL", Settings.PurgeInterval)
+	requirePositive("DELETE_FLUSH_INTERVAL", Settings.DeleteFlushInterval)
+}
+
+// requirePositive stops application when duration setting is not positive, tickers panic on such intervals.
+func requirePositive(name string, value time.Duration) {
+	if value <= 0 {
+		log.Fatalf("%s has to be positive, got %s", name, value)
+	}
 }
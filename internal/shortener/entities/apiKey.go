@@ -0,0 +1,70 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// API key scopes.
+const (
+	ScopeCreate = "create"
+	ScopeRead   = "read"
+	ScopeDelete = "delete"
+)
+
+// AllScopes lists every scope an API key may be granted.
+var AllScopes = []string{ScopeCreate, ScopeRead, ScopeDelete}
+
+// APIKey entity to store API key in database. Only hash of the key is stored.
+type APIKey struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	KeyHash   string    `json:"key_hash"`
+	UserID    uuid.UUID `json:"user_id"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+	IsActive  bool      `json:"is_active"`
+}
+
+// HasScope checks if API key is granted with scope.
+func (key *APIKey) HasScope(scope string) bool {
+	for _, s := range key.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeyCreateDTO dto for POST request.
+type APIKeyCreateDTO struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// APIKeyResponseDto response dto.
+type APIKeyResponseDto struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+	IsActive  bool      `json:"is_active"`
+}
+
+// APIKeyCreatedResponseDto response dto with raw key, returned only once.
+type APIKeyCreatedResponseDto struct {
+	APIKeyResponseDto
+	Key string `json:"key"`
+}
+
+// ToResponseDto converts APIKey to APIKeyResponseDto.
+func (key *APIKey) ToResponseDto() APIKeyResponseDto {
+	return APIKeyResponseDto{
+		ID:        key.ID,
+		Name:      key.Name,
+		Scopes:    key.Scopes,
+		CreatedAt: key.CreatedAt,
+		IsActive:  key.IsActive,
+	}
+}
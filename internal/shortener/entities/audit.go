@@ -0,0 +1,33 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Admin actions written to audit log.
+const (
+	AuditActionLookup          = "lookup"
+	AuditActionDeactivate      = "deactivate"
+	AuditActionReactivate      = "reactivate"
+	AuditActionReassign        = "reassign_owner"
+	AuditActionListUser        = "list_user_urls"
+	AuditActionListDeadLetters = "list_dead_letters"
+	AuditActionImport          = "import"
+	AuditActionViewMigration   = "view_migration"
+)
+
+// AuditLogEntry record about action made by admin.
+type AuditLogEntry struct {
+	Actor     string    `json:"actor"`
+	Action    string    `json:"action"`
+	Target    string    `json:"target"`
+	Details   string    `json:"details"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AdminOwnerDTO dto for owner reassignment request.
+type AdminOwnerDTO struct {
+	UserID uuid.UUID `json:"user_id"`
+}
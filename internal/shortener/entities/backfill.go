@@ -0,0 +1,59 @@
+package entities
+
+import "time"
+
+// States of backfill copying records to secondary storage.
+const (
+	BackfillStatePending  = "pending"
+	BackfillStateRunning  = "running"
+	BackfillStateFinished = "finished"
+	BackfillStateFailed   = "failed"
+)
+
+// Entities copied by backfill, in order they are copied, so entities are copied after ones they refer to.
+const (
+	BackfillUsers            = "users"
+	BackfillWorkspaces       = "workspaces"
+	BackfillWorkspaceMembers = "workspace_members"
+	BackfillShortURLs        = "short_urls"
+	BackfillTags             = "tags"
+	BackfillHistory          = "history"
+	BackfillClicks           = "clicks"
+	BackfillAPIKeys          = "api_keys"
+	BackfillAuditLog         = "audit_log"
+)
+
+// BackfillProgress progress of copying records from primary storage to secondary one,
+// counters are totals over all entities.
+type BackfillProgress struct {
+	State           string                            `json:"state"`
+	Scanned         int64                             `json:"scanned"`
+	Copied          int64                             `json:"copied"`
+	Skipped         int64                             `json:"skipped"`
+	Entities        map[string]BackfillEntityProgress `json:"entities,omitempty"`
+	SecondaryErrors int64                             `json:"secondary_errors"`
+	StartedAt       *time.Time                        `json:"started_at,omitempty"`
+	FinishedAt      *time.Time                        `json:"finished_at,omitempty"`
+	Error           string                            `json:"error,omitempty"`
+}
+
+// BackfillEntityProgress progress of copying one of entities, records present in secondary storage
+// already are skipped.
+type BackfillEntityProgress struct {
+	Scanned int64 `json:"scanned"`
+	Copied  int64 `json:"copied"`
+	Skipped int64 `json:"skipped"`
+}
+
+// ShortURLTag tag of ShortURL as it is copied between storages.
+type ShortURLTag struct {
+	ShortURLID string `json:"short_url_id"`
+	Tag        string `json:"tag"`
+}
+
+// ClickCount number of clicks of ShortURL variant as it is copied between storages.
+type ClickCount struct {
+	ShortURLID string `json:"short_url_id"`
+	Variant    string `json:"variant"`
+	Clicks     int64  `json:"clicks"`
+}
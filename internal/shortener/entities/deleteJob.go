@@ -0,0 +1,39 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// States of deletion job.
+const (
+	DeleteJobQueued           = "queued"
+	DeleteJobApplied          = "applied"
+	DeleteJobPartiallyApplied = "partially_applied"
+	DeleteJobFailed           = "failed"
+)
+
+// Results of deletion for every requested id.
+const (
+	DeleteResultDeleted  = "deleted"
+	DeleteResultNotFound = "not_found"
+)
+
+// DeleteJob tracks state of deletion requested by user.
+type DeleteJob struct {
+	ID        string            `json:"id"`
+	UserID    uuid.UUID         `json:"user_id"`
+	IDs       []string          `json:"ids"`
+	State     string            `json:"state"`
+	Results   map[string]string `json:"results,omitempty"`
+	Attempts  int               `json:"attempts"`
+	LastError string            `json:"last_error,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// IsFinished reports whether job is not going to change anymore.
+func (job *DeleteJob) IsFinished() bool {
+	return job.State != DeleteJobQueued
+}
@@ -0,0 +1,20 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DestinationChange record about previous destination of ShortURL.
+type DestinationChange struct {
+	ShortURLID string    `json:"-"`
+	Original   string    `json:"original_url"`
+	ChangedBy  uuid.UUID `json:"changed_by"`
+	ChangedAt  time.Time `json:"changed_at"`
+}
+
+// ShortURLUpdateDTO dto for destination update request.
+type ShortURLUpdateDTO struct {
+	URL string `json:"url"`
+}
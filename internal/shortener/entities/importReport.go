@@ -0,0 +1,16 @@
+package entities
+
+// ImportProblem describes row of import which was not accepted.
+type ImportProblem struct {
+	Line   int    `json:"line"`
+	ID     string `json:"id,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// ImportReport result of links import.
+type ImportReport struct {
+	Accepted   int             `json:"accepted"`
+	Duplicates int             `json:"duplicates"`
+	Rejected   int             `json:"rejected"`
+	Problems   []ImportProblem `json:"problems,omitempty"`
+}
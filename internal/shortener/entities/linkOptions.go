@@ -0,0 +1,76 @@
+package entities
+
+import (
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/shortenerrors"
+)
+
+// MaxTitleLength maximal length of ShortURL title, matches short_urls.title column.
+const MaxTitleLength = 255
+
+// redirectTypes statuses allowed for redirect from ShortURL.
+var redirectTypes = map[int]bool{
+	http.StatusMovedPermanently:  true,
+	http.StatusFound:             true,
+	http.StatusSeeOther:          true,
+	http.StatusTemporaryRedirect: true,
+	http.StatusPermanentRedirect: true,
+}
+
+// IsValidRedirectType reports whether status may be used for redirect from ShortURL.
+func IsValidRedirectType(status int) bool {
+	return redirectTypes[status]
+}
+
+// IsPermanentRedirect reports whether redirect with the status may be cached by clients.
+func IsPermanentRedirect(status int) bool {
+	return status == http.StatusMovedPermanently || status == http.StatusPermanentRedirect
+}
+
+// Policies of passing query and path of short url through to destination, query params of short url
+// are dropped if destination has the same ones, replace them or are added to them.
+const (
+	PassthroughKeep     = "keep"
+	PassthroughOverride = "override"
+	PassthroughAppend   = "append"
+)
+
+// LinkOptions options of ShortURL chosen by its owner on creation.
+type LinkOptions struct {
+	Title         string       `json:"title,omitempty"`
+	Interstitial  bool         `json:"interstitial,omitempty"`
+	RedirectType  int          `json:"redirect_type,omitempty"`
+	Passthrough   string       `json:"passthrough,omitempty"`
+	UTM           *UTM         `json:"utm,omitempty"`
+	MaxClicks     int          `json:"max_clicks,omitempty"`
+	Rules         RoutingRules `json:"rules,omitempty"`
+	Variants      Variants     `json:"variants,omitempty"`
+	StickyVariant bool         `json:"sticky_variant,omitempty"`
+	Domain        string       `json:"domain,omitempty"`
+	WorkspaceID   string       `json:"workspace_id,omitempty"`
+}
+
+// Validate checks options given by user.
+func (options *LinkOptions) Validate() error {
+	if utf8.RuneCountInString(options.Title) > MaxTitleLength {
+		return fmt.Errorf("%w: title is longer than %d characters", shortenerrors.ErrInvalidLinkOptions, MaxTitleLength)
+	}
+	if options.RedirectType != 0 && !IsValidRedirectType(options.RedirectType) {
+		return fmt.Errorf("%w: redirect_type has to be one of 301, 302, 303, 307, 308", shortenerrors.ErrInvalidLinkOptions)
+	}
+	switch options.Passthrough {
+	case "", PassthroughKeep, PassthroughOverride, PassthroughAppend:
+	default:
+		return fmt.Errorf("%w: passthrough has to be one of keep, override, append", shortenerrors.ErrInvalidLinkOptions)
+	}
+	if options.MaxClicks < 0 {
+		return fmt.Errorf("%w: max_clicks can not be negative", shortenerrors.ErrInvalidLinkOptions)
+	}
+	if err := options.Rules.Validate(); err != nil {
+		return err
+	}
+	return options.Variants.Validate()
+}
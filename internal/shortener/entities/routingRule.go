@@ -0,0 +1,165 @@
+package entities
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/shortenerrors"
+)
+
+// MaxRoutingRules maximal number of routing rules of ShortURL.
+const MaxRoutingRules = 20
+
+// Platforms of client recognized by User-Agent.
+const (
+	PlatformIOS     = "ios"
+	PlatformAndroid = "android"
+	PlatformWindows = "windows"
+	PlatformMacOS   = "macos"
+	PlatformLinux   = "linux"
+)
+
+// platforms allowed in routing rules.
+var platforms = map[string]bool{
+	PlatformIOS:     true,
+	PlatformAndroid: true,
+	PlatformWindows: true,
+	PlatformMacOS:   true,
+	PlatformLinux:   true,
+}
+
+// RoutingRule sends clients matching all of its non-empty conditions to URL instead of ShortURL destination.
+type RoutingRule struct {
+	Platform  string   `json:"platform,omitempty"`
+	Languages []string `json:"languages,omitempty"`
+	Countries []string `json:"countries,omitempty"`
+	URL       string   `json:"url"`
+}
+
+// Client traits routing rules are matched against.
+type Client struct {
+	Platform string
+	Language string
+	Country  string
+}
+
+// Matches reports whether client satisfies every condition of the rule, language "en" matches "en-US" as well.
+func (rule *RoutingRule) Matches(client Client) bool {
+	if rule.Platform != "" && rule.Platform != client.Platform {
+		return false
+	}
+	if len(rule.Languages) > 0 && !containsFold(rule.Languages, client.Language) {
+		primary, _, _ := strings.Cut(client.Language, "-")
+		if !containsFold(rule.Languages, primary) {
+			return false
+		}
+	}
+	return len(rule.Countries) == 0 || containsFold(rule.Countries, client.Country)
+}
+
+// containsFold reports whether values contain value ignoring case, empty value is never contained.
+func containsFold(values []string, value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, item := range values {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// RoutingRules rules of ShortURL evaluated in order, destination of the first matching one is used.
+type RoutingRules []RoutingRule
+
+// Destination returns URL of the first rule client matches, false if there is none.
+func (rules RoutingRules) Destination(client Client) (string, bool) {
+	for i := range rules {
+		if rules[i].Matches(client) {
+			return rules[i].URL, true
+		}
+	}
+	return "", false
+}
+
+// NeedCountry reports whether any rule depends on country of client.
+func (rules RoutingRules) NeedCountry() bool {
+	for i := range rules {
+		if len(rules[i].Countries) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks rules given by user, destinations are checked by caller.
+func (rules RoutingRules) Validate() error {
+	if len(rules) > MaxRoutingRules {
+		return fmt.Errorf("%w: there can be up to %d rules", shortenerrors.ErrInvalidLinkOptions, MaxRoutingRules)
+	}
+	for i, rule := range rules {
+		if rule.Platform == "" && len(rule.Languages) == 0 && len(rule.Countries) == 0 {
+			return fmt.Errorf("%w: rule %d has no conditions", shortenerrors.ErrInvalidLinkOptions, i)
+		}
+		if rule.Platform != "" && !platforms[rule.Platform] {
+			return fmt.Errorf(
+				"%w: rule %d platform has to be one of ios, android, windows, macos, linux",
+				shortenerrors.ErrInvalidLinkOptions, i,
+			)
+		}
+		for _, language := range rule.Languages {
+			if language == "" || len(language) > 35 {
+				return fmt.Errorf("%w: rule %d has invalid language %q", shortenerrors.ErrInvalidLinkOptions, i, language)
+			}
+		}
+		for _, country := range rule.Countries {
+			if len(country) != 2 {
+				return fmt.Errorf(
+					"%w: rule %d country %q has to be ISO 3166 two-letter code",
+					shortenerrors.ErrInvalidLinkOptions, i, country,
+				)
+			}
+		}
+	}
+	return nil
+}
+
+// Value stores rules in database as json array.
+func (rules RoutingRules) Value() (driver.Value, error) {
+	if len(rules) == 0 {
+		return "[]", nil
+	}
+	value, err := json.Marshal(rules)
+	if err != nil {
+		return nil, err
+	}
+	return string(value), nil
+}
+
+// Scan reads rules stored in database as json array.
+func (rules *RoutingRules) Scan(src any) error {
+	var value []byte
+	switch src := src.(type) {
+	case nil:
+		*rules = nil
+		return nil
+	case []byte:
+		value = src
+	case string:
+		value = []byte(src)
+	default:
+		return fmt.Errorf("unsupported type %T of routing rules", src)
+	}
+	var scanned RoutingRules
+	if err := json.Unmarshal(value, &scanned); err != nil {
+		return err
+	}
+	if len(scanned) == 0 {
+		scanned = nil
+	}
+	*rules = scanned
+	return nil
+}
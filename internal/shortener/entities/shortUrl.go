@@ -2,23 +2,102 @@
 package entities
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/shortenerrors"
 	"github.com/google/uuid"
 )
 
+// MaxPasswordLength maximal length of ShortURL password in bytes, longer ones are not supported by bcrypt.
+const MaxPasswordLength = 72
+
 // ShortURL main DTO to store entity in database.
 type ShortURL struct {
-	ID            string    `json:"id"`
-	Short         string    `json:"short_url"`
-	Original      string    `json:"original_url"`
-	CorrelationID string    `json:"correlation_id"`
-	UserID        uuid.UUID `json:"user_id"`
-	IsActive      bool      `json:"is_active"`
+	ID            string     `json:"id"`
+	Short         string     `json:"short_url"`
+	Original      string     `json:"original_url"`
+	CorrelationID string     `json:"correlation_id"`
+	UserID        uuid.UUID  `json:"user_id"`
+	IsActive      bool       `json:"is_active"`
+	DeletedAt     *time.Time `json:"deleted_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	PasswordHash  string     `json:"-"`
+	ClicksLeft    int        `json:"clicks_left,omitempty"`
+	LinkOptions
+}
+
+// StoredShortURL ShortURL as it is saved by file storage and backups, unlike ShortURL it keeps password hash,
+// so it must never be sent to clients.
+type StoredShortURL struct {
+	ShortURL
+	PasswordHash string `json:"password_hash,omitempty"`
+}
+
+// ToStored converts ShortURL to StoredShortURL.
+func (item *ShortURL) ToStored() StoredShortURL {
+	return StoredShortURL{ShortURL: *item, PasswordHash: item.PasswordHash}
+}
+
+// ToShortURL converts StoredShortURL back to ShortURL.
+func (stored *StoredShortURL) ToShortURL() ShortURL {
+	item := stored.ShortURL
+	item.PasswordHash = stored.PasswordHash
+	return item
+}
+
+// ShortURLAdminResponseDto response dto for admins, password of protected ShortURL is never revealed.
+type ShortURLAdminResponseDto struct {
+	ID            string     `json:"id"`
+	Short         string     `json:"short_url"`
+	Original      string     `json:"original_url"`
+	CorrelationID string     `json:"correlation_id"`
+	UserID        uuid.UUID  `json:"user_id"`
+	IsActive      bool       `json:"is_active"`
+	DeletedAt     *time.Time `json:"deleted_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	IsProtected   bool       `json:"is_protected"`
+	ClicksLeft    int        `json:"clicks_left,omitempty"`
+	LinkOptions
 }
 
 // ShortURLResponseDto response dto.
 type ShortURLResponseDto struct {
-	Short    string `json:"short_url"`
-	Original string `json:"original_url"`
+	Short    string   `json:"short_url"`
+	Original string   `json:"original_url"`
+	UTM      *UTM     `json:"utm,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+}
+
+// DomainsResponseDTO response dto with domains short urls may be created on.
+type DomainsResponseDTO struct {
+	Domains []string `json:"domains"`
+}
+
+// ShortURLDeletedResponseDto response dto for deleted ShortURL.
+type ShortURLDeletedResponseDto struct {
+	ID        string    `json:"id"`
+	Short     string    `json:"short_url"`
+	Original  string    `json:"original_url"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// Statuses of ShortURL in export.
+const (
+	ShortURLStatusActive   = "active"
+	ShortURLStatusDeleted  = "deleted"
+	ShortURLStatusInactive = "inactive"
+)
+
+// ShortURLExportDto dto for export of user's ShortURLs.
+type ShortURLExportDto struct {
+	ID        string     `json:"id"`
+	Short     string     `json:"short_url"`
+	Original  string     `json:"original_url"`
+	Status    string     `json:"status"`
+	CreatedAt time.Time  `json:"created_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 // ShortURLResponseWithCorrelationDto response dto with correlation.
@@ -31,6 +110,8 @@ type ShortURLResponseWithCorrelationDto struct {
 type ShortURLWithCorrelationCreateDto struct {
 	CorrelationID string `json:"correlation_id"`
 	Original      string `json:"original_url"`
+	Password      string `json:"password,omitempty"`
+	LinkOptions
 }
 
 // ToResponseDto converts ShortURL to ShortURLResponseDto
@@ -38,7 +119,97 @@ func (item *ShortURL) ToResponseDto() ShortURLResponseDto {
 	return ShortURLResponseDto{
 		Short:    item.Short,
 		Original: item.Original,
+		UTM:      item.UTM,
+	}
+}
+
+// ToAdminResponseDto converts ShortURL to ShortURLAdminResponseDto
+func (item *ShortURL) ToAdminResponseDto() ShortURLAdminResponseDto {
+	return ShortURLAdminResponseDto{
+		ID:            item.ID,
+		Short:         item.Short,
+		Original:      item.Original,
+		CorrelationID: item.CorrelationID,
+		UserID:        item.UserID,
+		IsActive:      item.IsActive,
+		DeletedAt:     item.DeletedAt,
+		CreatedAt:     item.CreatedAt,
+		IsProtected:   item.IsProtected(),
+		ClicksLeft:    item.ClicksLeft,
+		LinkOptions:   item.LinkOptions,
+	}
+}
+
+// ToDeletedResponseDto converts ShortURL to ShortURLDeletedResponseDto
+func (item *ShortURL) ToDeletedResponseDto() ShortURLDeletedResponseDto {
+	dto := ShortURLDeletedResponseDto{
+		ID:       item.ID,
+		Short:    item.Short,
+		Original: item.Original,
+	}
+	if item.DeletedAt != nil {
+		dto.DeletedAt = *item.DeletedAt
+	}
+	return dto
+}
+
+// ToExportDto converts ShortURL to ShortURLExportDto
+func (item *ShortURL) ToExportDto() ShortURLExportDto {
+	status := ShortURLStatusActive
+	switch {
+	case item.IsDeleted():
+		status = ShortURLStatusDeleted
+	case !item.IsActive:
+		status = ShortURLStatusInactive
+	}
+	return ShortURLExportDto{
+		ID:        item.ID,
+		Short:     item.Short,
+		Original:  item.Original,
+		Status:    status,
+		CreatedAt: item.CreatedAt,
+		DeletedAt: item.DeletedAt,
+	}
+}
+
+// IsProtected reports whether ShortURL redirects only after its password is given.
+func (item *ShortURL) IsProtected() bool {
+	return item.PasswordHash != ""
+}
+
+// IsExhausted reports whether ShortURL created with max clicks has been followed that many times.
+func (item *ShortURL) IsExhausted() bool {
+	return item.MaxClicks > 0 && item.ClicksLeft <= 0
+}
+
+// ValidatePassword checks password given by user for ShortURL, empty one leaves it unprotected.
+func ValidatePassword(password string) error {
+	if len(password) > MaxPasswordLength {
+		return fmt.Errorf("%w: password is longer than %d bytes", shortenerrors.ErrInvalidLinkOptions, MaxPasswordLength)
+	}
+	return nil
+}
+
+// ShortURLKey returns id ShortURL with slug on domain is stored by, links on default domain are stored by slug,
+// ones on custom domain get the domain appended after "@" so the same slug may be used on every domain.
+func ShortURLKey(domain, slug string) string {
+	if domain == "" {
+		return slug
+	}
+	return slug + "@" + domain
+}
+
+// Slug returns path ShortURL is reached by on its domain.
+func (item *ShortURL) Slug() string {
+	if item.Domain == "" {
+		return item.ID
 	}
+	return strings.TrimSuffix(item.ID, "@"+item.Domain)
+}
+
+// IsDeleted reports whether ShortURL has been deleted by its owner and may be restored.
+func (item *ShortURL) IsDeleted() bool {
+	return !item.IsActive && item.DeletedAt != nil
 }
 
 // ToResponseWithCorrelationDto converts ShortURL to ShortURLResponseWithCorrelationDto
@@ -51,7 +222,9 @@ func (item *ShortURL) ToResponseWithCorrelationDto() ShortURLResponseWithCorrela
 
 // ShortenerSimpleCreateDTO simple create dto.
 type ShortenerSimpleCreateDTO struct {
-	URL string `json:"url"`
+	URL      string `json:"url"`
+	Password string `json:"password,omitempty"`
+	LinkOptions
 }
 
 // ShortenerSimpleResponseDTO simple response dto.
@@ -61,6 +234,9 @@ type ShortenerSimpleResponseDTO struct {
 
 // ItemToDelete delete dto.
 type ItemToDelete struct {
-	UserID   uuid.UUID
-	ItemsIDs []string
+	JobID         string
+	UserID        uuid.UUID
+	ItemsIDs      []string
+	Attempts      int
+	NextAttemptAt time.Time
 }
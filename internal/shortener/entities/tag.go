@@ -0,0 +1,46 @@
+package entities
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/shortenerrors"
+)
+
+// Limits of tags grouping ShortURLs.
+const (
+	// MaxTagLength maximal length of tag, matches tags.name column.
+	MaxTagLength = 64
+	// MaxTagsPerRequest maximal number of tags added or removed at once.
+	MaxTagsPerRequest = 20
+)
+
+// NormalizeTags checks tags given by user, returns them trimmed, lower-cased, deduplicated and sorted,
+// so "Promo" and " promo" are the same tag.
+func NormalizeTags(tags []string) ([]string, error) {
+	if len(tags) == 0 || len(tags) > MaxTagsPerRequest {
+		return nil, fmt.Errorf("%w: there have to be from 1 to %d tags", shortenerrors.ErrInvalidLinkOptions, MaxTagsPerRequest)
+	}
+	unique := make(map[string]bool, len(tags))
+	result := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || utf8.RuneCountInString(tag) > MaxTagLength {
+			return nil, fmt.Errorf("%w: tag has to be from 1 to %d characters", shortenerrors.ErrInvalidLinkOptions, MaxTagLength)
+		}
+		if !unique[tag] {
+			unique[tag] = true
+			result = append(result, tag)
+		}
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// TagsUpdateDTO dto for request adding tags to ShortURLs or removing them.
+type TagsUpdateDTO struct {
+	IDs  []string `json:"ids"`
+	Tags []string `json:"tags"`
+}
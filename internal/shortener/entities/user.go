@@ -0,0 +1,37 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// User registered account, anonymous users exist only as ids in auth cookie.
+type User struct {
+	ID           uuid.UUID `json:"id"`
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"password_hash"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// UserCredentialsDTO dto for register, login and claim requests.
+type UserCredentialsDTO struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// UserResponseDto response dto.
+type UserResponseDto struct {
+	ID       uuid.UUID `json:"id"`
+	Username string    `json:"username"`
+	Claimed  int64     `json:"claimed_urls"`
+}
+
+// ToResponseDto converts User to UserResponseDto.
+func (user *User) ToResponseDto(claimed int64) UserResponseDto {
+	return UserResponseDto{
+		ID:       user.ID,
+		Username: user.Username,
+		Claimed:  claimed,
+	}
+}
@@ -0,0 +1,37 @@
+package entities
+
+import "net/url"
+
+// UTM campaign params merged into destination of ShortURL on creation.
+type UTM struct {
+	Source   string `json:"source,omitempty"`
+	Medium   string `json:"medium,omitempty"`
+	Campaign string `json:"campaign,omitempty"`
+	Term     string `json:"term,omitempty"`
+	Content  string `json:"content,omitempty"`
+}
+
+// IsEmpty reports whether no param is set.
+func (utm *UTM) IsEmpty() bool {
+	return utm == nil || *utm == UTM{}
+}
+
+// Params returns query params of the set fields.
+func (utm *UTM) Params() url.Values {
+	params := url.Values{}
+	if utm == nil {
+		return params
+	}
+	for key, value := range map[string]string{
+		"utm_source":   utm.Source,
+		"utm_medium":   utm.Medium,
+		"utm_campaign": utm.Campaign,
+		"utm_term":     utm.Term,
+		"utm_content":  utm.Content,
+	} {
+		if value != "" {
+			params.Set(key, value)
+		}
+	}
+	return params
+}
@@ -0,0 +1,175 @@
+package entities
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/shortenerrors"
+)
+
+// Limits of A/B split destinations of ShortURL.
+const (
+	MaxVariants        = 10
+	MaxVariantWeight   = 1000
+	MaxVariantIDLength = 32
+)
+
+// defaultVariantIDs ids given to variants by their position.
+const defaultVariantIDs = "abcdefghij"
+
+// Variant one of destinations of ShortURL split between visitors in proportion to weights.
+type Variant struct {
+	ID     string `json:"id"`
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+}
+
+// Variants destinations of ShortURL, one of them is picked by weighted random on every redirect.
+type Variants []Variant
+
+// SetDefaultIDs names variants without id by their position, "a" for the first one, "b" for the second and so on.
+func (variants Variants) SetDefaultIDs() {
+	for i := range variants {
+		if variants[i].ID == "" && i < len(defaultVariantIDs) {
+			variants[i].ID = defaultVariantIDs[i : i+1]
+		}
+	}
+}
+
+// Validate checks variants given by user, destinations are checked by caller.
+func (variants Variants) Validate() error {
+	if len(variants) == 0 {
+		return nil
+	}
+	if len(variants) < 2 || len(variants) > MaxVariants {
+		return fmt.Errorf("%w: there have to be from 2 to %d variants", shortenerrors.ErrInvalidLinkOptions, MaxVariants)
+	}
+	ids := make(map[string]bool, len(variants))
+	for _, variant := range variants {
+		if !isValidVariantID(variant.ID) {
+			return fmt.Errorf(
+				"%w: variant id has to be from 1 to %d letters, digits, '-' or '_'",
+				shortenerrors.ErrInvalidLinkOptions, MaxVariantIDLength,
+			)
+		}
+		if ids[variant.ID] {
+			return fmt.Errorf("%w: variant id %q is not unique", shortenerrors.ErrInvalidLinkOptions, variant.ID)
+		}
+		ids[variant.ID] = true
+		if variant.Weight < 1 || variant.Weight > MaxVariantWeight {
+			return fmt.Errorf(
+				"%w: weight of variant %q has to be from 1 to %d",
+				shortenerrors.ErrInvalidLinkOptions, variant.ID, MaxVariantWeight,
+			)
+		}
+	}
+	return nil
+}
+
+// isValidVariantID reports whether id may be used as variant id, it is kept in cookie of visitor.
+func isValidVariantID(id string) bool {
+	if id == "" || len(id) > MaxVariantIDLength {
+		return false
+	}
+	for _, char := range id {
+		isLetter := (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z')
+		if !isLetter && (char < '0' || char > '9') && char != '-' && char != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// TotalWeight returns sum of weights of all variants.
+func (variants Variants) TotalWeight() int {
+	total := 0
+	for _, variant := range variants {
+		total += variant.Weight
+	}
+	return total
+}
+
+// Pick returns variant the point falls into, point is taken from [0, TotalWeight()).
+func (variants Variants) Pick(point int) Variant {
+	for _, variant := range variants {
+		if point < variant.Weight {
+			return variant
+		}
+		point -= variant.Weight
+	}
+	return variants[len(variants)-1]
+}
+
+// Find returns variant by its id.
+func (variants Variants) Find(id string) (Variant, bool) {
+	for _, variant := range variants {
+		if variant.ID == id {
+			return variant, true
+		}
+	}
+	return Variant{}, false
+}
+
+// Value stores variants in database as json array.
+func (variants Variants) Value() (driver.Value, error) {
+	if len(variants) == 0 {
+		return "[]", nil
+	}
+	value, err := json.Marshal(variants)
+	if err != nil {
+		return nil, err
+	}
+	return string(value), nil
+}
+
+// Scan reads variants stored in database as json array.
+func (variants *Variants) Scan(src any) error {
+	var value []byte
+	switch src := src.(type) {
+	case nil:
+		*variants = nil
+		return nil
+	case []byte:
+		value = src
+	case string:
+		value = []byte(src)
+	default:
+		return fmt.Errorf("unsupported type %T of variants", src)
+	}
+	var scanned Variants
+	if err := json.Unmarshal(value, &scanned); err != nil {
+		return err
+	}
+	if len(scanned) == 0 {
+		scanned = nil
+	}
+	*variants = scanned
+	return nil
+}
+
+// VariantsUpdateDTO dto for request replacing variants of ShortURL, empty list turns split off.
+type VariantsUpdateDTO struct {
+	Variants      Variants `json:"variants"`
+	StickyVariant bool     `json:"sticky_variant"`
+}
+
+// Click record about redirect from ShortURL, Variant is id of destination served to split link visitor.
+type Click struct {
+	ShortURLID string    `json:"short_url_id"`
+	Variant    string    `json:"variant,omitempty"`
+	ClickedAt  time.Time `json:"clicked_at"`
+}
+
+// VariantStats variant of ShortURL with number of times it was served.
+type VariantStats struct {
+	Variant
+	Clicks int64 `json:"clicks"`
+}
+
+// VariantsResponseDTO variants of ShortURL with their clicks.
+type VariantsResponseDTO struct {
+	StickyVariant bool           `json:"sticky_variant"`
+	Variants      []VariantStats `json:"variants"`
+}
@@ -0,0 +1,75 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxWorkspaceNameLength maximal length of workspace name, matches workspaces.name column.
+const MaxWorkspaceNameLength = 255
+
+// Roles of workspace members.
+const (
+	// RoleOwner manages members and links of workspace.
+	RoleOwner = "owner"
+	// RoleEditor creates and deletes links of workspace.
+	RoleEditor = "editor"
+	// RoleViewer lists links of workspace.
+	RoleViewer = "viewer"
+)
+
+// IsValidRole reports whether role may be given to workspace member.
+func IsValidRole(role string) bool {
+	return role == RoleOwner || role == RoleEditor || role == RoleViewer
+}
+
+// Workspace links shared by its members, ShortURL with WorkspaceID belongs to workspace rather than to its creator.
+type Workspace struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedBy uuid.UUID `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WorkspaceMember user with role in workspace.
+type WorkspaceMember struct {
+	WorkspaceID string    `json:"workspace_id"`
+	UserID      uuid.UUID `json:"user_id"`
+	Role        string    `json:"role"`
+	AddedAt     time.Time `json:"added_at"`
+}
+
+// CanEdit reports whether member may create and delete links of workspace.
+func (member *WorkspaceMember) CanEdit() bool {
+	return member.Role == RoleOwner || member.Role == RoleEditor
+}
+
+// roleRanks roles of workspace members by rights they give, the higher the more.
+var roleRanks = map[string]int{RoleViewer: 1, RoleEditor: 2, RoleOwner: 3}
+
+// Outranks reports whether role of member gives more rights than role of other.
+func (member *WorkspaceMember) Outranks(other WorkspaceMember) bool {
+	return roleRanks[member.Role] > roleRanks[other.Role]
+}
+
+// CanManage reports whether member may change members of workspace.
+func (member *WorkspaceMember) CanManage() bool {
+	return member.Role == RoleOwner
+}
+
+// WorkspaceMembership workspace with role of user in it.
+type WorkspaceMembership struct {
+	Workspace
+	Role string `json:"role"`
+}
+
+// WorkspaceCreateDTO dto for POST request.
+type WorkspaceCreateDTO struct {
+	Name string `json:"name"`
+}
+
+// WorkspaceMemberDTO dto for request inviting member or changing their role.
+type WorkspaceMemberDTO struct {
+	Role string `json:"role"`
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/config"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/middlewares"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/shortenerrors"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Credentials restrictions.
+const (
+	minUsernameLength = 3
+	maxUsernameLength = 150
+	minPasswordLength = 8
+	maxPasswordLength = 72
+)
+
+// RegisterHandler creates account and claims links of current anonymous user.
+func (h *Shortener) RegisterHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	credentials, doneWithError := h.readCredentials(w, r)
+	if doneWithError {
+		return
+	}
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(credentials.Password), bcrypt.DefaultCost)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	currentUserID := r.Context().Value(middlewares.UserIDKey).(uuid.UUID)
+	_, isAccount, err := h.Repo.GetUserByID(r.Context(), currentUserID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	user := entities.User{
+		ID:           uuid.New(),
+		Username:     credentials.Username,
+		PasswordHash: string(passwordHash),
+		CreatedAt:    time.Now().UTC(),
+	}
+	var claimed int64
+	if isAccount {
+		user, err = h.Repo.CreateUser(r.Context(), user)
+	} else {
+		// Account is created together with links it claims, so failed claim leaves username free.
+		user, claimed, err = h.Repo.CreateUserWithRecords(r.Context(), user, currentUserID)
+	}
+	if errors.Is(err, shortenerrors.ErrItemAlreadyExists) {
+		http.Error(w, config.UsernameAlreadyTaken, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.respondWithUser(w, user, claimed, http.StatusCreated)
+}
+
+// LoginHandler switches current user to account.
+func (h *Shortener) LoginHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	credentials, doneWithError := h.readCredentials(w, r)
+	if doneWithError {
+		return
+	}
+	user, ok, err := h.authenticate(r.Context(), credentials)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, config.WrongCredentials, http.StatusUnauthorized)
+		return
+	}
+
+	h.respondWithUser(w, user, 0, http.StatusOK)
+}
+
+// ClaimHandler reassigns all links of current anonymous user to account and logs in.
+func (h *Shortener) ClaimHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	credentials, doneWithError := h.readCredentials(w, r)
+	if doneWithError {
+		return
+	}
+	user, ok, err := h.authenticate(r.Context(), credentials)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, config.WrongCredentials, http.StatusUnauthorized)
+		return
+	}
+
+	currentUserID := r.Context().Value(middlewares.UserIDKey).(uuid.UUID)
+	var claimed int64
+	if currentUserID != user.ID {
+		_, isAccount, errGet := h.Repo.GetUserByID(r.Context(), currentUserID)
+		if errGet != nil {
+			http.Error(w, errGet.Error(), http.StatusInternalServerError)
+			return
+		}
+		if isAccount {
+			http.Error(w, config.IdentityIsAlreadyAccount, http.StatusConflict)
+			return
+		}
+		claimed, err = h.Repo.ReassignRecords(r.Context(), currentUserID, user.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	h.respondWithUser(w, user, claimed, http.StatusOK)
+}
+
+func (h *Shortener) readCredentials(
+	w http.ResponseWriter,
+	r *http.Request,
+) (credentials entities.UserCredentialsDTO, doneWithError bool) {
+	if _, ok := middlewares.APIKeyFromContext(r.Context()); ok {
+		http.Error(w, config.APIKeyNotAllowed, http.StatusForbidden)
+		return credentials, true
+	}
+	requestBody, doneWithError := h.readBody(w, r)
+	if doneWithError {
+		return credentials, true
+	}
+	err := json.Unmarshal(requestBody, &credentials)
+	if err != nil ||
+		len(credentials.Username) < minUsernameLength || len(credentials.Username) > maxUsernameLength ||
+		len(credentials.Password) < minPasswordLength || len(credentials.Password) > maxPasswordLength {
+		http.Error(w, config.BadInputData, http.StatusUnprocessableEntity)
+		return credentials, true
+	}
+	return credentials, false
+}
+
+func (h *Shortener) authenticate(
+	ctx context.Context,
+	credentials entities.UserCredentialsDTO,
+) (entities.User, bool, error) {
+	user, exist, err := h.Repo.GetUserByUsername(ctx, credentials.Username)
+	if err != nil || !exist {
+		return entities.User{}, false, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(credentials.Password)) != nil {
+		return entities.User{}, false, nil
+	}
+	return user, true, nil
+}
+
+func (h *Shortener) respondWithUser(w http.ResponseWriter, user entities.User, claimed int64, statusCode int) {
+	if err := middlewares.SetUserCookie(w, user.ID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	jsonResponse, err := json.Marshal(user.ToResponseDto(claimed))
+	if err != nil {
+		http.Error(w, config.UnknownError, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(jsonResponse)
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/middlewares"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/repositories"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAccountsClaim(t *testing.T) {
+	repo := &repositories.InMemoryRepository{Storage: make(map[string]entities.ShortURL)}
+	h := NewShortener(repo)
+
+	send := func(url, body string, userID uuid.UUID) (*http.Response, uuid.UUID) {
+		request := httptest.NewRequest(http.MethodPost, url, strings.NewReader(body))
+		request.AddCookie(&http.Cookie{
+			Name:  middlewares.CookieName,
+			Value: middlewares.GenerateCookieStringForUserID(userID),
+		})
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, request)
+		res := w.Result()
+		defer res.Body.Close()
+		_, _ = io.ReadAll(res.Body)
+
+		var cookies []*http.Cookie
+		for _, c := range res.Cookies() {
+			if c.Name == middlewares.CookieName {
+				cookies = append(cookies, c)
+			}
+		}
+		if len(cookies) == 0 {
+			return res, userID
+		}
+		require.Len(t, cookies, 1)
+		received := httptest.NewRequest(http.MethodGet, "/", nil)
+		received.AddCookie(cookies[0])
+		currentUserID, _ := middlewares.GetUserIDFromCookie(received)
+		return res, currentUserID
+	}
+	countLinks := func(userID uuid.UUID) int {
+		records, _ := repo.GetByUserID(context.Background(), userID)
+		return len(records)
+	}
+	credentials := `{"username": "alice", "password": "correct horse"}`
+
+	firstAnonymous, secondAnonymous := uuid.New(), uuid.New()
+	send("/api/shorten", `{"url": "https://mail.ru"}`, firstAnonymous)
+	send("/api/shorten", `{"url": "https://ya.ru"}`, secondAnonymous)
+
+	res, accountID := send("/api/user/register", credentials, firstAnonymous)
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+	assert.NotEqual(t, firstAnonymous, accountID)
+	assert.Equal(t, 1, countLinks(accountID))
+	assert.Equal(t, 0, countLinks(firstAnonymous))
+
+	res, _ = send("/api/user/register", credentials, uuid.New())
+	assert.Equal(t, http.StatusConflict, res.StatusCode)
+
+	res, _ = send("/api/user/login", `{"username": "alice", "password": "wrong password"}`, secondAnonymous)
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	res, currentUserID := send("/api/user/claim", credentials, secondAnonymous)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, accountID, currentUserID)
+	assert.Equal(t, 2, countLinks(accountID))
+	assert.Equal(t, 0, countLinks(secondAnonymous))
+
+	bob, err := repo.CreateUser(context.Background(), entities.User{ID: uuid.New(), Username: "bob"})
+	require.NoError(t, err)
+	res, _ = send("/api/user/claim", credentials, bob.ID)
+	assert.Equal(t, http.StatusConflict, res.StatusCode)
+
+	res, currentUserID = send("/api/user/login", credentials, uuid.New())
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, accountID, currentUserID)
+}
+
+func TestDatabaseClaimIsAtomic(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	accountID, anonymousID := uuid.New(), uuid.New()
+	passwordHash, _ := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+
+	mock.ExpectQuery("SELECT id, username, password_hash, created_at FROM users WHERE username").
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "username", "password_hash", "created_at"}).
+				AddRow(accountID.String(), "alice", string(passwordHash), time.Now()),
+		)
+	mock.ExpectQuery("SELECT id, username, password_hash, created_at FROM users WHERE id").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "password_hash", "created_at"}))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE short_urls SET user_id").
+		WithArgs(accountID.String(), anonymousID.String()).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("UPDATE api_keys SET user_id").
+		WithArgs(accountID.String(), anonymousID.String()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO workspace_members \(workspace_id, user_id, role, added_at\) SELECT .* ON CONFLICT`).
+		WithArgs(accountID.String(), anonymousID.String()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM workspace_members WHERE user_id = \$1`).
+		WithArgs(anonymousID.String()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	request := httptest.NewRequest(
+		http.MethodPost,
+		"/api/user/claim",
+		strings.NewReader(`{"username": "alice", "password": "correct horse"}`),
+	)
+	request.AddCookie(&http.Cookie{
+		Name:  middlewares.CookieName,
+		Value: middlewares.GenerateCookieStringForUserID(anonymousID),
+	})
+	w := httptest.NewRecorder()
+	NewShortener(&repositories.DatabaseRepository{Storage: db}).ServeHTTP(w, request)
+	res := w.Result()
+	defer res.Body.Close()
+
+	var response entities.UserResponseDto
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&response))
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, int64(3), response.Claimed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDatabaseRegisterIsAtomic(t *testing.T) {
+	anonymousID := uuid.New()
+	userColumns := []string{"id", "username", "password_hash", "created_at"}
+	expectAnonymous := func(mock sqlmock.Sqlmock) {
+		mock.ExpectQuery("SELECT id, username, password_hash, created_at FROM users WHERE id").
+			WithArgs(anonymousID.String()).
+			WillReturnRows(sqlmock.NewRows(userColumns))
+		mock.ExpectBegin()
+		mock.ExpectExec(`INSERT INTO users \(id, username, password_hash, created_at\)`).
+			WithArgs(sqlmock.AnyArg(), "alice", sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	tests := []struct {
+		name       string
+		expect     func(sqlmock.Sqlmock)
+		statusCode int
+		claimed    int64
+	}{
+		{
+			name: "user is created with claimed links",
+			expect: func(mock sqlmock.Sqlmock) {
+				expectAnonymous(mock)
+				mock.ExpectExec("UPDATE short_urls SET user_id").
+					WithArgs(sqlmock.AnyArg(), anonymousID.String()).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectExec("UPDATE api_keys SET user_id").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("INSERT INTO workspace_members").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("DELETE FROM workspace_members").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+			},
+			statusCode: http.StatusCreated,
+			claimed:    2,
+		},
+		{
+			name: "user is not created when claim fails",
+			expect: func(mock sqlmock.Sqlmock) {
+				expectAnonymous(mock)
+				mock.ExpectExec("UPDATE short_urls SET user_id").WillReturnError(sql.ErrConnDone)
+				mock.ExpectRollback()
+			},
+			statusCode: http.StatusInternalServerError,
+		},
+		{
+			name: "current user is not looked up",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT id, username, password_hash, created_at FROM users WHERE id").
+					WillReturnError(sql.ErrConnDone)
+			},
+			statusCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+			tt.expect(mock)
+
+			h := NewShortener(&repositories.DatabaseRepository{Storage: db})
+			w := sendAs(h, anonymousID, http.MethodPost, "/api/user/register", `{"username": "alice", "password": "correct horse"}`)
+			require.Equal(t, tt.statusCode, w.Code)
+			if tt.statusCode == http.StatusCreated {
+				var response entities.UserResponseDto
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Equal(t, tt.claimed, response.Claimed)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
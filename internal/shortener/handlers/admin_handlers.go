@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/config"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/importer"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/middlewares"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/shortenerrors"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/utils"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// defaultAuditLogLimit number of audit log entries returned if limit is not set.
+const defaultAuditLogLimit = 100
+
+// AdminGetURLHandler returns any ShortURL by its id.
+func (h *Shortener) AdminGetURLHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	urlID := chi.URLParam(r, "id")
+	if !h.audit(w, r, entities.AuditActionLookup, urlID, "by id") {
+		return
+	}
+	urlItem, exist, err := h.Repo.GetByID(r.Context(), urlID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !exist {
+		http.Error(w, config.NoURLFoundByID, http.StatusNotFound)
+		return
+	}
+	respondWithJSON(w, urlItem.ToAdminResponseDto(), http.StatusOK)
+}
+
+// AdminFindURLHandler returns any ShortURL by its original url passed as `original_url` query param,
+// link on custom domain or in workspace is found with `domain` or `workspace_id` query param as well.
+func (h *Shortener) AdminFindURLHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	query := r.URL.Query()
+	original := query.Get("original_url")
+	if original == "" {
+		http.Error(w, config.BadInputData, http.StatusUnprocessableEntity)
+		return
+	}
+	domain, ok := utils.NormalizeDomain(query.Get("domain"))
+	if !ok {
+		http.Error(w, config.UnknownDomain, http.StatusUnprocessableEntity)
+		return
+	}
+	if !h.audit(w, r, entities.AuditActionLookup, original, "by original url") {
+		return
+	}
+	urlItem, exist, err := h.Repo.GetByOriginal(r.Context(), domain, query.Get("workspace_id"), original)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !exist {
+		http.Error(w, config.NoURLFoundByID, http.StatusNotFound)
+		return
+	}
+	respondWithJSON(w, urlItem.ToAdminResponseDto(), http.StatusOK)
+}
+
+// AdminDeactivateURLHandler deactivates any ShortURL.
+func (h *Shortener) AdminDeactivateURLHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	h.setActive(w, r, false)
+}
+
+// AdminReactivateURLHandler reactivates any ShortURL.
+func (h *Shortener) AdminReactivateURLHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	h.setActive(w, r, true)
+}
+
+// AdminSetOwnerHandler reassigns ShortURL to another user.
+func (h *Shortener) AdminSetOwnerHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	requestBody, doneWithError := h.readBody(w, r)
+	if doneWithError {
+		return
+	}
+	var ownerDTO entities.AdminOwnerDTO
+	if err := json.Unmarshal(requestBody, &ownerDTO); err != nil || ownerDTO.UserID == uuid.Nil {
+		http.Error(w, config.BadInputData, http.StatusUnprocessableEntity)
+		return
+	}
+	urlID := chi.URLParam(r, "id")
+	if !h.audit(w, r, entities.AuditActionReassign, urlID, "new owner "+ownerDTO.UserID.String()) {
+		return
+	}
+	urlItem, err := h.Repo.SetOwner(r.Context(), urlID, ownerDTO.UserID)
+	h.respondWithUpdated(w, urlItem, err)
+}
+
+// AdminGetUserURLsHandler returns all ShortURLs of user including inactive ones.
+func (h *Shortener) AdminGetUserURLsHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	userID, err := uuid.Parse(chi.URLParam(r, "userID"))
+	if err != nil {
+		http.Error(w, config.BadInputData, http.StatusUnprocessableEntity)
+		return
+	}
+	if !h.audit(w, r, entities.AuditActionListUser, userID.String(), "") {
+		return
+	}
+	records, err := h.Repo.GetAllByUserID(r.Context(), userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(records) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	responseDTOs := make([]entities.ShortURLAdminResponseDto, 0, len(records))
+	for _, shortURL := range records {
+		responseDTOs = append(responseDTOs, shortURL.ToAdminResponseDto())
+	}
+	respondWithJSON(w, responseDTOs, http.StatusOK)
+}
+
+// AdminGetAuditLogHandler returns latest audit log entries, amount is set with `limit` query param.
+func (h *Shortener) AdminGetAuditLogHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	limit := defaultAuditLogLimit
+	if rawLimit := r.URL.Query().Get("limit"); rawLimit != "" {
+		parsed, err := strconv.Atoi(rawLimit)
+		if err != nil || parsed <= 0 {
+			http.Error(w, config.BadInputData, http.StatusUnprocessableEntity)
+			return
+		}
+		limit = parsed
+	}
+	entries, err := h.Repo.GetAuditLog(r.Context(), limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondWithJSON(w, entries, http.StatusOK)
+}
+
+// AdminGetDeadLetterDeleteJobsHandler returns deletion jobs given up after all retries.
+func (h *Shortener) AdminGetDeadLetterDeleteJobsHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	if !h.audit(w, r, entities.AuditActionListDeadLetters, "delete-jobs", "") {
+		return
+	}
+	jobs, err := h.Repo.GetDeadLetterDeleteJobs(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondWithJSON(w, jobs, http.StatusOK)
+}
+
+// backfillReporter repository migrating data to another storage.
+type backfillReporter interface {
+	BackfillProgress() entities.BackfillProgress
+}
+
+// AdminGetMigrationHandler returns progress of copying records to secondary storage.
+func (h *Shortener) AdminGetMigrationHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	if !h.audit(w, r, entities.AuditActionViewMigration, "storage", "") {
+		return
+	}
+	reporter, ok := h.Repo.(backfillReporter)
+	if !ok {
+		http.Error(w, config.NoMigrationInProgress, http.StatusNotFound)
+		return
+	}
+	respondWithJSON(w, reporter.BackfillProgress(), http.StatusOK)
+}
+
+// AdminImportHandler imports links from request body in CSV or JSON-lines format and returns report.
+//
+// Format is taken from `format` query param or from Content-Type header,
+// rows without user id are given to user set in `owner` query param.
+func (h *Shortener) AdminImportHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	format, err := importer.ParseFormat(importFormatName(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	var owner uuid.UUID
+	if raw := r.URL.Query().Get("owner"); raw != "" {
+		if owner, err = uuid.Parse(raw); err != nil {
+			http.Error(w, config.BadInputData, http.StatusUnprocessableEntity)
+			return
+		}
+	}
+	if !h.audit(w, r, entities.AuditActionImport, "short_urls", "format "+format) {
+		return
+	}
+	defer r.Body.Close()
+	imp := importer.Importer{Repo: h.Repo, Owner: owner}
+	report, err := imp.Import(r.Context(), r.Body, format)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondWithJSON(w, report, http.StatusOK)
+}
+
+// importFormatName returns name of import format requested.
+func importFormatName(r *http.Request) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		return importer.FormatCSV
+	case "application/x-ndjson", "application/jsonl":
+		return importer.FormatJSONLines
+	}
+	return ""
+}
+
+func (h *Shortener) setActive(w http.ResponseWriter, r *http.Request, isActive bool) {
+	urlID := chi.URLParam(r, "id")
+	action := entities.AuditActionDeactivate
+	if isActive {
+		action = entities.AuditActionReactivate
+	}
+	if !h.audit(w, r, action, urlID, "") {
+		return
+	}
+	urlItem, err := h.Repo.SetActive(r.Context(), urlID, isActive)
+	h.respondWithUpdated(w, urlItem, err)
+}
+
+func (h *Shortener) respondWithUpdated(w http.ResponseWriter, urlItem entities.ShortURL, err error) {
+	if errors.Is(err, shortenerrors.ErrItemNotFound) {
+		http.Error(w, config.NoURLFoundByID, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondWithJSON(w, urlItem.ToAdminResponseDto(), http.StatusOK)
+}
+
+// audit writes admin action to audit log before it is performed, action is refused if log is unavailable.
+func (h *Shortener) audit(w http.ResponseWriter, r *http.Request, action string, target string, details string) bool {
+	actor, _ := r.Context().Value(middlewares.AdminActorKey).(string)
+	err := h.Repo.WriteAuditLog(r.Context(), entities.AuditLogEntry{
+		Actor:     actor,
+		Action:    action,
+		Target:    target,
+		Details:   details,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+func respondWithJSON(w http.ResponseWriter, value any, statusCode int) {
+	jsonResponse, err := json.Marshal(value)
+	if err != nil {
+		http.Error(w, config.UnknownError, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(jsonResponse)
+}
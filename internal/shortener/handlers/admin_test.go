@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/config"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/middlewares"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/repositories"
+	tLoc "github.com/RomanAVolodin/go-url-shortener/internal/shortener/tests"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminHandlers(t *testing.T) {
+	defer func(settings config.AppSettings) { config.Settings = settings }(config.Settings)
+	adminID := uuid.New()
+	newOwnerID := uuid.New()
+	config.Settings.AdminAPIKey = "admin-secret"
+	config.Settings.AdminUserIDs = []string{adminID.String()}
+
+	type wanted struct {
+		code         int
+		responsePart string
+		auditAction  string
+	}
+	tests := []struct {
+		name         string
+		requestURL   string
+		requestType  string
+		requestBody  string
+		userID       uuid.UUID
+		adminKey     string
+		wantedResult wanted
+		check        func(t *testing.T, repo *repositories.InMemoryRepository)
+	}{
+		{
+			name:         "Regular user should not access admin API",
+			requestURL:   "/api/admin/urls/" + tLoc.ShortURLFixture.ID,
+			requestType:  http.MethodGet,
+			userID:       tLoc.UserIDFixture,
+			wantedResult: wanted{code: http.StatusForbidden},
+		},
+		{
+			name:         "Wrong admin key should not be accepted",
+			requestURL:   "/api/admin/urls/" + tLoc.ShortURLFixture.ID,
+			requestType:  http.MethodGet,
+			adminKey:     "wrong",
+			wantedResult: wanted{code: http.StatusForbidden},
+		},
+		{
+			name:        "Admin user should look up link by id",
+			requestURL:  "/api/admin/urls/" + tLoc.ShortURLFixture.ID,
+			requestType: http.MethodGet,
+			userID:      adminID,
+			wantedResult: wanted{
+				code:         http.StatusOK,
+				responsePart: tLoc.ShortURLFixture.Original,
+				auditAction:  entities.AuditActionLookup,
+			},
+		},
+		{
+			name:        "Admin key should look up link by original url",
+			requestURL:  "/api/admin/urls?original_url=" + url.QueryEscape(tLoc.ShortURLFixture.Original),
+			requestType: http.MethodGet,
+			adminKey:    "admin-secret",
+			wantedResult: wanted{
+				code:         http.StatusOK,
+				responsePart: tLoc.ShortURLFixture.ID,
+				auditAction:  entities.AuditActionLookup,
+			},
+		},
+		{
+			name:        "Admin should deactivate link",
+			requestURL:  "/api/admin/urls/" + tLoc.ShortURLFixture.ID + "/deactivate",
+			requestType: http.MethodPost,
+			adminKey:    "admin-secret",
+			wantedResult: wanted{
+				code:        http.StatusOK,
+				auditAction: entities.AuditActionDeactivate,
+			},
+			check: func(t *testing.T, repo *repositories.InMemoryRepository) {
+				assert.False(t, repo.Storage[tLoc.ShortURLFixture.ID].IsActive)
+			},
+		},
+		{
+			name:        "Admin should reactivate link",
+			requestURL:  "/api/admin/urls/" + tLoc.ShortURLFixtureInactive.ID + "/reactivate",
+			requestType: http.MethodPost,
+			adminKey:    "admin-secret",
+			wantedResult: wanted{
+				code:        http.StatusOK,
+				auditAction: entities.AuditActionReactivate,
+			},
+		},
+		{
+			name:        "Admin should reassign owner",
+			requestURL:  "/api/admin/urls/" + tLoc.ShortURLFixture.ID + "/owner",
+			requestType: http.MethodPut,
+			requestBody: `{"user_id": "` + newOwnerID.String() + `"}`,
+			userID:      adminID,
+			wantedResult: wanted{
+				code:        http.StatusOK,
+				auditAction: entities.AuditActionReassign,
+			},
+			check: func(t *testing.T, repo *repositories.InMemoryRepository) {
+				assert.Equal(t, newOwnerID, repo.Storage[tLoc.ShortURLFixture.ID].UserID)
+			},
+		},
+		{
+			name:        "Reassignment of unknown link should fail",
+			requestURL:  "/api/admin/urls/unknown/owner",
+			requestType: http.MethodPut,
+			requestBody: `{"user_id": "` + newOwnerID.String() + `"}`,
+			userID:      adminID,
+			wantedResult: wanted{
+				code:        http.StatusNotFound,
+				auditAction: entities.AuditActionReassign,
+			},
+		},
+		{
+			name:        "Admin should list all links of user",
+			requestURL:  "/api/admin/users/" + tLoc.UserIDFixture.String() + "/urls",
+			requestType: http.MethodGet,
+			adminKey:    "admin-secret",
+			wantedResult: wanted{
+				code:         http.StatusOK,
+				responsePart: tLoc.ShortURLFixture.ID,
+				auditAction:  entities.AuditActionListUser,
+			},
+		},
+		{
+			name:        "Admin should import links",
+			requestURL:  "/api/admin/import?format=csv&owner=" + tLoc.UserIDFixture.String(),
+			requestType: http.MethodPost,
+			requestBody: "id,original_url\nimported,https://go.dev\nbroken,not a url\n",
+			adminKey:    "admin-secret",
+			wantedResult: wanted{
+				code:         http.StatusOK,
+				responsePart: `"accepted":1,"duplicates":0,"rejected":1`,
+				auditAction:  entities.AuditActionImport,
+			},
+			check: func(t *testing.T, repo *repositories.InMemoryRepository) {
+				assert.Equal(t, "https://go.dev", repo.Storage["imported"].Original)
+				assert.Equal(t, tLoc.UserIDFixture, repo.Storage["imported"].UserID)
+			},
+		},
+		{
+			name:        "Import without owner should reject rows without user id",
+			requestURL:  "/api/admin/import?format=csv",
+			requestType: http.MethodPost,
+			requestBody: "id,original_url\nimported,https://go.dev\n",
+			adminKey:    "admin-secret",
+			wantedResult: wanted{
+				code:         http.StatusOK,
+				responsePart: `"accepted":0,"duplicates":0,"rejected":1`,
+				auditAction:  entities.AuditActionImport,
+			},
+		},
+		{
+			name:         "Import of unknown format should fail",
+			requestURL:   "/api/admin/import",
+			requestType:  http.MethodPost,
+			requestBody:  "<links/>",
+			adminKey:     "admin-secret",
+			wantedResult: wanted{code: http.StatusUnprocessableEntity},
+		},
+		{
+			name:        "Migration progress should not be found without secondary storage",
+			requestURL:  "/api/admin/migration",
+			requestType: http.MethodGet,
+			adminKey:    "admin-secret",
+			wantedResult: wanted{
+				code:        http.StatusNotFound,
+				auditAction: entities.AuditActionViewMigration,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &repositories.InMemoryRepository{
+				Storage: map[string]entities.ShortURL{tLoc.ShortURLFixture.ID: tLoc.ShortURLFixture},
+			}
+			request := httptest.NewRequest(tt.requestType, tt.requestURL, strings.NewReader(tt.requestBody))
+			if tt.userID != uuid.Nil {
+				request.AddCookie(&http.Cookie{
+					Name:  middlewares.CookieName,
+					Value: middlewares.GenerateCookieStringForUserID(tt.userID),
+				})
+			}
+			if tt.adminKey != "" {
+				request.Header.Set(middlewares.AdminKeyHeader, tt.adminKey)
+			}
+			w := httptest.NewRecorder()
+			NewShortener(repo).ServeHTTP(w, request)
+			res := w.Result()
+			defer res.Body.Close()
+			resBody, _ := io.ReadAll(res.Body)
+
+			assert.Equal(t, tt.wantedResult.code, res.StatusCode)
+			if tt.wantedResult.responsePart != "" {
+				assert.Contains(t, string(resBody), tt.wantedResult.responsePart)
+			}
+			auditLog, _ := repo.GetAuditLog(context.Background(), 10)
+			if tt.wantedResult.auditAction == "" {
+				assert.Empty(t, auditLog)
+			} else {
+				assert.Len(t, auditLog, 1)
+				assert.Equal(t, tt.wantedResult.auditAction, auditLog[0].Action)
+				assert.NotEmpty(t, auditLog[0].Actor)
+			}
+			if tt.check != nil {
+				tt.check(t, repo)
+			}
+		})
+	}
+}
+
+func TestAdminHandlersHidePasswordHash(t *testing.T) {
+	config.Settings.AdminAPIKey = "admin-secret"
+	hash, err := hashLinkPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	protected := entities.ShortURL{
+		ID: "protected", Original: "https://go.dev/doc", UserID: tLoc.UserIDFixture, IsActive: true,
+		PasswordHash: hash,
+	}
+	repo := &repositories.InMemoryRepository{Storage: map[string]entities.ShortURL{protected.ID: protected}}
+	h := NewShortener(repo)
+
+	for _, requestURL := range []string{
+		"/api/admin/urls/" + protected.ID,
+		"/api/admin/urls?original_url=" + url.QueryEscape(protected.Original),
+		"/api/admin/users/" + tLoc.UserIDFixture.String() + "/urls",
+	} {
+		request := httptest.NewRequest(http.MethodGet, requestURL, nil)
+		request.Header.Set(middlewares.AdminKeyHeader, "admin-secret")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, request)
+
+		assert.Equal(t, http.StatusOK, w.Code, requestURL)
+		assert.Contains(t, w.Body.String(), `"is_protected":true`, requestURL)
+		assert.NotContains(t, w.Body.String(), hash, requestURL)
+		assert.NotContains(t, w.Body.String(), "password_hash", requestURL)
+	}
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/config"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/middlewares"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/shortenerrors"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/utils"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/lithammer/shortuuid"
+)
+
+// CreateAPIKeyHandler creates named API key for current user.
+//
+// Raw key is returned only once, just hash of it is stored.
+func (h *Shortener) CreateAPIKeyHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	if _, ok := middlewares.APIKeyFromContext(r.Context()); ok {
+		http.Error(w, config.APIKeyNotAllowed, http.StatusForbidden)
+		return
+	}
+	requestBody, doneWithError := h.readBody(w, r)
+	if doneWithError {
+		return
+	}
+	var createDTO entities.APIKeyCreateDTO
+	if err := json.Unmarshal(requestBody, &createDTO); err != nil || createDTO.Name == "" {
+		http.Error(w, config.BadInputData, http.StatusUnprocessableEntity)
+		return
+	}
+	if len(createDTO.Scopes) == 0 {
+		createDTO.Scopes = entities.AllScopes
+	}
+	for _, scope := range createDTO.Scopes {
+		if !isKnownScope(scope) {
+			http.Error(w, config.BadInputData, http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	userID := r.Context().Value(middlewares.UserIDKey).(uuid.UUID)
+
+	rawKey, err := utils.GenerateAPIKey()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	key, err := h.Repo.CreateAPIKey(r.Context(), entities.APIKey{
+		ID:        shortuuid.New(),
+		Name:      createDTO.Name,
+		KeyHash:   utils.HashAPIKey(rawKey),
+		UserID:    userID,
+		Scopes:    createDTO.Scopes,
+		CreatedAt: time.Now().UTC(),
+		IsActive:  true,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse, err := json.Marshal(entities.APIKeyCreatedResponseDto{
+		APIKeyResponseDto: key.ToResponseDto(),
+		Key:               rawKey,
+	})
+	if err != nil {
+		http.Error(w, config.UnknownError, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(jsonResponse)
+}
+
+// GetAPIKeysHandler returns all API keys of current user.
+func (h *Shortener) GetAPIKeysHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	if _, ok := middlewares.APIKeyFromContext(r.Context()); ok {
+		http.Error(w, config.APIKeyNotAllowed, http.StatusForbidden)
+		return
+	}
+	userID := r.Context().Value(middlewares.UserIDKey).(uuid.UUID)
+
+	keys, err := h.Repo.GetAPIKeysByUserID(r.Context(), userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(keys) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	responseDTOs := make([]entities.APIKeyResponseDto, 0, len(keys))
+	for _, key := range keys {
+		responseDTOs = append(responseDTOs, key.ToResponseDto())
+	}
+
+	jsonKeys, _ := json.Marshal(responseDTOs)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonKeys)
+}
+
+// RevokeAPIKeyHandler revokes API key of current user by its id.
+func (h *Shortener) RevokeAPIKeyHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	if _, ok := middlewares.APIKeyFromContext(r.Context()); ok {
+		http.Error(w, config.APIKeyNotAllowed, http.StatusForbidden)
+		return
+	}
+	userID := r.Context().Value(middlewares.UserIDKey).(uuid.UUID)
+
+	err := h.Repo.RevokeAPIKey(r.Context(), userID, chi.URLParam(r, "id"))
+	if errors.Is(err, shortenerrors.ErrItemNotFound) {
+		http.Error(w, config.NoAPIKeyFoundByID, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func isKnownScope(scope string) bool {
+	for _, known := range entities.AllScopes {
+		if scope == known {
+			return true
+		}
+	}
+	return false
+}
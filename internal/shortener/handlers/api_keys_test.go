@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/config"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/middlewares"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/repositories"
+	tLoc "github.com/RomanAVolodin/go-url-shortener/internal/shortener/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeys(t *testing.T) {
+	repo := &repositories.InMemoryRepository{Storage: make(map[string]entities.ShortURL)}
+	h := NewShortener(repo)
+
+	send := func(method, url, body string, header http.Header) (*http.Response, string) {
+		request := httptest.NewRequest(method, url, strings.NewReader(body))
+		for name, values := range header {
+			request.Header.Set(name, values[0])
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, request)
+		res := w.Result()
+		defer res.Body.Close()
+		resBody, _ := io.ReadAll(res.Body)
+		return res, string(resBody)
+	}
+	withCookie := http.Header{"Cookie": {
+		(&http.Cookie{
+			Name:  middlewares.CookieName,
+			Value: middlewares.GenerateCookieStringForUserID(tLoc.UserIDFixture),
+		}).String(),
+	}}
+
+	res, body := send(http.MethodPost, "/api/user/keys", `{"name": "ci", "scopes": ["create"]}`, withCookie)
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	var created entities.APIKeyCreatedResponseDto
+	require.NoError(t, json.Unmarshal([]byte(body), &created))
+	assert.Equal(t, "ci", created.Name)
+	assert.True(t, strings.HasPrefix(created.Key, "sk_"))
+	withKey := http.Header{middlewares.APIKeyHeader: {created.Key}}
+
+	t.Run("Unknown scope should be rejected", func(t *testing.T) {
+		res, _ := send(http.MethodPost, "/api/user/keys", `{"name": "ci", "scopes": ["admin"]}`, withCookie)
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+	})
+
+	t.Run("Key should not be stored in raw form", func(t *testing.T) {
+		stored, exist, err := repo.GetAPIKeyByHash(context.Background(), created.Key)
+		assert.NoError(t, err)
+		assert.False(t, exist)
+		assert.Empty(t, stored.ID)
+	})
+
+	t.Run("Link should be created on behalf of key owner", func(t *testing.T) {
+		res, _ := send(http.MethodPost, "/api/shorten", `{"url": "https://mail.ru"}`, withKey)
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+		assert.Empty(t, res.Cookies())
+
+		records, err := repo.GetByUserID(context.Background(), tLoc.UserIDFixture)
+		assert.NoError(t, err)
+		assert.Len(t, records, 1)
+	})
+
+	t.Run("Key without read scope should not list links", func(t *testing.T) {
+		res, body := send(http.MethodGet, "/api/user/urls", "", withKey)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+		assert.Equal(t, config.InsufficientAPIKeyScope, strings.Trim(body, "\n"))
+	})
+
+	t.Run("Key should not manage keys", func(t *testing.T) {
+		res, _ := send(http.MethodGet, "/api/user/keys", "", withKey)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+	})
+
+	t.Run("Keys should be listed without raw value", func(t *testing.T) {
+		res, body := send(http.MethodGet, "/api/user/keys", "", withCookie)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.NotContains(t, body, created.Key)
+		assert.Contains(t, body, created.ID)
+	})
+
+	t.Run("Revoked key should be rejected", func(t *testing.T) {
+		res, _ := send(http.MethodDelete, "/api/user/keys/"+created.ID, "", withCookie)
+		assert.Equal(t, http.StatusNoContent, res.StatusCode)
+
+		res, body := send(http.MethodPost, "/api/shorten", `{"url": "https://ya.ru"}`, withKey)
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+		assert.Equal(t, config.InvalidAPIKey, strings.Trim(body, "\n"))
+	})
+
+	t.Run("Unknown key should not be revoked", func(t *testing.T) {
+		res, _ := send(http.MethodDelete, "/api/user/keys/unknown", "", withCookie)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/middlewares"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/repositories"
+	tLoc "github.com/RomanAVolodin/go-url-shortener/internal/shortener/tests"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteJobs(t *testing.T) {
+	repo := &repositories.InMemoryRepository{
+		Storage: map[string]entities.ShortURL{tLoc.ShortURLFixture.ID: tLoc.ShortURLFixture},
+	}
+	h := NewShortener(repo)
+
+	send := func(method, url, body string, userID uuid.UUID) (*http.Response, entities.DeleteJob) {
+		request := httptest.NewRequest(method, url, strings.NewReader(body))
+		request.AddCookie(&http.Cookie{
+			Name:  middlewares.CookieName,
+			Value: middlewares.GenerateCookieStringForUserID(userID),
+		})
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, request)
+		res := w.Result()
+		defer res.Body.Close()
+		var job entities.DeleteJob
+		_ = json.NewDecoder(res.Body).Decode(&job)
+		return res, job
+	}
+
+	res, job := send(http.MethodDelete, "/api/user/urls", `["`+tLoc.ShortURLFixture.ID+`", "unknown"]`, tLoc.UserIDFixture)
+	require.Equal(t, http.StatusAccepted, res.StatusCode)
+	require.NotEmpty(t, job.ID)
+	assert.Equal(t, entities.DeleteJobPartiallyApplied, job.State)
+
+	res, job = send(http.MethodGet, "/api/user/urls/delete/"+job.ID, "", tLoc.UserIDFixture)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, entities.DeleteJobPartiallyApplied, job.State)
+	assert.Equal(t, map[string]string{
+		tLoc.ShortURLFixture.ID: entities.DeleteResultDeleted,
+		"unknown":               entities.DeleteResultNotFound,
+	}, job.Results)
+
+	res, _ = send(http.MethodGet, "/api/user/urls/delete/"+job.ID, "", uuid.New())
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+	res, _ = send(http.MethodGet, "/api/user/urls/delete/unknown", "", tLoc.UserIDFixture)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestDeleteJobsDatabaseRepository(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	columns := []string{"id", "user_id", "ids", "state", "results", "attempts", "last_error", "created_at", "updated_at"}
+	failedAt := tLoc.ShortURLFixture.CreatedAt
+	mock.ExpectQuery("SELECT id, user_id, ids, state, results, attempts, last_error, created_at, updated_at FROM delete_jobs").
+		WithArgs("job", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(
+			"job", tLoc.UserIDFixture.String(), []byte(`["`+tLoc.ShortURLFixture.ID+`"]`), entities.DeleteJobApplied,
+			[]byte(`{"`+tLoc.ShortURLFixture.ID+`": "deleted"}`), 1, "", failedAt, failedAt,
+		))
+	mock.ExpectQuery("FROM delete_jobs WHERE state = 'failed' ORDER BY updated_at DESC LIMIT").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(
+			"failed", tLoc.UserIDFixture.String(), []byte(`["a"]`), entities.DeleteJobFailed,
+			[]byte(`null`), 5, "connection refused", failedAt, failedAt,
+		))
+
+	repo := &repositories.DatabaseRepository{Storage: db}
+	job, exist, err := repo.GetDeleteJob(context.Background(), "job")
+	require.NoError(t, err)
+	require.True(t, exist)
+	assert.Equal(t, tLoc.UserIDFixture, job.UserID)
+	assert.Equal(t, []string{tLoc.ShortURLFixture.ID}, job.IDs)
+	assert.Equal(t, map[string]string{tLoc.ShortURLFixture.ID: entities.DeleteResultDeleted}, job.Results)
+
+	deadLetters, err := repo.GetDeadLetterDeleteJobs(context.Background())
+	require.NoError(t, err)
+	require.Len(t, deadLetters, 1)
+	assert.Equal(t, "connection refused", deadLetters[0].LastError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestResumeDeleteJobsDatabaseRepository(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	columns := []string{"id", "user_id", "ids", "state", "results", "attempts", "last_error", "created_at", "updated_at"}
+	queuedAt := tLoc.ShortURLFixture.CreatedAt
+	mock.ExpectQuery("SELECT id, user_id, ids, state, results, attempts, last_error, created_at, updated_at " +
+		"FROM delete_jobs WHERE state = 'queued' ORDER BY created_at").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(
+			"queued", tLoc.UserIDFixture.String(), []byte(`["`+tLoc.ShortURLFixture.ID+`"]`), entities.DeleteJobQueued,
+			[]byte(`null`), 2, "connection refused", queuedAt, queuedAt,
+		))
+	mock.ExpectQuery(`UPDATE short_urls SET is_active=false, deleted_at=now\(\) WHERE is_active=true AND id IN \(\$1\)`).
+		WithArgs(tLoc.ShortURLFixture.ID, tLoc.UserIDFixture.String(), tLoc.UserIDFixture.String()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(tLoc.ShortURLFixture.ID))
+	mock.ExpectExec("INSERT INTO delete_jobs").
+		WithArgs("queued", sqlmock.AnyArg(), sqlmock.AnyArg(), entities.DeleteJobApplied, sqlmock.AnyArg(), 3,
+			"connection refused", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	repo := &repositories.DatabaseRepository{Storage: db}
+	repo.Batcher = repositories.NewDeleteBatcher(repo.DeleteRecordsForUser, time.Hour, 100)
+	resumed, err := repo.ResumeDeleteJobs(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, resumed)
+
+	repo.Batcher.Flush(context.Background(), time.Now())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/middlewares"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/repositories"
+	tLoc "github.com/RomanAVolodin/go-url-shortener/internal/shortener/tests"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestoreDeletedRecords(t *testing.T) {
+	otherUserID := uuid.New()
+	otherURL := entities.ShortURL{ID: "other", Original: "https://mail.ru", UserID: otherUserID, IsActive: true}
+	repo := &repositories.InMemoryRepository{
+		Storage: map[string]entities.ShortURL{
+			tLoc.ShortURLFixture.ID: tLoc.ShortURLFixture,
+			otherURL.ID:             otherURL,
+		},
+	}
+	h := NewShortener(repo)
+
+	send := func(method, url, body string) (int, []byte) {
+		request := httptest.NewRequest(method, url, strings.NewReader(body))
+		request.AddCookie(&http.Cookie{
+			Name:  middlewares.CookieName,
+			Value: middlewares.GenerateCookieStringForUserID(tLoc.UserIDFixture),
+		})
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, request)
+		res := w.Result()
+		defer res.Body.Close()
+		resBody, _ := io.ReadAll(res.Body)
+		return res.StatusCode, resBody
+	}
+	ids := `["` + tLoc.ShortURLFixture.ID + `", "` + otherURL.ID + `"]`
+
+	code, _ := send(http.MethodGet, "/api/user/urls/deleted", "")
+	assert.Equal(t, http.StatusNoContent, code)
+
+	code, _ = send(http.MethodDelete, "/api/user/urls", ids)
+	assert.Equal(t, http.StatusAccepted, code)
+	assert.True(t, repo.Storage[otherURL.ID].IsActive)
+
+	code, body := send(http.MethodGet, "/api/user/urls/deleted", "")
+	require.Equal(t, http.StatusOK, code)
+	var deleted []entities.ShortURLDeletedResponseDto
+	require.NoError(t, json.Unmarshal(body, &deleted))
+	require.Len(t, deleted, 1)
+	assert.Equal(t, tLoc.ShortURLFixture.ID, deleted[0].ID)
+	assert.False(t, deleted[0].DeletedAt.IsZero())
+
+	code, _ = send(http.MethodGet, "/"+tLoc.ShortURLFixture.ID, "")
+	assert.Equal(t, http.StatusGone, code)
+
+	code, body = send(http.MethodPost, "/api/user/urls/restore", ids)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, string(body), tLoc.ShortURLFixture.Original)
+	assert.NotContains(t, string(body), otherURL.Original)
+	assert.True(t, repo.Storage[tLoc.ShortURLFixture.ID].IsActive)
+	assert.Nil(t, repo.Storage[tLoc.ShortURLFixture.ID].DeletedAt)
+
+	code, _ = send(http.MethodPost, "/api/user/urls/restore", ids)
+	assert.Equal(t, http.StatusNoContent, code)
+
+	code, _ = send(http.MethodPost, "/api/user/urls/restore", `[]`)
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+
+	send(http.MethodDelete, "/api/user/urls", ids)
+	purged, err := repo.PurgeDeleted(context.Background(), time.Now().UTC().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), purged)
+	purged, err = repo.PurgeDeleted(context.Background(), time.Now().UTC().Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+	assert.NotContains(t, repo.Storage, tLoc.ShortURLFixture.ID)
+	assert.Contains(t, repo.Storage, otherURL.ID)
+}
+
+func TestPurgeDeletedDropsClicks(t *testing.T) {
+	deletedAt := time.Now().UTC().Add(-time.Hour)
+	purgedURL := tLoc.ShortURLFixture
+	purgedURL.IsActive, purgedURL.DeletedAt = false, &deletedAt
+	keptURL := splitFixture(false)
+	for name, newRepo := range tLoc.MapRepositories(t, purgedURL, keptURL) {
+		t.Run(name, func(t *testing.T) {
+			repo := newRepo()
+			ctx := context.Background()
+			for _, click := range []entities.Click{
+				{ShortURLID: purgedURL.ID, Variant: "a", ClickedAt: deletedAt},
+				{ShortURLID: keptURL.ID, Variant: "b", ClickedAt: deletedAt},
+			} {
+				require.NoError(t, repo.RecordClick(ctx, click))
+			}
+
+			purged, err := repo.PurgeDeleted(ctx, time.Now().UTC())
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), purged)
+			clicks, err := repo.GetVariantClicks(ctx, purgedURL.ID)
+			require.NoError(t, err)
+			assert.Empty(t, clicks, "clicks of purged link are dropped")
+			clicks, err = repo.GetVariantClicks(ctx, keptURL.ID)
+			require.NoError(t, err)
+			assert.Equal(t, map[string]int64{"b": 1}, clicks)
+		})
+	}
+}
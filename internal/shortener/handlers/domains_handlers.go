@@ -0,0 +1,16 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/utils"
+)
+
+// GetDomainsHandler returns domains short urls may be created on, the first one is used when none is chosen.
+func (h *Shortener) GetDomainsHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	respondWithJSON(w, entities.DomainsResponseDTO{Domains: utils.Domains()}, http.StatusOK)
+}
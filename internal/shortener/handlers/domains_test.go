@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/config"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/repositories"
+	tLoc "github.com/RomanAVolodin/go-url-shortener/internal/shortener/tests"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCustomDomains(t *testing.T) {
+	defer func(settings config.AppSettings) { config.Settings = settings }(config.Settings)
+	config.Settings.BaseURL = "http://sho.rt"
+	config.Settings.Domains = []string{"https://go.brand.com/", "https://Brand.link"}
+
+	repo := &repositories.InMemoryRepository{Storage: map[string]entities.ShortURL{
+		"promo": {ID: "promo", Original: "https://example.com/default", IsActive: true},
+		"promo@go.brand.com": {
+			ID: "promo@go.brand.com", Original: "https://example.com/brand", IsActive: true,
+			LinkOptions: entities.LinkOptions{Domain: "go.brand.com"},
+		},
+	}}
+	h := NewShortener(repo)
+
+	tests := []struct {
+		name     string
+		host     string
+		path     string
+		code     int
+		location string
+	}{
+		{name: "Default host should serve default link", host: "sho.rt", path: "/promo", code: http.StatusTemporaryRedirect, location: "https://example.com/default"},
+		{name: "Unknown host should serve default link", host: "localhost:8080", path: "/promo", code: http.StatusTemporaryRedirect, location: "https://example.com/default"},
+		{name: "Custom host should serve its own link", host: "GO.brand.com", path: "/promo", code: http.StatusTemporaryRedirect, location: "https://example.com/brand"},
+		{name: "Other custom host should not serve it", host: "brand.link", path: "/promo", code: http.StatusNotFound},
+		{name: "Full id should not be reachable on default host", host: "sho.rt", path: "/promo@go.brand.com", code: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			request.Host = tt.host
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, request)
+			assert.Equal(t, tt.code, w.Code)
+			assert.Equal(t, tt.location, w.Header().Get("Location"))
+		})
+	}
+
+	w := sendAsOwner(h, http.MethodGet, "/api/domains", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"domains": ["sho.rt", "go.brand.com", "brand.link"]}`, w.Body.String())
+
+	for body, wanted := range map[string]int{
+		`{"url": "https://go.dev/brand", "domain": "go.brand.com"}`: http.StatusCreated,
+		`{"url": "https://go.dev/default", "domain": "sho.rt"}`:     http.StatusCreated,
+		`{"url": "https://go.dev/evil", "domain": "evil.com"}`:      http.StatusUnprocessableEntity,
+	} {
+		assert.Equal(t, wanted, sendAsOwner(h, http.MethodPost, "/api/shorten", body).Code, body)
+	}
+	for _, url := range repo.Storage {
+		switch url.Original {
+		case "https://go.dev/brand":
+			assert.Equal(t, "go.brand.com", url.Domain)
+			assert.Equal(t, "https://go.brand.com/"+url.Slug(), url.Short)
+			assert.Equal(t, url.Slug()+"@go.brand.com", url.ID)
+		case "https://go.dev/default":
+			assert.Empty(t, url.Domain)
+			assert.Equal(t, "http://sho.rt/"+url.ID, url.Short)
+		}
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/promo+", nil)
+	request.Host = "go.brand.com"
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, request)
+	assert.Contains(t, w.Body.String(), "https://go.brand.com/promo")
+}
+
+func TestCustomDomainDatabaseRepository(t *testing.T) {
+	defer func(settings config.AppSettings) { config.Settings = settings }(config.Settings)
+	config.Settings.Domains = []string{"https://go.brand.com"}
+	branded := tLoc.ShortURLFixture
+	branded.ID = entities.ShortURLKey("go.brand.com", branded.ID)
+	branded.Domain = "go.brand.com"
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	mock.ExpectQuery(tLoc.ShortURLSelectQuery).
+		WithArgs(branded.ID).
+		WillReturnRows(sqlmock.NewRows(tLoc.ShortURLColumns).AddRow(tLoc.ShortURLRow(branded)...))
+
+	h := NewShortener(&repositories.DatabaseRepository{Storage: db})
+	request := httptest.NewRequest(http.MethodGet, "/"+branded.Slug(), nil)
+	request.Host = "go.brand.com"
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, request)
+
+	assert.Equal(t, branded.Original, w.Header().Get("Location"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOriginalURLUniqueWithinDomain(t *testing.T) {
+	defer func(settings config.AppSettings) { config.Settings = settings }(config.Settings)
+	config.Settings.Domains = []string{"https://go.brand.com"}
+	repo := &repositories.InMemoryRepository{Storage: map[string]entities.ShortURL{
+		"promo": {ID: "promo", Original: "https://example.com/same", UserID: tLoc.UserIDFixture, IsActive: true},
+		"other": {ID: "other", Original: "https://example.com/other", UserID: tLoc.UserIDFixture, IsActive: true},
+		"promo@go.brand.com": {
+			ID: "promo@go.brand.com", Original: "https://example.com/brand", UserID: tLoc.UserIDFixture, IsActive: true,
+			LinkOptions: entities.LinkOptions{Domain: "go.brand.com"},
+		},
+	}}
+	h := NewShortener(repo)
+	update := `{"url": "https://example.com/same"}`
+
+	assert.Equal(t, http.StatusOK, sendAsOwner(h, http.MethodPatch, "/api/user/urls/promo@go.brand.com", update).Code)
+	assert.Equal(t, http.StatusConflict, sendAsOwner(h, http.MethodPatch, "/api/user/urls/other", update).Code)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	branded := tLoc.ShortURLFixture
+	branded.Domain = "go.brand.com"
+	mock.ExpectExec("INSERT INTO short_urls").
+		WillReturnError(&pgconn.PgError{Code: pgerrcode.UniqueViolation})
+	mock.ExpectQuery(tLoc.ShortURLSelectQuery+` WHERE domain = \$1 AND workspace_id = \$2 AND original_url = \$3`).
+		WithArgs("go.brand.com", "", branded.Original).
+		WillReturnRows(sqlmock.NewRows(tLoc.ShortURLColumns).AddRow(tLoc.ShortURLRow(branded)...))
+
+	h = NewShortener(&repositories.DatabaseRepository{Storage: db})
+	w := sendAsOwner(h, http.MethodPost, "/api/shorten", `{"url": "`+branded.Original+`", "domain": "go.brand.com"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/config"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/middlewares"
+	"github.com/google/uuid"
+)
+
+// Export formats and their content types.
+const (
+	exportFormatCSV    = "csv"
+	exportFormatJSON   = "json"
+	exportFormatNDJSON = "ndjson"
+)
+
+var exportContentTypes = map[string]string{
+	exportFormatCSV:    "text/csv",
+	exportFormatJSON:   "application/json",
+	exportFormatNDJSON: "application/x-ndjson",
+}
+
+// exportCSVHeader columns of CSV export.
+var exportCSVHeader = []string{"id", "short_url", "original_url", "status", "created_at", "deleted_at"}
+
+// ExportRecordsHandler streams all records of current user including inactive ones.
+//
+// Format is taken from `format` query param, otherwise negotiated with Accept header, JSON is default.
+func (h *Shortener) ExportRecordsHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	format, ok := exportFormat(r)
+	if !ok {
+		http.Error(w, config.UnsupportedExportFormat, http.StatusNotAcceptable)
+		return
+	}
+	userID := r.Context().Value(middlewares.UserIDKey).(uuid.UUID)
+
+	w.Header().Set("Content-Type", exportContentTypes[format])
+	w.Header().Set("Content-Disposition", `attachment; filename="links.`+format+`"`)
+	w.WriteHeader(http.StatusOK)
+
+	writer := newExportWriter(w, format)
+	err := h.Repo.IterateByUserID(r.Context(), userID, func(shortURL entities.ShortURL) error {
+		return writer.write(shortURL.ToExportDto())
+	})
+	if err == nil {
+		err = writer.close()
+	}
+	if err != nil {
+		// Status has already been sent, truncated body is the only way to report failure.
+		log.Printf("Export of user %s records failed: %v", userID, err)
+	}
+}
+
+// exportFormat returns format requested with query param or Accept header.
+func exportFormat(r *http.Request) (string, bool) {
+	if format := strings.ToLower(r.URL.Query().Get("format")); format != "" {
+		_, ok := exportContentTypes[format]
+		return format, ok
+	}
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return exportFormatJSON, true
+	}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "text/csv":
+			return exportFormatCSV, true
+		case "application/x-ndjson", "application/jsonl":
+			return exportFormatNDJSON, true
+		case "application/json", "application/*", "*/*":
+			return exportFormatJSON, true
+		}
+	}
+	return "", false
+}
+
+// exportWriter writes exported records one by one.
+type exportWriter interface {
+	write(record entities.ShortURLExportDto) error
+	close() error
+}
+
+func newExportWriter(w io.Writer, format string) exportWriter {
+	switch format {
+	case exportFormatCSV:
+		return &csvExportWriter{writer: csv.NewWriter(w)}
+	case exportFormatNDJSON:
+		return &ndjsonExportWriter{encoder: json.NewEncoder(w)}
+	default:
+		return &jsonExportWriter{w: w}
+	}
+}
+
+// csvExportWriter writes records as CSV rows after header.
+type csvExportWriter struct {
+	writer        *csv.Writer
+	headerWritten bool
+}
+
+func (cw *csvExportWriter) write(record entities.ShortURLExportDto) error {
+	if !cw.headerWritten {
+		cw.headerWritten = true
+		if err := cw.writer.Write(exportCSVHeader); err != nil {
+			return err
+		}
+	}
+	deletedAt := ""
+	if record.DeletedAt != nil {
+		deletedAt = record.DeletedAt.Format(time.RFC3339)
+	}
+	return cw.writer.Write([]string{
+		record.ID,
+		record.Short,
+		record.Original,
+		record.Status,
+		record.CreatedAt.Format(time.RFC3339),
+		deletedAt,
+	})
+}
+
+func (cw *csvExportWriter) close() error {
+	if !cw.headerWritten {
+		cw.headerWritten = true
+		if err := cw.writer.Write(exportCSVHeader); err != nil {
+			return err
+		}
+	}
+	cw.writer.Flush()
+	return cw.writer.Error()
+}
+
+// ndjsonExportWriter writes every record as json object on its own line.
+type ndjsonExportWriter struct {
+	encoder *json.Encoder
+}
+
+func (nw *ndjsonExportWriter) write(record entities.ShortURLExportDto) error {
+	return nw.encoder.Encode(record)
+}
+
+func (nw *ndjsonExportWriter) close() error {
+	return nil
+}
+
+// jsonExportWriter writes records as json array without holding them in memory.
+type jsonExportWriter struct {
+	w       io.Writer
+	started bool
+}
+
+func (jw *jsonExportWriter) write(record entities.ShortURLExportDto) error {
+	prefix := ","
+	if !jw.started {
+		jw.started = true
+		prefix = "["
+	}
+	jsonRecord, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = jw.w.Write(append([]byte(prefix), jsonRecord...))
+	return err
+}
+
+func (jw *jsonExportWriter) close() error {
+	closing := "]"
+	if !jw.started {
+		closing = "[]"
+	}
+	_, err := io.WriteString(jw.w, closing)
+	return err
+}
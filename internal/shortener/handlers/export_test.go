@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/middlewares"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/repositories"
+	tLoc "github.com/RomanAVolodin/go-url-shortener/internal/shortener/tests"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportRecordsHandler(t *testing.T) {
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	deletedAt := createdAt.Add(time.Hour)
+	active := entities.ShortURL{
+		ID: "active", Original: "https://ya.ru", UserID: tLoc.UserIDFixture,
+		IsActive: true, CreatedAt: createdAt,
+	}
+	deleted := entities.ShortURL{
+		ID: "deleted", Original: "https://mail.ru", UserID: tLoc.UserIDFixture,
+		CreatedAt: createdAt.Add(time.Minute), DeletedAt: &deletedAt,
+	}
+	inactive := entities.ShortURL{
+		ID: "inactive", Original: "https://go.dev", UserID: tLoc.UserIDFixture,
+		CreatedAt: createdAt.Add(2 * time.Minute),
+	}
+	foreign := entities.ShortURL{
+		ID: "foreign", Original: "https://example.com", UserID: uuid.New(),
+		IsActive: true, CreatedAt: createdAt,
+	}
+	repo := &repositories.InMemoryRepository{
+		Storage: map[string]entities.ShortURL{
+			active.ID:   active,
+			deleted.ID:  deleted,
+			inactive.ID: inactive,
+			foreign.ID:  foreign,
+		},
+	}
+	h := NewShortener(repo)
+
+	tests := []struct {
+		name        string
+		url         string
+		accept      string
+		statusCode  int
+		contentType string
+	}{
+		{
+			name:        "default is json",
+			url:         "/api/user/urls/export",
+			statusCode:  http.StatusOK,
+			contentType: "application/json",
+		},
+		{
+			name:        "csv by query",
+			url:         "/api/user/urls/export?format=csv",
+			accept:      "application/json",
+			statusCode:  http.StatusOK,
+			contentType: "text/csv",
+		},
+		{
+			name:        "ndjson by query",
+			url:         "/api/user/urls/export?format=ndjson",
+			statusCode:  http.StatusOK,
+			contentType: "application/x-ndjson",
+		},
+		{
+			name:        "csv by accept",
+			url:         "/api/user/urls/export",
+			accept:      "text/html, text/csv;q=0.9",
+			statusCode:  http.StatusOK,
+			contentType: "text/csv",
+		},
+		{
+			name:        "ndjson by accept",
+			url:         "/api/user/urls/export",
+			accept:      "application/x-ndjson",
+			statusCode:  http.StatusOK,
+			contentType: "application/x-ndjson",
+		},
+		{
+			name:       "unknown format",
+			url:        "/api/user/urls/export?format=xml",
+			statusCode: http.StatusNotAcceptable,
+		},
+		{
+			name:       "unacceptable accept",
+			url:        "/api/user/urls/export",
+			accept:     "text/html",
+			statusCode: http.StatusNotAcceptable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.accept != "" {
+				request.Header.Set("Accept", tt.accept)
+			}
+			request.AddCookie(&http.Cookie{
+				Name:  middlewares.CookieName,
+				Value: middlewares.GenerateCookieStringForUserID(tLoc.UserIDFixture),
+			})
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, request)
+			res := w.Result()
+			defer res.Body.Close()
+			body, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.statusCode, res.StatusCode)
+			if tt.statusCode != http.StatusOK {
+				return
+			}
+			assert.Equal(t, tt.contentType, res.Header.Get("Content-Type"))
+			assert.Contains(t, res.Header.Get("Content-Disposition"), "attachment")
+
+			var records []entities.ShortURLExportDto
+			switch tt.contentType {
+			case "application/json":
+				require.NoError(t, json.Unmarshal(body, &records))
+			case "application/x-ndjson":
+				decoder := json.NewDecoder(strings.NewReader(string(body)))
+				for decoder.More() {
+					var record entities.ShortURLExportDto
+					require.NoError(t, decoder.Decode(&record))
+					records = append(records, record)
+				}
+			case "text/csv":
+				rows, err := csv.NewReader(strings.NewReader(string(body))).ReadAll()
+				require.NoError(t, err)
+				require.Len(t, rows, 4)
+				assert.Equal(t, exportCSVHeader, rows[0])
+				for _, row := range rows[1:] {
+					records = append(records, entities.ShortURLExportDto{ID: row[0], Status: row[3]})
+				}
+				assert.Equal(t, deletedAt.Format(time.RFC3339), rows[2][5])
+			}
+			require.Len(t, records, 3)
+			assert.Equal(t, active.ID, records[0].ID)
+			assert.Equal(t, entities.ShortURLStatusActive, records[0].Status)
+			assert.Equal(t, deleted.ID, records[1].ID)
+			assert.Equal(t, entities.ShortURLStatusDeleted, records[1].Status)
+			assert.Equal(t, inactive.ID, records[2].ID)
+			assert.Equal(t, entities.ShortURLStatusInactive, records[2].Status)
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/middlewares"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/repositories"
+	tLoc "github.com/RomanAVolodin/go-url-shortener/internal/shortener/tests"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateRecord(t *testing.T) {
+	otherURL := entities.ShortURL{ID: "other", Original: "https://mail.ru", UserID: uuid.New(), IsActive: true}
+	repo := &repositories.InMemoryRepository{
+		Storage: map[string]entities.ShortURL{
+			tLoc.ShortURLFixture.ID: tLoc.ShortURLFixture,
+			otherURL.ID:             otherURL,
+		},
+	}
+	h := NewShortener(repo)
+
+	type wanted struct {
+		code         int
+		responsePart string
+		original     string
+	}
+	tests := []struct {
+		name        string
+		urlID       string
+		requestBody string
+		wanted      wanted
+	}{
+		{
+			name:        "Invalid url should be rejected",
+			urlID:       tLoc.ShortURLFixture.ID,
+			requestBody: `{"url": "not a url"}`,
+			wanted:      wanted{code: http.StatusUnprocessableEntity, original: tLoc.ShortURLFixture.Original},
+		},
+		{
+			name:        "Url shortened by another link should conflict",
+			urlID:       tLoc.ShortURLFixture.ID,
+			requestBody: `{"url": "` + otherURL.Original + `"}`,
+			wanted:      wanted{code: http.StatusConflict, original: tLoc.ShortURLFixture.Original},
+		},
+		{
+			name:        "Link of another user should not be found",
+			urlID:       otherURL.ID,
+			requestBody: `{"url": "https://google.com"}`,
+			wanted:      wanted{code: http.StatusNotFound},
+		},
+		{
+			name:        "Destination should be changed",
+			urlID:       tLoc.ShortURLFixture.ID,
+			requestBody: `{"url": "https://google.com"}`,
+			wanted: wanted{
+				code:         http.StatusOK,
+				responsePart: "https://google.com",
+				original:     "https://google.com",
+			},
+		},
+		{
+			name:        "Destination should be changed once more",
+			urlID:       tLoc.ShortURLFixture.ID,
+			requestBody: `{"url": "https://go.dev"}`,
+			wanted: wanted{
+				code:         http.StatusOK,
+				responsePart: "https://go.dev",
+				original:     "https://go.dev",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPatch, "/api/user/urls/"+tt.urlID, strings.NewReader(tt.requestBody))
+			request.AddCookie(&http.Cookie{
+				Name:  middlewares.CookieName,
+				Value: middlewares.GenerateCookieStringForUserID(tLoc.UserIDFixture),
+			})
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, request)
+			res := w.Result()
+			defer res.Body.Close()
+			resBody, _ := io.ReadAll(res.Body)
+
+			assert.Equal(t, tt.wanted.code, res.StatusCode)
+			assert.Contains(t, string(resBody), tt.wanted.responsePart)
+			if tt.wanted.original != "" {
+				assert.Equal(t, tt.wanted.original, repo.Storage[tt.urlID].Original)
+			}
+		})
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/api/user/urls/"+tLoc.ShortURLFixture.ID+"/history", nil)
+	request.AddCookie(&http.Cookie{
+		Name:  middlewares.CookieName,
+		Value: middlewares.GenerateCookieStringForUserID(tLoc.UserIDFixture),
+	})
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, request)
+	res := w.Result()
+	defer res.Body.Close()
+
+	var history []entities.DestinationChange
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&history))
+	require.Len(t, history, 2)
+	assert.Equal(t, "https://google.com", history[0].Original)
+	assert.Equal(t, tLoc.ShortURLFixture.Original, history[1].Original)
+	assert.Equal(t, tLoc.UserIDFixture, history[1].ChangedBy)
+}
+
+func TestCreateDoesNotValidateURL(t *testing.T) {
+	h := NewShortener(&repositories.InMemoryRepository{Storage: map[string]entities.ShortURL{}})
+
+	assert.Equal(t, http.StatusCreated, sendAsOwner(h, http.MethodPost, "/", "mail.ru").Code)
+	assert.Equal(t, http.StatusCreated, sendAsOwner(h, http.MethodPost, "/api/shorten", `{"url": "ya.ru"}`).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity,
+		sendAsOwner(h, http.MethodPatch, "/api/user/urls/"+tLoc.ShortURLFixture.ID, `{"url": "ya.ru"}`).Code,
+		"destination is validated on update only")
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/config"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/middlewares"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/repositories"
+	tLoc "github.com/RomanAVolodin/go-url-shortener/internal/shortener/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxClicksConcurrentRedirects(t *testing.T) {
+	const maxClicks, requests = 3, 50
+	limited := entities.ShortURL{
+		ID: "limited", Original: "https://ya.ru/invite", UserID: tLoc.UserIDFixture, IsActive: true,
+		ClicksLeft: maxClicks, LinkOptions: entities.LinkOptions{MaxClicks: maxClicks, RedirectType: http.StatusPermanentRedirect},
+	}
+	repo := &repositories.InMemoryRepository{Storage: map[string]entities.ShortURL{limited.ID: limited}}
+	h := NewShortener(repo)
+
+	var wg sync.WaitGroup
+	statuses := make(chan *http.Response, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+limited.ID, nil))
+			statuses <- w.Result()
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	counted := make(map[int]int)
+	for res := range statuses {
+		res.Body.Close()
+		counted[res.StatusCode]++
+		if res.StatusCode == http.StatusPermanentRedirect {
+			assert.Equal(t, "no-store", res.Header.Get("Cache-Control"))
+		}
+	}
+	assert.Equal(t, map[int]int{http.StatusPermanentRedirect: maxClicks, http.StatusGone: requests - maxClicks}, counted)
+	assert.Equal(t, 0, repo.Storage[limited.ID].ClicksLeft)
+
+	for _, url := range []string{"/" + limited.ID + "+", "/api/qr/" + limited.ID} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+		assert.Equal(t, http.StatusGone, w.Code, url)
+	}
+}
+
+func TestMaxClicksTakenByRedirectAndInterstitial(t *testing.T) {
+	defer func(settings config.AppSettings) { config.Settings = settings }(config.Settings)
+	limited := entities.ShortURL{
+		ID: "limited", Original: "https://ya.ru/invite", UserID: tLoc.UserIDFixture, IsActive: true,
+		ClicksLeft: 2, LinkOptions: entities.LinkOptions{MaxClicks: 2},
+	}
+	repo := &repositories.InMemoryRepository{Storage: map[string]entities.ShortURL{limited.ID: limited}}
+	h := NewShortener(repo)
+	send := func(url string) int {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, send("/"+limited.ID+"+"))
+	assert.Equal(t, 2, repo.Storage[limited.ID].ClicksLeft, "preview page does not take clicks")
+
+	config.Settings.InterstitialAllowed = []string{"go.dev"}
+	assert.Equal(t, http.StatusOK, send("/"+limited.ID), "interstitial page is shown")
+	assert.Equal(t, 1, repo.Storage[limited.ID].ClicksLeft, "interstitial page takes click")
+	config.Settings.InterstitialAllowed = nil
+
+	assert.Equal(t, http.StatusTemporaryRedirect, send("/"+limited.ID))
+	assert.Equal(t, http.StatusGone, send("/"+limited.ID))
+
+	config.Settings.InterstitialAllowed = []string{"go.dev"}
+	assert.Equal(t, http.StatusGone, send("/"+limited.ID), "exhausted link shows no interstitial page")
+}
+
+func TestCreateWithMaxClicks(t *testing.T) {
+	repo := &repositories.InMemoryRepository{Storage: map[string]entities.ShortURL{}}
+	h := NewShortener(repo)
+	send := func(url, body string) int {
+		request := httptest.NewRequest(http.MethodPost, url, strings.NewReader(body))
+		request.AddCookie(&http.Cookie{
+			Name:  middlewares.CookieName,
+			Value: middlewares.GenerateCookieStringForUserID(tLoc.UserIDFixture),
+		})
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, request)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusCreated, send("/api/shorten", `{"url": "https://go.dev", "max_clicks": 1}`))
+	assert.Equal(t, http.StatusCreated, send("/api/shorten/batch", `[{"correlation_id": "1", "original_url": "https://ya.ru", "max_clicks": 5}]`))
+	assert.Equal(t, http.StatusUnprocessableEntity, send("/api/shorten", `{"url": "https://mail.ru", "max_clicks": -1}`))
+
+	clicks := make(map[string][2]int)
+	for _, url := range repo.Storage {
+		clicks[url.Original] = [2]int{url.MaxClicks, url.ClicksLeft}
+	}
+	assert.Equal(t, map[string][2]int{"https://go.dev": {1, 1}, "https://ya.ru": {5, 5}}, clicks)
+}
+
+func TestMaxClicksDatabaseRepository(t *testing.T) {
+	limited := tLoc.ShortURLFixture
+	limited.MaxClicks, limited.ClicksLeft = 2, 1
+	used := limited
+	used.ClicksLeft = 0
+
+	tests := []struct {
+		name       string
+		expect     func(sqlmock.Sqlmock)
+		statusCode int
+	}{
+		{
+			name: "Redirect should take last click",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("UPDATE short_urls SET clicks_left = clicks_left - 1").
+					WithArgs(limited.ID).
+					WillReturnRows(sqlmock.NewRows(tLoc.ShortURLColumns).AddRow(tLoc.ShortURLRow(used)...))
+			},
+			statusCode: http.StatusTemporaryRedirect,
+		},
+		{
+			name: "Redirect should be gone when concurrent one took last click",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("UPDATE short_urls SET clicks_left = clicks_left - 1").
+					WithArgs(limited.ID).
+					WillReturnRows(sqlmock.NewRows(tLoc.ShortURLColumns))
+			},
+			statusCode: http.StatusGone,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+			mock.ExpectQuery(tLoc.ShortURLSelectQuery).
+				WillReturnRows(sqlmock.NewRows(tLoc.ShortURLColumns).AddRow(tLoc.ShortURLRow(limited)...))
+			tt.expect(mock)
+
+			h := NewShortener(&repositories.DatabaseRepository{Storage: db})
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+limited.ID, nil))
+
+			assert.Equal(t, tt.statusCode, w.Code)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"bytes"
+	_ "embed"
+	"html/template"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/config"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// LinkPasswordHeader header to pass password of protected ShortURL without form.
+const LinkPasswordHeader = "X-Link-Password"
+
+// maxAttemptEntries number of counted keys after which expired ones are dropped.
+const maxAttemptEntries = 10000
+
+//go:embed templates/password.html
+var passwordTemplateSource string
+
+// passwordTemplate form asking for password of protected ShortURL.
+var passwordTemplate = template.Must(template.New("password").Parse(passwordTemplateSource))
+
+// passwordPage data rendered by passwordTemplate.
+type passwordPage struct {
+	Action string
+	Error  string
+}
+
+// attemptWindow failed attempts counted for a key until resetAt.
+type attemptWindow struct {
+	count   int
+	resetAt time.Time
+}
+
+// attemptLimiter counts failed password attempts per key within fixed window,
+// non-positive limit disables it.
+type attemptLimiter struct {
+	mu      sync.Mutex
+	limit   int
+	window  time.Duration
+	entries map[string]attemptWindow
+}
+
+// newAttemptLimiter creates attemptLimiter allowing limit failed attempts per window.
+func newAttemptLimiter(limit int, window time.Duration) *attemptLimiter {
+	return &attemptLimiter{
+		limit:   limit,
+		window:  window,
+		entries: make(map[string]attemptWindow),
+	}
+}
+
+// retryAfter returns time left until attempts for key are allowed again, zero if they are allowed now.
+func (l *attemptLimiter) retryAfter(key string) time.Duration {
+	if l.limit <= 0 {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry, ok := l.entries[key]
+	if !ok || entry.count < l.limit {
+		return 0
+	}
+	return time.Until(entry.resetAt)
+}
+
+// fail counts failed attempt for key.
+func (l *attemptLimiter) fail(key string) {
+	if l.limit <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	entry, ok := l.entries[key]
+	if !ok || !now.Before(entry.resetAt) {
+		if len(l.entries) >= maxAttemptEntries {
+			l.prune(now)
+		}
+		entry = attemptWindow{resetAt: now.Add(l.window)}
+	}
+	entry.count++
+	l.entries[key] = entry
+}
+
+// prune drops keys whose window is over, has to be called with mu locked.
+func (l *attemptLimiter) prune(now time.Time) {
+	for key, entry := range l.entries {
+		if !now.Before(entry.resetAt) {
+			delete(l.entries, key)
+		}
+	}
+}
+
+// hashLinkPassword returns bcrypt hash of password, empty password leaves ShortURL unprotected.
+func hashLinkPassword(password string) (string, error) {
+	if password == "" {
+		return "", nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// checkLinkPassword reports whether request carries password of protected ShortURL,
+// otherwise responds with password form, wrong password or throttling error.
+func (h *Shortener) checkLinkPassword(w http.ResponseWriter, r *http.Request, urlItem entities.ShortURL) bool {
+	password := r.Header.Get(LinkPasswordHeader)
+	fromHeader := password != ""
+	if !fromHeader && r.Method == http.MethodPost {
+		password = r.PostFormValue("password")
+	}
+	if password == "" {
+		renderPasswordForm(w, r, "")
+		return false
+	}
+
+	linkKey, ipKey := urlItem.ID, clientIP(r)
+	wait := h.linkAttempts.retryAfter(linkKey)
+	if ipWait := h.ipAttempts.retryAfter(ipKey); ipWait > wait {
+		wait = ipWait
+	}
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int((wait+time.Second-1)/time.Second)))
+		http.Error(w, config.TooManyPasswordAttempts, http.StatusTooManyRequests)
+		return false
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(urlItem.PasswordHash), []byte(password)) != nil {
+		h.linkAttempts.fail(linkKey)
+		h.ipAttempts.fail(ipKey)
+		if fromHeader {
+			http.Error(w, config.WrongLinkPassword, http.StatusUnauthorized)
+		} else {
+			renderPasswordForm(w, r, config.WrongLinkPassword)
+		}
+		return false
+	}
+	return true
+}
+
+// renderPasswordForm responds with form submitting password to the same url.
+func renderPasswordForm(w http.ResponseWriter, r *http.Request, errorMessage string) {
+	var page bytes.Buffer
+	err := passwordTemplate.Execute(&page, passwordPage{
+		Action: r.URL.RequestURI(),
+		Error:  errorMessage,
+	})
+	if err != nil {
+		http.Error(w, config.UnknownError, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusUnauthorized)
+	w.Write(page.Bytes())
+}
+
+// clientIP returns address of client, RemoteAddr is already replaced with the real one by middleware.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
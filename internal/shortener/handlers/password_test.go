@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/config"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/middlewares"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/repositories"
+	tLoc "github.com/RomanAVolodin/go-url-shortener/internal/shortener/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordProtectedLink(t *testing.T) {
+	hash, err := hashLinkPassword("secret")
+	require.NoError(t, err)
+	protected := entities.ShortURL{
+		ID: "protected", Original: "https://go.dev/doc", UserID: tLoc.UserIDFixture, IsActive: true,
+		PasswordHash: hash,
+	}
+	repo := &repositories.InMemoryRepository{
+		Storage: map[string]entities.ShortURL{
+			tLoc.ShortURLFixture.ID: tLoc.ShortURLFixture,
+			protected.ID:            protected,
+		},
+	}
+	h := NewShortener(repo)
+
+	tests := []struct {
+		name         string
+		method       string
+		url          string
+		header       string
+		form         url.Values
+		statusCode   int
+		location     string
+		bodyParts    []string
+		notBodyParts []string
+	}{
+		{
+			name:       "Unprotected link should redirect",
+			method:     http.MethodGet,
+			url:        "/" + tLoc.ShortURLFixture.ID,
+			statusCode: http.StatusTemporaryRedirect,
+			location:   tLoc.ShortURLFixture.Original,
+		},
+		{
+			name:         "Protected link should ask for password",
+			method:       http.MethodGet,
+			url:          "/" + protected.ID + "?a=1",
+			statusCode:   http.StatusUnauthorized,
+			bodyParts:    []string{`action="/protected?a=1"`, `name="password"`},
+			notBodyParts: []string{protected.Original},
+		},
+		{
+			name:         "Preview of protected link should ask for password",
+			method:       http.MethodGet,
+			url:          "/" + protected.ID + "+",
+			statusCode:   http.StatusUnauthorized,
+			notBodyParts: []string{protected.Original},
+		},
+		{
+			name:       "Protected link should redirect with password in header",
+			method:     http.MethodGet,
+			url:        "/" + protected.ID,
+			header:     "secret",
+			statusCode: http.StatusTemporaryRedirect,
+			location:   protected.Original,
+		},
+		{
+			name:       "Protected link should redirect after form is submitted",
+			method:     http.MethodPost,
+			url:        "/" + protected.ID,
+			form:       url.Values{"password": {"secret"}},
+			statusCode: http.StatusSeeOther,
+			location:   protected.Original,
+		},
+		{
+			name:       "Preview of protected link should be shown after form is submitted",
+			method:     http.MethodPost,
+			url:        "/" + protected.ID + "+",
+			form:       url.Values{"password": {"secret"}},
+			statusCode: http.StatusOK,
+			bodyParts:  []string{protected.Original},
+		},
+		{
+			name:       "Wrong password in header should be rejected",
+			method:     http.MethodGet,
+			url:        "/" + protected.ID,
+			header:     "wrong",
+			statusCode: http.StatusUnauthorized,
+			bodyParts:  []string{config.WrongLinkPassword},
+		},
+		{
+			name:         "Wrong password in form should show form again",
+			method:       http.MethodPost,
+			url:          "/" + protected.ID,
+			form:         url.Values{"password": {"wrong"}},
+			statusCode:   http.StatusUnauthorized,
+			bodyParts:    []string{config.WrongLinkPassword, `name="password"`},
+			notBodyParts: []string{protected.Original},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.form.Encode()))
+			if tt.form != nil {
+				request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+			if tt.header != "" {
+				request.Header.Set(LinkPasswordHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, request)
+			res := w.Result()
+			defer res.Body.Close()
+			body, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.statusCode, res.StatusCode)
+			assert.Equal(t, tt.location, res.Header.Get("Location"))
+			for _, part := range tt.bodyParts {
+				assert.Contains(t, string(body), part)
+			}
+			for _, part := range tt.notBodyParts {
+				assert.NotContains(t, string(body), part)
+			}
+		})
+	}
+}
+
+func TestPasswordAttemptsThrottling(t *testing.T) {
+	defer func(settings config.AppSettings) { config.Settings = settings }(config.Settings)
+	config.Settings.PasswordLinkLimit = 3
+	config.Settings.PasswordIPLimit = 2
+	hash, err := hashLinkPassword("secret")
+	require.NoError(t, err)
+	repo := &repositories.InMemoryRepository{
+		Storage: map[string]entities.ShortURL{
+			"first":  {ID: "first", Original: "https://ya.ru", IsActive: true, PasswordHash: hash},
+			"second": {ID: "second", Original: "https://go.dev", IsActive: true, PasswordHash: hash},
+		},
+	}
+	h := NewShortener(repo)
+	send := func(id, password, ip string) *http.Response {
+		request := httptest.NewRequest(http.MethodGet, "/"+id, nil)
+		request.RemoteAddr = ip + ":1234"
+		request.Header.Set(LinkPasswordHeader, password)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, request)
+		res := w.Result()
+		res.Body.Close()
+		return res
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, send("first", "wrong", "10.0.0.1").StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, send("second", "wrong", "10.0.0.1").StatusCode)
+	res := send("second", "secret", "10.0.0.1")
+	assert.Equal(t, http.StatusTooManyRequests, res.StatusCode, "IP should be throttled across links")
+	assert.NotEmpty(t, res.Header.Get("Retry-After"))
+
+	assert.Equal(t, http.StatusUnauthorized, send("first", "wrong", "10.0.0.2").StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, send("first", "wrong", "10.0.0.3").StatusCode)
+	assert.Equal(t, http.StatusTooManyRequests, send("first", "secret", "10.0.0.4").StatusCode, "link should be throttled across IPs")
+	assert.Equal(t, http.StatusTemporaryRedirect, send("second", "secret", "10.0.0.4").StatusCode)
+}
+
+func TestCreateWithPassword(t *testing.T) {
+	repo := &repositories.InMemoryRepository{Storage: map[string]entities.ShortURL{}}
+	h := NewShortener(repo)
+	send := func(url, body string) int {
+		request := httptest.NewRequest(http.MethodPost, url, strings.NewReader(body))
+		request.AddCookie(&http.Cookie{
+			Name:  middlewares.CookieName,
+			Value: middlewares.GenerateCookieStringForUserID(tLoc.UserIDFixture),
+		})
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, request)
+		res := w.Result()
+		defer res.Body.Close()
+		return res.StatusCode
+	}
+
+	assert.Equal(t, http.StatusCreated, send("/api/shorten", `{"url": "https://go.dev", "password": "secret"}`))
+	assert.Equal(t, http.StatusCreated, send("/api/shorten/batch", `[{"correlation_id": "1", "original_url": "https://ya.ru"}]`))
+	tooLong := strings.Repeat("a", entities.MaxPasswordLength+1)
+	assert.Equal(t, http.StatusUnprocessableEntity, send("/api/shorten", `{"url": "https://mail.ru", "password": "`+tooLong+`"}`))
+	assert.Equal(t, http.StatusUnprocessableEntity, send("/api/shorten/batch", `[{"original_url": "https://mail.ru", "password": "`+tooLong+`"}]`))
+
+	require.Len(t, repo.Storage, 2)
+	for _, item := range repo.Storage {
+		switch item.Original {
+		case "https://go.dev":
+			assert.True(t, item.IsProtected())
+			assert.NotContains(t, item.PasswordHash, "secret")
+		default:
+			assert.False(t, item.IsProtected())
+		}
+	}
+}
+
+func TestPasswordHashKeptByFileRepository(t *testing.T) {
+	hash, err := hashLinkPassword("secret")
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "storage.json")
+	repo := &repositories.FileRepository{Storage: map[string]entities.ShortURL{}, FilePath: path}
+	_, err = repo.Create(context.Background(), entities.ShortURL{
+		ID: "protected", Original: "https://go.dev/doc", UserID: tLoc.UserIDFixture, IsActive: true,
+		PasswordHash: hash,
+	})
+	require.NoError(t, err)
+
+	restored := &repositories.FileRepository{Storage: map[string]entities.ShortURL{}, FilePath: path}
+	require.NoError(t, restored.Restore())
+	shortURL, exist, err := restored.GetByID(context.Background(), "protected")
+	require.NoError(t, err)
+	require.True(t, exist)
+	assert.Equal(t, hash, shortURL.PasswordHash)
+}
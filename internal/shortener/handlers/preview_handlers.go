@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	_ "embed"
+	"errors"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/config"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/shortenerrors"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+//go:embed templates/preview.html
+var previewTemplateSource string
+
+// previewTemplate page shown instead of redirect.
+var previewTemplate = template.Must(template.New("preview").Parse(previewTemplateSource))
+
+// previewPage data rendered by previewTemplate.
+type previewPage struct {
+	Title        string
+	Short        string
+	Destination  string
+	CreatedAt    time.Time
+	Interstitial bool
+}
+
+// PreviewShortURLHandler renders page with destination of short url instead of redirecting to it.
+func (h *Shortener) PreviewShortURLHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	urlItem, ok := h.getActiveRecord(w, r)
+	if !ok {
+		return
+	}
+	if urlItem.IsProtected() && !h.checkLinkPassword(w, r, urlItem) {
+		return
+	}
+	renderPreview(w, urlItem, false)
+}
+
+// getActiveRecord returns active ShortURL by id from url, responds with 404 or 410 if there is none,
+// link with no clicks left is gone as well as deleted one.
+func (h *Shortener) getActiveRecord(w http.ResponseWriter, r *http.Request) (entities.ShortURL, bool) {
+	domain := utils.RequestDomain(r.Host)
+	urlItem, exist, err := h.Repo.GetByID(r.Context(), entities.ShortURLKey(domain, chi.URLParam(r, "id")))
+	if exist && urlItem.Domain != domain {
+		// Link on custom domain is not reachable on other hosts by its full id.
+		exist = false
+	}
+	if !exist || (err != nil && errors.Is(err, sql.ErrNoRows)) {
+		http.Error(w, config.NoURLFoundByID, http.StatusNotFound)
+		return urlItem, false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return urlItem, false
+	}
+	if !urlItem.IsActive || urlItem.IsExhausted() {
+		w.WriteHeader(http.StatusGone)
+		return urlItem, false
+	}
+	return urlItem, true
+}
+
+// useClick takes one of clicks left of ShortURL created with max clicks, responds with 410 if there are none.
+func (h *Shortener) useClick(w http.ResponseWriter, r *http.Request, urlItem entities.ShortURL) bool {
+	_, err := h.Repo.UseClick(r.Context(), urlItem.ID)
+	if errors.Is(err, shortenerrors.ErrClicksExhausted) {
+		w.WriteHeader(http.StatusGone)
+		return false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+// needsInterstitial reports whether warning page has to be shown before redirect to destination,
+// it is forced for domains out of allowlist when the allowlist is configured.
+func needsInterstitial(urlItem entities.ShortURL) bool {
+	if urlItem.Interstitial {
+		return true
+	}
+	allowed := config.Settings.InterstitialAllowed
+	return len(allowed) > 0 && !utils.IsAllowedDomain(urlItem.Original, allowed)
+}
+
+// renderPreview responds with preview page of ShortURL, interstitial one warns about leaving the site.
+func renderPreview(w http.ResponseWriter, urlItem entities.ShortURL, interstitial bool) {
+	var page bytes.Buffer
+	err := previewTemplate.Execute(&page, previewPage{
+		Title:        urlItem.Title,
+		Short:        utils.GenerateDomainResultURL(urlItem.Domain, urlItem.Slug()),
+		Destination:  urlItem.Original,
+		CreatedAt:    urlItem.CreatedAt,
+		Interstitial: interstitial,
+	})
+	if err != nil {
+		http.Error(w, config.UnknownError, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	w.Write(page.Bytes())
+}
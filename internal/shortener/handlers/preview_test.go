@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/config"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/middlewares"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/repositories"
+	tLoc "github.com/RomanAVolodin/go-url-shortener/internal/shortener/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreviewAndInterstitial(t *testing.T) {
+	defer func(settings config.AppSettings) { config.Settings = settings }(config.Settings)
+	titled := entities.ShortURL{
+		ID: "titled", Original: "https://go.dev/doc", UserID: tLoc.UserIDFixture, IsActive: true,
+		CreatedAt:   time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC),
+		LinkOptions: entities.LinkOptions{Title: "<b>Docs</b>"},
+	}
+	flagged := entities.ShortURL{
+		ID: "flagged", Original: "https://mail.ru", UserID: tLoc.UserIDFixture, IsActive: true,
+		LinkOptions: entities.LinkOptions{Interstitial: true},
+	}
+	inactive := entities.ShortURL{ID: "inactive", Original: "https://ya.ru/inactive", UserID: tLoc.UserIDFixture}
+	repo := &repositories.InMemoryRepository{
+		Storage: map[string]entities.ShortURL{
+			tLoc.ShortURLFixture.ID: tLoc.ShortURLFixture,
+			titled.ID:               titled,
+			flagged.ID:              flagged,
+			inactive.ID:             inactive,
+		},
+	}
+	h := NewShortener(repo)
+
+	tests := []struct {
+		name         string
+		url          string
+		allowed      []string
+		statusCode   int
+		location     string
+		bodyParts    []string
+		notBodyParts []string
+	}{
+		{
+			name:       "Preview should show destination, title and creation date",
+			url:        "/" + titled.ID + "+",
+			statusCode: http.StatusOK,
+			bodyParts: []string{
+				titled.Original, "&lt;b&gt;Docs&lt;/b&gt;", "8 March 2024", config.Settings.BaseURL + "/" + titled.ID,
+			},
+			notBodyParts: []string{"<b>Docs</b>", "external site"},
+		},
+		{
+			name:       "Link without interstitial should redirect",
+			url:        "/" + titled.ID,
+			statusCode: http.StatusTemporaryRedirect,
+			location:   titled.Original,
+		},
+		{
+			name:       "Link with interstitial should show warning",
+			url:        "/" + flagged.ID,
+			statusCode: http.StatusOK,
+			bodyParts:  []string{flagged.Original, "external site"},
+		},
+		{
+			name:       "Link out of allowlist should show warning",
+			url:        "/" + titled.ID,
+			allowed:    []string{"ya.ru"},
+			statusCode: http.StatusOK,
+			bodyParts:  []string{titled.Original, "external site"},
+		},
+		{
+			name:       "Link from allowlist should redirect",
+			url:        "/" + tLoc.ShortURLFixture.ID,
+			allowed:    []string{"ya.ru"},
+			statusCode: http.StatusTemporaryRedirect,
+			location:   tLoc.ShortURLFixture.Original,
+		},
+		{
+			name:       "Preview of unknown link should not be found",
+			url:        "/unknown+",
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "Preview of inactive link should be gone",
+			url:        "/" + inactive.ID + "+",
+			statusCode: http.StatusGone,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Settings.InterstitialAllowed = tt.allowed
+			request := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, request)
+			res := w.Result()
+			defer res.Body.Close()
+			body, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.statusCode, res.StatusCode)
+			assert.Equal(t, tt.location, res.Header.Get("Location"))
+			if tt.statusCode == http.StatusOK {
+				assert.Equal(t, "text/html; charset=utf-8", res.Header.Get("Content-Type"))
+			}
+			for _, part := range tt.bodyParts {
+				assert.Contains(t, string(body), part)
+			}
+			for _, part := range tt.notBodyParts {
+				assert.NotContains(t, string(body), part)
+			}
+		})
+	}
+}
+
+func TestCreateWithLinkOptions(t *testing.T) {
+	repo := &repositories.InMemoryRepository{Storage: map[string]entities.ShortURL{}}
+	h := NewShortener(repo)
+	send := func(url, body string) int {
+		request := httptest.NewRequest(http.MethodPost, url, strings.NewReader(body))
+		request.AddCookie(&http.Cookie{
+			Name:  middlewares.CookieName,
+			Value: middlewares.GenerateCookieStringForUserID(tLoc.UserIDFixture),
+		})
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, request)
+		res := w.Result()
+		defer res.Body.Close()
+		return res.StatusCode
+	}
+
+	assert.Equal(t, http.StatusCreated, send("/api/shorten", `{"url": "https://go.dev", "title": "Go", "interstitial": true}`))
+	assert.Equal(t, http.StatusCreated, send("/api/shorten/batch", `[{"correlation_id": "1", "original_url": "https://ya.ru", "title": "Ya"}]`))
+	tooLong := strings.Repeat("a", entities.MaxTitleLength+1)
+	assert.Equal(t, http.StatusUnprocessableEntity, send("/api/shorten", `{"url": "https://mail.ru", "title": "`+tooLong+`"}`))
+	assert.Equal(t, http.StatusUnprocessableEntity, send("/api/shorten/batch", `[{"original_url": "https://mail.ru", "title": "`+tooLong+`"}]`))
+
+	options := make(map[string]entities.LinkOptions)
+	for _, url := range repo.Storage {
+		options[url.Original] = url.LinkOptions
+	}
+	assert.Equal(t, map[string]entities.LinkOptions{
+		"https://go.dev": {Title: "Go", Interstitial: true},
+		"https://ya.ru":  {Title: "Ya"},
+	}, options)
+}
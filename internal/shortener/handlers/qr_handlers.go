@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/config"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/utils"
+)
+
+// qrCodeCacheControl QR code depends on short url only, so it is cached and revalidated with ETag.
+const qrCodeCacheControl = "public, max-age=3600"
+
+var qrCodeContentTypes = map[string]string{
+	utils.QRCodeFormatPNG: "image/png",
+	utils.QRCodeFormatSVG: "image/svg+xml",
+}
+
+// QRCodeHandler renders QR code of short url, missing and inactive links are reported as by RetrieveShortURLHandler.
+//
+// Image is configured with `format` (png or svg), `size` in pixels and `ecc` level (L, M, Q or H) query params.
+func (h *Shortener) QRCodeHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	query := r.URL.Query()
+	options := utils.QRCodeOptions{Format: query.Get("format"), ECC: query.Get("ecc")}
+	if rawSize := query.Get("size"); rawSize != "" {
+		size, err := strconv.Atoi(rawSize)
+		if err != nil {
+			http.Error(w, config.InvalidQRCodeSize, http.StatusBadRequest)
+			return
+		}
+		options.Size = size
+	}
+	if err := options.Normalize(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	urlItem, ok := h.getActiveRecord(w, r)
+	if !ok {
+		return
+	}
+
+	content := utils.GenerateDomainResultURL(urlItem.Domain, urlItem.Slug())
+	etag := qrCodeETag(content, options)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", qrCodeCacheControl)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	image, err := utils.GenerateQRCode(content, options)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", qrCodeContentTypes[options.Format])
+	w.WriteHeader(http.StatusOK)
+	w.Write(image)
+}
+
+// qrCodeETag strong ETag of QR code image, it changes only with encoded content or options.
+func qrCodeETag(content string, options utils.QRCodeOptions) string {
+	hash := sha256.Sum256([]byte(
+		strings.Join([]string{content, options.Format, strconv.Itoa(options.Size), options.ECC}, "\n"),
+	))
+	return `"` + hex.EncodeToString(hash[:16]) + `"`
+}
+
+// etagMatches reports whether If-None-Match header lists etag.
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
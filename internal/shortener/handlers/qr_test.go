@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"bytes"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/repositories"
+	tLoc "github.com/RomanAVolodin/go-url-shortener/internal/shortener/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQRCodeHandler(t *testing.T) {
+	inactive := entities.ShortURL{ID: "inactive", Original: "https://mail.ru", UserID: tLoc.UserIDFixture}
+	repo := &repositories.InMemoryRepository{
+		Storage: map[string]entities.ShortURL{
+			tLoc.ShortURLFixture.ID: tLoc.ShortURLFixture,
+			inactive.ID:             inactive,
+		},
+	}
+	h := NewShortener(repo)
+	send := func(url string, ifNoneMatch string) *http.Response {
+		request := httptest.NewRequest(http.MethodGet, url, nil)
+		if ifNoneMatch != "" {
+			request.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, request)
+		return w.Result()
+	}
+
+	tests := []struct {
+		name        string
+		url         string
+		statusCode  int
+		contentType string
+		bodyPrefix  string
+	}{
+		{
+			name:        "png by default",
+			url:         "/api/qr/" + tLoc.ShortURLFixture.ID,
+			statusCode:  http.StatusOK,
+			contentType: "image/png",
+			bodyPrefix:  "\x89PNG",
+		},
+		{
+			name:        "svg with options",
+			url:         "/api/qr/" + tLoc.ShortURLFixture.ID + "?format=svg&size=512&ecc=h",
+			statusCode:  http.StatusOK,
+			contentType: "image/svg+xml",
+			bodyPrefix:  `<svg xmlns="http://www.w3.org/2000/svg" width="512"`,
+		},
+		{
+			name:       "unknown link",
+			url:        "/api/qr/unknown",
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "inactive link",
+			url:        "/api/qr/" + inactive.ID,
+			statusCode: http.StatusGone,
+		},
+		{
+			name:       "unknown format",
+			url:        "/api/qr/" + tLoc.ShortURLFixture.ID + "?format=gif",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "too big size",
+			url:        "/api/qr/" + tLoc.ShortURLFixture.ID + "?size=100000",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "size is not a number",
+			url:        "/api/qr/" + tLoc.ShortURLFixture.ID + "?size=big",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown ecc",
+			url:        "/api/qr/" + tLoc.ShortURLFixture.ID + "?ecc=X",
+			statusCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := send(tt.url, "")
+			defer res.Body.Close()
+			body, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.statusCode, res.StatusCode)
+			if tt.statusCode != http.StatusOK {
+				assert.Empty(t, res.Header.Get("ETag"))
+				return
+			}
+			assert.Equal(t, tt.contentType, res.Header.Get("Content-Type"))
+			assert.True(t, bytes.HasPrefix(body, []byte(tt.bodyPrefix)))
+			assert.NotEmpty(t, res.Header.Get("Cache-Control"))
+			etag := res.Header.Get("ETag")
+			require.NotEmpty(t, etag)
+
+			notModified := send(tt.url, `"other", `+etag)
+			defer notModified.Body.Close()
+			assert.Equal(t, http.StatusNotModified, notModified.StatusCode)
+			assert.Equal(t, etag, notModified.Header.Get("ETag"))
+		})
+	}
+
+	res := send("/api/qr/"+tLoc.ShortURLFixture.ID+"?size=300", "")
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	image, err := png.Decode(bytes.NewReader(body))
+	require.NoError(t, err)
+	assert.Equal(t, 300, image.Bounds().Dx())
+
+	other := send("/api/qr/"+tLoc.ShortURLFixture.ID+"?size=300&ecc=L", "")
+	defer other.Body.Close()
+	assert.NotEqual(t, res.Header.Get("ETag"), other.Header.Get("ETag"))
+}
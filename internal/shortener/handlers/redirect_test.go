@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/config"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/middlewares"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/repositories"
+	tLoc "github.com/RomanAVolodin/go-url-shortener/internal/shortener/tests"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedirectTypes(t *testing.T) {
+	defer func(settings config.AppSettings) { config.Settings = settings }(config.Settings)
+	config.Settings.PermanentCacheAge = time.Hour
+	withType := func(id string, redirectType int) entities.ShortURL {
+		return entities.ShortURL{
+			ID: id, Original: "https://ya.ru/" + id, UserID: tLoc.UserIDFixture, IsActive: true,
+			LinkOptions: entities.LinkOptions{RedirectType: redirectType},
+		}
+	}
+	repo := &repositories.InMemoryRepository{Storage: map[string]entities.ShortURL{}}
+	for _, url := range []entities.ShortURL{
+		withType("default", 0),
+		withType("moved", http.StatusMovedPermanently),
+		withType("found", http.StatusFound),
+		withType("permanent", http.StatusPermanentRedirect),
+	} {
+		repo.Storage[url.ID] = url
+	}
+	h := NewShortener(repo)
+
+	tests := []struct {
+		name          string
+		id            string
+		defaultType   int
+		statusCode    int
+		cacheControl  string
+		createRequest string
+	}{
+		{
+			name:         "Default redirect type should be used",
+			id:           "default",
+			defaultType:  http.StatusTemporaryRedirect,
+			statusCode:   http.StatusTemporaryRedirect,
+			cacheControl: "private, no-cache",
+		},
+		{
+			name:         "Changed default redirect type should be used",
+			id:           "default",
+			defaultType:  http.StatusMovedPermanently,
+			statusCode:   http.StatusMovedPermanently,
+			cacheControl: "public, max-age=3600",
+		},
+		{
+			name:         "Moved permanently should be cached",
+			id:           "moved",
+			defaultType:  http.StatusTemporaryRedirect,
+			statusCode:   http.StatusMovedPermanently,
+			cacheControl: "public, max-age=3600",
+		},
+		{
+			name:         "Permanent redirect should be cached",
+			id:           "permanent",
+			defaultType:  http.StatusTemporaryRedirect,
+			statusCode:   http.StatusPermanentRedirect,
+			cacheControl: "public, max-age=3600",
+		},
+		{
+			name:         "Found should not be cached",
+			id:           "found",
+			defaultType:  http.StatusPermanentRedirect,
+			statusCode:   http.StatusFound,
+			cacheControl: "private, no-cache",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Settings.RedirectType = tt.defaultType
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+tt.id, nil))
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.statusCode, res.StatusCode)
+			assert.Equal(t, tt.cacheControl, res.Header.Get("Cache-Control"))
+			assert.Equal(t, repo.Storage[tt.id].Original, res.Header.Get("Location"))
+		})
+	}
+}
+
+func TestCreateWithRedirectOptions(t *testing.T) {
+	repo := &repositories.InMemoryRepository{Storage: map[string]entities.ShortURL{}}
+	h := NewShortener(repo)
+	for body, statusCode := range map[string]int{
+		`{"url": "https://ya.ru", "redirect_type": 308}`:   http.StatusCreated,
+		`{"url": "https://mail.ru", "redirect_type": 200}`: http.StatusUnprocessableEntity,
+		`{"url": "https://go.dev", "redirect_type": 304}`:  http.StatusUnprocessableEntity,
+		`{"url": "https://go.dev", "passthrough": "all"}`:  http.StatusUnprocessableEntity,
+	} {
+		request := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(body))
+		request.AddCookie(&http.Cookie{
+			Name:  middlewares.CookieName,
+			Value: middlewares.GenerateCookieStringForUserID(tLoc.UserIDFixture),
+		})
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, request)
+		res := w.Result()
+		res.Body.Close()
+		assert.Equal(t, statusCode, res.StatusCode, body)
+	}
+	assert.Len(t, repo.Storage, 1)
+	for _, url := range repo.Storage {
+		assert.Equal(t, http.StatusPermanentRedirect, url.RedirectType)
+	}
+}
+
+func TestRedirectPassthrough(t *testing.T) {
+	plain := entities.ShortURL{ID: "plain", Original: "https://ya.ru/?from=link", UserID: tLoc.UserIDFixture, IsActive: true}
+	passing := entities.ShortURL{
+		ID: "passing", Original: "https://shop.ru/catalog?from=link", UserID: tLoc.UserIDFixture, IsActive: true,
+		LinkOptions: entities.LinkOptions{Passthrough: entities.PassthroughOverride},
+	}
+	repo := &repositories.InMemoryRepository{Storage: map[string]entities.ShortURL{plain.ID: plain, passing.ID: passing}}
+	h := NewShortener(repo)
+
+	tests := []struct {
+		url        string
+		statusCode int
+		location   string
+	}{
+		{url: "/plain?utm_source=x", statusCode: http.StatusTemporaryRedirect, location: plain.Original},
+		{url: "/plain/product/42", statusCode: http.StatusNotFound},
+		{url: "/passing", statusCode: http.StatusTemporaryRedirect, location: passing.Original},
+		{
+			url:        "/passing/product/42?from=mail&utm_source=x",
+			statusCode: http.StatusTemporaryRedirect,
+			location:   "https://shop.ru/catalog/product/42?from=mail&utm_source=x",
+		},
+		{
+			url:        "/passing/a%2Fb/c%20d",
+			statusCode: http.StatusTemporaryRedirect,
+			location:   "https://shop.ru/catalog/a%2Fb/c%20d?from=link",
+		},
+		{url: "/passing/a/../../admin", statusCode: http.StatusBadRequest},
+		{url: "/unknown/product", statusCode: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.statusCode, res.StatusCode)
+			assert.Equal(t, tt.location, res.Header.Get("Location"))
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/middlewares"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/repositories"
+	tLoc "github.com/RomanAVolodin/go-url-shortener/internal/shortener/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	iPhoneUserAgent  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148"
+	androidUserAgent = "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 Chrome/123.0 Mobile Safari/537.36"
+)
+
+// countriesStub CountryResolver with countries of known IP addresses.
+type countriesStub map[string]string
+
+func (stub countriesStub) Country(ip net.IP) (string, error) {
+	country, ok := stub[ip.String()]
+	if !ok {
+		return "", errors.New("unknown address")
+	}
+	return country, nil
+}
+
+func TestRoutingRules(t *testing.T) {
+	routed := entities.ShortURL{
+		ID: "routed", Original: "https://example.com", UserID: tLoc.UserIDFixture, IsActive: true,
+		LinkOptions: entities.LinkOptions{Rules: entities.RoutingRules{
+			{Platform: entities.PlatformIOS, URL: "https://apps.apple.com/app/id1"},
+			{Platform: entities.PlatformAndroid, URL: "https://play.google.com/store/apps/details?id=app"},
+			{Languages: []string{"de"}, Countries: []string{"AT"}, URL: "https://example.com/at"},
+			{Languages: []string{"pt-BR"}, URL: "https://example.com/br"},
+			{Countries: []string{"de"}, URL: "https://example.com/de"},
+		}},
+	}
+	repo := &repositories.InMemoryRepository{Storage: map[string]entities.ShortURL{routed.ID: routed}}
+	h := NewShortener(repo)
+	h.Countries = countriesStub{"10.0.0.1": "DE", "10.0.0.2": "AT"}
+
+	tests := []struct {
+		name           string
+		userAgent      string
+		acceptLanguage string
+		remoteAddr     string
+		location       string
+	}{
+		{name: "iOS should go to App Store", userAgent: iPhoneUserAgent, location: "https://apps.apple.com/app/id1"},
+		{
+			name:      "Android should go to Play",
+			userAgent: androidUserAgent, remoteAddr: "10.0.0.1:1234",
+			location: "https://play.google.com/store/apps/details?id=app",
+		},
+		{name: "Everyone else should go to web", location: routed.Original},
+		{
+			name:           "Rule should match every condition",
+			acceptLanguage: "de-AT, en;q=0.5", remoteAddr: "10.0.0.2:1234",
+			location: "https://example.com/at",
+		},
+		{
+			name:           "Rule should not match part of conditions",
+			acceptLanguage: "de-AT", remoteAddr: "10.0.0.3:1234",
+			location: routed.Original,
+		},
+		{name: "Regional language should match exactly", acceptLanguage: "pt-BR", location: "https://example.com/br"},
+		{name: "Other region should not match", acceptLanguage: "pt-PT", location: routed.Original},
+		{name: "Country should match", remoteAddr: "10.0.0.1:1234", location: "https://example.com/de"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/"+routed.ID, nil)
+			request.Header.Set("User-Agent", tt.userAgent)
+			request.Header.Set("Accept-Language", tt.acceptLanguage)
+			if tt.remoteAddr != "" {
+				request.RemoteAddr = tt.remoteAddr
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, request)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, http.StatusTemporaryRedirect, res.StatusCode)
+			assert.Equal(t, tt.location, res.Header.Get("Location"))
+			assert.Equal(t, "User-Agent, Accept-Language", res.Header.Get("Vary"))
+			assert.Equal(t, "private, no-cache", res.Header.Get("Cache-Control"))
+		})
+	}
+}
+
+func TestCreateWithRoutingRules(t *testing.T) {
+	repo := &repositories.InMemoryRepository{Storage: map[string]entities.ShortURL{}}
+	h := NewShortener(repo)
+	send := func(body string) int {
+		request := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(body))
+		request.AddCookie(&http.Cookie{
+			Name:  middlewares.CookieName,
+			Value: middlewares.GenerateCookieStringForUserID(tLoc.UserIDFixture),
+		})
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, request)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusCreated, send(
+		`{"url": "https://go.dev", "utm": {"source": "qr"}, "rules": [{"platform": "ios", "url": "https://apps.apple.com/app/id1"}]}`,
+	))
+	for _, invalid := range []string{
+		`{"url": "https://ya.ru", "rules": [{"platform": "ios", "url": "ftp://ya.ru"}]}`,
+		`{"url": "https://ya.ru", "rules": [{"platform": "symbian", "url": "https://ya.ru/s"}]}`,
+		`{"url": "https://ya.ru", "rules": [{"countries": ["RUS"], "url": "https://ya.ru/s"}]}`,
+		`{"url": "https://ya.ru", "rules": [{"url": "https://ya.ru/s"}]}`,
+	} {
+		assert.Equal(t, http.StatusUnprocessableEntity, send(invalid), invalid)
+	}
+
+	require.Len(t, repo.Storage, 1)
+	for _, url := range repo.Storage {
+		assert.Equal(t, entities.RoutingRules{
+			{Platform: entities.PlatformIOS, URL: "https://apps.apple.com/app/id1?utm_source=qr"},
+		}, url.Rules)
+	}
+}
+
+func TestRoutingRulesDatabaseRepository(t *testing.T) {
+	routed := tLoc.ShortURLFixture
+	routed.Rules = entities.RoutingRules{{Platform: entities.PlatformAndroid, URL: "https://play.google.com"}}
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	mock.ExpectQuery(tLoc.ShortURLSelectQuery).
+		WillReturnRows(sqlmock.NewRows(tLoc.ShortURLColumns).AddRow(tLoc.ShortURLRow(routed)...))
+
+	h := NewShortener(&repositories.DatabaseRepository{Storage: db})
+	request := httptest.NewRequest(http.MethodGet, "/"+routed.ID, nil)
+	request.Header.Set("User-Agent", androidUserAgent)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, request)
+
+	assert.Equal(t, "https://play.google.com", w.Header().Get("Location"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -5,8 +5,10 @@ import (
 	"net/http"
 
 	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/config"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
 	mw "github.com/RomanAVolodin/go-url-shortener/internal/shortener/middlewares"
 	repo "github.com/RomanAVolodin/go-url-shortener/internal/shortener/repositories"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/utils"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 )
@@ -17,6 +19,11 @@ import (
 type Shortener struct {
 	*chi.Mux
 	Repo repo.IRepository
+	// Countries resolves country of client for routing rules, rules with countries never match if it is nil.
+	Countries utils.CountryResolver
+
+	linkAttempts *attemptLimiter
+	ipAttempts   *attemptLimiter
 }
 
 // NewShortener creates new Shortener instance with all needed.
@@ -24,6 +31,9 @@ func NewShortener(repo repo.IRepository) *Shortener {
 	h := &Shortener{
 		Mux:  chi.NewMux(),
 		Repo: repo,
+
+		linkAttempts: newAttemptLimiter(config.Settings.PasswordLinkLimit, config.Settings.PasswordLimitWindow),
+		ipAttempts:   newAttemptLimiter(config.Settings.PasswordIPLimit, config.Settings.PasswordLimitWindow),
 	}
 	h.Use(middleware.RequestID)
 	h.Use(middleware.RealIP)
@@ -33,14 +43,60 @@ func NewShortener(repo repo.IRepository) *Shortener {
 	h.Use(middleware.Recoverer)
 	h.Use(mw.GzipMiddleware)
 	h.Use(mw.RequestUnzip)
+	h.Use(mw.APIKeyAuth(repo))
 	h.Use(mw.AuthCookie)
 
+	canCreate := mw.RequireScope(entities.ScopeCreate)
+	canRead := mw.RequireScope(entities.ScopeRead)
+	canDelete := mw.RequireScope(entities.ScopeDelete)
+
 	h.Get("/{id}", h.RetrieveShortURLHandler)
-	h.Post("/", h.CreateShortURLHandler)
-	h.Post("/api/shorten", h.CreateJSONShortURLHandler)
-	h.Post("/api/shorten/batch", h.CreateMultipleShortURLHandler)
-	h.Get("/api/user/urls", h.GetUsersRecordsHandler)
-	h.Delete("/api/user/urls", h.DeleteRecordsHandler)
+	h.Get("/{id}+", h.PreviewShortURLHandler)
+	h.Get("/{id}/*", h.RetrieveShortURLHandler)
+	h.Post("/{id}", h.RetrieveShortURLHandler)
+	h.Post("/{id}+", h.PreviewShortURLHandler)
+	h.Post("/{id}/*", h.RetrieveShortURLHandler)
+	h.Get("/api/qr/{id}", h.QRCodeHandler)
+	h.Get("/api/domains", h.GetDomainsHandler)
+	h.With(canCreate).Post("/", h.CreateShortURLHandler)
+	h.With(canCreate).Post("/api/shorten", h.CreateJSONShortURLHandler)
+	h.With(canCreate).Post("/api/shorten/batch", h.CreateMultipleShortURLHandler)
+	h.With(canRead).Get("/api/user/urls", h.GetUsersRecordsHandler)
+	h.With(canDelete).Delete("/api/user/urls", h.DeleteRecordsHandler)
+	h.With(canDelete).Get("/api/user/urls/delete/{job}", h.GetDeleteJobHandler)
+	h.With(canRead).Get("/api/user/urls/deleted", h.GetDeletedRecordsHandler)
+	h.With(canRead).Get("/api/user/urls/export", h.ExportRecordsHandler)
+	h.With(canDelete).Post("/api/user/urls/restore", h.RestoreRecordsHandler)
+	h.With(canCreate).Post("/api/user/urls/tags", h.AddTagsHandler)
+	h.With(canCreate).Delete("/api/user/urls/tags", h.RemoveTagsHandler)
+	h.With(canCreate).Patch("/api/user/urls/{id}", h.UpdateRecordHandler)
+	h.With(canRead).Get("/api/user/urls/{id}/history", h.GetRecordHistoryHandler)
+	h.With(canRead).Get("/api/user/urls/{id}/variants", h.GetVariantsHandler)
+	h.With(canCreate).Put("/api/user/urls/{id}/variants", h.UpdateVariantsHandler)
+	h.With(canCreate).Post("/api/workspaces", h.CreateWorkspaceHandler)
+	h.With(canRead).Get("/api/workspaces", h.GetWorkspacesHandler)
+	h.With(canRead).Get("/api/workspaces/{id}/members", h.GetWorkspaceMembersHandler)
+	h.With(canCreate).Put("/api/workspaces/{id}/members/{userID}", h.SetWorkspaceMemberHandler)
+	h.With(canDelete).Delete("/api/workspaces/{id}/members/{userID}", h.RemoveWorkspaceMemberHandler)
+	h.Post("/api/user/keys", h.CreateAPIKeyHandler)
+	h.Get("/api/user/keys", h.GetAPIKeysHandler)
+	h.Delete("/api/user/keys/{id}", h.RevokeAPIKeyHandler)
+	h.Post("/api/user/register", h.RegisterHandler)
+	h.Post("/api/user/login", h.LoginHandler)
+	h.Post("/api/user/claim", h.ClaimHandler)
+	h.Route("/api/admin", func(r chi.Router) {
+		r.Use(mw.AdminOnly)
+		r.Get("/urls", h.AdminFindURLHandler)
+		r.Get("/urls/{id}", h.AdminGetURLHandler)
+		r.Post("/urls/{id}/deactivate", h.AdminDeactivateURLHandler)
+		r.Post("/urls/{id}/reactivate", h.AdminReactivateURLHandler)
+		r.Put("/urls/{id}/owner", h.AdminSetOwnerHandler)
+		r.Get("/users/{userID}/urls", h.AdminGetUserURLsHandler)
+		r.Get("/audit", h.AdminGetAuditLogHandler)
+		r.Get("/delete-jobs/dead-letters", h.AdminGetDeadLetterDeleteJobsHandler)
+		r.Post("/import", h.AdminImportHandler)
+		r.Get("/migration", h.AdminGetMigrationHandler)
+	})
 	h.Get("/ping", h.PingDatabase)
 	h.MethodNotAllowed(func(writer http.ResponseWriter, request *http.Request) {
 		http.Error(writer, config.OnlyGetPostRequestAllowedError, http.StatusMethodNotAllowed)
@@ -5,8 +5,14 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"log"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/config"
@@ -21,6 +27,53 @@ import (
 	"github.com/lithammer/shortuuid"
 )
 
+// errInvalidURL returned for destination which can't be shortened.
+var errInvalidURL = errors.New(config.InvalidURL)
+
+// prepareLink checks options given by user, returned destination and destinations
+// of routing rules and variants have UTM params merged in.
+func prepareLink(original string, options *entities.LinkOptions) (string, error) {
+	destinations := optionDestinations(options)
+	for _, destination := range destinations {
+		if !utils.IsValidURL(*destination) {
+			return "", errInvalidURL
+		}
+	}
+	domain, ok := utils.NormalizeDomain(options.Domain)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", shortenerrors.ErrInvalidLinkOptions, config.UnknownDomain)
+	}
+	options.Domain = domain
+	options.Variants.SetDefaultIDs()
+	if err := options.Validate(); err != nil {
+		return "", err
+	}
+	if options.UTM.IsEmpty() {
+		options.UTM = nil
+		return original, nil
+	}
+	for _, destination := range append(destinations, &original) {
+		withUTM, err := utils.AddUTM(*destination, options.UTM)
+		if err != nil || len(withUTM) > utils.MaxURLLength {
+			return "", errInvalidURL
+		}
+		*destination = withUTM
+	}
+	return original, nil
+}
+
+// optionDestinations returns pointers to destinations of routing rules and variants given in options.
+func optionDestinations(options *entities.LinkOptions) []*string {
+	destinations := make([]*string, 0, len(options.Rules)+len(options.Variants))
+	for i := range options.Rules {
+		destinations = append(destinations, &options.Rules[i].URL)
+	}
+	for i := range options.Variants {
+		destinations = append(destinations, &options.Variants[i].URL)
+	}
+	return destinations
+}
+
 // CreateJSONShortURLHandler handles POST request with json DTO.
 func (h *Shortener) CreateJSONShortURLHandler(
 	w http.ResponseWriter,
@@ -35,10 +88,28 @@ func (h *Shortener) CreateJSONShortURLHandler(
 		http.Error(w, config.BadInputData, http.StatusUnprocessableEntity)
 		return
 	}
+	original, err := prepareLink(createDTO.URL, &createDTO.LinkOptions)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err = entities.ValidatePassword(createDTO.Password); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if !h.canCreateIn(w, r, createDTO.WorkspaceID) {
+		return
+	}
 
 	userID := r.Context().Value(middlewares.UserIDKey).(uuid.UUID)
 
-	shortURL, statusCode, err := h.saveToRepository(r.Context(), createDTO.URL, userID)
+	shortURL, statusCode, err := h.saveToRepository(
+		r.Context(),
+		original,
+		createDTO.LinkOptions,
+		createDTO.Password,
+		userID,
+	)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -69,6 +140,25 @@ func (h *Shortener) CreateMultipleShortURLHandler(
 		http.Error(w, config.BadInputData, http.StatusUnprocessableEntity)
 		return
 	}
+	for i := range incomingDTOs {
+		original, err := prepareLink(incomingDTOs[i].Original, &incomingDTOs[i].LinkOptions)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		if err = entities.ValidatePassword(incomingDTOs[i].Password); err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		incomingDTOs[i].Original = original
+	}
+	checked := make(map[string]bool)
+	for _, item := range incomingDTOs {
+		if !checked[item.WorkspaceID] && !h.canCreateIn(w, r, item.WorkspaceID) {
+			return
+		}
+		checked[item.WorkspaceID] = true
+	}
 
 	userID := r.Context().Value(middlewares.UserIDKey).(uuid.UUID)
 
@@ -100,10 +190,9 @@ func (h *Shortener) CreateShortURLHandler(
 	if doneWithError {
 		return
 	}
-
 	userID := r.Context().Value(middlewares.UserIDKey).(uuid.UUID)
 
-	shortURL, statusCode, err := h.saveToRepository(r.Context(), string(urlToEncode), userID)
+	shortURL, statusCode, err := h.saveToRepository(r.Context(), string(urlToEncode), entities.LinkOptions{}, "", userID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -118,37 +207,169 @@ func (h *Shortener) CreateShortURLHandler(
 }
 
 // RetrieveShortURLHandler returns short url by it`s id.
+//
+// Path after id and query params are passed through to destination of links created with passthrough policy.
+// Links with routing rules redirect to destination of the first rule matching platform, language or country of client.
+// Split links redirect to one of their variants picked by weight if no rule matches, the variant is recorded as click.
+// Links protected with password redirect only after the password is submitted with form or X-Link-Password header.
 func (h *Shortener) RetrieveShortURLHandler(
 	w http.ResponseWriter,
 	r *http.Request,
 ) {
-	urlID := chi.URLParam(r, "id")
-	urlItem, exist, err := h.Repo.GetByID(r.Context(), urlID)
-
-	if exist && err == nil {
-		if !urlItem.IsActive {
-			w.WriteHeader(http.StatusGone)
+	urlItem, ok := h.getActiveRecord(w, r)
+	if !ok {
+		return
+	}
+	segments, err := passedSegments(r)
+	if err != nil {
+		http.Error(w, config.BadInputData, http.StatusBadRequest)
+		return
+	}
+	routed := false
+	if len(urlItem.Rules) > 0 {
+		w.Header().Set("Vary", "User-Agent, Accept-Language")
+		routed = h.routeDestination(r, &urlItem)
+	}
+	var variant entities.Variant
+	var remember bool
+	if !routed && len(urlItem.Variants) > 0 {
+		variant, remember = pickVariant(r, urlItem)
+		urlItem.Original = variant.URL
+	}
+	if urlItem.Passthrough == "" && len(segments) > 0 {
+		http.Error(w, config.NoURLFoundByID, http.StatusNotFound)
+		return
+	}
+	if urlItem.Passthrough != "" {
+		urlItem.Original, err = utils.PassThrough(urlItem.Original, segments, r.URL.Query(), urlItem.Passthrough)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		w.Header().Set("Location", urlItem.Original)
-		w.WriteHeader(http.StatusTemporaryRedirect)
+	}
+	if urlItem.IsProtected() && !h.checkLinkPassword(w, r, urlItem) {
 		return
 	}
-	if !exist || (err != nil && errors.Is(err, sql.ErrNoRows)) {
-		http.Error(w, config.NoURLFoundByID, http.StatusNotFound)
+	// Clicks are taken and variants are remembered by interstitial page as well, it links straight to destination.
+	if urlItem.MaxClicks > 0 && !h.useClick(w, r, urlItem) {
+		return
+	}
+	if remember {
+		rememberVariant(w, urlItem, variant)
+	}
+	if variant.ID != "" {
+		h.recordClick(r, entities.Click{ShortURLID: urlItem.ID, Variant: variant.ID, ClickedAt: time.Now().UTC()})
+	}
+	if needsInterstitial(urlItem) {
+		renderPreview(w, urlItem, true)
 		return
 	}
-	http.Error(w, err.Error(), http.StatusInternalServerError)
+	status := redirectStatus(urlItem)
+	if r.Method == http.MethodPost {
+		// Password form is not submitted again when browser follows the redirect.
+		status = http.StatusSeeOther
+	}
+	w.Header().Set("Cache-Control", redirectCacheControl(urlItem, status))
+	w.Header().Set("Location", urlItem.Original)
+	w.WriteHeader(status)
+}
+
+// passedSegments returns decoded path segments following id of short url.
+func passedSegments(r *http.Request) ([]string, error) {
+	rest := chi.URLParam(r, "*")
+	if rest == "" {
+		return nil, nil
+	}
+	segments := strings.Split(rest, "/")
+	// Route params are taken from escaped path only if it differs from the default escaping.
+	if r.URL.RawPath == "" {
+		return segments, nil
+	}
+	for i, segment := range segments {
+		decoded, err := url.PathUnescape(segment)
+		if err != nil {
+			return nil, err
+		}
+		segments[i] = decoded
+	}
+	return segments, nil
+}
+
+// routeDestination sets destination of the first routing rule client matches, reports whether there is one.
+func (h *Shortener) routeDestination(r *http.Request, urlItem *entities.ShortURL) bool {
+	client := entities.Client{
+		Platform: utils.DetectPlatform(r.UserAgent()),
+		Language: utils.PreferredLanguage(r.Header.Get("Accept-Language")),
+	}
+	if h.Countries != nil && urlItem.Rules.NeedCountry() {
+		country, err := h.Countries.Country(net.ParseIP(clientIP(r)))
+		if err != nil {
+			log.Printf("Country of %s is not resolved: %v", clientIP(r), err)
+		}
+		client.Country = country
+	}
+	destination, ok := urlItem.Rules.Destination(client)
+	if ok {
+		urlItem.Original = destination
+	}
+	return ok
 }
 
-// GetUsersRecordsHandler returns all records related to current user.
+// redirectStatus returns redirect status chosen for ShortURL or the default one.
+func redirectStatus(urlItem entities.ShortURL) int {
+	if urlItem.RedirectType != 0 {
+		return urlItem.RedirectType
+	}
+	return config.Settings.RedirectType
+}
+
+// redirectCacheControl lets clients cache permanent redirects only, temporary ones are revalidated every time.
+// Redirects of links with max clicks are never cached, otherwise clicks would not be counted, and redirects
+// depending on client or picked by weight are not cached by shared caches.
+func redirectCacheControl(urlItem entities.ShortURL, status int) string {
+	if urlItem.MaxClicks > 0 {
+		return "no-store"
+	}
+	if len(urlItem.Rules) > 0 || len(urlItem.Variants) > 0 {
+		return "private, no-cache"
+	}
+	if entities.IsPermanentRedirect(status) {
+		return "public, max-age=" + strconv.Itoa(int(config.Settings.PermanentCacheAge.Seconds()))
+	}
+	return "private, no-cache"
+}
+
+// GetUsersRecordsHandler returns personal records of current user or records of workspace given as "workspace"
+// query param, the latter are listed to every member of workspace. Records are filtered by "tag" query params,
+// only ones having every one of the tags are listed.
 func (h *Shortener) GetUsersRecordsHandler(
 	w http.ResponseWriter,
 	r *http.Request,
 ) {
 	userID := r.Context().Value(middlewares.UserIDKey).(uuid.UUID)
 
-	records, err := h.Repo.GetByUserID(r.Context(), userID)
+	query := r.URL.Query()
+	workspaceID := query.Get("workspace")
+	if workspaceID != "" {
+		if _, ok := h.getMembership(w, r, workspaceID); !ok {
+			return
+		}
+	}
+	var records []entities.ShortURL
+	var err error
+	switch {
+	case query.Has("tag"):
+		tags, errTags := entities.NormalizeTags(query["tag"])
+		if errTags != nil {
+			http.Error(w, errTags.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		records, err = h.Repo.GetByTags(r.Context(), userID, workspaceID, tags)
+	case workspaceID != "":
+		records, err = h.Repo.GetByWorkspaceID(r.Context(), workspaceID)
+	default:
+		records, err = h.Repo.GetByUserID(r.Context(), userID)
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -157,9 +378,20 @@ func (h *Shortener) GetUsersRecordsHandler(
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
+	ids := make([]string, 0, len(records))
+	for _, shortURL := range records {
+		ids = append(ids, shortURL.ID)
+	}
+	tags, err := h.Repo.GetTags(r.Context(), ids)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 	responseDTOs := make([]entities.ShortURLResponseDto, 0, 8)
 	for _, shortURL := range records {
-		responseDTOs = append(responseDTOs, shortURL.ToResponseDto())
+		responseDTO := shortURL.ToResponseDto()
+		responseDTO.Tags = tags[shortURL.ID]
+		responseDTOs = append(responseDTOs, responseDTO)
 	}
 
 	jsonRecords, _ := json.Marshal(responseDTOs)
@@ -175,20 +407,33 @@ func (h *Shortener) GetUsersRecordsHandler(
 
 // PingDatabase returns Database connection status
 func (h *Shortener) PingDatabase(w http.ResponseWriter, r *http.Request) {
-	if repo, ok := h.Repo.(*repositories.DatabaseRepository); ok {
-		ctx, cancel := context.WithTimeout(r.Context(), 1*time.Second)
-		defer cancel()
+	databases := databaseRepositories(h.Repo)
+	if len(databases) == 0 {
+		http.Error(w, config.NoConnectionToDatabase, http.StatusInternalServerError)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 1*time.Second)
+	defer cancel()
 
+	for _, repo := range databases {
 		if err := repo.Storage.PingContext(ctx); err != nil {
 			http.Error(w, config.NoConnectionToDatabase, http.StatusInternalServerError)
 			return
 		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		return
+// databaseRepositories returns database storages behind repo, both of them while migrating.
+func databaseRepositories(repo repositories.IRepository) []*repositories.DatabaseRepository {
+	switch typed := repo.(type) {
+	case *repositories.DatabaseRepository:
+		return []*repositories.DatabaseRepository{typed}
+	case *repositories.DualWriteRepository:
+		return append(databaseRepositories(typed.Primary), databaseRepositories(typed.Secondary)...)
 	}
-	http.Error(w, config.NoConnectionToDatabase, http.StatusInternalServerError)
+	return nil
 }
 
 // DeleteRecordsHandler handles records deletion by it's IDs.
@@ -208,35 +453,186 @@ func (h *Shortener) DeleteRecordsHandler(
 
 	userID := r.Context().Value(middlewares.UserIDKey).(uuid.UUID)
 
-	err := h.deleteFromRepository(r.Context(), idsToDelete, userID)
+	job, err := h.deleteFromRepository(r.Context(), idsToDelete, userID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusAccepted)
+	respondWithJSON(w, job, http.StatusAccepted)
+}
+
+// GetDeleteJobHandler returns state of deletion job requested by current user.
+func (h *Shortener) GetDeleteJobHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	userID := r.Context().Value(middlewares.UserIDKey).(uuid.UUID)
+
+	job, exist, err := h.Repo.GetDeleteJob(r.Context(), chi.URLParam(r, "job"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !exist || job.UserID != userID {
+		http.Error(w, config.NoDeleteJobFoundByID, http.StatusNotFound)
+		return
+	}
+	respondWithJSON(w, job, http.StatusOK)
+}
+
+// RestoreRecordsHandler restores records deleted by current user by their IDs.
+func (h *Shortener) RestoreRecordsHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	requestBody, doneWithError := h.readBody(w, r)
+	if doneWithError {
+		return
+	}
+	var idsToRestore []string
+	if err := json.Unmarshal(requestBody, &idsToRestore); err != nil || len(idsToRestore) == 0 {
+		http.Error(w, config.BadInputData, http.StatusUnprocessableEntity)
+		return
+	}
+
+	userID := r.Context().Value(middlewares.UserIDKey).(uuid.UUID)
+
+	restored, err := h.Repo.RestoreRecords(r.Context(), userID, idsToRestore)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(restored) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	responseDTOs := make([]entities.ShortURLResponseDto, 0, len(restored))
+	for _, shortURL := range restored {
+		responseDTOs = append(responseDTOs, shortURL.ToResponseDto())
+	}
+	respondWithJSON(w, responseDTOs, http.StatusOK)
+}
+
+// GetDeletedRecordsHandler returns records deleted by current user which still may be restored.
+func (h *Shortener) GetDeletedRecordsHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	userID := r.Context().Value(middlewares.UserIDKey).(uuid.UUID)
+
+	records, err := h.Repo.GetDeletedByUserID(r.Context(), userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(records) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	responseDTOs := make([]entities.ShortURLDeletedResponseDto, 0, len(records))
+	for _, shortURL := range records {
+		responseDTOs = append(responseDTOs, shortURL.ToDeletedResponseDto())
+	}
+	respondWithJSON(w, responseDTOs, http.StatusOK)
+}
+
+// UpdateRecordHandler changes destination of record current user may edit.
+func (h *Shortener) UpdateRecordHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	requestBody, doneWithError := h.readBody(w, r)
+	if doneWithError {
+		return
+	}
+	var updateDTO entities.ShortURLUpdateDTO
+	if err := json.Unmarshal(requestBody, &updateDTO); err != nil || updateDTO.URL == "" {
+		http.Error(w, config.BadInputData, http.StatusUnprocessableEntity)
+		return
+	}
+	if !utils.IsValidURL(updateDTO.URL) {
+		http.Error(w, config.InvalidURL, http.StatusUnprocessableEntity)
+		return
+	}
+
+	userID := r.Context().Value(middlewares.UserIDKey).(uuid.UUID)
+
+	urlItem, err := h.Repo.Update(r.Context(), userID, chi.URLParam(r, "id"), updateDTO.URL)
+	switch {
+	case errors.Is(err, shortenerrors.ErrItemNotFound):
+		http.Error(w, config.NoURLFoundByID, http.StatusNotFound)
+	case errors.Is(err, shortenerrors.ErrItemAlreadyExists):
+		respondWithJSON(w, urlItem.ToResponseDto(), http.StatusConflict)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		respondWithJSON(w, urlItem.ToResponseDto(), http.StatusOK)
+	}
+}
+
+// GetRecordHistoryHandler returns previous destinations of record current user may edit, newest first.
+func (h *Shortener) GetRecordHistoryHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	urlID := chi.URLParam(r, "id")
+
+	urlItem, exist, err := h.Repo.GetByID(r.Context(), urlID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	editable := false
+	if exist {
+		editable, err = h.canEditRecord(r, urlItem)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !editable {
+		http.Error(w, config.NoURLFoundByID, http.StatusNotFound)
+		return
+	}
+	changes, err := h.Repo.GetHistory(r.Context(), urlID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondWithJSON(w, changes, http.StatusOK)
 }
 
 func (h *Shortener) deleteFromRepository(
 	ctx context.Context,
 	ids []string,
 	userID uuid.UUID,
-) error {
+) (entities.DeleteJob, error) {
 	return h.Repo.DeleteRecords(ctx, userID, ids)
 }
 
 func (h *Shortener) saveToRepository(
 	ctx context.Context,
 	urlToEncode string,
+	options entities.LinkOptions,
+	password string,
 	userID uuid.UUID,
 ) (entities.ShortURL, int, error) {
-	id := shortuuid.New()
+	passwordHash, err := hashLinkPassword(password)
+	if err != nil {
+		return entities.ShortURL{}, 0, err
+	}
+	slug := shortuuid.New()
 	shortURL := entities.ShortURL{
-		ID:       id,
-		Short:    utils.GenerateResultURL(id),
-		Original: urlToEncode,
-		UserID:   userID,
-		IsActive: true,
+		ID:           entities.ShortURLKey(options.Domain, slug),
+		Short:        utils.GenerateDomainResultURL(options.Domain, slug),
+		Original:     urlToEncode,
+		UserID:       userID,
+		IsActive:     true,
+		CreatedAt:    time.Now().UTC(),
+		PasswordHash: passwordHash,
+		ClicksLeft:   options.MaxClicks,
+		LinkOptions:  options,
 	}
 	url, err := h.Repo.Create(ctx, shortURL)
 
@@ -259,14 +655,22 @@ func (h *Shortener) saveMultipleToRepository(
 ) ([]entities.ShortURL, error) {
 	urls := make([]entities.ShortURL, 0, len(items))
 	for _, item := range items {
-		id := shortuuid.New()
+		passwordHash, err := hashLinkPassword(item.Password)
+		if err != nil {
+			return nil, err
+		}
+		slug := shortuuid.New()
 		shortURL := entities.ShortURL{
-			ID:            id,
-			Short:         utils.GenerateResultURL(id),
+			ID:            entities.ShortURLKey(item.Domain, slug),
+			Short:         utils.GenerateDomainResultURL(item.Domain, slug),
 			Original:      item.Original,
 			CorrelationID: item.CorrelationID,
 			UserID:        userID,
 			IsActive:      true,
+			CreatedAt:     time.Now().UTC(),
+			PasswordHash:  passwordHash,
+			ClicksLeft:    item.MaxClicks,
+			LinkOptions:   item.LinkOptions,
 		}
 		urls = append(urls, shortURL)
 	}
@@ -319,9 +319,9 @@ func compress(data []byte) ([]byte, error) {
 
 func TestAuthCookies(t *testing.T) {
 	type wanted struct {
-		cookieString string
-		response     string
-		code         int
+		response    string
+		code        int
+		keepsCookie bool
 	}
 	tests := []struct {
 		name         string
@@ -339,20 +339,19 @@ func TestAuthCookies(t *testing.T) {
 			requestBody: "{\"url\": \"https://mail.ru\"}",
 			repo:        &repositories.InMemoryRepository{Storage: make(map[string]entities.ShortURL)},
 			wantedResult: wanted{
-				cookieString: "",
-				code:         http.StatusCreated,
+				code: http.StatusCreated,
 			},
 		},
 		{
-			name:        "Request with auth cookie should return the same",
+			name:        "Request with valid auth cookie should not obtain a new one",
 			requestType: http.MethodPost,
 			requestURL:  "/api/shorten",
 			requestBody: "{\"url\": \"https://mail.ru\"}",
 			cookie:      middlewares.GenerateCookieStringForUserID(tLoc.UserIDFixture),
 			repo:        &repositories.InMemoryRepository{Storage: make(map[string]entities.ShortURL)},
 			wantedResult: wanted{
-				cookieString: middlewares.GenerateCookieStringForUserID(tLoc.UserIDFixture),
-				code:         http.StatusCreated,
+				code:        http.StatusCreated,
+				keepsCookie: true,
 			},
 		},
 		{
@@ -373,7 +372,8 @@ func TestAuthCookies(t *testing.T) {
 			cookie:      middlewares.GenerateCookieStringForUserID(tLoc.UserIDFixture),
 			repo:        &repositories.InMemoryRepository{Storage: make(map[string]entities.ShortURL)},
 			wantedResult: wanted{
-				code: http.StatusNoContent,
+				code:        http.StatusNoContent,
+				keepsCookie: true,
 			},
 		},
 		{
@@ -385,8 +385,9 @@ func TestAuthCookies(t *testing.T) {
 				Storage: map[string]entities.ShortURL{tLoc.ShortURLFixture.ID: tLoc.ShortURLFixture},
 			},
 			wantedResult: wanted{
-				code:     http.StatusOK,
-				response: string(tLoc.JSONStorageWithOneElement),
+				code:        http.StatusOK,
+				response:    string(tLoc.JSONStorageWithOneElement),
+				keepsCookie: true,
 			},
 		},
 	}
@@ -421,9 +422,10 @@ func TestAuthCookies(t *testing.T) {
 			resBody, _ := io.ReadAll(res.Body)
 
 			assert.Equal(t, tt.wantedResult.code, res.StatusCode)
-			assert.NotNil(t, cookieReceived)
-			if tt.wantedResult.cookieString != "" {
-				assert.Equal(t, tt.wantedResult.cookieString, cookieReceived.Value)
+			if tt.wantedResult.keepsCookie {
+				assert.Nil(t, cookieReceived)
+			} else {
+				assert.NotNil(t, cookieReceived)
 			}
 			if tt.wantedResult.response != "" {
 				assert.Equal(t, tt.wantedResult.response, strings.Trim(string(resBody), "\n"))
@@ -473,17 +475,9 @@ func TestDatabaseRepository(t *testing.T) {
 			urlString: "/some_id",
 			method:    http.MethodGet,
 			expect: func(mock sqlmock.Sqlmock) {
-				mock.ExpectQuery("SELECT id, short_url, original_url, user_id, correlation_id, is_active FROM short_urls").
+				mock.ExpectQuery(tLoc.ShortURLSelectQuery).
 					WillReturnRows(
-						sqlmock.NewRows([]string{"id", "short_url", "original_url", "user_id", "correlation_id", "is_active"}).
-							AddRow(
-								tLoc.ShortURLFixture.ID,
-								tLoc.ShortURLFixture.Short,
-								tLoc.ShortURLFixture.Original,
-								tLoc.ShortURLFixture.UserID.String(),
-								tLoc.ShortURLFixture.CorrelationID,
-								tLoc.ShortURLFixture.IsActive,
-							),
+						sqlmock.NewRows(tLoc.ShortURLColumns).AddRow(tLoc.ShortURLRow(tLoc.ShortURLFixture)...),
 					)
 			},
 			wanted: wanted{
@@ -496,7 +490,7 @@ func TestDatabaseRepository(t *testing.T) {
 			urlString: "/some_id",
 			method:    http.MethodGet,
 			expect: func(mock sqlmock.Sqlmock) {
-				mock.ExpectQuery("SELECT id, short_url, original_url, user_id, correlation_id, is_active FROM short_urls").
+				mock.ExpectQuery(tLoc.ShortURLSelectQuery).
 					WillReturnError(sql.ErrNoRows)
 			},
 			wanted: wanted{
@@ -508,18 +502,12 @@ func TestDatabaseRepository(t *testing.T) {
 			urlString: "/api/user/urls",
 			method:    http.MethodGet,
 			expect: func(mock sqlmock.Sqlmock) {
-				mock.ExpectQuery("SELECT id, short_url, original_url, user_id, correlation_id, is_active FROM short_urls").
+				mock.ExpectQuery(tLoc.ShortURLSelectQuery).
 					WillReturnRows(
-						sqlmock.NewRows([]string{"id", "short_url", "original_url", "user_id", "correlation_id", "is_active"}).
-							AddRow(
-								tLoc.ShortURLFixture.ID,
-								tLoc.ShortURLFixture.Short,
-								tLoc.ShortURLFixture.Original,
-								tLoc.ShortURLFixture.UserID.String(),
-								tLoc.ShortURLFixture.CorrelationID,
-								tLoc.ShortURLFixture.IsActive,
-							),
+						sqlmock.NewRows(tLoc.ShortURLColumns).AddRow(tLoc.ShortURLRow(tLoc.ShortURLFixture)...),
 					)
+				mock.ExpectQuery("SELECT st.short_url_id, t.name FROM short_url_tags").
+					WillReturnRows(sqlmock.NewRows([]string{"short_url_id", "name"}))
 			},
 			wanted: wanted{
 				code: http.StatusOK,
@@ -556,17 +544,10 @@ func TestDatabaseRepository(t *testing.T) {
 			expect: func(mock sqlmock.Sqlmock) {
 				mock.ExpectExec("INSERT INTO short_urls").
 					WillReturnError(&pgconn.PgError{Code: pgerrcode.UniqueViolation})
-				mock.ExpectQuery("SELECT id, short_url, original_url, user_id, correlation_id, is_active FROM short_urls").
+				mock.ExpectQuery(tLoc.ShortURLSelectQuery+` WHERE domain = \$1 AND workspace_id = \$2 AND original_url = \$3`).
+					WithArgs("", "", "https://mail.ru").
 					WillReturnRows(
-						sqlmock.NewRows([]string{"id", "short_url", "original_url", "user_id", "correlation_id", "is_active"}).
-							AddRow(
-								tLoc.ShortURLFixture.ID,
-								tLoc.ShortURLFixture.Short,
-								tLoc.ShortURLFixture.Original,
-								tLoc.ShortURLFixture.UserID.String(),
-								tLoc.ShortURLFixture.CorrelationID,
-								tLoc.ShortURLFixture.IsActive,
-							),
+						sqlmock.NewRows(tLoc.ShortURLColumns).AddRow(tLoc.ShortURLRow(tLoc.ShortURLFixture)...),
 					)
 			},
 			wanted: wanted{code: http.StatusConflict},
@@ -577,8 +558,17 @@ func TestDatabaseRepository(t *testing.T) {
 			bodyString: "[\"" + tLoc.ShortURLFixture.ID + "\"]",
 			method:     http.MethodDelete,
 			expect: func(mock sqlmock.Sqlmock) {
-				mock.ExpectExec("UPDATE short_urls SET is_active=false WHERE user_id").
-					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("INSERT INTO delete_jobs").
+					WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), entities.DeleteJobQueued,
+						sqlmock.AnyArg(), 0, "", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery("UPDATE short_urls SET is_active=false").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(tLoc.ShortURLFixture.ID))
+				mock.ExpectExec("INSERT INTO delete_jobs").
+					WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), entities.DeleteJobApplied,
+						[]byte(`{"`+tLoc.ShortURLFixture.ID+`":"deleted"}`), 1, "",
+						sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
 			},
 			wanted: wanted{code: http.StatusAccepted},
 		},
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/config"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/middlewares"
+	"github.com/google/uuid"
+)
+
+// AddTagsHandler adds tags to records of current user and to records of workspaces they may edit,
+// responds with tags of changed records by their IDs.
+func (h *Shortener) AddTagsHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	h.updateTags(w, r, h.Repo.AddTags)
+}
+
+// RemoveTagsHandler removes tags from records of current user and from records of workspaces they may edit,
+// responds with tags left on changed records by their IDs.
+func (h *Shortener) RemoveTagsHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	h.updateTags(w, r, h.Repo.RemoveTags)
+}
+
+// updateTags applies change to tags of records given in request, records current user may not edit are skipped.
+func (h *Shortener) updateTags(
+	w http.ResponseWriter,
+	r *http.Request,
+	change func(ctx context.Context, userID uuid.UUID, ids []string, tags []string) ([]string, error),
+) {
+	requestBody, doneWithError := h.readBody(w, r)
+	if doneWithError {
+		return
+	}
+	var updateDTO entities.TagsUpdateDTO
+	if err := json.Unmarshal(requestBody, &updateDTO); err != nil || len(updateDTO.IDs) == 0 {
+		http.Error(w, config.BadInputData, http.StatusUnprocessableEntity)
+		return
+	}
+	tags, err := entities.NormalizeTags(updateDTO.Tags)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	userID := r.Context().Value(middlewares.UserIDKey).(uuid.UUID)
+
+	changed, err := change(r.Context(), userID, updateDTO.IDs, tags)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(changed) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	changedTags, err := h.Repo.GetTags(r.Context(), changed)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, id := range changed {
+		if _, exist := changedTags[id]; !exist {
+			changedTags[id] = []string{}
+		}
+	}
+	respondWithJSON(w, changedTags, http.StatusOK)
+}
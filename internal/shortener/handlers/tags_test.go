@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/repositories"
+	tLoc "github.com/RomanAVolodin/go-url-shortener/internal/shortener/tests"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTags(t *testing.T) {
+	for name, newRepo := range tLoc.MapRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			repo := newRepo()
+			h := NewShortener(repo)
+			ids := make(map[string]string)
+			for _, original := range []string{"https://go.dev/blog", "https://go.dev/doc", "https://go.dev/play"} {
+				require.Equal(t, http.StatusCreated, sendAsOwner(h, http.MethodPost, "/api/shorten", `{"url": "`+original+`"}`).Code)
+				shortURL, _, err := repo.GetByOriginal(context.Background(), "", "", original)
+				require.NoError(t, err)
+				ids[original] = shortURL.ID
+			}
+			blog, doc, play := ids["https://go.dev/blog"], ids["https://go.dev/doc"], ids["https://go.dev/play"]
+
+			w := sendAsOwner(h, http.MethodPost, "/api/user/urls/tags",
+				`{"ids": ["`+blog+`", "`+doc+`", "missing"], "tags": ["Go", " promo ", "go"]}`)
+			require.Equal(t, http.StatusOK, w.Code)
+			var tags map[string][]string
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tags))
+			assert.Equal(t, map[string][]string{blog: {"go", "promo"}, doc: {"go", "promo"}}, tags)
+			require.Equal(t, http.StatusOK,
+				sendAsOwner(h, http.MethodPost, "/api/user/urls/tags", `{"ids": ["`+play+`"], "tags": ["go"]}`).Code)
+
+			w = sendAsOwner(h, http.MethodDelete, "/api/user/urls/tags", `{"ids": ["`+doc+`"], "tags": ["promo"]}`)
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.JSONEq(t, `{"`+doc+`": ["go"]}`, w.Body.String())
+
+			w = sendAsOwner(h, http.MethodGet, "/api/user/urls?tag=promo&tag=GO", "")
+			require.Equal(t, http.StatusOK, w.Code)
+			var listed []entities.ShortURLResponseDto
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+			require.Len(t, listed, 1)
+			assert.Equal(t, "https://go.dev/blog", listed[0].Original)
+			assert.Equal(t, []string{"go", "promo"}, listed[0].Tags)
+
+			w = sendAsOwner(h, http.MethodGet, "/api/user/urls?tag=go", "")
+			require.Equal(t, http.StatusOK, w.Code)
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+			assert.Len(t, listed, 3)
+			assert.Equal(t, http.StatusNoContent, sendAsOwner(h, http.MethodGet, "/api/user/urls?tag=rust", "").Code)
+			assert.Equal(t, http.StatusUnprocessableEntity, sendAsOwner(h, http.MethodGet, "/api/user/urls?tag=", "").Code)
+
+			stranger := uuid.New()
+			assert.Equal(t, http.StatusNoContent, sendAs(h, stranger, http.MethodPost, "/api/user/urls/tags",
+				`{"ids": ["`+blog+`"], "tags": ["spam"]}`).Code, "links of other users are not tagged")
+			assert.Equal(t, http.StatusNoContent, sendAs(h, stranger, http.MethodGet, "/api/user/urls?tag=go", "").Code)
+			assert.Equal(t, http.StatusUnprocessableEntity,
+				sendAsOwner(h, http.MethodPost, "/api/user/urls/tags", `{"ids": ["`+blog+`"], "tags": []}`).Code)
+			assert.Equal(t, http.StatusUnprocessableEntity,
+				sendAsOwner(h, http.MethodPost, "/api/user/urls/tags", `{"ids": [], "tags": ["go"]}`).Code)
+
+			require.Equal(t, http.StatusAccepted, sendAsOwner(h, http.MethodDelete, "/api/user/urls", `["`+play+`"]`).Code)
+			w = sendAsOwner(h, http.MethodGet, "/api/user/urls?tag=go", "")
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+			assert.Len(t, listed, 2, "deleted links are not listed")
+		})
+	}
+}
+
+func TestTagsInWorkspace(t *testing.T) {
+	repo := &repositories.InMemoryRepository{Storage: map[string]entities.ShortURL{}}
+	h := NewShortener(repo)
+	viewer := uuid.New()
+
+	w := sendAsOwner(h, http.MethodPost, "/api/workspaces", `{"name": "Marketing"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var workspace entities.WorkspaceMembership
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &workspace))
+	require.Equal(t, http.StatusOK, sendAsOwner(h, http.MethodPut,
+		"/api/workspaces/"+workspace.ID+"/members/"+viewer.String(), `{"role": "viewer"}`).Code)
+	require.Equal(t, http.StatusCreated, sendAsOwner(h, http.MethodPost, "/api/shorten",
+		`{"url": "https://go.dev/team", "workspace_id": "`+workspace.ID+`"}`).Code)
+	shortURL, _, err := repo.GetByOriginal(context.Background(), "", workspace.ID, "https://go.dev/team")
+	require.NoError(t, err)
+
+	body := `{"ids": ["` + shortURL.ID + `"], "tags": ["launch"]}`
+	assert.Equal(t, http.StatusNoContent, sendAs(h, viewer, http.MethodPost, "/api/user/urls/tags", body).Code)
+	assert.Equal(t, http.StatusOK, sendAsOwner(h, http.MethodPost, "/api/user/urls/tags", body).Code)
+
+	assert.Equal(t, http.StatusNoContent, sendAsOwner(h, http.MethodGet, "/api/user/urls?tag=launch", "").Code,
+		"workspace links are not personal")
+	w = sendAs(h, viewer, http.MethodGet, "/api/user/urls?tag=launch&workspace="+workspace.ID, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"tags":["launch"]`)
+}
+
+func TestTagsFileRepositoryRestore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.json")
+	repo := &repositories.FileRepository{Storage: map[string]entities.ShortURL{}, FilePath: path}
+	_, err := repo.Create(context.Background(), tLoc.ShortURLFixture)
+	require.NoError(t, err)
+	_, err = repo.AddTags(context.Background(), tLoc.UserIDFixture, []string{tLoc.ShortURLFixture.ID}, []string{"go"})
+	require.NoError(t, err)
+
+	restored := &repositories.FileRepository{Storage: map[string]entities.ShortURL{}, FilePath: path}
+	require.NoError(t, restored.Restore())
+	urls, err := restored.GetByTags(context.Background(), tLoc.UserIDFixture, "", []string{"go"})
+	require.NoError(t, err)
+	require.Len(t, urls, 1)
+	assert.Equal(t, tLoc.ShortURLFixture.ID, urls[0].ID)
+}
+
+func TestTagsDatabaseRepository(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id FROM short_urls WHERE deleted_at IS NULL AND id IN \(\$1\) AND .* FOR UPDATE`).
+		WithArgs(tLoc.ShortURLFixture.ID, tLoc.UserIDFixture.String(), tLoc.UserIDFixture.String()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(tLoc.ShortURLFixture.ID))
+	mock.ExpectExec(`INSERT INTO tags \(name\) SELECT unnest\(ARRAY\[\$1, \$2\]::varchar\[\]\) ON CONFLICT`).
+		WithArgs("go", "promo").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`INSERT INTO short_url_tags \(short_url_id, tag_id\) SELECT .* WHERE u.id IN \(\$1\) AND t.name IN \(\$2, \$3\)`).
+		WithArgs(tLoc.ShortURLFixture.ID, "go", "promo").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT st.short_url_id, t.name FROM short_url_tags").
+		WithArgs(tLoc.ShortURLFixture.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"short_url_id", "name"}).
+			AddRow(tLoc.ShortURLFixture.ID, "go").
+			AddRow(tLoc.ShortURLFixture.ID, "promo"))
+	mock.ExpectQuery(tLoc.ShortURLSelectQuery+` WHERE is_active=true AND user_id = \$1 AND workspace_id = '' AND id IN `+
+		`\(.* HAVING count\(\*\) = \$3\)`).
+		WithArgs(tLoc.UserIDFixture.String(), "go", 1).
+		WillReturnRows(sqlmock.NewRows(tLoc.ShortURLColumns).AddRow(tLoc.ShortURLRow(tLoc.ShortURLFixture)...))
+	mock.ExpectQuery("SELECT st.short_url_id, t.name FROM short_url_tags").
+		WithArgs(tLoc.ShortURLFixture.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"short_url_id", "name"}).AddRow(tLoc.ShortURLFixture.ID, "go"))
+
+	h := NewShortener(&repositories.DatabaseRepository{Storage: db})
+	w := sendAsOwner(h, http.MethodPost, "/api/user/urls/tags",
+		`{"ids": ["`+tLoc.ShortURLFixture.ID+`"], "tags": ["promo", "go"]}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"`+tLoc.ShortURLFixture.ID+`": ["go", "promo"]}`, w.Body.String())
+	w = sendAsOwner(h, http.MethodGet, "/api/user/urls?tag=go", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"tags":["go"]`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRemoveTagsDatabaseRepository(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	editableQuery := `SELECT id FROM short_urls WHERE deleted_at IS NULL AND id IN \(\$1, \$2\) AND .* FOR UPDATE`
+	mock.ExpectBegin()
+	mock.ExpectQuery(editableQuery).
+		WithArgs(tLoc.ShortURLFixture.ID, "foreign", tLoc.UserIDFixture.String(), tLoc.UserIDFixture.String()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(tLoc.ShortURLFixture.ID))
+	mock.ExpectExec(`DELETE FROM short_url_tags WHERE short_url_id IN \(\$1\) AND tag_id IN `+
+		`\(SELECT id FROM tags WHERE name IN \(\$2\)\)`).
+		WithArgs(tLoc.ShortURLFixture.ID, "promo").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT st.short_url_id, t.name FROM short_url_tags").
+		WithArgs(tLoc.ShortURLFixture.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"short_url_id", "name"}).AddRow(tLoc.ShortURLFixture.ID, "go"))
+	// nothing is inserted when none of records may be edited
+	mock.ExpectBegin()
+	mock.ExpectQuery(editableQuery).
+		WithArgs(tLoc.ShortURLFixture.ID, "foreign", tLoc.UserIDFixture.String(), tLoc.UserIDFixture.String()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+
+	h := NewShortener(&repositories.DatabaseRepository{Storage: db})
+	body := `{"ids": ["` + tLoc.ShortURLFixture.ID + `", "foreign"], "tags": ["Promo"]}`
+	w := sendAsOwner(h, http.MethodDelete, "/api/user/urls/tags", body)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"`+tLoc.ShortURLFixture.ID+`": ["go"]}`, w.Body.String())
+	assert.Equal(t, http.StatusNoContent, sendAsOwner(h, http.MethodPost, "/api/user/urls/tags", body).Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
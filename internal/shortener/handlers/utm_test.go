@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/middlewares"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/repositories"
+	tLoc "github.com/RomanAVolodin/go-url-shortener/internal/shortener/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateWithUTM(t *testing.T) {
+	repo := &repositories.InMemoryRepository{Storage: map[string]entities.ShortURL{}}
+	h := NewShortener(repo)
+	send := func(method, url, body string) (int, []byte) {
+		request := httptest.NewRequest(method, url, strings.NewReader(body))
+		request.AddCookie(&http.Cookie{
+			Name:  middlewares.CookieName,
+			Value: middlewares.GenerateCookieStringForUserID(tLoc.UserIDFixture),
+		})
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, request)
+		res := w.Result()
+		defer res.Body.Close()
+		resBody, _ := io.ReadAll(res.Body)
+		return res.StatusCode, resBody
+	}
+
+	code, _ := send(
+		http.MethodPost,
+		"/api/shorten",
+		`{"url": "https://ya.ru/?utm_source=site", "utm": {"source": "mail", "campaign": "spring"}}`,
+	)
+	assert.Equal(t, http.StatusCreated, code)
+	code, _ = send(
+		http.MethodPost,
+		"/api/shorten/batch",
+		`[{"correlation_id": "1", "original_url": "https://go.dev", "utm": {"medium": "cpc"}},
+		  {"correlation_id": "2", "original_url": "https://mail.ru", "utm": {}}]`,
+	)
+	assert.Equal(t, http.StatusCreated, code)
+	code, _ = send(
+		http.MethodPost,
+		"/api/shorten",
+		`{"url": "https://ya.ru/`+strings.Repeat("a", 230)+`", "utm": {"campaign": "makes url too long"}}`,
+	)
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+
+	code, body := send(http.MethodGet, "/api/user/urls", "")
+	require.Equal(t, http.StatusOK, code)
+	var listed []entities.ShortURLResponseDto
+	require.NoError(t, json.Unmarshal(body, &listed))
+	sort.Slice(listed, func(i, j int) bool { return listed[i].Original < listed[j].Original })
+	require.Len(t, listed, 3)
+	assert.Equal(t, "https://go.dev?utm_medium=cpc", listed[0].Original)
+	assert.Equal(t, &entities.UTM{Medium: "cpc"}, listed[0].UTM)
+	assert.Equal(t, "https://mail.ru", listed[1].Original)
+	assert.Nil(t, listed[1].UTM)
+	assert.Equal(t, "https://ya.ru/?utm_campaign=spring&utm_source=mail", listed[2].Original)
+	assert.Equal(t, &entities.UTM{Source: "mail", Campaign: "spring"}, listed[2].UTM)
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/config"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/middlewares"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/shortenerrors"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// variantCookieName cookie keeping variant served to visitor of split link with sticky variant,
+// it is scoped to path of the link.
+const variantCookieName = "variant"
+
+// variantCookieLifetime time visitor keeps getting the same variant.
+const variantCookieLifetime = 30 * 24 * time.Hour
+
+// GetVariantsHandler returns split destinations of record current user may edit with number of times each was served.
+func (h *Shortener) GetVariantsHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	urlItem, ok := h.getEditableRecord(w, r)
+	if !ok {
+		return
+	}
+	h.respondWithVariants(w, r, urlItem)
+}
+
+// UpdateVariantsHandler replaces split destinations of record current user may edit, empty list turns split off.
+func (h *Shortener) UpdateVariantsHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	requestBody, doneWithError := h.readBody(w, r)
+	if doneWithError {
+		return
+	}
+	var updateDTO entities.VariantsUpdateDTO
+	if err := json.Unmarshal(requestBody, &updateDTO); err != nil {
+		http.Error(w, config.BadInputData, http.StatusUnprocessableEntity)
+		return
+	}
+	urlItem, ok := h.getEditableRecord(w, r)
+	if !ok {
+		return
+	}
+	// Variants get the same UTM params as destination got on creation.
+	options := entities.LinkOptions{Variants: updateDTO.Variants, UTM: urlItem.UTM}
+	if _, err := prepareLink(urlItem.Original, &options); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	userID := r.Context().Value(middlewares.UserIDKey).(uuid.UUID)
+
+	urlItem, err := h.Repo.SetVariants(r.Context(), userID, urlItem.ID, options.Variants, updateDTO.StickyVariant)
+	switch {
+	case errors.Is(err, shortenerrors.ErrItemNotFound):
+		http.Error(w, config.NoURLFoundByID, http.StatusNotFound)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		h.respondWithVariants(w, r, urlItem)
+	}
+}
+
+// getEditableRecord returns active ShortURL current user may edit by id from url, responds with 404 if there is none.
+func (h *Shortener) getEditableRecord(w http.ResponseWriter, r *http.Request) (entities.ShortURL, bool) {
+	urlItem, exist, err := h.Repo.GetByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return urlItem, false
+	}
+	if !exist || !urlItem.IsActive {
+		http.Error(w, config.NoURLFoundByID, http.StatusNotFound)
+		return urlItem, false
+	}
+	editable, err := h.canEditRecord(r, urlItem)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return urlItem, false
+	}
+	if !editable {
+		http.Error(w, config.NoURLFoundByID, http.StatusNotFound)
+		return urlItem, false
+	}
+	return urlItem, true
+}
+
+// respondWithVariants responds with variants of ShortURL and their clicks.
+func (h *Shortener) respondWithVariants(w http.ResponseWriter, r *http.Request, urlItem entities.ShortURL) {
+	clicks, err := h.Repo.GetVariantClicks(r.Context(), urlItem.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response := entities.VariantsResponseDTO{
+		StickyVariant: urlItem.StickyVariant,
+		Variants:      make([]entities.VariantStats, 0, len(urlItem.Variants)),
+	}
+	for _, variant := range urlItem.Variants {
+		response.Variants = append(response.Variants, entities.VariantStats{Variant: variant, Clicks: clicks[variant.ID]})
+	}
+	respondWithJSON(w, response, http.StatusOK)
+}
+
+// pickVariant returns variant of split ShortURL by weighted random, visitor of link with sticky variant
+// gets the one kept in cookie. It reports whether picked variant has to be kept in cookie with rememberVariant.
+func pickVariant(r *http.Request, urlItem entities.ShortURL) (entities.Variant, bool) {
+	if urlItem.StickyVariant {
+		if cookie, err := r.Cookie(variantCookieName); err == nil {
+			if variant, ok := urlItem.Variants.Find(cookie.Value); ok {
+				return variant, false
+			}
+		}
+	}
+	return urlItem.Variants.Pick(randomPoint(urlItem.Variants.TotalWeight())), urlItem.StickyVariant
+}
+
+// rememberVariant keeps variant picked for visitor of sticky ShortURL in cookie.
+func rememberVariant(w http.ResponseWriter, urlItem entities.ShortURL, variant entities.Variant) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     variantCookieName,
+		Value:    variant.ID,
+		Path:     "/" + urlItem.Slug(),
+		MaxAge:   int(variantCookieLifetime.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// randomPoint returns random number from [0, total).
+func randomPoint(total int) int {
+	point, err := rand.Int(rand.Reader, big.NewInt(int64(total)))
+	if err != nil {
+		return 0
+	}
+	return int(point.Int64())
+}
+
+// recordClick stores click, redirect is not failed if it can't be stored.
+func (h *Shortener) recordClick(r *http.Request, click entities.Click) {
+	if err := h.Repo.RecordClick(r.Context(), click); err != nil {
+		log.Printf("Click of %s is not recorded: %v", click.ShortURLID, err)
+	}
+}
@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/middlewares"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/repositories"
+	tLoc "github.com/RomanAVolodin/go-url-shortener/internal/shortener/tests"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// splitFixture split ShortURL with two variants, the second one is served three times more often.
+func splitFixture(sticky bool) entities.ShortURL {
+	return entities.ShortURL{
+		ID: "split", Original: "https://example.com", UserID: tLoc.UserIDFixture, IsActive: true,
+		LinkOptions: entities.LinkOptions{
+			Variants: entities.Variants{
+				{ID: "a", URL: "https://example.com/a", Weight: 1},
+				{ID: "b", URL: "https://example.com/b", Weight: 3},
+			},
+			StickyVariant: sticky,
+			Rules:         entities.RoutingRules{{Platform: entities.PlatformIOS, URL: "https://apps.apple.com/app/id1"}},
+		},
+	}
+}
+
+// variantCookie returns variant cookie set by response, nil if there is none.
+func variantCookie(w *httptest.ResponseRecorder) *http.Cookie {
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == variantCookieName {
+			return cookie
+		}
+	}
+	return nil
+}
+
+// sendAsOwner sends request on behalf of user owning fixtures.
+func sendAsOwner(h *Shortener, method, url, body string) *httptest.ResponseRecorder {
+	return sendAs(h, tLoc.UserIDFixture, method, url, body)
+}
+
+// sendAs sends request on behalf of user.
+func sendAs(h *Shortener, userID uuid.UUID, method, url, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, url, strings.NewReader(body))
+	request.AddCookie(&http.Cookie{
+		Name:  middlewares.CookieName,
+		Value: middlewares.GenerateCookieStringForUserID(userID),
+	})
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, request)
+	return w
+}
+
+func TestWeightedVariants(t *testing.T) {
+	const requests = 400
+	for name, newRepo := range tLoc.MapRepositories(t, splitFixture(false)) {
+		t.Run(name, func(t *testing.T) {
+			h := NewShortener(newRepo())
+			served := make(map[string]int64)
+			for i := 0; i < requests; i++ {
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/split", nil))
+				require.Equal(t, http.StatusTemporaryRedirect, w.Code)
+				assert.Equal(t, "private, no-cache", w.Header().Get("Cache-Control"))
+				assert.Nil(t, variantCookie(w))
+				served[w.Header().Get("Location")]++
+			}
+			assert.Len(t, served, 2)
+			assert.InDelta(t, requests*3/4, served["https://example.com/b"], requests/8)
+
+			request := httptest.NewRequest(http.MethodGet, "/split", nil)
+			request.Header.Set("User-Agent", iPhoneUserAgent)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, request)
+			assert.Equal(t, "https://apps.apple.com/app/id1", w.Header().Get("Location"), "routing rule goes first")
+
+			w = sendAsOwner(h, http.MethodGet, "/api/user/urls/split/variants", "")
+			require.Equal(t, http.StatusOK, w.Code)
+			var stats entities.VariantsResponseDTO
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+			require.Len(t, stats.Variants, 2)
+			assert.Equal(t, served["https://example.com/a"], stats.Variants[0].Clicks)
+			assert.Equal(t, served["https://example.com/b"], stats.Variants[1].Clicks)
+		})
+	}
+}
+
+func TestStickyVariant(t *testing.T) {
+	repo := &repositories.InMemoryRepository{Storage: map[string]entities.ShortURL{"split": splitFixture(true)}}
+	h := NewShortener(repo)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/split", nil))
+	cookie := variantCookie(w)
+	require.NotNil(t, cookie)
+	assert.Equal(t, "/split", cookie.Path)
+	first := w.Header().Get("Location")
+
+	for i := 0; i < 20; i++ {
+		request := httptest.NewRequest(http.MethodGet, "/split", nil)
+		request.AddCookie(cookie)
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, request)
+		assert.Equal(t, first, w.Header().Get("Location"))
+		assert.Nil(t, variantCookie(w))
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/split", nil)
+	request.AddCookie(&http.Cookie{Name: variantCookieName, Value: "removed"})
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, request)
+	assert.NotNil(t, variantCookie(w), "unknown variant is picked again")
+}
+
+func TestStickyVariantRememberedAfterPassword(t *testing.T) {
+	hash, err := hashLinkPassword("secret")
+	require.NoError(t, err)
+	protected := splitFixture(true)
+	protected.PasswordHash = hash
+	repo := &repositories.InMemoryRepository{Storage: map[string]entities.ShortURL{protected.ID: protected}}
+	h := NewShortener(repo)
+	send := func(password string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, "/split", nil)
+		request.Header.Set(LinkPasswordHeader, password)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, request)
+		return w
+	}
+
+	w := send("wrong")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Nil(t, variantCookie(w))
+	w = send("secret")
+	assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+	assert.NotNil(t, variantCookie(w))
+}
+
+func TestVariantBehindInterstitial(t *testing.T) {
+	split := splitFixture(true)
+	split.Interstitial = true
+	repo := &repositories.InMemoryRepository{Storage: map[string]entities.ShortURL{split.ID: split}}
+	h := NewShortener(repo)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/split", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	cookie := variantCookie(w)
+	require.NotNil(t, cookie, "variant shown by interstitial page is remembered")
+
+	for i := 0; i < 5; i++ {
+		request := httptest.NewRequest(http.MethodGet, "/split", nil)
+		request.AddCookie(cookie)
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, request)
+		assert.Contains(t, w.Body.String(), "https://example.com/"+cookie.Value)
+	}
+	clicks, err := repo.GetVariantClicks(context.Background(), split.ID)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{cookie.Value: 6}, clicks)
+}
+
+func TestCreateAndUpdateVariants(t *testing.T) {
+	repo := &repositories.InMemoryRepository{Storage: map[string]entities.ShortURL{}}
+	h := NewShortener(repo)
+
+	w := sendAsOwner(h, http.MethodPost, "/api/shorten", `{"url": "https://go.dev", "utm": {"source": "ab"},
+		"variants": [{"url": "https://go.dev/a", "weight": 1}, {"url": "https://go.dev/b", "weight": 2}]}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	for _, invalid := range []string{
+		`{"url": "https://ya.ru", "variants": [{"url": "https://ya.ru/a", "weight": 1}]}`,
+		`{"url": "https://ya.ru", "variants": [{"url": "https://ya.ru/a", "weight": 0}, {"url": "https://ya.ru/b", "weight": 1}]}`,
+		`{"url": "https://ya.ru", "variants": [{"id": "x", "url": "https://ya.ru/a", "weight": 1}, {"id": "x", "url": "https://ya.ru/b", "weight": 1}]}`,
+		`{"url": "https://ya.ru", "variants": [{"id": "a b", "url": "https://ya.ru/a", "weight": 1}, {"url": "https://ya.ru/b", "weight": 1}]}`,
+		`{"url": "https://ya.ru", "variants": [{"url": "ya.ru/a", "weight": 1}, {"url": "https://ya.ru/b", "weight": 1}]}`,
+	} {
+		assert.Equal(t, http.StatusUnprocessableEntity, sendAsOwner(h, http.MethodPost, "/api/shorten", invalid).Code, invalid)
+	}
+	require.Len(t, repo.Storage, 1)
+	var id string
+	for _, url := range repo.Storage {
+		id = url.ID
+		assert.Equal(t, entities.Variants{
+			{ID: "a", URL: "https://go.dev/a?utm_source=ab", Weight: 1},
+			{ID: "b", URL: "https://go.dev/b?utm_source=ab", Weight: 2},
+		}, url.Variants)
+	}
+
+	w = sendAsOwner(h, http.MethodPut, "/api/user/urls/"+id+"/variants",
+		`{"sticky_variant": true, "variants": [{"id": "new", "url": "https://go.dev/new", "weight": 5}, {"id": "old", "url": "https://go.dev/old", "weight": 5}]}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	var stats entities.VariantsResponseDTO
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.True(t, stats.StickyVariant)
+	assert.Equal(t, []entities.VariantStats{
+		{Variant: entities.Variant{ID: "new", URL: "https://go.dev/new?utm_source=ab", Weight: 5}},
+		{Variant: entities.Variant{ID: "old", URL: "https://go.dev/old?utm_source=ab", Weight: 5}},
+	}, stats.Variants)
+	assert.True(t, repo.Storage[id].StickyVariant)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, sendAsOwner(h, http.MethodPut, "/api/user/urls/"+id+"/variants",
+		`{"variants": [{"url": "https://go.dev/new", "weight": 5}]}`).Code)
+	assert.Equal(t, http.StatusOK, sendAsOwner(h, http.MethodPut, "/api/user/urls/"+id+"/variants", `{"variants": []}`).Code)
+	assert.Empty(t, repo.Storage[id].Variants)
+
+	stranger := httptest.NewRequest(http.MethodGet, "/api/user/urls/"+id+"/variants", nil)
+	stranger.AddCookie(&http.Cookie{
+		Name:  middlewares.CookieName,
+		Value: middlewares.GenerateCookieStringForUserID(uuid.New()),
+	})
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, stranger)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestVariantsDatabaseRepository(t *testing.T) {
+	split := tLoc.ShortURLFixture
+	split.Variants = entities.Variants{
+		{ID: "a", URL: "https://example.com/a", Weight: 1},
+		{ID: "b", URL: "https://example.com/b", Weight: 1},
+	}
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	mock.ExpectQuery(tLoc.ShortURLSelectQuery).
+		WillReturnRows(sqlmock.NewRows(tLoc.ShortURLColumns).AddRow(tLoc.ShortURLRow(split)...))
+	mock.ExpectExec("INSERT INTO short_url_clicks").
+		WithArgs(split.ID, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	h := NewShortener(&repositories.DatabaseRepository{Storage: db})
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+split.ID, nil))
+
+	assert.Contains(t, []string{"https://example.com/a", "https://example.com/b"}, w.Header().Get("Location"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateVariantsDatabaseRepository(t *testing.T) {
+	link := tLoc.ShortURLFixture
+	updated := link
+	updated.StickyVariant = true
+	updated.Variants = entities.Variants{
+		{ID: "a", URL: "https://ya.ru/a", Weight: 1},
+		{ID: "b", URL: "https://ya.ru/b", Weight: 3},
+	}
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	mock.ExpectQuery(tLoc.ShortURLSelectQuery + ` WHERE id = \$1`).
+		WithArgs(link.ID).
+		WillReturnRows(sqlmock.NewRows(tLoc.ShortURLColumns).AddRow(tLoc.ShortURLRow(link)...))
+	mock.ExpectQuery(`UPDATE short_urls SET variants = \$1, sticky_variant = \$2 WHERE id = \$3 AND is_active=true AND `+
+		`\(\(workspace_id = '' AND user_id = \$4\) OR workspace_id IN \(.* WHERE user_id = \$5`).
+		WithArgs(`[{"id":"a","url":"https://ya.ru/a","weight":1},{"id":"b","url":"https://ya.ru/b","weight":3}]`,
+			true, link.ID, tLoc.UserIDFixture.String(), tLoc.UserIDFixture.String()).
+		WillReturnRows(sqlmock.NewRows(tLoc.ShortURLColumns).AddRow(tLoc.ShortURLRow(updated)...))
+	mock.ExpectQuery(`SELECT variant, count\(\*\) FROM short_url_clicks WHERE short_url_id = \$1 GROUP BY variant`).
+		WithArgs(link.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"variant", "count"}).AddRow("b", 7))
+
+	h := NewShortener(&repositories.DatabaseRepository{Storage: db})
+	w := sendAsOwner(h, http.MethodPut, "/api/user/urls/"+link.ID+"/variants", `{"sticky_variant": true,
+		"variants": [{"id": "a", "url": "https://ya.ru/a", "weight": 1}, {"id": "b", "url": "https://ya.ru/b", "weight": 3}]}`)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var stats entities.VariantsResponseDTO
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.True(t, stats.StickyVariant)
+	assert.Equal(t, []entities.VariantStats{
+		{Variant: updated.Variants[0]},
+		{Variant: updated.Variants[1], Clicks: 7},
+	}, stats.Variants)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/config"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/middlewares"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/shortenerrors"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/lithammer/shortuuid"
+)
+
+// CreateWorkspaceHandler creates workspace with current user as its owner.
+func (h *Shortener) CreateWorkspaceHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	requestBody, doneWithError := h.readBody(w, r)
+	if doneWithError {
+		return
+	}
+	var createDTO entities.WorkspaceCreateDTO
+	err := json.Unmarshal(requestBody, &createDTO)
+	createDTO.Name = strings.TrimSpace(createDTO.Name)
+	if err != nil || createDTO.Name == "" || utf8.RuneCountInString(createDTO.Name) > entities.MaxWorkspaceNameLength {
+		http.Error(w, config.BadInputData, http.StatusUnprocessableEntity)
+		return
+	}
+
+	userID := r.Context().Value(middlewares.UserIDKey).(uuid.UUID)
+
+	workspace, err := h.Repo.CreateWorkspace(r.Context(), entities.Workspace{
+		ID:        shortuuid.New(),
+		Name:      createDTO.Name,
+		CreatedBy: userID,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondWithJSON(w, entities.WorkspaceMembership{Workspace: workspace, Role: entities.RoleOwner}, http.StatusCreated)
+}
+
+// GetWorkspacesHandler returns workspaces current user is member of with their role.
+func (h *Shortener) GetWorkspacesHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	userID := r.Context().Value(middlewares.UserIDKey).(uuid.UUID)
+
+	workspaces, err := h.Repo.GetWorkspacesByUserID(r.Context(), userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(workspaces) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	respondWithJSON(w, workspaces, http.StatusOK)
+}
+
+// GetWorkspaceMembersHandler returns members of workspace, they are visible to every member.
+func (h *Shortener) GetWorkspaceMembersHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	workspaceID := chi.URLParam(r, "id")
+	if _, ok := h.getMembership(w, r, workspaceID); !ok {
+		return
+	}
+	members, err := h.Repo.GetWorkspaceMembers(r.Context(), workspaceID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondWithJSON(w, members, http.StatusOK)
+}
+
+// SetWorkspaceMemberHandler invites user to workspace or changes their role, only owners manage members.
+func (h *Shortener) SetWorkspaceMemberHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	requestBody, doneWithError := h.readBody(w, r)
+	if doneWithError {
+		return
+	}
+	var memberDTO entities.WorkspaceMemberDTO
+	if err := json.Unmarshal(requestBody, &memberDTO); err != nil || !entities.IsValidRole(memberDTO.Role) {
+		http.Error(w, config.BadInputData, http.StatusUnprocessableEntity)
+		return
+	}
+	memberID, err := uuid.Parse(chi.URLParam(r, "userID"))
+	if err != nil {
+		http.Error(w, config.BadInputData, http.StatusUnprocessableEntity)
+		return
+	}
+	workspaceID := chi.URLParam(r, "id")
+	if !h.canManageMember(w, r, workspaceID, memberID, memberDTO.Role != entities.RoleOwner) {
+		return
+	}
+
+	member, err := h.Repo.SetWorkspaceMember(r.Context(), entities.WorkspaceMember{
+		WorkspaceID: workspaceID,
+		UserID:      memberID,
+		Role:        memberDTO.Role,
+		AddedAt:     time.Now().UTC(),
+	})
+	switch {
+	case errors.Is(err, shortenerrors.ErrItemNotFound):
+		http.Error(w, config.NoWorkspaceFoundByID, http.StatusNotFound)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		respondWithJSON(w, member, http.StatusOK)
+	}
+}
+
+// RemoveWorkspaceMemberHandler removes member from workspace, owners remove anyone and members may leave.
+func (h *Shortener) RemoveWorkspaceMemberHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	memberID, err := uuid.Parse(chi.URLParam(r, "userID"))
+	if err != nil {
+		http.Error(w, config.BadInputData, http.StatusUnprocessableEntity)
+		return
+	}
+	workspaceID := chi.URLParam(r, "id")
+	if !h.canManageMember(w, r, workspaceID, memberID, true) {
+		return
+	}
+
+	err = h.Repo.RemoveWorkspaceMember(r.Context(), workspaceID, memberID)
+	switch {
+	case errors.Is(err, shortenerrors.ErrItemNotFound):
+		http.Error(w, config.NoWorkspaceMemberFoundByID, http.StatusNotFound)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// canManageMember checks that current user may change member of workspace, responds with error if not.
+// Member may only leave on their own, last owner can not leave or lose the role when losesOwnership is set.
+func (h *Shortener) canManageMember(
+	w http.ResponseWriter,
+	r *http.Request,
+	workspaceID string,
+	memberID uuid.UUID,
+	losesOwnership bool,
+) bool {
+	membership, ok := h.getMembership(w, r, workspaceID)
+	if !ok {
+		return false
+	}
+	leaving := membership.UserID == memberID && r.Method == http.MethodDelete
+	if !membership.CanManage() && !leaving {
+		http.Error(w, config.InsufficientWorkspaceRole, http.StatusForbidden)
+		return false
+	}
+	if !losesOwnership {
+		return true
+	}
+	members, err := h.Repo.GetWorkspaceMembers(r.Context(), workspaceID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	owners, isOwner := 0, false
+	for _, member := range members {
+		if member.Role == entities.RoleOwner {
+			owners++
+			isOwner = isOwner || member.UserID == memberID
+		}
+	}
+	if isOwner && owners == 1 {
+		http.Error(w, config.LastWorkspaceOwner, http.StatusConflict)
+		return false
+	}
+	return true
+}
+
+// getMembership returns membership of current user in workspace, responds with 404 if they are not a member.
+func (h *Shortener) getMembership(
+	w http.ResponseWriter,
+	r *http.Request,
+	workspaceID string,
+) (entities.WorkspaceMember, bool) {
+	userID := r.Context().Value(middlewares.UserIDKey).(uuid.UUID)
+
+	member, exist, err := h.Repo.GetWorkspaceMember(r.Context(), workspaceID, userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return member, false
+	}
+	if !exist {
+		http.Error(w, config.NoWorkspaceFoundByID, http.StatusNotFound)
+		return member, false
+	}
+	return member, true
+}
+
+// canEditRecord reports whether current user may edit ShortURL, personal one has to be created by the user,
+// one of workspace needs owner or editor role there.
+func (h *Shortener) canEditRecord(r *http.Request, urlItem entities.ShortURL) (bool, error) {
+	userID := r.Context().Value(middlewares.UserIDKey).(uuid.UUID)
+	if urlItem.WorkspaceID == "" {
+		return urlItem.UserID == userID, nil
+	}
+	member, exist, err := h.Repo.GetWorkspaceMember(r.Context(), urlItem.WorkspaceID, userID)
+	return exist && member.CanEdit(), err
+}
+
+// canCreateIn checks that current user may create links in workspace, personal links need no check.
+func (h *Shortener) canCreateIn(w http.ResponseWriter, r *http.Request, workspaceID string) bool {
+	if workspaceID == "" {
+		return true
+	}
+	membership, ok := h.getMembership(w, r, workspaceID)
+	if !ok {
+		return false
+	}
+	if !membership.CanEdit() {
+		http.Error(w, config.InsufficientWorkspaceRole, http.StatusForbidden)
+		return false
+	}
+	return true
+}
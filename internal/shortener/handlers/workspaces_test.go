@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/repositories"
+	tLoc "github.com/RomanAVolodin/go-url-shortener/internal/shortener/tests"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkspaces(t *testing.T) {
+	for name, newRepo := range tLoc.MapRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			repo := newRepo()
+			h := NewShortener(repo)
+			owner, editor, viewer, stranger := tLoc.UserIDFixture, uuid.New(), uuid.New(), uuid.New()
+
+			w := sendAs(h, owner, http.MethodPost, "/api/workspaces", `{"name": "Marketing"}`)
+			require.Equal(t, http.StatusCreated, w.Code)
+			var workspace entities.WorkspaceMembership
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &workspace))
+			assert.Equal(t, entities.RoleOwner, workspace.Role)
+			assert.Equal(t, http.StatusUnprocessableEntity, sendAs(h, owner, http.MethodPost, "/api/workspaces", `{"name": " "}`).Code)
+			members := "/api/workspaces/" + workspace.ID + "/members/"
+
+			assert.Equal(t, http.StatusOK, sendAs(h, owner, http.MethodPut, members+editor.String(), `{"role": "editor"}`).Code)
+			assert.Equal(t, http.StatusOK, sendAs(h, owner, http.MethodPut, members+viewer.String(), `{"role": "viewer"}`).Code)
+			assert.Equal(t, http.StatusUnprocessableEntity, sendAs(h, owner, http.MethodPut, members+stranger.String(), `{"role": "admin"}`).Code)
+			assert.Equal(t, http.StatusForbidden, sendAs(h, editor, http.MethodPut, members+stranger.String(), `{"role": "viewer"}`).Code)
+			assert.Equal(t, http.StatusNotFound, sendAs(h, stranger, http.MethodGet, "/api/workspaces/"+workspace.ID+"/members", "").Code)
+			assert.Equal(t, http.StatusNotFound, sendAs(h, owner, http.MethodPut, "/api/workspaces/missing/members/"+editor.String(), `{"role": "editor"}`).Code)
+
+			w = sendAs(h, viewer, http.MethodGet, "/api/workspaces/"+workspace.ID+"/members", "")
+			require.Equal(t, http.StatusOK, w.Code)
+			var listed []entities.WorkspaceMember
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+			require.Len(t, listed, 3)
+			assert.Equal(t, owner, listed[0].UserID)
+
+			w = sendAs(h, editor, http.MethodGet, "/api/workspaces", "")
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Contains(t, w.Body.String(), `"role":"editor"`)
+			assert.Equal(t, http.StatusNoContent, sendAs(h, stranger, http.MethodGet, "/api/workspaces", "").Code)
+
+			link := `{"url": "https://go.dev/team", "workspace_id": "` + workspace.ID + `"}`
+			assert.Equal(t, http.StatusCreated, sendAs(h, editor, http.MethodPost, "/api/shorten", link).Code)
+			assert.Equal(t, http.StatusForbidden, sendAs(h, viewer, http.MethodPost, "/api/shorten", link).Code)
+			assert.Equal(t, http.StatusNotFound, sendAs(h, stranger, http.MethodPost, "/api/shorten/batch",
+				`[{"correlation_id": "1", "original_url": "https://go.dev/batch", "workspace_id": "`+workspace.ID+`"}]`).Code)
+
+			w = sendAs(h, viewer, http.MethodGet, "/api/user/urls?workspace="+workspace.ID, "")
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Contains(t, w.Body.String(), "https://go.dev/team")
+			assert.Equal(t, http.StatusNotFound, sendAs(h, stranger, http.MethodGet, "/api/user/urls?workspace="+workspace.ID, "").Code)
+			assert.Equal(t, http.StatusNoContent, sendAs(h, editor, http.MethodGet, "/api/user/urls", "").Code, "workspace links are not personal")
+
+			shortURL, exist, err := repo.GetByOriginal(context.Background(), "", workspace.ID, "https://go.dev/team")
+			require.NoError(t, err)
+			require.True(t, exist)
+			ids := `["` + shortURL.ID + `"]`
+			require.Equal(t, http.StatusAccepted, sendAs(h, viewer, http.MethodDelete, "/api/user/urls", ids).Code)
+			shortURL, _, _ = repo.GetByID(context.Background(), shortURL.ID)
+			assert.True(t, shortURL.IsActive, "viewer can't delete links of workspace")
+			require.Equal(t, http.StatusAccepted, sendAs(h, owner, http.MethodDelete, "/api/user/urls", ids).Code)
+			shortURL, _, _ = repo.GetByID(context.Background(), shortURL.ID)
+			assert.False(t, shortURL.IsActive, "owner deletes links created by editor")
+
+			assert.Equal(t, http.StatusConflict, sendAs(h, owner, http.MethodPut, members+owner.String(), `{"role": "viewer"}`).Code)
+			assert.Equal(t, http.StatusConflict, sendAs(h, owner, http.MethodDelete, members+owner.String(), "").Code)
+			assert.Equal(t, http.StatusForbidden, sendAs(h, viewer, http.MethodDelete, members+editor.String(), "").Code)
+			assert.Equal(t, http.StatusNoContent, sendAs(h, viewer, http.MethodDelete, members+viewer.String(), "").Code)
+			assert.Equal(t, http.StatusNotFound, sendAs(h, viewer, http.MethodGet, "/api/user/urls?workspace="+workspace.ID, "").Code)
+		})
+	}
+}
+
+func TestWorkspacesEditRecords(t *testing.T) {
+	for name, newRepo := range tLoc.MapRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			repo := newRepo()
+			h := NewShortener(repo)
+			owner, editor, creator := tLoc.UserIDFixture, uuid.New(), uuid.New()
+
+			w := sendAs(h, owner, http.MethodPost, "/api/workspaces", `{"name": "Marketing"}`)
+			require.Equal(t, http.StatusCreated, w.Code)
+			var workspace entities.WorkspaceMembership
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &workspace))
+			members := "/api/workspaces/" + workspace.ID + "/members/"
+			require.Equal(t, http.StatusOK, sendAs(h, owner, http.MethodPut, members+editor.String(), `{"role": "editor"}`).Code)
+			require.Equal(t, http.StatusOK, sendAs(h, owner, http.MethodPut, members+creator.String(), `{"role": "editor"}`).Code)
+			require.Equal(t, http.StatusCreated, sendAs(h, creator, http.MethodPost, "/api/shorten",
+				`{"url": "https://go.dev/team", "workspace_id": "`+workspace.ID+`"}`).Code)
+			shortURL, exist, err := repo.GetByOriginal(context.Background(), "", workspace.ID, "https://go.dev/team")
+			require.NoError(t, err)
+			require.True(t, exist)
+			link := "/api/user/urls/" + shortURL.ID
+			variants := `{"variants": [{"id": "a", "url": "https://go.dev/a", "weight": 1}, {"id": "b", "url": "https://go.dev/b", "weight": 1}]}`
+
+			assert.Equal(t, http.StatusOK, sendAs(h, editor, http.MethodPatch, link, `{"url": "https://go.dev/edited"}`).Code,
+				"editor changes links created by other members")
+			assert.Equal(t, http.StatusOK, sendAs(h, editor, http.MethodPut, link+"/variants", variants).Code)
+			assert.Equal(t, http.StatusOK, sendAs(h, owner, http.MethodGet, link+"/variants", "").Code)
+			w = sendAs(h, owner, http.MethodGet, link+"/history", "")
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Contains(t, w.Body.String(), "https://go.dev/team")
+
+			require.Equal(t, http.StatusOK, sendAs(h, owner, http.MethodPut, members+creator.String(), `{"role": "viewer"}`).Code)
+			assert.Equal(t, http.StatusNotFound, sendAs(h, creator, http.MethodPatch, link, `{"url": "https://go.dev/viewer"}`).Code,
+				"creator demoted to viewer can't change their link")
+			require.Equal(t, http.StatusNoContent, sendAs(h, owner, http.MethodDelete, members+creator.String(), "").Code)
+			assert.Equal(t, http.StatusNotFound, sendAs(h, creator, http.MethodPatch, link, `{"url": "https://go.dev/removed"}`).Code,
+				"removed creator can't change their link")
+			assert.Equal(t, http.StatusNotFound, sendAs(h, creator, http.MethodPut, link+"/variants", variants).Code)
+			assert.Equal(t, http.StatusNotFound, sendAs(h, creator, http.MethodGet, link+"/variants", "").Code)
+			assert.Equal(t, http.StatusNotFound, sendAs(h, creator, http.MethodGet, link+"/history", "").Code)
+
+			shortURL, _, err = repo.GetByID(context.Background(), shortURL.ID)
+			require.NoError(t, err)
+			assert.Equal(t, "https://go.dev/edited", shortURL.Original)
+		})
+	}
+}
+
+func TestWorkspacesReassignRecords(t *testing.T) {
+	for name, newRepo := range tLoc.MapRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			repo := newRepo()
+			h := NewShortener(repo)
+			anonymous, account := uuid.New(), uuid.New()
+			workspaces := make([]string, 0, 2)
+			for _, creator := range []uuid.UUID{anonymous, tLoc.UserIDFixture} {
+				w := sendAs(h, creator, http.MethodPost, "/api/workspaces", `{"name": "Marketing"}`)
+				require.Equal(t, http.StatusCreated, w.Code)
+				var workspace entities.WorkspaceMembership
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &workspace))
+				workspaces = append(workspaces, workspace.ID)
+			}
+			owned, shared := workspaces[0], workspaces[1]
+			members := "/api/workspaces/" + shared + "/members/"
+			require.Equal(t, http.StatusOK, sendAsOwner(h, http.MethodPut, members+anonymous.String(), `{"role": "editor"}`).Code)
+			require.Equal(t, http.StatusOK, sendAsOwner(h, http.MethodPut, members+account.String(), `{"role": "viewer"}`).Code)
+
+			_, err := repo.ReassignRecords(context.Background(), anonymous, account)
+			require.NoError(t, err)
+			if file, ok := repo.(*repositories.FileRepository); ok {
+				repo = &repositories.FileRepository{Storage: map[string]entities.ShortURL{}, FilePath: file.FilePath}
+				require.NoError(t, repo.(*repositories.FileRepository).Restore())
+			}
+
+			for workspaceID, role := range map[string]string{owned: entities.RoleOwner, shared: entities.RoleEditor} {
+				member, exist, err := repo.GetWorkspaceMember(context.Background(), workspaceID, account)
+				require.NoError(t, err)
+				require.True(t, exist)
+				assert.Equal(t, role, member.Role, "role with more rights is kept")
+				_, exist, err = repo.GetWorkspaceMember(context.Background(), workspaceID, anonymous)
+				require.NoError(t, err)
+				assert.False(t, exist)
+			}
+		})
+	}
+}
+
+func TestWorkspacesFileRepositoryRestore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.json")
+	repo := &repositories.FileRepository{Storage: map[string]entities.ShortURL{}, FilePath: path}
+	h := NewShortener(repo)
+	w := sendAsOwner(h, http.MethodPost, "/api/workspaces", `{"name": "Marketing"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var workspace entities.WorkspaceMembership
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &workspace))
+
+	restored := &repositories.FileRepository{Storage: map[string]entities.ShortURL{}, FilePath: path}
+	require.NoError(t, restored.Restore())
+	member, exist, err := restored.GetWorkspaceMember(context.Background(), workspace.ID, tLoc.UserIDFixture)
+	require.NoError(t, err)
+	require.True(t, exist)
+	assert.Equal(t, entities.RoleOwner, member.Role)
+}
+
+func TestWorkspacesDatabaseRepository(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	mock.ExpectQuery("SELECT workspace_id, user_id, role, added_at FROM workspace_members").
+		WithArgs("team", tLoc.UserIDFixture.String()).
+		WillReturnRows(sqlmock.NewRows([]string{"workspace_id", "user_id", "role", "added_at"}).
+			AddRow("team", tLoc.UserIDFixture.String(), entities.RoleViewer, tLoc.ShortURLFixture.CreatedAt))
+	mock.ExpectQuery(tLoc.ShortURLSelectQuery + " WHERE is_active=true AND workspace_id").
+		WithArgs("team").
+		WillReturnRows(sqlmock.NewRows(tLoc.ShortURLColumns).AddRow(tLoc.ShortURLRow(tLoc.ShortURLFixture)...))
+	mock.ExpectQuery("SELECT st.short_url_id, t.name FROM short_url_tags").
+		WithArgs(tLoc.ShortURLFixture.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"short_url_id", "name"}))
+	mock.ExpectExec("INSERT INTO delete_jobs").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`UPDATE short_urls SET is_active=false, deleted_at=now\(\) WHERE is_active=true AND id IN \(\$1\) AND .*workspace_members`).
+		WithArgs(tLoc.ShortURLFixture.ID, tLoc.UserIDFixture.String(), tLoc.UserIDFixture.String()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec("INSERT INTO delete_jobs").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	h := NewShortener(&repositories.DatabaseRepository{Storage: db})
+	assert.Equal(t, http.StatusOK, sendAsOwner(h, http.MethodGet, "/api/user/urls?workspace=team", "").Code)
+	assert.Equal(t, http.StatusAccepted, sendAsOwner(h, http.MethodDelete, "/api/user/urls", `["`+tLoc.ShortURLFixture.ID+`"]`).Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetWorkspaceMemberDatabaseRepository(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	member, addedAt := uuid.New(), tLoc.ShortURLFixture.CreatedAt
+	columns := []string{"workspace_id", "user_id", "role", "added_at"}
+	mock.ExpectQuery("SELECT workspace_id, user_id, role, added_at FROM workspace_members WHERE workspace_id = \\$1 AND user_id = \\$2").
+		WithArgs("team", tLoc.UserIDFixture.String()).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("team", tLoc.UserIDFixture.String(), entities.RoleOwner, addedAt))
+	mock.ExpectQuery("SELECT workspace_id, user_id, role, added_at FROM workspace_members WHERE workspace_id = \\$1 ORDER BY").
+		WithArgs("team").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("team", tLoc.UserIDFixture.String(), entities.RoleOwner, addedAt).
+			AddRow("team", member.String(), entities.RoleViewer, addedAt))
+	mock.ExpectQuery(`INSERT INTO workspace_members \(workspace_id, user_id, role, added_at\) values \(\$1, \$2, \$3, \$4\) `+
+		`ON CONFLICT \(workspace_id, user_id\) DO UPDATE SET role = EXCLUDED.role`).
+		WithArgs("team", member.String(), entities.RoleEditor, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("team", member.String(), entities.RoleEditor, addedAt))
+
+	h := NewShortener(&repositories.DatabaseRepository{Storage: db})
+	w := sendAsOwner(h, http.MethodPut, "/api/workspaces/team/members/"+member.String(), `{"role": "editor"}`)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var stored entities.WorkspaceMember
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stored))
+	assert.Equal(t, entities.WorkspaceMember{WorkspaceID: "team", UserID: member, Role: entities.RoleEditor, AddedAt: addedAt}, stored)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateWorkspaceRecordDatabaseRepository(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	link := tLoc.ShortURLFixture
+	link.WorkspaceID = "team"
+	editor := uuid.New()
+	mock.ExpectBegin()
+	mock.ExpectQuery(tLoc.ShortURLSelectQuery+` WHERE id = \$1 AND is_active=true AND \(\(workspace_id = '' AND user_id = \$2\) `+
+		`OR workspace_id IN \(SELECT workspace_id FROM workspace_members WHERE user_id = \$3 AND role IN \('owner', 'editor'\)\)\) FOR UPDATE`).
+		WithArgs(link.ID, editor.String(), editor.String()).
+		WillReturnRows(sqlmock.NewRows(tLoc.ShortURLColumns).AddRow(tLoc.ShortURLRow(link)...))
+	mock.ExpectExec(`UPDATE short_urls SET original_url = \$1 WHERE id = \$2`).
+		WithArgs("https://go.dev/edited", link.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO short_url_history").
+		WithArgs(link.ID, link.Original, editor.String(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	h := NewShortener(&repositories.DatabaseRepository{Storage: db})
+	w := sendAs(h, editor, http.MethodPatch, "/api/user/urls/"+link.ID, `{"url": "https://go.dev/edited"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "https://go.dev/edited")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
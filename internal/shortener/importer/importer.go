@@ -0,0 +1,370 @@
+// Package importer imports links from CSV and JSON-lines streams.
+package importer
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/repositories"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/shortenerrors"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/utils"
+	"github.com/google/uuid"
+)
+
+// Supported input formats.
+const (
+	FormatCSV       = "csv"
+	FormatJSONLines = "jsonl"
+)
+
+// Import limits.
+const (
+	DefaultChunkSize    = 1000
+	maxReportedProblems = 1000
+	maxIDLength         = 45
+	maxJSONLineLength   = 1024 * 1024
+)
+
+// Columns of imported rows, CSV header may list them in any order.
+var columns = []string{"id", "original_url", "user_id", "created_at", "is_active"}
+
+// validID allowed characters of imported slugs.
+var validID = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ErrUnknownFormat returned for unsupported input format.
+var ErrUnknownFormat = errors.New("unknown import format, csv or jsonl expected")
+
+// ParseFormat returns import format by its name.
+func ParseFormat(name string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "csv":
+		return FormatCSV, nil
+	case "jsonl", "ndjson":
+		return FormatJSONLines, nil
+	}
+	return "", ErrUnknownFormat
+}
+
+// ErrNoOwner returned for row without user id when owner of such rows is not set.
+var ErrNoOwner = errors.New("user_id is required")
+
+// Importer writes imported links to repository in chunks.
+//
+// Rows without user id are given to Owner, they are rejected if Owner is not set.
+// Repository saving the whole storage at once gets chunks buffered and saved by single write.
+type Importer struct {
+	Repo      repositories.IRepository
+	ChunkSize int
+	Owner     uuid.UUID
+}
+
+// row single record of input.
+type row struct {
+	line   int
+	fields map[string]string
+	err    error
+}
+
+// Import reads links from r row by row and stores them, the whole input is never held in memory.
+//
+// Rows which are invalid are rejected, rows with id or original url already stored are duplicates.
+func (imp *Importer) Import(ctx context.Context, r io.Reader, format string) (entities.ImportReport, error) {
+	var next func() (row, error)
+	switch format {
+	case FormatCSV:
+		next = csvRows(r)
+	case FormatJSONLines:
+		next = jsonRows(r)
+	default:
+		return entities.ImportReport{}, ErrUnknownFormat
+	}
+	chunkSize := imp.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+
+	if buffered, ok := imp.Repo.(repositories.IBufferedRepository); ok {
+		report, err := imp.importChunks(ctx, next, chunkSize, buffered.CreateBuffered)
+		if errFlush := buffered.Flush(ctx); err == nil {
+			err = errFlush
+		}
+		return report, err
+	}
+	return imp.importChunks(ctx, next, chunkSize, imp.Repo.CreateMultiple)
+}
+
+// importChunks reads rows and stores valid ones with create in chunks of chunkSize.
+func (imp *Importer) importChunks(
+	ctx context.Context,
+	next func() (row, error),
+	chunkSize int,
+	create func(context.Context, []entities.ShortURL) ([]entities.ShortURL, error),
+) (entities.ImportReport, error) {
+	report := entities.ImportReport{}
+	chunk := make([]entities.ShortURL, 0, chunkSize)
+	lines := make([]int, 0, chunkSize)
+	for {
+		record, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return report, err
+		}
+		if record.err != nil {
+			reject(&report, record.line, record.fields["id"], record.err.Error())
+			continue
+		}
+		shortURL, err := toShortURL(record.fields, imp.Owner)
+		if err != nil {
+			reject(&report, record.line, record.fields["id"], err.Error())
+			continue
+		}
+		chunk = append(chunk, shortURL)
+		lines = append(lines, record.line)
+		if len(chunk) == chunkSize {
+			if err = imp.writeChunk(ctx, &report, chunk, lines, create); err != nil {
+				return report, err
+			}
+			chunk, lines = chunk[:0], lines[:0]
+		}
+	}
+	if len(chunk) > 0 {
+		if err := imp.writeChunk(ctx, &report, chunk, lines, create); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// writeChunk stores new links of chunk with create, falls back to one by one on failure.
+func (imp *Importer) writeChunk(
+	ctx context.Context,
+	report *entities.ImportReport,
+	chunk []entities.ShortURL,
+	lines []int,
+	create func(context.Context, []entities.ShortURL) ([]entities.ShortURL, error),
+) error {
+	existing, err := imp.Repo.GetExisting(ctx, chunk)
+	if err != nil {
+		return err
+	}
+	takenIDs := make(map[string]bool, len(existing)+len(chunk))
+	takenOriginals := make(map[scopedOriginal]bool, len(existing)+len(chunk))
+	for _, url := range existing {
+		takenIDs[url.ID] = true
+		takenOriginals[scopeOf(url)] = true
+	}
+
+	fresh := make([]entities.ShortURL, 0, len(chunk))
+	freshLines := make([]int, 0, len(chunk))
+	for i, url := range chunk {
+		if takenIDs[url.ID] || takenOriginals[scopeOf(url)] {
+			duplicate(report, lines[i], url.ID)
+			continue
+		}
+		takenIDs[url.ID] = true
+		takenOriginals[scopeOf(url)] = true
+		fresh = append(fresh, url)
+		freshLines = append(freshLines, lines[i])
+	}
+	if len(fresh) == 0 {
+		return nil
+	}
+
+	if _, err = create(ctx, fresh); err == nil {
+		report.Accepted += len(fresh)
+		return nil
+	}
+	for i, url := range fresh {
+		_, err = imp.Repo.Create(ctx, url)
+		switch {
+		case errors.Is(err, shortenerrors.ErrItemAlreadyExists):
+			duplicate(report, freshLines[i], url.ID)
+		case err != nil:
+			reject(report, freshLines[i], url.ID, err.Error())
+		default:
+			report.Accepted++
+		}
+	}
+	return nil
+}
+
+// scopedOriginal original url with domain and workspace it is unique within.
+type scopedOriginal struct {
+	domain      string
+	workspaceID string
+	original    string
+}
+
+// scopeOf returns original url of ShortURL with its scope.
+func scopeOf(url entities.ShortURL) scopedOriginal {
+	return scopedOriginal{domain: url.Domain, workspaceID: url.WorkspaceID, original: url.Original}
+}
+
+// toShortURL validates fields of row and converts them to ShortURL, row without user id is given to owner.
+func toShortURL(fields map[string]string, owner uuid.UUID) (entities.ShortURL, error) {
+	shortURL := entities.ShortURL{
+		ID:        fields["id"],
+		Original:  fields["original_url"],
+		UserID:    owner,
+		IsActive:  true,
+		CreatedAt: time.Now().UTC(),
+	}
+	if shortURL.ID == "" || len(shortURL.ID) > maxIDLength || !validID.MatchString(shortURL.ID) {
+		return shortURL, errors.New("invalid id")
+	}
+	if !utils.IsValidURL(shortURL.Original) {
+		return shortURL, errors.New("invalid original_url")
+	}
+	if raw := fields["user_id"]; raw != "" {
+		userID, err := uuid.Parse(raw)
+		if err != nil {
+			return shortURL, errors.New("invalid user_id")
+		}
+		shortURL.UserID = userID
+	}
+	if shortURL.UserID == uuid.Nil {
+		return shortURL, ErrNoOwner
+	}
+	if raw := fields["created_at"]; raw != "" {
+		createdAt, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return shortURL, errors.New("invalid created_at, RFC 3339 expected")
+		}
+		shortURL.CreatedAt = createdAt.UTC()
+	}
+	if raw := fields["is_active"]; raw != "" {
+		isActive, err := strconv.ParseBool(raw)
+		if err != nil {
+			return shortURL, errors.New("invalid is_active")
+		}
+		shortURL.IsActive = isActive
+	}
+	shortURL.Short = utils.GenerateResultURL(shortURL.ID)
+	return shortURL, nil
+}
+
+// csvRows returns reader of CSV rows, header row is optional and defines order of columns.
+func csvRows(r io.Reader) func() (row, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	order := columns
+	isFirst := true
+
+	return func() (row, error) {
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				return row{}, err
+			}
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				return row{line: parseErr.Line, err: parseErr.Err}, nil
+			}
+			if err != nil {
+				return row{}, err
+			}
+			line, _ := reader.FieldPos(0)
+			if isFirst {
+				isFirst = false
+				if header, ok := parseHeader(record); ok {
+					order = header
+					continue
+				}
+			}
+			if len(record) != len(order) {
+				return row{line: line, err: fmt.Errorf("%d fields expected", len(order))}, nil
+			}
+			fields := make(map[string]string, len(order))
+			for i, column := range order {
+				fields[column] = strings.TrimSpace(record[i])
+			}
+			return row{line: line, fields: fields}, nil
+		}
+	}
+}
+
+// parseHeader returns columns listed in header, record is not a header if id column is missing.
+func parseHeader(record []string) ([]string, bool) {
+	header := make([]string, 0, len(record))
+	hasID := false
+	for _, field := range record {
+		column := strings.ToLower(strings.TrimSpace(field))
+		hasID = hasID || column == "id"
+		header = append(header, column)
+	}
+	return header, hasID
+}
+
+// jsonRow record of JSON-lines input, is_active may be either boolean or string.
+type jsonRow struct {
+	ID        string `json:"id"`
+	Original  string `json:"original_url"`
+	UserID    string `json:"user_id"`
+	CreatedAt string `json:"created_at"`
+	IsActive  any    `json:"is_active"`
+}
+
+// jsonRows returns reader of JSON-lines rows, empty lines are skipped.
+func jsonRows(r io.Reader) func() (row, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxJSONLineLength)
+	line := 0
+
+	return func() (row, error) {
+		for scanner.Scan() {
+			line++
+			text := strings.TrimSpace(scanner.Text())
+			if text == "" {
+				continue
+			}
+			var record jsonRow
+			if err := json.Unmarshal([]byte(text), &record); err != nil {
+				return row{line: line, err: errors.New("invalid json")}, nil
+			}
+			fields := map[string]string{
+				"id":           record.ID,
+				"original_url": record.Original,
+				"user_id":      record.UserID,
+				"created_at":   record.CreatedAt,
+			}
+			if record.IsActive != nil {
+				fields["is_active"] = fmt.Sprint(record.IsActive)
+			}
+			return row{line: line, fields: fields}, nil
+		}
+		if err := scanner.Err(); err != nil {
+			return row{}, err
+		}
+		return row{}, io.EOF
+	}
+}
+
+func reject(report *entities.ImportReport, line int, id string, reason string) {
+	report.Rejected++
+	addProblem(report, line, id, reason)
+}
+
+func duplicate(report *entities.ImportReport, line int, id string) {
+	report.Duplicates++
+	addProblem(report, line, id, "duplicate")
+}
+
+// addProblem adds problem to report, only first maxReportedProblems are kept.
+func addProblem(report *entities.ImportReport, line int, id string, reason string) {
+	if len(report.Problems) < maxReportedProblems {
+		report.Problems = append(report.Problems, entities.ImportProblem{Line: line, ID: id, Reason: reason})
+	}
+}
@@ -0,0 +1,156 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/repositories"
+	tLoc "github.com/RomanAVolodin/go-url-shortener/internal/shortener/tests"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingBatchRepository refuses to create links in batches.
+type failingBatchRepository struct {
+	*repositories.InMemoryRepository
+}
+
+func (repo failingBatchRepository) CreateMultiple(
+	ctx context.Context,
+	urls []entities.ShortURL,
+) ([]entities.ShortURL, error) {
+	return nil, errors.New("batch insert failed")
+}
+
+func TestImport(t *testing.T) {
+	userID := tLoc.UserIDFixture.String()
+	owner := uuid.New()
+	tests := []struct {
+		name      string
+		format    string
+		input     string
+		failBatch bool
+		want      entities.ImportReport
+		check     func(t *testing.T, storage map[string]entities.ShortURL)
+	}{
+		{
+			name:   "CSV with header in custom order",
+			format: FormatCSV,
+			input: "original_url,id,is_active,created_at,user_id\n" +
+				"https://mail.ru,mail,false,2020-01-02T03:04:05Z," + userID + "\n" +
+				"https://go.dev,go,,,\n",
+			want: entities.ImportReport{Accepted: 2},
+			check: func(t *testing.T, storage map[string]entities.ShortURL) {
+				mail := storage["mail"]
+				assert.Equal(t, "https://mail.ru", mail.Original)
+				assert.False(t, mail.IsActive)
+				assert.Equal(t, tLoc.UserIDFixture, mail.UserID)
+				assert.Equal(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), mail.CreatedAt)
+				assert.True(t, storage["go"].IsActive)
+				assert.Equal(t, owner, storage["go"].UserID)
+				assert.True(t, strings.HasSuffix(storage["go"].Short, "/go"))
+			},
+		},
+		{
+			name:   "CSV without header, invalid and duplicate rows",
+			format: FormatCSV,
+			input: "first,https://first.com," + userID + ",,true\n" +
+				"bad id,https://second.com,,,\n" +
+				"third,not a url,,,\n" +
+				"fourth,https://fourth.com,not-uuid,,\n" +
+				"fifth,https://fifth.com,,yesterday,\n" +
+				"sixth,https://sixth.com,,,maybe\n" +
+				"short,row\n" +
+				tLoc.ShortURLFixture.ID + ",https://taken-id.com,,,\n" +
+				"taken-original," + tLoc.ShortURLFixture.Original + ",,,\n" +
+				"first,https://first-again.com,,,\n",
+			want: entities.ImportReport{Accepted: 1, Duplicates: 3, Rejected: 6},
+		},
+		{
+			name:   "JSON lines",
+			format: FormatJSONLines,
+			input: `{"id": "mail", "original_url": "https://mail.ru", "is_active": false}` + "\n\n" +
+				`{"id": "go", "original_url": "https://go.dev", "is_active": "true"}` + "\n" +
+				`{"id": "broken"` + "\n",
+			want: entities.ImportReport{Accepted: 2, Rejected: 1},
+			check: func(t *testing.T, storage map[string]entities.ShortURL) {
+				assert.False(t, storage["mail"].IsActive)
+				assert.True(t, storage["go"].IsActive)
+			},
+		},
+		{
+			name:      "Rows are written one by one if chunk fails",
+			format:    FormatCSV,
+			input:     "id,original_url\nmail,https://mail.ru\ngo,https://go.dev\n",
+			failBatch: true,
+			want:      entities.ImportReport{Accepted: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memory := &repositories.InMemoryRepository{
+				Storage: map[string]entities.ShortURL{tLoc.ShortURLFixture.ID: tLoc.ShortURLFixture},
+			}
+			var repo repositories.IRepository = memory
+			if tt.failBatch {
+				repo = failingBatchRepository{memory}
+			}
+			imp := Importer{Repo: repo, ChunkSize: 2, Owner: owner}
+			report, err := imp.Import(context.Background(), strings.NewReader(tt.input), tt.format)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.want.Accepted, report.Accepted)
+			assert.Equal(t, tt.want.Duplicates, report.Duplicates)
+			assert.Equal(t, tt.want.Rejected, report.Rejected)
+			assert.Len(t, report.Problems, report.Duplicates+report.Rejected)
+			assert.Len(t, memory.Storage, 1+report.Accepted)
+			if tt.check != nil {
+				tt.check(t, memory.Storage)
+			}
+		})
+	}
+}
+
+func TestImportWithoutOwner(t *testing.T) {
+	repo := &repositories.InMemoryRepository{Storage: map[string]entities.ShortURL{}}
+	imp := Importer{Repo: repo}
+	input := "id,original_url,user_id\nmail,https://mail.ru," + tLoc.UserIDFixture.String() + "\ngo,https://go.dev,\n"
+	report, err := imp.Import(context.Background(), strings.NewReader(input), FormatCSV)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, report.Accepted)
+	assert.Equal(t, 1, report.Rejected)
+	assert.Equal(t, ErrNoOwner.Error(), report.Problems[0].Reason)
+	assert.NotContains(t, repo.Storage, "go")
+}
+
+func TestImportToFileRepository(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.json")
+	repo := &repositories.FileRepository{Storage: map[string]entities.ShortURL{}, FilePath: path}
+	imp := Importer{Repo: repo, ChunkSize: 2, Owner: tLoc.UserIDFixture}
+	input := "id,original_url\nmail,https://mail.ru\ngo,https://go.dev\nya,https://ya.ru\n"
+	report, err := imp.Import(context.Background(), strings.NewReader(input), FormatCSV)
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Accepted)
+
+	restored := &repositories.FileRepository{Storage: map[string]entities.ShortURL{}, FilePath: path}
+	require.NoError(t, restored.Restore())
+	assert.Len(t, restored.Storage, 3, "buffered chunks are saved to file")
+}
+
+func TestParseFormat(t *testing.T) {
+	format, err := ParseFormat("CSV")
+	assert.NoError(t, err)
+	assert.Equal(t, FormatCSV, format)
+	format, err = ParseFormat("ndjson")
+	assert.NoError(t, err)
+	assert.Equal(t, FormatJSONLines, format)
+	_, err = ParseFormat("xml")
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+}
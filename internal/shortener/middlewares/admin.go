@@ -0,0 +1,55 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/config"
+	"github.com/google/uuid"
+)
+
+// AdminKeyHeader header name for admin API key.
+const AdminKeyHeader = "X-Admin-Key"
+
+// AdminActorKey context key for the name of admin the request is made by.
+const AdminActorKey UserKey = "admin"
+
+// AdminOnly middleware allows requests with admin API key or from users listed as admins.
+//
+// Users authenticated with their own API keys are never treated as admins.
+func AdminOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor, ok := adminActor(r)
+		if !ok {
+			http.Error(w, config.AdminPrivilegesRequired, http.StatusForbidden)
+			return
+		}
+		ctx := context.WithValue(r.Context(), AdminActorKey, actor)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// adminActor returns name of admin to be written to audit log.
+func adminActor(r *http.Request) (string, bool) {
+	key := r.Header.Get(AdminKeyHeader)
+	if key != "" && config.Settings.AdminAPIKey != "" &&
+		subtle.ConstantTimeCompare([]byte(key), []byte(config.Settings.AdminAPIKey)) == 1 {
+		return "admin-key", true
+	}
+
+	if _, viaAPIKey := APIKeyFromContext(r.Context()); viaAPIKey {
+		return "", false
+	}
+	userID, ok := r.Context().Value(UserIDKey).(uuid.UUID)
+	if !ok || userID == uuid.Nil {
+		return "", false
+	}
+	for _, adminID := range config.Settings.AdminUserIDs {
+		if strings.EqualFold(strings.TrimSpace(adminID), userID.String()) {
+			return "user:" + userID.String(), true
+		}
+	}
+	return "", false
+}
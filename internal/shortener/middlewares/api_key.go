@@ -0,0 +1,71 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/config"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/utils"
+)
+
+// APIKeyHeader header name for API key.
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyContextKey context key for API key used in request.
+const APIKeyContextKey UserKey = "api_key"
+
+// APIKeyGetter looks up API key by hash.
+type APIKeyGetter interface {
+	GetAPIKeyByHash(ctx context.Context, hash string) (entities.APIKey, bool, error)
+}
+
+// APIKeyAuth middleware resolves user id from `X-API-Key` header.
+//
+// Requests without header are passed as is, to be handled by AuthCookie.
+func APIKeyAuth(repo APIKeyGetter) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rawKey := r.Header.Get(APIKeyHeader)
+			if rawKey == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key, exist, err := repo.GetAPIKeyByHash(r.Context(), utils.HashAPIKey(rawKey))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if !exist || !key.IsActive {
+				http.Error(w, config.InvalidAPIKey, http.StatusUnauthorized)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), UserIDKey, key.UserID)
+			ctx = context.WithValue(ctx, APIKeyContextKey, key)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequireScope middleware rejects requests made with API key lacking the scope.
+//
+// Cookie authenticated requests are allowed to do everything.
+func RequireScope(scope string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if key, ok := APIKeyFromContext(r.Context()); ok && !key.HasScope(scope) {
+				http.Error(w, config.InsufficientAPIKeyScope, http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// APIKeyFromContext returns API key the request has been authenticated with.
+func APIKeyFromContext(ctx context.Context) (entities.APIKey, bool) {
+	key, ok := ctx.Value(APIKeyContextKey).(entities.APIKey)
+	return key, ok
+}
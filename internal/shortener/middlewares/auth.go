@@ -25,63 +25,170 @@ type UserKey string
 const UserIDKey UserKey = "id"
 
 // AuthCookie middleware coops with user id in cookie.
+//
+// Cookie is issued when it is missing or invalid and re-issued only when it has legacy format or less than half
+// of AUTH_COOKIE_LIFETIME is left, so it is not prolonged by every request.
 func AuthCookie(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		var userID uuid.UUID
-		var err error
-		userID, err = GetUserIDFromCookie(r)
+		if _, ok := APIKeyFromContext(r.Context()); ok {
+			next.ServeHTTP(w, r)
+			return
+		}
 
+		userID, renew, err := readUserCookie(r, time.Now())
 		if err != nil || userID == uuid.Nil {
 			userID, err = uuid.NewUUID()
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
 			}
+			renew = true
 		}
 
-		cookie := &http.Cookie{
-			Name:     CookieName,
-			Value:    GenerateCookieStringForUserID(userID),
-			Expires:  time.Now().Add(24 * time.Hour),
-			HttpOnly: true,
-			Path:     "/",
+		if renew {
+			cookie, err := NewUserCookie(userID)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			r.AddCookie(cookie)
+			http.SetCookie(w, cookie)
 		}
-		r.AddCookie(cookie)
-		http.SetCookie(w, cookie)
 
 		ctx := context.WithValue(r.Context(), UserIDKey, userID)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-// MakeSignature makes signature.
+// MakeSignature makes signature with active key.
 func MakeSignature(itemToEncode string) string {
-	h := hmac.New(sha256.New, []byte(config.Settings.SecretAuthKey))
+	key, _ := AuthKeyRing.ActiveKey()
+	return MakeSignatureWithKey(key, itemToEncode)
+}
+
+// MakeSignatureWithKey makes signature with given key.
+func MakeSignatureWithKey(key SigningKey, itemToEncode string) string {
+	h := hmac.New(sha256.New, []byte(key.Secret))
 	h.Write([]byte(itemToEncode))
 	return fmt.Sprintf("%x", h.Sum(nil))
 }
 
+// NewUserCookie creates auth cookie with encrypted session of user.
+func NewUserCookie(userID uuid.UUID) (*http.Cookie, error) {
+	now := time.Now()
+	key, _ := AuthKeyRing.ActiveKey()
+	value, err := EncodeSession(key, Session{
+		UserID:    userID,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(config.Settings.CookieLifetime),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &http.Cookie{
+		Name:     CookieName,
+		Value:    value,
+		Expires:  now.Add(config.Settings.CookieLifetime),
+		HttpOnly: true,
+		Path:     "/",
+	}, nil
+}
+
+// SetUserCookie replaces auth cookie in response, used to switch user during request.
+func SetUserCookie(w http.ResponseWriter, userID uuid.UUID) error {
+	cookie, err := NewUserCookie(userID)
+	if err != nil {
+		return err
+	}
+	cookies := w.Header().Values("Set-Cookie")
+	w.Header().Del("Set-Cookie")
+	for _, value := range cookies {
+		if !strings.HasPrefix(value, CookieName+"=") {
+			w.Header().Add("Set-Cookie", value)
+		}
+	}
+	http.SetCookie(w, cookie)
+	return nil
+}
+
 // GenerateCookieStringForUserID generates cookie string for user id.
 func GenerateCookieStringForUserID(userID uuid.UUID) string {
-	return hex.EncodeToString([]byte(userID.String())) + "|" + MakeSignature(userID.String())
+	cookie, err := NewUserCookie(userID)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
 }
 
 // GetUserIDFromCookie gets user id from cookie.
+//
+// Legacy signed cookies are accepted until `config.Settings.LegacyCookiesUntil`.
 func GetUserIDFromCookie(r *http.Request) (uuid.UUID, error) {
+	userID, _, err := readUserCookie(r, time.Now())
+	return userID, err
+}
+
+// readUserCookie gets user id from cookie and reports whether cookie has to be re-issued,
+// legacy cookies and ones with less than half of lifetime left are re-issued.
+func readUserCookie(r *http.Request, now time.Time) (uuid.UUID, bool, error) {
 	cookie, err := r.Cookie(CookieName)
 	if cookie == nil {
-		return uuid.Nil, err
+		return uuid.Nil, true, err
+	}
+	if strings.HasPrefix(cookie.Value, sessionCookieVersion+".") {
+		session, errDecode := DecodeSession(AuthKeyRing, cookie.Value, now)
+		if errDecode != nil {
+			return uuid.Nil, true, errDecode
+		}
+		return session.UserID, session.ExpiresAt.Sub(now) < config.Settings.CookieLifetime/2, nil
+	}
+
+	if now.After(config.Settings.LegacyCookiesUntil) {
+		return uuid.Nil, true, ErrMalformedCookie
+	}
+	if userID, ok := parseLegacyCookie(cookie.Value); ok {
+		return userID, true, nil
 	}
-	cookieArray := strings.Split(cookie.Value, "|")
-	if len(cookieArray) == 2 {
-		decodedID, errDecode := hex.DecodeString(cookieArray[0])
-		userIDString, signString := decodedID, cookieArray[1]
-		userID, errParse := uuid.ParseBytes(userIDString)
-		newSign := MakeSignature(userID.String())
-
-		if hmac.Equal([]byte(newSign), []byte(signString)) && errParse == nil && errDecode == nil {
-			return userID, nil
+	return uuid.Nil, true, ErrMalformedCookie
+}
+
+// GenerateLegacyCookieStringForUserID generates cookie string of format used before encryption.
+//
+// Value has format `hex(user id)|key id|signature`, it is signed with active key.
+func GenerateLegacyCookieStringForUserID(userID uuid.UUID) string {
+	key, _ := AuthKeyRing.ActiveKey()
+	return hex.EncodeToString([]byte(userID.String())) + "|" + key.ID + "|" + MakeSignatureWithKey(key, userID.String())
+}
+
+// parseLegacyCookie verifies signed cookie of format `hex(user id)|key id|signature`.
+//
+// Signature is verified with the key mentioned in cookie, oldest cookies
+// without key id are verified against all non-retired keys.
+func parseLegacyCookie(value string) (uuid.UUID, bool) {
+	cookieArray := strings.Split(value, "|")
+	var keys []SigningKey
+	switch len(cookieArray) {
+	case 2:
+		keys = AuthKeyRing.VerificationKeys()
+	case 3:
+		if key, ok := AuthKeyRing.Key(cookieArray[1]); ok {
+			keys = []SigningKey{key}
 		}
+	default:
+		return uuid.Nil, false
 	}
 
-	return uuid.Nil, err
+	decodedID, errDecode := hex.DecodeString(cookieArray[0])
+	userIDString, signString := decodedID, cookieArray[len(cookieArray)-1]
+	userID, errParse := uuid.ParseBytes(userIDString)
+	if errDecode != nil || errParse != nil {
+		return uuid.Nil, false
+	}
+	for _, key := range keys {
+		newSign := MakeSignatureWithKey(key, userID.String())
+		if hmac.Equal([]byte(newSign), []byte(signString)) {
+			return userID, true
+		}
+	}
+	return uuid.Nil, false
 }
@@ -0,0 +1,144 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/config"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCookieKeyRotation(t *testing.T) {
+	defer func(ring *KeyRing) { AuthKeyRing = ring }(AuthKeyRing)
+	defer func(deadline time.Time) { config.Settings.LegacyCookiesUntil = deadline }(config.Settings.LegacyCookiesUntil)
+	config.Settings.LegacyCookiesUntil = time.Now().Add(time.Hour)
+	userID := uuid.New()
+
+	requestWithCookie := func(value string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.AddCookie(&http.Cookie{Name: CookieName, Value: value})
+		return r
+	}
+
+	AuthKeyRing = &KeyRing{Active: "k1", Keys: []SigningKey{{ID: "k1", Secret: "one"}}}
+	oldCookie := GenerateCookieStringForUserID(userID)
+	legacyCookie := GenerateLegacyCookieStringForUserID(userID)
+	oldestCookie := legacyCookie[:len(userID.String())*2] + "|" + MakeSignature(userID.String())
+
+	AuthKeyRing = &KeyRing{Active: "k2", Keys: []SigningKey{{ID: "k1", Secret: "one"}, {ID: "k2", Secret: "two"}}}
+	newCookie := GenerateCookieStringForUserID(userID)
+	assert.True(t, strings.HasPrefix(newCookie, "v2.k2."))
+
+	for _, value := range []string{oldCookie, legacyCookie, oldestCookie, newCookie} {
+		got, err := GetUserIDFromCookie(requestWithCookie(value))
+		assert.NoError(t, err)
+		assert.Equal(t, userID, got)
+	}
+
+	AuthKeyRing = &KeyRing{Active: "k2", Keys: []SigningKey{{ID: "k1", Secret: "one", Retired: true}, {ID: "k2", Secret: "two"}}}
+	for _, value := range []string{oldCookie, legacyCookie, oldestCookie} {
+		got, _ := GetUserIDFromCookie(requestWithCookie(value))
+		assert.Equal(t, uuid.Nil, got)
+	}
+	got, _ := GetUserIDFromCookie(requestWithCookie(newCookie))
+	assert.Equal(t, userID, got)
+}
+
+func TestSessionCookie(t *testing.T) {
+	ring := &KeyRing{Active: "k1", Keys: []SigningKey{{ID: "k1", Secret: "one"}}}
+	key, _ := ring.ActiveKey()
+	userID := uuid.New()
+	now := time.Now()
+
+	valid, err := EncodeSession(key, Session{UserID: userID, IssuedAt: now, ExpiresAt: now.Add(time.Hour)})
+	assert.NoError(t, err)
+	assert.NotContains(t, valid, userID.String())
+
+	session, err := DecodeSession(ring, valid, now)
+	assert.NoError(t, err)
+	assert.Equal(t, userID, session.UserID)
+	assert.Equal(t, now.Add(time.Hour).Unix(), session.ExpiresAt.Unix())
+
+	_, err = DecodeSession(ring, valid, now.Add(2*time.Hour))
+	assert.ErrorIs(t, err, ErrExpiredCookie)
+
+	future, _ := EncodeSession(key, Session{UserID: userID, IssuedAt: now.Add(time.Hour), ExpiresAt: now.Add(2 * time.Hour)})
+	_, err = DecodeSession(ring, future, now)
+	assert.ErrorIs(t, err, ErrExpiredCookie)
+
+	tampered := valid[:len(valid)-2] + "AA"
+	if tampered == valid {
+		tampered = valid[:len(valid)-2] + "BB"
+	}
+	_, err = DecodeSession(ring, tampered, now)
+	assert.ErrorIs(t, err, ErrMalformedCookie)
+
+	_, err = DecodeSession(ring, strings.Replace(valid, "v2.k1.", "v2.k2.", 1), now)
+	assert.ErrorIs(t, err, ErrUnknownCookieKey)
+}
+
+func TestLegacyCookieDeadline(t *testing.T) {
+	defer func(deadline time.Time) { config.Settings.LegacyCookiesUntil = deadline }(config.Settings.LegacyCookiesUntil)
+	userID := uuid.New()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: CookieName, Value: GenerateLegacyCookieStringForUserID(userID)})
+
+	config.Settings.LegacyCookiesUntil = time.Now().Add(time.Hour)
+	got, err := GetUserIDFromCookie(r)
+	assert.NoError(t, err)
+	assert.Equal(t, userID, got)
+
+	config.Settings.LegacyCookiesUntil = time.Now().Add(-time.Hour)
+	got, err = GetUserIDFromCookie(r)
+	assert.Error(t, err)
+	assert.Equal(t, uuid.Nil, got)
+}
+
+func TestAuthCookieRenewal(t *testing.T) {
+	defer func(deadline time.Time) { config.Settings.LegacyCookiesUntil = deadline }(config.Settings.LegacyCookiesUntil)
+	config.Settings.LegacyCookiesUntil = time.Now().Add(time.Hour)
+	key, _ := AuthKeyRing.ActiveKey()
+	userID := uuid.New()
+	now := time.Now()
+	expiringSoon, err := EncodeSession(key, Session{
+		UserID:    userID,
+		IssuedAt:  now.Add(-config.Settings.CookieLifetime),
+		ExpiresAt: now.Add(time.Minute),
+	})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		cookie   string
+		renewed  bool
+		sameUser bool
+	}{
+		{name: "Missing cookie is issued", renewed: true},
+		{name: "Fresh cookie is kept", cookie: GenerateCookieStringForUserID(userID), sameUser: true},
+		{name: "Cookie expiring soon is renewed", cookie: expiringSoon, renewed: true, sameUser: true},
+		{name: "Legacy cookie is renewed", cookie: GenerateLegacyCookieStringForUserID(userID), renewed: true, sameUser: true},
+		{name: "Malformed cookie is replaced", cookie: "v2.broken", renewed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got uuid.UUID
+			handler := AuthCookie(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.Context().Value(UserIDKey).(uuid.UUID)
+			}))
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: CookieName, Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			assert.NotEqual(t, uuid.Nil, got)
+			assert.Equal(t, tt.sameUser, got == userID)
+			assert.Equal(t, tt.renewed, len(w.Result().Cookies()) == 1)
+		})
+	}
+}
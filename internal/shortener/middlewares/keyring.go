@@ -0,0 +1,143 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/config"
+)
+
+// DefaultKeyID id of the key built from `config.Settings.SecretAuthKey`.
+const DefaultKeyID = "default"
+
+// ErrDefaultSecret is returned when insecure default secret is used outside of test mode.
+var ErrDefaultSecret = errors.New("default auth secret must not be used, configure AUTH_SECRET_KEY or signing keys")
+
+// SigningKey secret to sign auth cookies with.
+//
+// Retired keys are neither used for signing nor for verification.
+type SigningKey struct {
+	ID      string `json:"id"`
+	Secret  string `json:"secret"`
+	Retired bool   `json:"retired"`
+}
+
+// KeyRing set of signing keys, the active one is used to sign new cookies.
+//
+// JSON representation is used both for AUTH_KEYS env variable and keys file:
+//
+//	{"active": "2023-05", "keys": [{"id": "2023-04", "secret": "..."}, {"id": "2023-05", "secret": "..."}]}
+type KeyRing struct {
+	Active string       `json:"active"`
+	Keys   []SigningKey `json:"keys"`
+}
+
+// AuthKeyRing key ring used by auth middleware, replaced by SetupKeyRing on start.
+var AuthKeyRing = &KeyRing{
+	Active: DefaultKeyID,
+	Keys:   []SigningKey{{ID: DefaultKeyID, Secret: config.Settings.SecretAuthKey}},
+}
+
+// ActiveKey returns key to sign with.
+func (ring *KeyRing) ActiveKey() (SigningKey, bool) {
+	return ring.Key(ring.Active)
+}
+
+// Key returns non-retired key by its id.
+func (ring *KeyRing) Key(id string) (SigningKey, bool) {
+	for _, key := range ring.Keys {
+		if key.ID == id && !key.Retired {
+			return key, true
+		}
+	}
+	return SigningKey{}, false
+}
+
+// VerificationKeys returns all non-retired keys.
+func (ring *KeyRing) VerificationKeys() []SigningKey {
+	keys := make([]SigningKey, 0, len(ring.Keys))
+	for _, key := range ring.Keys {
+		if !key.Retired {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Validate checks key ring consistency.
+func (ring *KeyRing) Validate() error {
+	seen := make(map[string]bool, len(ring.Keys))
+	for _, key := range ring.Keys {
+		if key.ID == "" || key.Secret == "" {
+			return errors.New("signing key must have both id and secret")
+		}
+		if seen[key.ID] {
+			return fmt.Errorf("duplicated signing key id %q", key.ID)
+		}
+		seen[key.ID] = true
+	}
+	if _, ok := ring.ActiveKey(); !ok {
+		return fmt.Errorf("active signing key %q is not found or retired", ring.Active)
+	}
+	return nil
+}
+
+// usesDefaultSecret reports if any non-retired key is the insecure default one.
+func (ring *KeyRing) usesDefaultSecret() bool {
+	for _, key := range ring.VerificationKeys() {
+		if key.Secret == config.DefaultSecretAuthKey {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadKeyRing builds key ring from settings.
+//
+// Keys file has priority over AUTH_KEYS env variable, single AUTH_SECRET_KEY is used if none of them is set.
+// If active key is not set explicitly the last non-retired one is used.
+func LoadKeyRing(settings config.AppSettings) (*KeyRing, error) {
+	var content []byte
+	switch {
+	case settings.AuthKeysFile != "":
+		var err error
+		if content, err = os.ReadFile(settings.AuthKeysFile); err != nil {
+			return nil, err
+		}
+	case settings.AuthKeys != "":
+		content = []byte(settings.AuthKeys)
+	}
+
+	ring := &KeyRing{
+		Active: DefaultKeyID,
+		Keys:   []SigningKey{{ID: DefaultKeyID, Secret: settings.SecretAuthKey}},
+	}
+	if content != nil {
+		ring = &KeyRing{}
+		if err := json.Unmarshal(content, ring); err != nil {
+			return nil, err
+		}
+		if keys := ring.VerificationKeys(); ring.Active == "" && len(keys) > 0 {
+			ring.Active = keys[len(keys)-1].ID
+		}
+	}
+	if err := ring.Validate(); err != nil {
+		return nil, err
+	}
+	if !settings.IsTestMode && ring.usesDefaultSecret() {
+		return nil, ErrDefaultSecret
+	}
+	return ring, nil
+}
+
+// SetupKeyRing loads key ring from application settings and makes it used by auth middleware.
+func SetupKeyRing() error {
+	ring, err := LoadKeyRing(config.Settings)
+	if err != nil {
+		return err
+	}
+	AuthKeyRing = ring
+	return nil
+}
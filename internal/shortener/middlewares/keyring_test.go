@@ -0,0 +1,65 @@
+package middlewares
+
+import (
+	"testing"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadKeyRing(t *testing.T) {
+	tests := []struct {
+		name       string
+		settings   config.AppSettings
+		wantActive string
+		wantErr    bool
+	}{
+		{
+			name:     "Default secret should be refused in non-test mode",
+			settings: config.AppSettings{SecretAuthKey: config.DefaultSecretAuthKey},
+			wantErr:  true,
+		},
+		{
+			name:       "Default secret should be allowed in test mode",
+			settings:   config.AppSettings{SecretAuthKey: config.DefaultSecretAuthKey, IsTestMode: true},
+			wantActive: DefaultKeyID,
+		},
+		{
+			name:       "Single secret should be used as default key",
+			settings:   config.AppSettings{SecretAuthKey: "secret"},
+			wantActive: DefaultKeyID,
+		},
+		{
+			name: "Last non-retired key should be active if not set",
+			settings: config.AppSettings{
+				AuthKeys: `{"keys": [{"id": "k1", "secret": "one"}, {"id": "k2", "secret": "two"}, {"id": "k3", "secret": "three", "retired": true}]}`,
+			},
+			wantActive: "k2",
+		},
+		{
+			name: "Retired active key should be refused",
+			settings: config.AppSettings{
+				AuthKeys: `{"active": "k1", "keys": [{"id": "k1", "secret": "one", "retired": true}]}`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "Duplicated key ids should be refused",
+			settings: config.AppSettings{
+				AuthKeys: `{"keys": [{"id": "k1", "secret": "one"}, {"id": "k1", "secret": "two"}]}`,
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ring, err := LoadKeyRing(tt.settings)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantActive, ring.Active)
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package middlewares
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// sessionCookieVersion prefix of encrypted cookie value.
+const sessionCookieVersion = "v2"
+
+// sessionClockSkew tolerated difference between clocks of application instances.
+const sessionClockSkew = time.Minute
+
+// Session cookie errors.
+var (
+	ErrMalformedCookie  = errors.New("malformed auth cookie")
+	ErrUnknownCookieKey = errors.New("auth cookie is signed with unknown or retired key")
+	ErrExpiredCookie    = errors.New("auth cookie is expired")
+)
+
+// Session payload of encrypted auth cookie.
+type Session struct {
+	UserID    uuid.UUID
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// EncodeSession encrypts session with given key.
+//
+// Cookie value has format `v2.<key id>.<base64url(nonce|ciphertext)>`,
+// version and key id are authenticated as additional data.
+func EncodeSession(key SigningKey, session Session) (string, error) {
+	aead, err := newSessionAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+32+aead.Overhead())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	plaintext := make([]byte, 0, 32)
+	plaintext = append(plaintext, session.UserID[:]...)
+	plaintext = binary.BigEndian.AppendUint64(plaintext, uint64(session.IssuedAt.Unix()))
+	plaintext = binary.BigEndian.AppendUint64(plaintext, uint64(session.ExpiresAt.Unix()))
+
+	header := sessionCookieVersion + "." + key.ID
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(header))
+	return header + "." + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// DecodeSession decrypts cookie value with key ring and validates issue and expiry time.
+func DecodeSession(ring *KeyRing, value string, now time.Time) (Session, error) {
+	parts := strings.Split(value, ".")
+	if len(parts) != 3 || parts[0] != sessionCookieVersion {
+		return Session{}, ErrMalformedCookie
+	}
+	key, ok := ring.Key(parts[1])
+	if !ok {
+		return Session{}, ErrUnknownCookieKey
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Session{}, ErrMalformedCookie
+	}
+	aead, err := newSessionAEAD(key)
+	if err != nil {
+		return Session{}, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return Session{}, ErrMalformedCookie
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(parts[0]+"."+parts[1]))
+	if err != nil || len(plaintext) != 32 {
+		return Session{}, ErrMalformedCookie
+	}
+
+	var session Session
+	copy(session.UserID[:], plaintext[:16])
+	session.IssuedAt = time.Unix(int64(binary.BigEndian.Uint64(plaintext[16:24])), 0)
+	session.ExpiresAt = time.Unix(int64(binary.BigEndian.Uint64(plaintext[24:32])), 0)
+
+	if session.IssuedAt.After(now.Add(sessionClockSkew)) || !session.ExpiresAt.After(now) {
+		return Session{}, ErrExpiredCookie
+	}
+	return session, nil
+}
+
+// newSessionAEAD builds AES-256-GCM cipher with key derived from signing key secret.
+func newSessionAEAD(key SigningKey) (cipher.AEAD, error) {
+	derived := sha256.Sum256([]byte("session-cookie|" + key.Secret))
+	block, err := aes.NewCipher(derived[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -3,9 +3,11 @@ package repositories
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"log"
-	"sync"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
@@ -16,39 +18,60 @@ import (
 	"github.com/jmoiron/sqlx"
 )
 
+// shortURLColumns columns of short_urls table in order expected by scanShortURL and given by shortURLValues.
+const shortURLColumns = "id, short_url, original_url, user_id, correlation_id, is_active, deleted_at, created_at, " +
+	"title, interstitial, redirect_type, passthrough, " +
+	"utm_source, utm_medium, utm_campaign, utm_term, utm_content, password_hash, max_clicks, clicks_left, rules, " +
+	"variants, sticky_variant, domain, workspace_id"
+
+// insertShortURLQuery inserts ShortURL with all of its columns.
+var insertShortURLQuery = "INSERT INTO short_urls (" + shortURLColumns + ") values (" +
+	placeholders(strings.Count(shortURLColumns, ",")+1) + ")"
+
+// editableByUser condition of ShortURLs user given twice as parameter may edit, delete or restore, personal one has to be
+// created by the user, one of workspace needs owner or editor role there.
+const editableByUser = "((workspace_id = '' AND user_id = ?) OR workspace_id IN (" +
+	"SELECT workspace_id FROM workspace_members WHERE user_id = ? AND role IN ('owner', 'editor')))"
+
+// selectByOriginalQuery selects ShortURL by domain, workspace and original url, they are unique together.
+const selectByOriginalQuery = "SELECT " + shortURLColumns + " FROM short_urls " +
+	"WHERE domain = $1 AND workspace_id = $2 AND original_url = $3;"
+
+// reassignMembersQuery copies workspace memberships of user to another one,
+// role with more rights is kept when both users are members of the same workspace.
+const reassignMembersQuery = `INSERT INTO workspace_members (workspace_id, user_id, role, added_at)
+	SELECT workspace_id, $1, role, added_at FROM workspace_members WHERE user_id = $2
+	ON CONFLICT (workspace_id, user_id) DO UPDATE SET role = EXCLUDED.role
+	WHERE array_position(ARRAY['viewer', 'editor', 'owner'], EXCLUDED.role::text) >
+		array_position(ARRAY['viewer', 'editor', 'owner'], workspace_members.role::text);`
+
+// saveDeleteJobQuery upserts deletion job, finished jobs expired since deleteJobTTL are removed by the way.
+const saveDeleteJobQuery = `WITH expired AS (
+		DELETE FROM delete_jobs WHERE state IN ('applied', 'partially_applied') AND updated_at < $10
+	)
+	INSERT INTO delete_jobs (id, user_id, ids, state, results, attempts, last_error, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	ON CONFLICT (id) DO UPDATE SET state = EXCLUDED.state, results = EXCLUDED.results,
+		attempts = EXCLUDED.attempts, last_error = EXCLUDED.last_error, updated_at = EXCLUDED.updated_at;`
+
+// deleteJobColumns columns of delete_jobs table in order expected by scanDeleteJob.
+const deleteJobColumns = "id, user_id, ids, state, results, attempts, last_error, created_at, updated_at"
+
 // DatabaseRepository repository based on database.
 type DatabaseRepository struct {
-	Storage  *sql.DB
-	ToDelete chan *entities.ItemToDelete
+	Storage *sql.DB
+	Batcher *DeleteBatcher
 }
 
-// lockURLToDeleteStorage mutex for deletion process.
-var lockURLToDeleteStorage = sync.Mutex{}
-
 // Create creates ShortURL.
 func (repo *DatabaseRepository) Create(ctx context.Context, shortURL entities.ShortURL) (entities.ShortURL, error) {
-	_, err := repo.Storage.ExecContext(
-		ctx,
-		"INSERT INTO short_urls (id, short_url, original_url, user_id, correlation_id) values ($1, $2, $3, $4, $5);",
-		shortURL.ID, shortURL.Short, shortURL.Original, shortURL.UserID.String(), shortURL.CorrelationID,
-	)
+	_, err := repo.Storage.ExecContext(ctx, insertShortURLQuery+";", shortURLValues(shortURL)...)
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
-			var existed entities.ShortURL
-			row := repo.Storage.QueryRowContext(
-				ctx,
-				"SELECT id, short_url, original_url, user_id, correlation_id, is_active FROM short_urls WHERE original_url = $1;",
-				shortURL.Original,
-			)
-			errExisted := row.Scan(
-				&existed.ID,
-				&existed.Short,
-				&existed.Original,
-				&existed.UserID,
-				&existed.CorrelationID,
-				&existed.IsActive,
-			)
+			existed, errExisted := scanShortURL(repo.Storage.QueryRowContext(
+				ctx, selectByOriginalQuery, shortURL.Domain, shortURL.WorkspaceID, shortURL.Original,
+			))
 			if errExisted != nil {
 				return entities.ShortURL{}, errExisted
 			}
@@ -69,24 +92,14 @@ func (repo *DatabaseRepository) CreateMultiple(
 		return []entities.ShortURL{}, err
 	}
 	defer tx.Rollback()
-	stmt, err := tx.PrepareContext(
-		ctx,
-		"INSERT INTO short_urls (id, short_url, original_url, user_id, correlation_id) values ($1, $2, $3, $4, $5);",
-	)
+	stmt, err := tx.PrepareContext(ctx, insertShortURLQuery+";")
 	if err != nil {
 		return []entities.ShortURL{}, err
 	}
 	defer stmt.Close()
 
 	for _, shortURL := range urls {
-		if _, err = stmt.ExecContext(
-			ctx,
-			shortURL.ID,
-			shortURL.Short,
-			shortURL.Original,
-			shortURL.UserID.String(),
-			shortURL.CorrelationID,
-		); err != nil {
+		if _, err = stmt.ExecContext(ctx, shortURLValues(shortURL)...); err != nil {
 			return []entities.ShortURL{}, err
 		}
 	}
@@ -96,33 +109,25 @@ func (repo *DatabaseRepository) CreateMultiple(
 
 // GetByID returns ShortURL by its id.
 func (repo *DatabaseRepository) GetByID(ctx context.Context, id string) (entities.ShortURL, bool, error) {
-	var shortURL entities.ShortURL
 	row := repo.Storage.QueryRowContext(
 		ctx,
-		"SELECT id, short_url, original_url, user_id, correlation_id, is_active FROM short_urls WHERE id = $1;",
+		"SELECT "+shortURLColumns+" FROM short_urls WHERE id = $1;",
 		id,
 	)
-	err := row.Scan(
-		&shortURL.ID,
-		&shortURL.Short,
-		&shortURL.Original,
-		&shortURL.UserID,
-		&shortURL.CorrelationID,
-		&shortURL.IsActive,
-	)
+	shortURL, err := scanShortURL(row)
 	if err != nil {
 		return entities.ShortURL{}, false, err
 	}
 	return shortURL, true, nil
 }
 
-// GetByUserID returns ShortURLs by user id.
+// GetByUserID returns personal ShortURLs by user id, ones created in workspaces are listed by GetByWorkspaceID.
 func (repo *DatabaseRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]entities.ShortURL, error) {
 	shortURLs := make([]entities.ShortURL, 0, 16)
 
 	rows, err := repo.Storage.QueryContext(
 		ctx,
-		"SELECT id, short_url, original_url, user_id, correlation_id, is_active FROM short_urls WHERE is_active=true AND user_id = $1;",
+		"SELECT "+shortURLColumns+" FROM short_urls WHERE is_active=true AND user_id = $1 AND workspace_id = '';",
 		userID.String(),
 	)
 	if err != nil {
@@ -132,14 +137,7 @@ func (repo *DatabaseRepository) GetByUserID(ctx context.Context, userID uuid.UUI
 
 	for rows.Next() {
 		var shortURL entities.ShortURL
-		err = rows.Scan(
-			&shortURL.ID,
-			&shortURL.Short,
-			&shortURL.Original,
-			&shortURL.UserID,
-			&shortURL.CorrelationID,
-			&shortURL.IsActive,
-		)
+		shortURL, err = scanShortURL(rows)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -155,64 +153,1497 @@ func (repo *DatabaseRepository) GetByUserID(ctx context.Context, userID uuid.UUI
 	return shortURLs, nil
 }
 
-// DeleteRecords deletes ShortURLs by ids.
-func (repo *DatabaseRepository) DeleteRecords(ctx context.Context, userID uuid.UUID, ids []string) error {
-	itemToDelete := &entities.ItemToDelete{
-		UserID:   userID,
-		ItemsIDs: ids,
+// IterateByUserID calls fn for every ShortURL of user including inactive ones while rows are read, stops on first error.
+func (repo *DatabaseRepository) IterateByUserID(
+	ctx context.Context,
+	userID uuid.UUID,
+	fn func(entities.ShortURL) error,
+) error {
+	return repo.iterateShortURLs(
+		ctx,
+		fn,
+		"SELECT "+shortURLColumns+" FROM short_urls WHERE user_id = $1 ORDER BY created_at, id;",
+		userID.String(),
+	)
+}
+
+// IterateAll calls fn for every ShortURL of every user while rows are read, stops on first error.
+func (repo *DatabaseRepository) IterateAll(ctx context.Context, fn func(entities.ShortURL) error) error {
+	return repo.iterateShortURLs(ctx, fn, "SELECT "+shortURLColumns+" FROM short_urls ORDER BY created_at, id;")
+}
+
+// LoadBackup stores ShortURLs as they are in single transaction, replacing the ones with the same id.
+func (repo *DatabaseRepository) LoadBackup(ctx context.Context, urls []entities.ShortURL) error {
+	tx, err := repo.Storage.Begin()
+	if err != nil {
+		return err
 	}
-	go func() { repo.ToDelete <- itemToDelete }()
-	return nil
+	defer tx.Rollback()
+	stmt, err := tx.PrepareContext(ctx, insertShortURLQuery+" ON CONFLICT (id) DO UPDATE SET "+excludedColumns()+";")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, shortURL := range urls {
+		if _, err = stmt.ExecContext(ctx, shortURLValues(shortURL)...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteRecords deletes ShortURLs by ids, state of returned job is tracked until it is applied.
+func (repo *DatabaseRepository) DeleteRecords(
+	ctx context.Context,
+	userID uuid.UUID,
+	ids []string,
+) (entities.DeleteJob, error) {
+	return deleteRecordsWith(ctx, repo.Batcher, repo, repo.DeleteRecordsForUser, userID, ids)
+}
+
+// GetDeleteJob returns deletion job by its id, finished jobs are kept for deleteJobTTL.
+func (repo *DatabaseRepository) GetDeleteJob(ctx context.Context, id string) (entities.DeleteJob, bool, error) {
+	job, err := scanDeleteJob(repo.Storage.QueryRowContext(
+		ctx,
+		"SELECT "+deleteJobColumns+" FROM delete_jobs "+
+			"WHERE id = $1 AND (state IN ('queued', 'failed') OR updated_at >= $2);",
+		id,
+		time.Now().UTC().Add(-deleteJobTTL),
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return job, false, nil
+	}
+	return job, err == nil, err
+}
+
+// ResumeDeleteJobs queues deletion jobs left queued by previous run again, returns number of them.
+func (repo *DatabaseRepository) ResumeDeleteJobs(ctx context.Context) (int, error) {
+	rows, err := repo.Storage.QueryContext(
+		ctx,
+		"SELECT "+deleteJobColumns+" FROM delete_jobs WHERE state = 'queued' ORDER BY created_at;",
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	jobs := make([]entities.DeleteJob, 0)
+	for rows.Next() {
+		job, errScan := scanDeleteJob(rows)
+		if errScan != nil {
+			return 0, errScan
+		}
+		jobs = append(jobs, job)
+	}
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	for _, job := range jobs {
+		resumeDeleteJob(ctx, repo.Batcher, repo, repo.DeleteRecordsForUser, job)
+	}
+	return len(jobs), nil
+}
+
+// SaveDeleteJob persists deletion job.
+func (repo *DatabaseRepository) SaveDeleteJob(ctx context.Context, job entities.DeleteJob) error {
+	ids, err := json.Marshal(job.IDs)
+	if err != nil {
+		return err
+	}
+	results, err := json.Marshal(job.Results)
+	if err != nil {
+		return err
+	}
+	_, err = repo.Storage.ExecContext(
+		ctx,
+		saveDeleteJobQuery,
+		job.ID,
+		job.UserID,
+		ids,
+		job.State,
+		results,
+		job.Attempts,
+		job.LastError,
+		job.CreatedAt,
+		job.UpdatedAt,
+		time.Now().UTC().Add(-deleteJobTTL),
+	)
+	return err
+}
+
+// scanDeleteJob scans DeleteJob from the row selected with deleteJobColumns.
+func scanDeleteJob(row rowScanner) (entities.DeleteJob, error) {
+	var job entities.DeleteJob
+	var ids, results []byte
+	err := row.Scan(
+		&job.ID,
+		&job.UserID,
+		&ids,
+		&job.State,
+		&results,
+		&job.Attempts,
+		&job.LastError,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+	)
+	if err != nil {
+		return job, err
+	}
+	if err = json.Unmarshal(ids, &job.IDs); err != nil {
+		return job, err
+	}
+	return job, json.Unmarshal(results, &job.Results)
+}
+
+// DeleteRecordsForUser deletes ShortURLs of user by ids, returns ids of deleted ones.
+func (repo *DatabaseRepository) DeleteRecordsForUser(ctx context.Context, userID uuid.UUID, ids []string) ([]string, error) {
+	query, args, err := sqlx.In(
+		"UPDATE short_urls SET is_active=false, deleted_at=now() WHERE is_active=true AND id IN (?) AND "+
+			editableByUser+" RETURNING id",
+		ids,
+		userID.String(),
+		userID.String(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := repo.Storage.QueryContext(ctx, sqlx.Rebind(sqlx.DOLLAR, query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deleted := make([]string, 0, len(ids))
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		deleted = append(deleted, id)
+	}
+	return deleted, rows.Err()
 }
 
-// AccumulateRecordsToDelete accumulates ShortURLs to delete in background.
-func (repo *DatabaseRepository) AccumulateRecordsToDelete() {
-	ticker := time.NewTicker(time.Millisecond * 500)
-	defer ticker.Stop()
-
-	localStorage := make(map[uuid.UUID][]string)
-
-	go func() {
-		for range ticker.C {
-			lockURLToDeleteStorage.Lock()
-			for userID, ids := range localStorage {
-				err := repo.DeleteRecordsForUser(context.Background(), userID, ids)
-				if err != nil {
-					repo.ToDelete <- &entities.ItemToDelete{
-						UserID:   userID,
-						ItemsIDs: ids,
-					}
-				}
-				delete(localStorage, userID)
+// Update changes destination of ShortURL user may edit, previous destination is kept in history.
+func (repo *DatabaseRepository) Update(
+	ctx context.Context,
+	userID uuid.UUID,
+	id string,
+	original string,
+) (entities.ShortURL, error) {
+	tx, err := repo.Storage.BeginTx(ctx, nil)
+	if err != nil {
+		return entities.ShortURL{}, err
+	}
+	defer tx.Rollback()
+
+	shortURL, err := scanShortURL(tx.QueryRowContext(
+		ctx,
+		sqlx.Rebind(sqlx.DOLLAR, "SELECT "+shortURLColumns+" FROM short_urls WHERE id = ? AND is_active=true AND "+
+			editableByUser+" FOR UPDATE;"),
+		id, userID.String(), userID.String(),
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return entities.ShortURL{}, shortenerrors.ErrItemNotFound
+	}
+	if err != nil {
+		return entities.ShortURL{}, err
+	}
+	if shortURL.Original == original {
+		return shortURL, nil
+	}
+
+	if _, err = tx.ExecContext(
+		ctx,
+		"UPDATE short_urls SET original_url = $1 WHERE id = $2;",
+		original, id,
+	); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+			tx.Rollback()
+			existed, exist, errExisted := repo.GetByOriginal(ctx, shortURL.Domain, shortURL.WorkspaceID, original)
+			if errExisted != nil || !exist {
+				return entities.ShortURL{}, err
 			}
-			lockURLToDeleteStorage.Unlock()
+			return existed, shortenerrors.ErrItemAlreadyExists
+		}
+		return entities.ShortURL{}, err
+	}
+	if _, err = tx.ExecContext(
+		ctx,
+		"INSERT INTO short_url_history (short_url_id, original_url, changed_by, changed_at) values ($1, $2, $3, $4);",
+		id, shortURL.Original, userID.String(), time.Now().UTC(),
+	); err != nil {
+		return entities.ShortURL{}, err
+	}
+	shortURL.Original = original
+	return shortURL, tx.Commit()
+}
+
+// SetVariants replaces split destinations of ShortURL user may edit.
+func (repo *DatabaseRepository) SetVariants(
+	ctx context.Context,
+	userID uuid.UUID,
+	id string,
+	variants entities.Variants,
+	sticky bool,
+) (entities.ShortURL, error) {
+	return repo.updateReturning(
+		ctx,
+		sqlx.Rebind(sqlx.DOLLAR, "UPDATE short_urls SET variants = ?, sticky_variant = ? "+
+			"WHERE id = ? AND is_active=true AND "+editableByUser+" RETURNING "+shortURLColumns+";"),
+		variants, sticky, id, userID.String(), userID.String(),
+	)
+}
+
+// RecordClick stores click.
+func (repo *DatabaseRepository) RecordClick(ctx context.Context, click entities.Click) error {
+	_, err := repo.Storage.ExecContext(
+		ctx,
+		"INSERT INTO short_url_clicks (short_url_id, variant, clicked_at) values ($1, $2, $3);",
+		click.ShortURLID, click.Variant, click.ClickedAt,
+	)
+	return err
+}
+
+// GetVariantClicks returns numbers of clicks of ShortURL by variant id.
+func (repo *DatabaseRepository) GetVariantClicks(ctx context.Context, id string) (map[string]int64, error) {
+	clicks := make(map[string]int64)
+	rows, err := repo.Storage.QueryContext(
+		ctx,
+		"SELECT variant, count(*) FROM short_url_clicks WHERE short_url_id = $1 GROUP BY variant;",
+		id,
+	)
+	if err != nil {
+		return clicks, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var variant string
+		var count int64
+		if err = rows.Scan(&variant, &count); err != nil {
+			return clicks, err
 		}
-	}()
+		clicks[variant] = count
+	}
+	return clicks, rows.Err()
+}
 
-	for item := range repo.ToDelete {
-		lockURLToDeleteStorage.Lock()
-		urlsIDs, exist := localStorage[item.UserID]
-		if exist {
-			localStorage[item.UserID] = append(urlsIDs, item.ItemsIDs...)
-		} else {
-			localStorage[item.UserID] = item.ItemsIDs
+// GetHistory returns previous destinations of ShortURL, newest first.
+func (repo *DatabaseRepository) GetHistory(ctx context.Context, id string) ([]entities.DestinationChange, error) {
+	changes := make([]entities.DestinationChange, 0, 8)
+	rows, err := repo.Storage.QueryContext(
+		ctx,
+		"SELECT short_url_id, original_url, changed_by, changed_at FROM short_url_history WHERE short_url_id = $1 ORDER BY id DESC;",
+		id,
+	)
+	if err != nil {
+		return changes, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var change entities.DestinationChange
+		if err = rows.Scan(&change.ShortURLID, &change.Original, &change.ChangedBy, &change.ChangedAt); err != nil {
+			return changes, err
 		}
-		lockURLToDeleteStorage.Unlock()
+		changes = append(changes, change)
 	}
+	return changes, rows.Err()
 }
 
-// DeleteRecordsForUser deletes all ShortURLs for user.
-func (repo *DatabaseRepository) DeleteRecordsForUser(ctx context.Context, userID uuid.UUID, ids []string) error {
-	query, args, _ := sqlx.In(
-		"UPDATE short_urls SET is_active=false WHERE user_id = ? AND id IN (?)",
+// GetDeletedByUserID returns ShortURLs deleted by user.
+func (repo *DatabaseRepository) GetDeletedByUserID(ctx context.Context, userID uuid.UUID) ([]entities.ShortURL, error) {
+	return repo.queryShortURLs(
+		ctx,
+		"SELECT "+shortURLColumns+" FROM short_urls WHERE is_active=false AND deleted_at IS NOT NULL AND user_id = $1;",
 		userID.String(),
+	)
+}
+
+// RestoreRecords restores ShortURLs deleted by user, returns restored ones.
+func (repo *DatabaseRepository) RestoreRecords(
+	ctx context.Context,
+	userID uuid.UUID,
+	ids []string,
+) ([]entities.ShortURL, error) {
+	query, args, err := sqlx.In(
+		"UPDATE short_urls SET is_active=true, deleted_at=NULL "+
+			"WHERE is_active=false AND deleted_at IS NOT NULL AND id IN (?) AND "+editableByUser+" "+
+			"RETURNING "+shortURLColumns,
 		ids,
+		userID.String(),
+		userID.String(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return repo.queryShortURLs(ctx, sqlx.Rebind(sqlx.DOLLAR, query), args...)
+}
+
+// PurgeDeleted removes ShortURLs deleted before the time, returns number of removed ones.
+func (repo *DatabaseRepository) PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	result, err := repo.Storage.ExecContext(
+		ctx,
+		"DELETE FROM short_urls WHERE is_active=false AND deleted_at < $1;",
+		deletedBefore,
 	)
-	query = sqlx.Rebind(sqlx.DOLLAR, query)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// CreateAPIKey creates APIKey.
+func (repo *DatabaseRepository) CreateAPIKey(ctx context.Context, key entities.APIKey) (entities.APIKey, error) {
 	_, err := repo.Storage.ExecContext(
 		ctx,
-		query,
-		args...,
+		"INSERT INTO api_keys (id, name, key_hash, user_id, scopes, created_at, is_active) values ($1, $2, $3, $4, $5, $6, $7);",
+		key.ID, key.Name, key.KeyHash, key.UserID.String(), strings.Join(key.Scopes, ","), key.CreatedAt, key.IsActive,
+	)
+	if err != nil {
+		return entities.APIKey{}, err
+	}
+	return key, nil
+}
+
+// GetAPIKeyByHash returns APIKey by hash of its raw value.
+func (repo *DatabaseRepository) GetAPIKeyByHash(ctx context.Context, hash string) (entities.APIKey, bool, error) {
+	row := repo.Storage.QueryRowContext(
+		ctx,
+		"SELECT id, name, key_hash, user_id, scopes, created_at, is_active FROM api_keys WHERE key_hash = $1;",
+		hash,
+	)
+	key, err := scanAPIKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entities.APIKey{}, false, nil
+	}
+	if err != nil {
+		return entities.APIKey{}, false, err
+	}
+	return key, true, nil
+}
+
+// GetAPIKeysByUserID returns APIKeys by user id.
+func (repo *DatabaseRepository) GetAPIKeysByUserID(ctx context.Context, userID uuid.UUID) ([]entities.APIKey, error) {
+	keys := make([]entities.APIKey, 0, 4)
+
+	rows, err := repo.Storage.QueryContext(
+		ctx,
+		"SELECT id, name, key_hash, user_id, scopes, created_at, is_active FROM api_keys WHERE user_id = $1;",
+		userID.String(),
+	)
+	if err != nil {
+		return keys, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		key, errScan := scanAPIKey(rows)
+		if errScan != nil {
+			return keys, errScan
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeAPIKey deactivates APIKey owned by user.
+func (repo *DatabaseRepository) RevokeAPIKey(ctx context.Context, userID uuid.UUID, id string) error {
+	result, err := repo.Storage.ExecContext(
+		ctx,
+		"UPDATE api_keys SET is_active=false WHERE user_id = $1 AND id = $2;",
+		userID.String(), id,
+	)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return shortenerrors.ErrItemNotFound
+	}
+	return nil
+}
+
+// CreateUser creates User, username has to be unique.
+func (repo *DatabaseRepository) CreateUser(ctx context.Context, user entities.User) (entities.User, error) {
+	if err := insertUser(ctx, repo.Storage, user); err != nil {
+		return entities.User{}, err
+	}
+	return user, nil
+}
+
+// CreateUserWithRecords creates User and moves all records of another user to it in a single transaction,
+// returns number of moved ShortURLs.
+func (repo *DatabaseRepository) CreateUserWithRecords(
+	ctx context.Context,
+	user entities.User,
+	fromUserID uuid.UUID,
+) (entities.User, int64, error) {
+	tx, err := repo.Storage.BeginTx(ctx, nil)
+	if err != nil {
+		return entities.User{}, 0, err
+	}
+	defer tx.Rollback()
+
+	if err = insertUser(ctx, tx, user); err != nil {
+		return entities.User{}, 0, err
+	}
+	moved, err := reassignRecordsTx(ctx, tx, fromUserID, user.ID)
+	if err != nil {
+		return entities.User{}, 0, err
+	}
+	if err = tx.Commit(); err != nil {
+		return entities.User{}, 0, err
+	}
+	return user, moved, nil
+}
+
+// insertUser inserts User with executor given, taken username is reported as ErrItemAlreadyExists.
+func insertUser(
+	ctx context.Context,
+	executor execer,
+	user entities.User,
+) error {
+	_, err := executor.ExecContext(
+		ctx,
+		"INSERT INTO users (id, username, password_hash, created_at) values ($1, $2, $3, $4);",
+		user.ID.String(), user.Username, user.PasswordHash, user.CreatedAt,
 	)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+		return shortenerrors.ErrItemAlreadyExists
+	}
 	return err
 }
+
+// GetUserByID returns User by its id.
+func (repo *DatabaseRepository) GetUserByID(ctx context.Context, id uuid.UUID) (entities.User, bool, error) {
+	row := repo.Storage.QueryRowContext(
+		ctx,
+		"SELECT id, username, password_hash, created_at FROM users WHERE id = $1;",
+		id.String(),
+	)
+	return scanUser(row)
+}
+
+// GetUserByUsername returns User by its username.
+func (repo *DatabaseRepository) GetUserByUsername(ctx context.Context, username string) (entities.User, bool, error) {
+	row := repo.Storage.QueryRowContext(
+		ctx,
+		"SELECT id, username, password_hash, created_at FROM users WHERE username = $1;",
+		username,
+	)
+	return scanUser(row)
+}
+
+// ReassignRecords moves all ShortURLs and APIKeys of one user to another in a single transaction.
+func (repo *DatabaseRepository) ReassignRecords(
+	ctx context.Context,
+	fromUserID uuid.UUID,
+	toUserID uuid.UUID,
+) (int64, error) {
+	tx, err := repo.Storage.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	moved, err := reassignRecordsTx(ctx, tx, fromUserID, toUserID)
+	if err != nil {
+		return 0, err
+	}
+	return moved, tx.Commit()
+}
+
+// reassignRecordsTx moves all ShortURLs, APIKeys and workspace memberships of one user to another within tx.
+func reassignRecordsTx(ctx context.Context, tx *sql.Tx, fromUserID uuid.UUID, toUserID uuid.UUID) (int64, error) {
+	result, err := tx.ExecContext(
+		ctx,
+		"UPDATE short_urls SET user_id = $1 WHERE user_id = $2;",
+		toUserID.String(), fromUserID.String(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	moved, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if _, err = tx.ExecContext(
+		ctx,
+		"UPDATE api_keys SET user_id = $1 WHERE user_id = $2;",
+		toUserID.String(), fromUserID.String(),
+	); err != nil {
+		return 0, err
+	}
+	if _, err = tx.ExecContext(ctx, reassignMembersQuery, toUserID.String(), fromUserID.String()); err != nil {
+		return 0, err
+	}
+	if _, err = tx.ExecContext(
+		ctx,
+		"DELETE FROM workspace_members WHERE user_id = $1;",
+		fromUserID.String(),
+	); err != nil {
+		return 0, err
+	}
+	return moved, nil
+}
+
+// scanUser scans User from the row, missing row is not an error.
+func scanUser(row *sql.Row) (entities.User, bool, error) {
+	var user entities.User
+	err := row.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entities.User{}, false, nil
+	}
+	if err != nil {
+		return entities.User{}, false, err
+	}
+	return user, true, nil
+}
+
+// GetByOriginal returns ShortURL by its original url, original url is unique within domain and workspace.
+func (repo *DatabaseRepository) GetByOriginal(
+	ctx context.Context,
+	domain string,
+	workspaceID string,
+	original string,
+) (entities.ShortURL, bool, error) {
+	shortURL, err := scanShortURL(repo.Storage.QueryRowContext(ctx, selectByOriginalQuery, domain, workspaceID, original))
+	if errors.Is(err, sql.ErrNoRows) {
+		return entities.ShortURL{}, false, nil
+	}
+	if err != nil {
+		return entities.ShortURL{}, false, err
+	}
+	return shortURL, true, nil
+}
+
+// GetAllByUserID returns ShortURLs by user id including inactive ones.
+func (repo *DatabaseRepository) GetAllByUserID(ctx context.Context, userID uuid.UUID) ([]entities.ShortURL, error) {
+	return repo.queryShortURLs(
+		ctx,
+		"SELECT "+shortURLColumns+" FROM short_urls WHERE user_id = $1;",
+		userID.String(),
+	)
+}
+
+// UseClick takes one of clicks left of active ShortURL in a single statement, so concurrent redirects
+// never take more clicks than there are.
+func (repo *DatabaseRepository) UseClick(ctx context.Context, id string) (entities.ShortURL, error) {
+	shortURL, err := repo.updateReturning(
+		ctx,
+		"UPDATE short_urls SET clicks_left = clicks_left - 1 "+
+			"WHERE id = $1 AND is_active AND clicks_left > 0 RETURNING "+shortURLColumns+";",
+		id,
+	)
+	if errors.Is(err, shortenerrors.ErrItemNotFound) {
+		return shortURL, shortenerrors.ErrClicksExhausted
+	}
+	return shortURL, err
+}
+
+// SetActive activates or deactivates ShortURL regardless of its owner.
+func (repo *DatabaseRepository) SetActive(ctx context.Context, id string, isActive bool) (entities.ShortURL, error) {
+	return repo.updateReturning(
+		ctx,
+		"UPDATE short_urls SET is_active = $1, deleted_at = CASE WHEN $1 THEN NULL ELSE deleted_at END "+
+			"WHERE id = $2 RETURNING "+shortURLColumns+";",
+		isActive, id,
+	)
+}
+
+// SetOwner changes owner of ShortURL.
+func (repo *DatabaseRepository) SetOwner(ctx context.Context, id string, userID uuid.UUID) (entities.ShortURL, error) {
+	return repo.updateReturning(
+		ctx,
+		"UPDATE short_urls SET user_id = $1 WHERE id = $2 RETURNING "+shortURLColumns+";",
+		userID.String(), id,
+	)
+}
+
+// WriteAuditLog appends entry to audit log.
+func (repo *DatabaseRepository) WriteAuditLog(ctx context.Context, entry entities.AuditLogEntry) error {
+	_, err := repo.Storage.ExecContext(
+		ctx,
+		"INSERT INTO audit_log (actor, action, target, details, created_at) values ($1, $2, $3, $4, $5);",
+		entry.Actor, entry.Action, entry.Target, entry.Details, entry.CreatedAt,
+	)
+	return err
+}
+
+// GetAuditLog returns latest audit log entries, newest first.
+func (repo *DatabaseRepository) GetAuditLog(ctx context.Context, limit int) ([]entities.AuditLogEntry, error) {
+	entries := make([]entities.AuditLogEntry, 0, limit)
+	rows, err := repo.Storage.QueryContext(
+		ctx,
+		"SELECT actor, action, target, details, created_at FROM audit_log ORDER BY id DESC LIMIT $1;",
+		limit,
+	)
+	if err != nil {
+		return entries, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry entities.AuditLogEntry
+		if err = rows.Scan(&entry.Actor, &entry.Action, &entry.Target, &entry.Details, &entry.CreatedAt); err != nil {
+			return entries, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// GetDeadLetterDeleteJobs returns deletion jobs given up after all retries.
+func (repo *DatabaseRepository) GetDeadLetterDeleteJobs(ctx context.Context) ([]entities.DeleteJob, error) {
+	rows, err := repo.Storage.QueryContext(
+		ctx,
+		"SELECT "+deleteJobColumns+" FROM ("+
+			"SELECT "+deleteJobColumns+" FROM delete_jobs WHERE state = 'failed' ORDER BY updated_at DESC LIMIT $1"+
+			") AS latest ORDER BY updated_at;",
+		maxDeadLetters,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	jobs := make([]entities.DeleteJob, 0)
+	for rows.Next() {
+		job, err := scanDeleteJob(rows)
+		if err != nil {
+			return jobs, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// GetExisting returns stored ShortURLs having the same id or original url as any of urls.
+func (repo *DatabaseRepository) GetExisting(ctx context.Context, urls []entities.ShortURL) ([]entities.ShortURL, error) {
+	if len(urls) == 0 {
+		return []entities.ShortURL{}, nil
+	}
+	ids := make([]string, 0, len(urls))
+	originals := make([]string, 0, len(urls))
+	for _, url := range urls {
+		ids = append(ids, url.ID)
+		originals = append(originals, url.Original)
+	}
+	query, args, err := sqlx.In(
+		"SELECT "+shortURLColumns+" FROM short_urls WHERE id IN (?) OR original_url IN (?);",
+		ids,
+		originals,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return repo.queryShortURLs(ctx, sqlx.Rebind(sqlx.DOLLAR, query), args...)
+}
+
+// queryShortURLs returns all ShortURLs selected by query.
+func (repo *DatabaseRepository) queryShortURLs(ctx context.Context, query string, args ...any) ([]entities.ShortURL, error) {
+	shortURLs := make([]entities.ShortURL, 0, 16)
+	rows, err := repo.Storage.QueryContext(ctx, query, args...)
+	if err != nil {
+		return shortURLs, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		shortURL, errScan := scanShortURL(rows)
+		if errScan != nil {
+			return shortURLs, errScan
+		}
+		shortURLs = append(shortURLs, shortURL)
+	}
+	return shortURLs, rows.Err()
+}
+
+// iterateShortURLs calls fn for every ShortURL selected by query while rows are read.
+func (repo *DatabaseRepository) iterateShortURLs(
+	ctx context.Context,
+	fn func(entities.ShortURL) error,
+	query string,
+	args ...any,
+) error {
+	rows, err := repo.Storage.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		shortURL, errScan := scanShortURL(rows)
+		if errScan != nil {
+			return errScan
+		}
+		if err = fn(shortURL); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// updateReturning executes update query returning changed ShortURL.
+func (repo *DatabaseRepository) updateReturning(ctx context.Context, query string, args ...any) (entities.ShortURL, error) {
+	shortURL, err := scanShortURL(repo.Storage.QueryRowContext(ctx, query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return entities.ShortURL{}, shortenerrors.ErrItemNotFound
+	}
+	return shortURL, err
+}
+
+// execer is implemented by both *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanShortURL scans ShortURL from the row selected with shortURLColumns.
+func scanShortURL(row rowScanner) (entities.ShortURL, error) {
+	var shortURL entities.ShortURL
+	var deletedAt sql.NullTime
+	var utm entities.UTM
+	err := row.Scan(
+		&shortURL.ID,
+		&shortURL.Short,
+		&shortURL.Original,
+		&shortURL.UserID,
+		&shortURL.CorrelationID,
+		&shortURL.IsActive,
+		&deletedAt,
+		&shortURL.CreatedAt,
+		&shortURL.Title,
+		&shortURL.Interstitial,
+		&shortURL.RedirectType,
+		&shortURL.Passthrough,
+		&utm.Source,
+		&utm.Medium,
+		&utm.Campaign,
+		&utm.Term,
+		&utm.Content,
+		&shortURL.PasswordHash,
+		&shortURL.MaxClicks,
+		&shortURL.ClicksLeft,
+		&shortURL.Rules,
+		&shortURL.Variants,
+		&shortURL.StickyVariant,
+		&shortURL.Domain,
+		&shortURL.WorkspaceID,
+	)
+	if err != nil {
+		return entities.ShortURL{}, err
+	}
+	if deletedAt.Valid {
+		shortURL.DeletedAt = &deletedAt.Time
+	}
+	if !utm.IsEmpty() {
+		shortURL.UTM = &utm
+	}
+	return shortURL, nil
+}
+
+// shortURLValues returns values of ShortURL in order of shortURLColumns.
+func shortURLValues(shortURL entities.ShortURL) []any {
+	var utm entities.UTM
+	if shortURL.UTM != nil {
+		utm = *shortURL.UTM
+	}
+	return []any{
+		shortURL.ID,
+		shortURL.Short,
+		shortURL.Original,
+		shortURL.UserID.String(),
+		shortURL.CorrelationID,
+		shortURL.IsActive,
+		shortURL.DeletedAt,
+		shortURL.CreatedAt,
+		shortURL.Title,
+		shortURL.Interstitial,
+		shortURL.RedirectType,
+		shortURL.Passthrough,
+		utm.Source,
+		utm.Medium,
+		utm.Campaign,
+		utm.Term,
+		utm.Content,
+		shortURL.PasswordHash,
+		shortURL.MaxClicks,
+		shortURL.ClicksLeft,
+		shortURL.Rules,
+		shortURL.Variants,
+		shortURL.StickyVariant,
+		shortURL.Domain,
+		shortURL.WorkspaceID,
+	}
+}
+
+// placeholders returns list of count positional query parameters.
+func placeholders(count int) string {
+	params := make([]string, 0, count)
+	for i := 1; i <= count; i++ {
+		params = append(params, "$"+strconv.Itoa(i))
+	}
+	return strings.Join(params, ", ")
+}
+
+// excludedColumns returns assignments of all short_urls columns but id from the row of failed insert.
+func excludedColumns() string {
+	columns := strings.Split(shortURLColumns, ", ")[1:]
+	assignments := make([]string, 0, len(columns))
+	for _, column := range columns {
+		assignments = append(assignments, column+" = EXCLUDED."+column)
+	}
+	return strings.Join(assignments, ", ")
+}
+
+// scanAPIKey scans APIKey from the row.
+func scanAPIKey(row rowScanner) (entities.APIKey, error) {
+	var key entities.APIKey
+	var scopes string
+	err := row.Scan(
+		&key.ID,
+		&key.Name,
+		&key.KeyHash,
+		&key.UserID,
+		&scopes,
+		&key.CreatedAt,
+		&key.IsActive,
+	)
+	if err != nil {
+		return entities.APIKey{}, err
+	}
+	if scopes != "" {
+		key.Scopes = strings.Split(scopes, ",")
+	}
+	return key, nil
+}
+
+// CreateWorkspace creates Workspace with its creator as owner in single transaction.
+func (repo *DatabaseRepository) CreateWorkspace(
+	ctx context.Context,
+	workspace entities.Workspace,
+) (entities.Workspace, error) {
+	tx, err := repo.Storage.BeginTx(ctx, nil)
+	if err != nil {
+		return entities.Workspace{}, err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(
+		ctx,
+		"INSERT INTO workspaces (id, name, created_by, created_at) values ($1, $2, $3, $4);",
+		workspace.ID, workspace.Name, workspace.CreatedBy.String(), workspace.CreatedAt,
+	); err != nil {
+		return entities.Workspace{}, err
+	}
+	if _, err = tx.ExecContext(
+		ctx,
+		"INSERT INTO workspace_members (workspace_id, user_id, role, added_at) values ($1, $2, $3, $4);",
+		workspace.ID, workspace.CreatedBy.String(), entities.RoleOwner, workspace.CreatedAt,
+	); err != nil {
+		return entities.Workspace{}, err
+	}
+	return workspace, tx.Commit()
+}
+
+// GetWorkspacesByUserID returns Workspaces user is member of with their role, oldest first.
+func (repo *DatabaseRepository) GetWorkspacesByUserID(
+	ctx context.Context,
+	userID uuid.UUID,
+) ([]entities.WorkspaceMembership, error) {
+	workspaces := make([]entities.WorkspaceMembership, 0, 4)
+	rows, err := repo.Storage.QueryContext(
+		ctx,
+		"SELECT w.id, w.name, w.created_by, w.created_at, m.role FROM workspaces w "+
+			"JOIN workspace_members m ON m.workspace_id = w.id WHERE m.user_id = $1 ORDER BY w.created_at, w.id;",
+		userID.String(),
+	)
+	if err != nil {
+		return workspaces, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var workspace entities.WorkspaceMembership
+		if err = rows.Scan(
+			&workspace.ID,
+			&workspace.Name,
+			&workspace.CreatedBy,
+			&workspace.CreatedAt,
+			&workspace.Role,
+		); err != nil {
+			return workspaces, err
+		}
+		workspaces = append(workspaces, workspace)
+	}
+	return workspaces, rows.Err()
+}
+
+// GetWorkspaceMember returns member of Workspace by user id.
+func (repo *DatabaseRepository) GetWorkspaceMember(
+	ctx context.Context,
+	workspaceID string,
+	userID uuid.UUID,
+) (entities.WorkspaceMember, bool, error) {
+	member, err := scanWorkspaceMember(repo.Storage.QueryRowContext(
+		ctx,
+		"SELECT workspace_id, user_id, role, added_at FROM workspace_members WHERE workspace_id = $1 AND user_id = $2;",
+		workspaceID, userID.String(),
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return entities.WorkspaceMember{}, false, nil
+	}
+	if err != nil {
+		return entities.WorkspaceMember{}, false, err
+	}
+	return member, true, nil
+}
+
+// GetWorkspaceMembers returns members of Workspace in order they were added.
+func (repo *DatabaseRepository) GetWorkspaceMembers(
+	ctx context.Context,
+	workspaceID string,
+) ([]entities.WorkspaceMember, error) {
+	members := make([]entities.WorkspaceMember, 0, 8)
+	rows, err := repo.Storage.QueryContext(
+		ctx,
+		"SELECT workspace_id, user_id, role, added_at FROM workspace_members WHERE workspace_id = $1 "+
+			"ORDER BY added_at, user_id;",
+		workspaceID,
+	)
+	if err != nil {
+		return members, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		member, errScan := scanWorkspaceMember(rows)
+		if errScan != nil {
+			return members, errScan
+		}
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
+
+// SetWorkspaceMember adds member to Workspace or changes role of existing one.
+func (repo *DatabaseRepository) SetWorkspaceMember(
+	ctx context.Context,
+	member entities.WorkspaceMember,
+) (entities.WorkspaceMember, error) {
+	stored, err := scanWorkspaceMember(repo.Storage.QueryRowContext(
+		ctx,
+		"INSERT INTO workspace_members (workspace_id, user_id, role, added_at) values ($1, $2, $3, $4) "+
+			"ON CONFLICT (workspace_id, user_id) DO UPDATE SET role = EXCLUDED.role "+
+			"RETURNING workspace_id, user_id, role, added_at;",
+		member.WorkspaceID, member.UserID.String(), member.Role, member.AddedAt,
+	))
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.ForeignKeyViolation {
+		return entities.WorkspaceMember{}, shortenerrors.ErrItemNotFound
+	}
+	if err != nil {
+		return entities.WorkspaceMember{}, err
+	}
+	return stored, nil
+}
+
+// RemoveWorkspaceMember removes member from Workspace.
+func (repo *DatabaseRepository) RemoveWorkspaceMember(
+	ctx context.Context,
+	workspaceID string,
+	userID uuid.UUID,
+) error {
+	result, err := repo.Storage.ExecContext(
+		ctx,
+		"DELETE FROM workspace_members WHERE workspace_id = $1 AND user_id = $2;",
+		workspaceID, userID.String(),
+	)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return shortenerrors.ErrItemNotFound
+	}
+	return nil
+}
+
+// GetByWorkspaceID returns active ShortURLs of Workspace.
+func (repo *DatabaseRepository) GetByWorkspaceID(
+	ctx context.Context,
+	workspaceID string,
+) ([]entities.ShortURL, error) {
+	return repo.queryShortURLs(
+		ctx,
+		"SELECT "+shortURLColumns+" FROM short_urls WHERE is_active=true AND workspace_id = $1;",
+		workspaceID,
+	)
+}
+
+// scanWorkspaceMember reads WorkspaceMember from row.
+func scanWorkspaceMember(row rowScanner) (entities.WorkspaceMember, error) {
+	var member entities.WorkspaceMember
+	err := row.Scan(&member.WorkspaceID, &member.UserID, &member.Role, &member.AddedAt)
+	return member, err
+}
+
+// AddTags tags ShortURLs user may edit in single transaction, returns ids of them.
+// Tag names are kept in tags table and linked to ShortURLs through short_url_tags.
+func (repo *DatabaseRepository) AddTags(
+	ctx context.Context,
+	userID uuid.UUID,
+	ids []string,
+	tags []string,
+) ([]string, error) {
+	tx, err := repo.Storage.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	editable, err := editableIDs(ctx, tx, userID, ids)
+	if err != nil || len(editable) == 0 {
+		return editable, err
+	}
+	query, args, err := sqlx.In(
+		"INSERT INTO tags (name) SELECT unnest(ARRAY[?]::varchar[]) ON CONFLICT (name) DO NOTHING;",
+		tags,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = tx.ExecContext(ctx, sqlx.Rebind(sqlx.DOLLAR, query), args...); err != nil {
+		return nil, err
+	}
+	query, args, err = sqlx.In(
+		"INSERT INTO short_url_tags (short_url_id, tag_id) SELECT u.id, t.id FROM short_urls u CROSS JOIN tags t "+
+			"WHERE u.id IN (?) AND t.name IN (?) ON CONFLICT DO NOTHING;",
+		editable,
+		tags,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = tx.ExecContext(ctx, sqlx.Rebind(sqlx.DOLLAR, query), args...); err != nil {
+		return nil, err
+	}
+	return editable, tx.Commit()
+}
+
+// RemoveTags removes tags from ShortURLs user may edit in single transaction, returns ids of them.
+func (repo *DatabaseRepository) RemoveTags(
+	ctx context.Context,
+	userID uuid.UUID,
+	ids []string,
+	tags []string,
+) ([]string, error) {
+	tx, err := repo.Storage.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	editable, err := editableIDs(ctx, tx, userID, ids)
+	if err != nil || len(editable) == 0 {
+		return editable, err
+	}
+	query, args, err := sqlx.In(
+		"DELETE FROM short_url_tags WHERE short_url_id IN (?) AND tag_id IN (SELECT id FROM tags WHERE name IN (?));",
+		editable,
+		tags,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = tx.ExecContext(ctx, sqlx.Rebind(sqlx.DOLLAR, query), args...); err != nil {
+		return nil, err
+	}
+	return editable, tx.Commit()
+}
+
+// GetTags returns sorted tags of ShortURLs by their ids.
+func (repo *DatabaseRepository) GetTags(ctx context.Context, ids []string) (map[string][]string, error) {
+	tags := make(map[string][]string, len(ids))
+	if len(ids) == 0 {
+		return tags, nil
+	}
+	query, args, err := sqlx.In(
+		"SELECT st.short_url_id, t.name FROM short_url_tags st JOIN tags t ON t.id = st.tag_id "+
+			"WHERE st.short_url_id IN (?) ORDER BY st.short_url_id, t.name;",
+		ids,
+	)
+	if err != nil {
+		return tags, err
+	}
+	rows, err := repo.Storage.QueryContext(ctx, sqlx.Rebind(sqlx.DOLLAR, query), args...)
+	if err != nil {
+		return tags, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, tag string
+		if err = rows.Scan(&id, &tag); err != nil {
+			return tags, err
+		}
+		tags[id] = append(tags[id], tag)
+	}
+	return tags, rows.Err()
+}
+
+// GetByTags returns active ShortURLs having every one of tags, personal ones of user
+// or ones of Workspace when workspaceID is given.
+func (repo *DatabaseRepository) GetByTags(
+	ctx context.Context,
+	userID uuid.UUID,
+	workspaceID string,
+	tags []string,
+) ([]entities.ShortURL, error) {
+	owner, ownerArgs := "user_id = ? AND workspace_id = ''", []any{userID.String()}
+	if workspaceID != "" {
+		owner, ownerArgs = "workspace_id = ?", []any{workspaceID}
+	}
+	query, args, err := sqlx.In(
+		"SELECT "+shortURLColumns+" FROM short_urls WHERE is_active=true AND "+owner+" AND id IN ("+
+			"SELECT st.short_url_id FROM short_url_tags st JOIN tags t ON t.id = st.tag_id WHERE t.name IN (?) "+
+			"GROUP BY st.short_url_id HAVING count(*) = ?);",
+		append(ownerArgs, tags, len(tags))...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return repo.queryShortURLs(ctx, sqlx.Rebind(sqlx.DOLLAR, query), args...)
+}
+
+// editableIDs returns ids of ShortURLs user may edit and which are not deleted, locking them until tx ends.
+func editableIDs(ctx context.Context, tx *sql.Tx, userID uuid.UUID, ids []string) ([]string, error) {
+	query, args, err := sqlx.In(
+		"SELECT id FROM short_urls WHERE deleted_at IS NULL AND id IN (?) AND "+editableByUser+" FOR UPDATE;",
+		ids,
+		userID.String(),
+		userID.String(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := tx.QueryContext(ctx, sqlx.Rebind(sqlx.DOLLAR, query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	editable := make([]string, 0, len(ids))
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		editable = append(editable, id)
+	}
+	return editable, rows.Err()
+}
+
+// queryEach calls fn for every row of query scanned by scan while rows are read, stops on first error.
+func queryEach[T any](
+	ctx context.Context,
+	db *sql.DB,
+	scan func(row rowScanner) (T, error),
+	fn func(T) error,
+	query string,
+	args ...any,
+) error {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		value, errScan := scan(rows)
+		if errScan != nil {
+			return errScan
+		}
+		if err = fn(value); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// insertEach executes insert query with args of every value in single transaction,
+// returns number of values which inserted anything.
+func insertEach[T any](
+	ctx context.Context,
+	db *sql.DB,
+	query string,
+	values []T,
+	args func(T) []any,
+) (int64, error) {
+	if len(values) == 0 {
+		return 0, nil
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var inserted int64
+	for _, value := range values {
+		result, err := stmt.ExecContext(ctx, args(value)...)
+		if err != nil {
+			return 0, err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		if affected > 0 {
+			inserted++
+		}
+	}
+	return inserted, tx.Commit()
+}
+
+// InsertMissingShortURLs stores ShortURLs having neither stored id nor stored original url in their scope.
+func (repo *DatabaseRepository) InsertMissingShortURLs(ctx context.Context, urls []entities.ShortURL) (int64, error) {
+	return insertEach(ctx, repo.Storage, insertShortURLQuery+" ON CONFLICT DO NOTHING;", urls, shortURLValues)
+}
+
+// IterateUsers calls fn for every User while rows are read, stops on first error.
+func (repo *DatabaseRepository) IterateUsers(ctx context.Context, fn func(entities.User) error) error {
+	return queryEach(ctx, repo.Storage, func(row rowScanner) (entities.User, error) {
+		var user entities.User
+		err := row.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.CreatedAt)
+		return user, err
+	}, fn, "SELECT id, username, password_hash, created_at FROM users ORDER BY created_at, id;")
+}
+
+// InsertMissingUsers stores Users having neither stored id nor stored username.
+func (repo *DatabaseRepository) InsertMissingUsers(ctx context.Context, users []entities.User) (int64, error) {
+	return insertEach(
+		ctx,
+		repo.Storage,
+		"INSERT INTO users (id, username, password_hash, created_at) values ($1, $2, $3, $4) ON CONFLICT DO NOTHING;",
+		users,
+		func(user entities.User) []any {
+			return []any{user.ID.String(), user.Username, user.PasswordHash, user.CreatedAt}
+		},
+	)
+}
+
+// IterateWorkspaces calls fn for every Workspace while rows are read, stops on first error.
+func (repo *DatabaseRepository) IterateWorkspaces(ctx context.Context, fn func(entities.Workspace) error) error {
+	return queryEach(ctx, repo.Storage, func(row rowScanner) (entities.Workspace, error) {
+		var workspace entities.Workspace
+		err := row.Scan(&workspace.ID, &workspace.Name, &workspace.CreatedBy, &workspace.CreatedAt)
+		return workspace, err
+	}, fn, "SELECT id, name, created_by, created_at FROM workspaces ORDER BY id;")
+}
+
+// InsertMissingWorkspaces stores Workspaces with ids not stored yet.
+func (repo *DatabaseRepository) InsertMissingWorkspaces(
+	ctx context.Context,
+	workspaces []entities.Workspace,
+) (int64, error) {
+	return insertEach(
+		ctx,
+		repo.Storage,
+		"INSERT INTO workspaces (id, name, created_by, created_at) values ($1, $2, $3, $4) ON CONFLICT DO NOTHING;",
+		workspaces,
+		func(workspace entities.Workspace) []any {
+			return []any{workspace.ID, workspace.Name, workspace.CreatedBy.String(), workspace.CreatedAt}
+		},
+	)
+}
+
+// IterateWorkspaceMembers calls fn for every member of every Workspace while rows are read, stops on first error.
+func (repo *DatabaseRepository) IterateWorkspaceMembers(
+	ctx context.Context,
+	fn func(entities.WorkspaceMember) error,
+) error {
+	return queryEach(
+		ctx,
+		repo.Storage,
+		scanWorkspaceMember,
+		fn,
+		"SELECT workspace_id, user_id, role, added_at FROM workspace_members ORDER BY workspace_id, user_id;",
+	)
+}
+
+// InsertMissingWorkspaceMembers stores members of stored Workspaces who are not their members yet.
+func (repo *DatabaseRepository) InsertMissingWorkspaceMembers(
+	ctx context.Context,
+	members []entities.WorkspaceMember,
+) (int64, error) {
+	return insertEach(
+		ctx,
+		repo.Storage,
+		`INSERT INTO workspace_members (workspace_id, user_id, role, added_at)
+		SELECT id, $2::uuid, $3, $4::timestamptz FROM workspaces WHERE id = $1
+		ON CONFLICT DO NOTHING;`,
+		members,
+		func(member entities.WorkspaceMember) []any {
+			return []any{member.WorkspaceID, member.UserID.String(), member.Role, member.AddedAt}
+		},
+	)
+}
+
+// IterateTags calls fn for every tag of every ShortURL while rows are read, stops on first error.
+func (repo *DatabaseRepository) IterateTags(ctx context.Context, fn func(entities.ShortURLTag) error) error {
+	return queryEach(ctx, repo.Storage, func(row rowScanner) (entities.ShortURLTag, error) {
+		var tag entities.ShortURLTag
+		err := row.Scan(&tag.ShortURLID, &tag.Tag)
+		return tag, err
+	}, fn, "SELECT st.short_url_id, t.name FROM short_url_tags st JOIN tags t ON t.id = st.tag_id "+
+		"ORDER BY st.short_url_id, t.name;")
+}
+
+// InsertMissingTags adds tags missing on stored ShortURLs.
+func (repo *DatabaseRepository) InsertMissingTags(ctx context.Context, tags []entities.ShortURLTag) (int64, error) {
+	return insertEach(
+		ctx,
+		repo.Storage,
+		`WITH tag AS (
+			INSERT INTO tags (name) VALUES ($2) ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name RETURNING id
+		)
+		INSERT INTO short_url_tags (short_url_id, tag_id)
+		SELECT u.id, tag.id FROM short_urls u CROSS JOIN tag WHERE u.id = $1
+		ON CONFLICT DO NOTHING;`,
+		tags,
+		func(tag entities.ShortURLTag) []any { return []any{tag.ShortURLID, tag.Tag} },
+	)
+}
+
+// IterateHistory calls fn for every destination change of every ShortURL while rows are read,
+// stops on first error.
+func (repo *DatabaseRepository) IterateHistory(ctx context.Context, fn func(entities.DestinationChange) error) error {
+	return queryEach(ctx, repo.Storage, func(row rowScanner) (entities.DestinationChange, error) {
+		var change entities.DestinationChange
+		err := row.Scan(&change.ShortURLID, &change.Original, &change.ChangedBy, &change.ChangedAt)
+		return change, err
+	}, fn, "SELECT short_url_id, original_url, changed_by, changed_at FROM short_url_history ORDER BY id;")
+}
+
+// InsertMissingHistory adds destination changes of stored ShortURLs missing in their history.
+func (repo *DatabaseRepository) InsertMissingHistory(
+	ctx context.Context,
+	changes []entities.DestinationChange,
+) (int64, error) {
+	return insertEach(
+		ctx,
+		repo.Storage,
+		`INSERT INTO short_url_history (short_url_id, original_url, changed_by, changed_at)
+		SELECT id, $2::varchar, $3::uuid, $4::timestamptz FROM short_urls WHERE id = $1::varchar
+		AND NOT EXISTS (
+			SELECT 1 FROM short_url_history WHERE short_url_id = $1::varchar AND original_url = $2::varchar
+			AND changed_by = $3::uuid AND changed_at = $4::timestamptz
+		);`,
+		changes,
+		func(change entities.DestinationChange) []any {
+			return []any{change.ShortURLID, change.Original, change.ChangedBy.String(), change.ChangedAt}
+		},
+	)
+}
+
+// IterateClicks calls fn for number of clicks of every ShortURL variant while rows are read, stops on first error.
+func (repo *DatabaseRepository) IterateClicks(ctx context.Context, fn func(entities.ClickCount) error) error {
+	return queryEach(ctx, repo.Storage, func(row rowScanner) (entities.ClickCount, error) {
+		var count entities.ClickCount
+		err := row.Scan(&count.ShortURLID, &count.Variant, &count.Clicks)
+		return count, err
+	}, fn, "SELECT short_url_id, variant, count(*) FROM short_url_clicks "+
+		"GROUP BY short_url_id, variant ORDER BY short_url_id, variant;")
+}
+
+// InsertMissingClicks adds clicks of stored ShortURLs until their numbers of clicks reach counts,
+// added clicks are dated by now.
+func (repo *DatabaseRepository) InsertMissingClicks(ctx context.Context, counts []entities.ClickCount) (int64, error) {
+	return insertEach(
+		ctx,
+		repo.Storage,
+		`INSERT INTO short_url_clicks (short_url_id, variant, clicked_at)
+		SELECT u.id, $2::varchar, now() FROM short_urls u CROSS JOIN generate_series(1, $3::bigint - (
+			SELECT count(*) FROM short_url_clicks WHERE short_url_id = $1::varchar AND variant = $2::varchar
+		)) WHERE u.id = $1::varchar;`,
+		counts,
+		func(count entities.ClickCount) []any { return []any{count.ShortURLID, count.Variant, count.Clicks} },
+	)
+}
+
+// IterateAPIKeys calls fn for every APIKey while rows are read, stops on first error.
+func (repo *DatabaseRepository) IterateAPIKeys(ctx context.Context, fn func(entities.APIKey) error) error {
+	return queryEach(
+		ctx,
+		repo.Storage,
+		scanAPIKey,
+		fn,
+		"SELECT id, name, key_hash, user_id, scopes, created_at, is_active FROM api_keys ORDER BY created_at, id;",
+	)
+}
+
+// InsertMissingAPIKeys stores APIKeys having neither stored id nor stored hash.
+func (repo *DatabaseRepository) InsertMissingAPIKeys(ctx context.Context, keys []entities.APIKey) (int64, error) {
+	return insertEach(
+		ctx,
+		repo.Storage,
+		"INSERT INTO api_keys (id, name, key_hash, user_id, scopes, created_at, is_active) "+
+			"values ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT DO NOTHING;",
+		keys,
+		func(key entities.APIKey) []any {
+			return []any{
+				key.ID, key.Name, key.KeyHash, key.UserID.String(), strings.Join(key.Scopes, ","), key.CreatedAt, key.IsActive,
+			}
+		},
+	)
+}
+
+// IterateAuditLog calls fn for every audit log entry, oldest first, while rows are read, stops on first error.
+func (repo *DatabaseRepository) IterateAuditLog(ctx context.Context, fn func(entities.AuditLogEntry) error) error {
+	return queryEach(ctx, repo.Storage, func(row rowScanner) (entities.AuditLogEntry, error) {
+		var entry entities.AuditLogEntry
+		err := row.Scan(&entry.Actor, &entry.Action, &entry.Target, &entry.Details, &entry.CreatedAt)
+		return entry, err
+	}, fn, "SELECT actor, action, target, details, created_at FROM audit_log ORDER BY id;")
+}
+
+// InsertMissingAuditLog appends entries absent in audit log.
+func (repo *DatabaseRepository) InsertMissingAuditLog(
+	ctx context.Context,
+	entries []entities.AuditLogEntry,
+) (int64, error) {
+	return insertEach(
+		ctx,
+		repo.Storage,
+		`INSERT INTO audit_log (actor, action, target, details, created_at)
+		SELECT $1::varchar, $2::varchar, $3::varchar, $4::text, $5::timestamptz
+		WHERE NOT EXISTS (
+			SELECT 1 FROM audit_log WHERE actor = $1::varchar AND action = $2::varchar AND target = $3::varchar
+			AND details = $4::text AND created_at = $5::timestamptz
+		);`,
+		entries,
+		func(entry entities.AuditLogEntry) []any {
+			return []any{entry.Actor, entry.Action, entry.Target, entry.Details, entry.CreatedAt}
+		},
+	)
+}
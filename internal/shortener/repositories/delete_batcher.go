@@ -0,0 +1,205 @@
+package repositories
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/google/uuid"
+)
+
+// RecordsDeleter deletes ShortURLs of user by ids, returns ids of deleted ones.
+type RecordsDeleter func(ctx context.Context, userID uuid.UUID, ids []string) ([]string, error)
+
+// DeleteBatcher accumulates deletion requests of any repository and applies them in batches.
+//
+// Batch is flushed every flush interval or as soon as max batch size of ids is queued.
+// Failed items are retried with growing delay, after deleteMaxAttempts their jobs are failed and dead-lettered.
+type DeleteBatcher struct {
+	deleter       RecordsDeleter
+	flushInterval time.Duration
+	maxBatchSize  int
+
+	mu         sync.Mutex
+	pending    []*entities.ItemToDelete
+	pendingIDs int
+	full       chan struct{}
+}
+
+// NewDeleteBatcher creates DeleteBatcher applying batches with deleter, it has to be started with Run.
+func NewDeleteBatcher(deleter RecordsDeleter, flushInterval time.Duration, maxBatchSize int) *DeleteBatcher {
+	return &DeleteBatcher{
+		deleter:       deleter,
+		flushInterval: flushInterval,
+		maxBatchSize:  maxBatchSize,
+		pending:       make([]*entities.ItemToDelete, 0, 16),
+		full:          make(chan struct{}, 1),
+	}
+}
+
+// Enqueue adds item to the next batch.
+func (b *DeleteBatcher) Enqueue(item *entities.ItemToDelete) {
+	b.mu.Lock()
+	b.pending = append(b.pending, item)
+	b.pendingIDs += len(item.ItemsIDs)
+	isFull := b.maxBatchSize > 0 && b.pendingIDs >= b.maxBatchSize
+	b.mu.Unlock()
+
+	if isFull {
+		select {
+		case b.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Run flushes batches until ctx is done, queued items are flushed once more on exit.
+func (b *DeleteBatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(b.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			b.Flush(context.Background(), time.Now())
+			return
+		case now := <-ticker.C:
+			b.Flush(ctx, now)
+		case <-b.full:
+			b.Flush(ctx, time.Now())
+		}
+	}
+}
+
+// Flush applies items due by now grouped by user, items waiting for retry are kept queued.
+func (b *DeleteBatcher) Flush(ctx context.Context, now time.Time) {
+	b.mu.Lock()
+	pending := b.pending
+	b.pending = make([]*entities.ItemToDelete, 0, 16)
+	b.pendingIDs = 0
+	b.mu.Unlock()
+
+	left := make([]*entities.ItemToDelete, 0, len(pending))
+	due := make(map[uuid.UUID][]*entities.ItemToDelete)
+	for _, item := range pending {
+		if item.NextAttemptAt.After(now) {
+			left = append(left, item)
+			continue
+		}
+		due[item.UserID] = append(due[item.UserID], item)
+	}
+	for userID, items := range due {
+		for _, batch := range b.split(items) {
+			left = append(left, b.apply(ctx, userID, batch, now)...)
+		}
+	}
+
+	b.mu.Lock()
+	b.pending = append(b.pending, left...)
+	b.mu.Unlock()
+}
+
+// split splits items of one user into batches of up to maxBatchSize ids, item is never split.
+func (b *DeleteBatcher) split(items []*entities.ItemToDelete) [][]*entities.ItemToDelete {
+	batches := make([][]*entities.ItemToDelete, 0, 1)
+	var batch []*entities.ItemToDelete
+	var batchIDs int
+	for _, item := range items {
+		if len(batch) > 0 && b.maxBatchSize > 0 && batchIDs+len(item.ItemsIDs) > b.maxBatchSize {
+			batches = append(batches, batch)
+			batch, batchIDs = nil, 0
+		}
+		batch = append(batch, item)
+		batchIDs += len(item.ItemsIDs)
+	}
+	return append(batches, batch)
+}
+
+// apply deletes batch of one user, returns items to be retried.
+func (b *DeleteBatcher) apply(
+	ctx context.Context,
+	userID uuid.UUID,
+	batch []*entities.ItemToDelete,
+	now time.Time,
+) []*entities.ItemToDelete {
+	ids := make([]string, 0, len(batch))
+	for _, item := range batch {
+		ids = append(ids, item.ItemsIDs...)
+	}
+	deleted, err := b.deleter(ctx, userID, ids)
+
+	retry := make([]*entities.ItemToDelete, 0)
+	for _, item := range batch {
+		switch {
+		case err == nil:
+			deleteJobs.Finish(item.JobID, deleted)
+		case item.Attempts+1 >= deleteMaxAttempts:
+			deleteJobs.Fail(item.JobID, err)
+			log.Printf("Deletion job %s failed after %d attempts: %v", item.JobID, item.Attempts+1, err)
+		default:
+			deleteJobs.Retry(item.JobID, err)
+			item.Attempts++
+			item.NextAttemptAt = now.Add(deleteRetryDelay(item.Attempts))
+			retry = append(retry, item)
+		}
+	}
+	return retry
+}
+
+// deleteRecordsWith starts deletion job persisted to store if it is given, job is queued to batcher
+// or applied at once if there is no batcher.
+func deleteRecordsWith(
+	ctx context.Context,
+	batcher *DeleteBatcher,
+	store DeleteJobStore,
+	deleter RecordsDeleter,
+	userID uuid.UUID,
+	ids []string,
+) (entities.DeleteJob, error) {
+	job, err := deleteJobs.Start(ctx, store, userID, ids)
+	if err != nil {
+		return job, err
+	}
+	if batcher != nil {
+		batcher.Enqueue(&entities.ItemToDelete{
+			JobID:    job.ID,
+			UserID:   userID,
+			ItemsIDs: ids,
+		})
+		return job, nil
+	}
+	deleted, err := deleter(ctx, userID, ids)
+	if err != nil {
+		return deleteJobs.Fail(job.ID, err), err
+	}
+	return deleteJobs.Finish(job.ID, deleted), nil
+}
+
+// resumeDeleteJob registers job left queued by previous run and queues it to batcher again with attempts
+// it has already made, job is applied at once if there is no batcher.
+func resumeDeleteJob(
+	ctx context.Context,
+	batcher *DeleteBatcher,
+	store DeleteJobStore,
+	deleter RecordsDeleter,
+	job entities.DeleteJob,
+) {
+	deleteJobs.Resume(store, job)
+	if batcher != nil {
+		batcher.Enqueue(&entities.ItemToDelete{
+			JobID:    job.ID,
+			UserID:   job.UserID,
+			ItemsIDs: job.IDs,
+			Attempts: job.Attempts,
+		})
+		return
+	}
+	deleted, err := deleter(ctx, job.UserID, job.IDs)
+	if err != nil {
+		deleteJobs.Fail(job.ID, err)
+		return
+	}
+	deleteJobs.Finish(job.ID, deleted)
+}
@@ -0,0 +1,114 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDeleter records batches and deletes ids starting with "ok", fails while err is set.
+type fakeDeleter struct {
+	mu      sync.Mutex
+	err     error
+	batches [][]string
+}
+
+func (d *fakeDeleter) delete(ctx context.Context, userID uuid.UUID, ids []string) ([]string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.batches = append(d.batches, ids)
+	if d.err != nil {
+		return nil, d.err
+	}
+	deleted := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if len(id) >= 2 && id[:2] == "ok" {
+			deleted = append(deleted, id)
+		}
+	}
+	return deleted, nil
+}
+
+func TestDeleteBatcherFlush(t *testing.T) {
+	deleter := &fakeDeleter{}
+	batcher := NewDeleteBatcher(deleter.delete, time.Hour, 3)
+	userID := uuid.New()
+	now := time.Now()
+
+	first, _ := deleteRecordsWith(context.Background(), batcher, nil, deleter.delete, userID, []string{"ok1", "missing"})
+	second, _ := deleteRecordsWith(context.Background(), batcher, nil, deleter.delete, userID, []string{"ok2", "ok3"})
+	assert.Equal(t, entities.DeleteJobQueued, first.State)
+	assert.Empty(t, deleter.batches, "nothing is deleted before flush")
+
+	batcher.Flush(context.Background(), now)
+	assert.Equal(t, [][]string{{"ok1", "missing"}, {"ok2", "ok3"}}, deleter.batches, "batches are limited by max size")
+	first, _ = deleteJobs.Get(first.ID)
+	assert.Equal(t, entities.DeleteJobPartiallyApplied, first.State)
+	assert.Equal(t, entities.DeleteResultNotFound, first.Results["missing"])
+	second, _ = deleteJobs.Get(second.ID)
+	assert.Equal(t, entities.DeleteJobApplied, second.State)
+}
+
+func TestDeleteBatcherRetries(t *testing.T) {
+	deleter := &fakeDeleter{err: errors.New("connection lost")}
+	batcher := NewDeleteBatcher(deleter.delete, time.Hour, 100)
+	now := time.Now()
+
+	job, _ := deleteRecordsWith(context.Background(), batcher, nil, deleter.delete, uuid.New(), []string{"ok1"})
+	for attempt := 1; attempt < deleteMaxAttempts; attempt++ {
+		batcher.Flush(context.Background(), now)
+		require.Len(t, batcher.pending, 1)
+		assert.Equal(t, now.Add(deleteRetryDelay(attempt)), batcher.pending[0].NextAttemptAt)
+
+		batcher.Flush(context.Background(), now)
+		require.Len(t, deleter.batches, attempt, "retry should wait for its delay")
+		now = batcher.pending[0].NextAttemptAt
+	}
+	job, _ = deleteJobs.Get(job.ID)
+	assert.Equal(t, entities.DeleteJobQueued, job.State)
+	assert.Equal(t, "connection lost", job.LastError)
+
+	batcher.Flush(context.Background(), now)
+	assert.Empty(t, batcher.pending)
+	job, _ = deleteJobs.Get(job.ID)
+	assert.Equal(t, entities.DeleteJobFailed, job.State)
+	assert.Equal(t, deleteMaxAttempts, job.Attempts)
+	assert.Contains(t, deleteJobs.DeadLetters(), job)
+}
+
+func TestDeleteBatcherRun(t *testing.T) {
+	deleter := &fakeDeleter{}
+	batcher := NewDeleteBatcher(deleter.delete, time.Hour, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		batcher.Run(ctx)
+		close(done)
+	}()
+
+	job, _ := deleteRecordsWith(context.Background(), batcher, nil, deleter.delete, uuid.New(), []string{"ok1", "ok2"})
+	assert.Eventually(t, func() bool {
+		job, _ = deleteJobs.Get(job.ID)
+		return job.IsFinished()
+	}, time.Second, 10*time.Millisecond, "full batch should be flushed before interval passes")
+
+	job, _ = deleteRecordsWith(context.Background(), batcher, nil, deleter.delete, uuid.New(), []string{"ok3"})
+	cancel()
+	<-done
+	job, _ = deleteJobs.Get(job.ID)
+	assert.Equal(t, entities.DeleteJobApplied, job.State, "queued items should be flushed on exit")
+}
+
+func TestDeleteRecordsWithoutBatcher(t *testing.T) {
+	repo := &InMemoryRepository{Storage: map[string]entities.ShortURL{}}
+	job, err := repo.DeleteRecords(context.Background(), uuid.New(), []string{"unknown"})
+	require.NoError(t, err)
+	assert.Equal(t, entities.DeleteJobPartiallyApplied, job.State)
+}
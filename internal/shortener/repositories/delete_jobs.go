@@ -0,0 +1,248 @@
+package repositories
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/google/uuid"
+)
+
+// Deletion retry policy and limits of job tracking.
+const (
+	deleteRetryBaseDelay = time.Second
+	deleteRetryMaxDelay  = time.Minute
+	deleteMaxAttempts    = 8
+	deleteJobTTL         = 24 * time.Hour
+	maxDeadLetters       = 1000
+)
+
+// DeleteJobStore persists deletion jobs, so their states and dead letters survive restarts.
+type DeleteJobStore interface {
+	SaveDeleteJob(ctx context.Context, job entities.DeleteJob) error
+}
+
+// trackedJob deletion job with store it is persisted to, store is nil for storages keeping jobs in memory only.
+// Hooks are called with ids deleted by job once it is applied.
+type trackedJob struct {
+	job   entities.DeleteJob
+	store DeleteJobStore
+	hooks []func(deleted []string)
+}
+
+// finishedJob id of finished job with time it was finished at.
+type finishedJob struct {
+	id         string
+	finishedAt time.Time
+}
+
+// DeleteJobTracker keeps states of deletion jobs and jobs given up after all retries.
+//
+// Finished jobs are queued in order they finish, so the expired ones are forgotten from the head
+// of the queue without scanning all jobs.
+type DeleteJobTracker struct {
+	mu          sync.Mutex
+	jobs        map[string]trackedJob
+	finished    []finishedJob
+	deadLetters []entities.DeleteJob
+}
+
+// deleteJobs tracker shared by all repositories.
+var deleteJobs = NewDeleteJobTracker()
+
+// NewDeleteJobTracker creates empty DeleteJobTracker.
+func NewDeleteJobTracker() *DeleteJobTracker {
+	return &DeleteJobTracker{jobs: make(map[string]trackedJob)}
+}
+
+// Start registers new queued job persisted to store if it is given, finished jobs older than deleteJobTTL
+// are forgotten.
+func (t *DeleteJobTracker) Start(
+	ctx context.Context,
+	store DeleteJobStore,
+	userID uuid.UUID,
+	ids []string,
+) (entities.DeleteJob, error) {
+	now := time.Now().UTC()
+	job := entities.DeleteJob{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		IDs:       ids,
+		State:     entities.DeleteJobQueued,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if store != nil {
+		if err := store.SaveDeleteJob(ctx, job); err != nil {
+			return entities.DeleteJob{}, err
+		}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.forgetExpired(now)
+	t.jobs[job.ID] = trackedJob{job: job, store: store}
+	return job, nil
+}
+
+// Resume registers queued job persisted to store by previous run, so its changes are persisted again.
+func (t *DeleteJobTracker) Resume(store DeleteJobStore, job entities.DeleteJob) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.jobs[job.ID] = trackedJob{job: job, store: store}
+}
+
+// forgetExpired removes jobs finished more than deleteJobTTL ago, has to be called with mu held.
+func (t *DeleteJobTracker) forgetExpired(now time.Time) {
+	expired := 0
+	for expired < len(t.finished) && now.Sub(t.finished[expired].finishedAt) > deleteJobTTL {
+		delete(t.jobs, t.finished[expired].id)
+		expired++
+	}
+	t.finished = t.finished[expired:]
+}
+
+// Get returns job by its id.
+func (t *DeleteJobTracker) Get(id string) (entities.DeleteJob, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tracked, exist := t.jobs[id]
+	return tracked.job, exist
+}
+
+// Finish records result of applied job, ids missing from deleted were not found or not owned by user.
+func (t *DeleteJobTracker) Finish(id string, deleted []string) entities.DeleteJob {
+	isDeleted := make(map[string]bool, len(deleted))
+	for _, deletedID := range deleted {
+		isDeleted[deletedID] = true
+	}
+	defer func() {
+		for _, hook := range t.takeHooks(id) {
+			hook(deleted)
+		}
+	}()
+	return t.change(id, func(job *entities.DeleteJob) {
+		job.Attempts++
+		job.State = entities.DeleteJobApplied
+		job.Results = make(map[string]string, len(job.IDs))
+		for _, itemID := range job.IDs {
+			if isDeleted[itemID] {
+				job.Results[itemID] = entities.DeleteResultDeleted
+			} else {
+				job.Results[itemID] = entities.DeleteResultNotFound
+				job.State = entities.DeleteJobPartiallyApplied
+			}
+		}
+	})
+}
+
+// Retry records failed attempt of job which is going to be retried.
+func (t *DeleteJobTracker) Retry(id string, err error) entities.DeleteJob {
+	return t.change(id, func(job *entities.DeleteJob) {
+		job.Attempts++
+		job.LastError = err.Error()
+	})
+}
+
+// Fail marks job as failed and moves it to dead letters.
+func (t *DeleteJobTracker) Fail(id string, err error) entities.DeleteJob {
+	job := t.change(id, func(job *entities.DeleteJob) {
+		job.Attempts++
+		job.LastError = err.Error()
+		job.State = entities.DeleteJobFailed
+	})
+	if job.ID == "" {
+		return job
+	}
+	t.takeHooks(id)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.deadLetters = append(t.deadLetters, job)
+	if len(t.deadLetters) > maxDeadLetters {
+		t.deadLetters = t.deadLetters[len(t.deadLetters)-maxDeadLetters:]
+	}
+	return job
+}
+
+// OnFinish calls hook with ids deleted by job once it is applied, at once if it is applied already.
+// Hook is never called for failed or unknown jobs.
+func (t *DeleteJobTracker) OnFinish(id string, hook func(deleted []string)) {
+	t.mu.Lock()
+	tracked, exist := t.jobs[id]
+	if exist && !tracked.job.IsFinished() {
+		tracked.hooks = append(tracked.hooks, hook)
+		t.jobs[id] = tracked
+	}
+	t.mu.Unlock()
+	if !exist || !tracked.job.IsFinished() || tracked.job.State == entities.DeleteJobFailed {
+		return
+	}
+	deleted := make([]string, 0, len(tracked.job.Results))
+	for _, itemID := range tracked.job.IDs {
+		if tracked.job.Results[itemID] == entities.DeleteResultDeleted {
+			deleted = append(deleted, itemID)
+		}
+	}
+	hook(deleted)
+}
+
+// takeHooks removes hooks of job and returns them.
+func (t *DeleteJobTracker) takeHooks(id string) []func(deleted []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tracked, exist := t.jobs[id]
+	if !exist {
+		return nil
+	}
+	hooks := tracked.hooks
+	tracked.hooks = nil
+	t.jobs[id] = tracked
+	return hooks
+}
+
+// DeadLetters returns jobs given up after all retries, oldest first.
+func (t *DeleteJobTracker) DeadLetters() []entities.DeleteJob {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	result := make([]entities.DeleteJob, len(t.deadLetters))
+	copy(result, t.deadLetters)
+	return result
+}
+
+// change applies change to job and persists it to its store, job failed to be persisted is logged only,
+// it is kept in memory anyway.
+func (t *DeleteJobTracker) change(id string, apply func(job *entities.DeleteJob)) entities.DeleteJob {
+	t.mu.Lock()
+	tracked, exist := t.jobs[id]
+	if !exist {
+		t.mu.Unlock()
+		return tracked.job
+	}
+	apply(&tracked.job)
+	tracked.job.UpdatedAt = time.Now().UTC()
+	t.jobs[id] = tracked
+	if tracked.job.IsFinished() {
+		t.finished = append(t.finished, finishedJob{id: id, finishedAt: tracked.job.UpdatedAt})
+	}
+	t.mu.Unlock()
+
+	if tracked.store != nil {
+		if err := tracked.store.SaveDeleteJob(context.Background(), tracked.job); err != nil {
+			log.Printf("Deletion job %s is not saved: %v", id, err)
+		}
+	}
+	return tracked.job
+}
+
+// deleteRetryDelay returns delay before next attempt, doubled after every failed one up to deleteRetryMaxDelay.
+func deleteRetryDelay(attempts int) time.Duration {
+	delay := deleteRetryBaseDelay
+	for i := 1; i < attempts && delay < deleteRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > deleteRetryMaxDelay {
+		return deleteRetryMaxDelay
+	}
+	return delay
+}
@@ -0,0 +1,41 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteRetryDelay(t *testing.T) {
+	assert.Equal(t, deleteRetryBaseDelay, deleteRetryDelay(1))
+	assert.Equal(t, 4*deleteRetryBaseDelay, deleteRetryDelay(3))
+	assert.Equal(t, deleteRetryMaxDelay, deleteRetryDelay(100))
+}
+
+func TestDeleteJobTrackerForgetsExpiredJobs(t *testing.T) {
+	tracker := NewDeleteJobTracker()
+	started := make([]string, 0, 3)
+	for i := 0; i < 3; i++ {
+		job, err := tracker.Start(context.Background(), nil, uuid.New(), []string{"id"})
+		require.NoError(t, err)
+		started = append(started, job.ID)
+	}
+	tracker.Finish(started[0], []string{"id"})
+	tracker.Finish(started[1], nil)
+	tracker.finished[0].finishedAt = time.Now().Add(-deleteJobTTL - time.Minute)
+
+	_, err := tracker.Start(context.Background(), nil, uuid.New(), []string{"id"})
+	require.NoError(t, err)
+
+	_, exist := tracker.Get(started[0])
+	assert.False(t, exist, "job finished before TTL should be forgotten")
+	for _, id := range started[1:] {
+		_, exist = tracker.Get(id)
+		assert.True(t, exist, "recently finished and queued jobs should be kept")
+	}
+	assert.Len(t, tracker.finished, 1)
+}
@@ -0,0 +1,745 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/shortenerrors"
+	"github.com/google/uuid"
+)
+
+// DefaultBackfillChunkSize number of records copied to secondary storage at once.
+const DefaultBackfillChunkSize = 1000
+
+// DualWriteRepository repository writing to both primary and secondary storages while migrating between them.
+//
+// Primary storage is the source of truth: its errors are returned, errors of secondary one are only logged
+// and counted. Records changed by a write are copied to secondary storage as primary one returned them,
+// records untouched since migration started are copied by Backfill.
+type DualWriteRepository struct {
+	Primary      IRepository
+	Secondary    IRepository
+	ReadFallback bool
+
+	mu       sync.Mutex
+	progress entities.BackfillProgress
+}
+
+// NewDualWriteRepository creates DualWriteRepository, secondary storage is read when nothing is found
+// in primary one if readFallback is set.
+func NewDualWriteRepository(primary IRepository, secondary IRepository, readFallback bool) *DualWriteRepository {
+	return &DualWriteRepository{
+		Primary:      primary,
+		Secondary:    secondary,
+		ReadFallback: readFallback,
+		progress:     entities.BackfillProgress{State: entities.BackfillStatePending},
+	}
+}
+
+// Backfill copies every entity of primary storage missing in secondary one, progress of every entity
+// is reported by BackfillProgress.
+//
+// Entities already present in secondary storage were written there by dual writes and are newer, so they are kept.
+// Entities are inserted only when missing, so Backfill may be repeated after failure.
+func (repo *DualWriteRepository) Backfill(ctx context.Context, chunkSize int) error {
+	if chunkSize <= 0 {
+		chunkSize = DefaultBackfillChunkSize
+	}
+	startedAt := time.Now().UTC()
+	repo.updateProgress(func(progress *entities.BackfillProgress) {
+		*progress = entities.BackfillProgress{
+			State:           entities.BackfillStateRunning,
+			Entities:        make(map[string]entities.BackfillEntityProgress),
+			SecondaryErrors: progress.SecondaryErrors,
+			StartedAt:       &startedAt,
+		}
+	})
+
+	err := repo.backfillEntities(ctx, chunkSize)
+
+	finishedAt := time.Now().UTC()
+	repo.updateProgress(func(progress *entities.BackfillProgress) {
+		progress.FinishedAt = &finishedAt
+		progress.State = entities.BackfillStateFinished
+		if err != nil {
+			progress.State = entities.BackfillStateFailed
+			progress.Error = err.Error()
+		}
+	})
+	progress := repo.BackfillProgress()
+	log.Printf(
+		"Backfill %s: %d scanned, %d copied, %d skipped",
+		progress.State, progress.Scanned, progress.Copied, progress.Skipped,
+	)
+	return err
+}
+
+// backfillEntities copies entities one after another, so entities are copied after ones they refer to.
+func (repo *DualWriteRepository) backfillEntities(ctx context.Context, chunkSize int) error {
+	primary, isPrimaryMigrated := repo.Primary.(IMigrationRepository)
+	secondary, isSecondaryMigrated := repo.Secondary.(IMigrationRepository)
+	if !isPrimaryMigrated || !isSecondaryMigrated {
+		return errors.New("storage does not support migration")
+	}
+	steps := []func() error{
+		func() error {
+			return backfillEntity(ctx, repo, entities.BackfillUsers, chunkSize, primary.IterateUsers, secondary.InsertMissingUsers)
+		},
+		func() error {
+			return backfillEntity(
+				ctx, repo, entities.BackfillWorkspaces, chunkSize, primary.IterateWorkspaces, secondary.InsertMissingWorkspaces,
+			)
+		},
+		func() error {
+			return backfillEntity(
+				ctx, repo, entities.BackfillWorkspaceMembers, chunkSize,
+				primary.IterateWorkspaceMembers, secondary.InsertMissingWorkspaceMembers,
+			)
+		},
+		func() error {
+			return backfillEntity(
+				ctx, repo, entities.BackfillShortURLs, chunkSize, primary.IterateAll, secondary.InsertMissingShortURLs,
+			)
+		},
+		func() error {
+			return backfillEntity(ctx, repo, entities.BackfillTags, chunkSize, primary.IterateTags, secondary.InsertMissingTags)
+		},
+		func() error {
+			return backfillEntity(
+				ctx, repo, entities.BackfillHistory, chunkSize, primary.IterateHistory, secondary.InsertMissingHistory,
+			)
+		},
+		func() error {
+			return backfillEntity(
+				ctx, repo, entities.BackfillClicks, chunkSize, primary.IterateClicks, secondary.InsertMissingClicks,
+			)
+		},
+		func() error {
+			return backfillEntity(
+				ctx, repo, entities.BackfillAPIKeys, chunkSize, primary.IterateAPIKeys, secondary.InsertMissingAPIKeys,
+			)
+		},
+		func() error {
+			return backfillEntity(
+				ctx, repo, entities.BackfillAuditLog, chunkSize, primary.IterateAuditLog, secondary.InsertMissingAuditLog,
+			)
+		},
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillEntity copies entities read by iterate in chunks inserted by insert, progress is counted per chunk.
+func backfillEntity[T any](
+	ctx context.Context,
+	repo *DualWriteRepository,
+	entity string,
+	chunkSize int,
+	iterate func(ctx context.Context, fn func(T) error) error,
+	insert func(ctx context.Context, values []T) (int64, error),
+) error {
+	chunk := make([]T, 0, chunkSize)
+	flush := func() error {
+		copied, err := insert(ctx, chunk)
+		if err != nil {
+			return err
+		}
+		scanned := int64(len(chunk))
+		repo.updateProgress(func(progress *entities.BackfillProgress) {
+			entityProgress := progress.Entities[entity]
+			entityProgress.Scanned += scanned
+			entityProgress.Copied += copied
+			entityProgress.Skipped += scanned - copied
+			progress.Entities[entity] = entityProgress
+			progress.Scanned += scanned
+			progress.Copied += copied
+			progress.Skipped += scanned - copied
+		})
+		chunk = chunk[:0]
+		return nil
+	}
+	err := iterate(ctx, func(value T) error {
+		chunk = append(chunk, value)
+		if len(chunk) < chunkSize {
+			return nil
+		}
+		return flush()
+	})
+	if err == nil && len(chunk) > 0 {
+		err = flush()
+	}
+	return err
+}
+
+// BackfillProgress returns current progress of Backfill.
+func (repo *DualWriteRepository) BackfillProgress() entities.BackfillProgress {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	progress := repo.progress
+	if repo.progress.Entities != nil {
+		progress.Entities = make(map[string]entities.BackfillEntityProgress, len(repo.progress.Entities))
+		for entity, entityProgress := range repo.progress.Entities {
+			progress.Entities[entity] = entityProgress
+		}
+	}
+	return progress
+}
+
+func (repo *DualWriteRepository) updateProgress(change func(progress *entities.BackfillProgress)) {
+	repo.mu.Lock()
+	change(&repo.progress)
+	repo.mu.Unlock()
+}
+
+// secondaryFailed logs and counts failed write to secondary storage.
+func (repo *DualWriteRepository) secondaryFailed(operation string, err error) {
+	if err == nil {
+		return
+	}
+	log.Printf("Secondary storage %s failed: %v", operation, err)
+	repo.updateProgress(func(progress *entities.BackfillProgress) {
+		progress.SecondaryErrors++
+	})
+}
+
+// mirror copies records returned by primary storage to secondary one.
+func (repo *DualWriteRepository) mirror(ctx context.Context, operation string, urls ...entities.ShortURL) {
+	if len(urls) > 0 {
+		repo.secondaryFailed(operation, repo.Secondary.LoadBackup(ctx, urls))
+	}
+}
+
+// fallback tells whether secondary storage has to be read after primary one returned nothing or failed.
+func (repo *DualWriteRepository) fallback(exist bool, err error) bool {
+	return repo.ReadFallback && (!exist || err != nil)
+}
+
+// GetByID returns ShortURL by its id.
+func (repo *DualWriteRepository) GetByID(ctx context.Context, id string) (entities.ShortURL, bool, error) {
+	shortURL, exist, err := repo.Primary.GetByID(ctx, id)
+	if repo.fallback(exist, err) {
+		return repo.Secondary.GetByID(ctx, id)
+	}
+	return shortURL, exist, err
+}
+
+// GetByUserID returns personal ShortURLs by user id.
+func (repo *DualWriteRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]entities.ShortURL, error) {
+	urls, err := repo.Primary.GetByUserID(ctx, userID)
+	if repo.fallback(true, err) {
+		return repo.Secondary.GetByUserID(ctx, userID)
+	}
+	return urls, err
+}
+
+// IterateByUserID calls fn for every ShortURL of user in primary storage.
+func (repo *DualWriteRepository) IterateByUserID(
+	ctx context.Context,
+	userID uuid.UUID,
+	fn func(entities.ShortURL) error,
+) error {
+	return repo.Primary.IterateByUserID(ctx, userID, fn)
+}
+
+// IterateAll calls fn for every ShortURL in primary storage.
+func (repo *DualWriteRepository) IterateAll(ctx context.Context, fn func(entities.ShortURL) error) error {
+	return repo.Primary.IterateAll(ctx, fn)
+}
+
+// LoadBackup stores ShortURLs in both storages.
+func (repo *DualWriteRepository) LoadBackup(ctx context.Context, urls []entities.ShortURL) error {
+	if err := repo.Primary.LoadBackup(ctx, urls); err != nil {
+		return err
+	}
+	repo.mirror(ctx, "load backup", urls...)
+	return nil
+}
+
+// Create creates ShortURL.
+func (repo *DualWriteRepository) Create(ctx context.Context, shortURL entities.ShortURL) (entities.ShortURL, error) {
+	created, err := repo.Primary.Create(ctx, shortURL)
+	if err != nil {
+		return created, err
+	}
+	repo.mirror(ctx, "create", created)
+	return created, nil
+}
+
+// CreateMultiple creates multiple ShortURLs.
+func (repo *DualWriteRepository) CreateMultiple(
+	ctx context.Context,
+	urls []entities.ShortURL,
+) ([]entities.ShortURL, error) {
+	created, err := repo.Primary.CreateMultiple(ctx, urls)
+	if err != nil {
+		return created, err
+	}
+	repo.mirror(ctx, "create multiple", created...)
+	return created, nil
+}
+
+// DeleteRecords deletes ShortURLs by ids in both storages, only primary storage tracks deletion job,
+// secondary one deletes ids primary one has deleted once the job is applied, nothing if the job fails.
+func (repo *DualWriteRepository) DeleteRecords(
+	ctx context.Context,
+	userID uuid.UUID,
+	ids []string,
+) (entities.DeleteJob, error) {
+	job, err := repo.Primary.DeleteRecords(ctx, userID, ids)
+	if err != nil {
+		return job, err
+	}
+	deleteJobs.OnFinish(job.ID, func(deleted []string) {
+		if len(deleted) == 0 {
+			return
+		}
+		_, errDelete := repo.Secondary.DeleteRecordsForUser(context.Background(), userID, deleted)
+		repo.secondaryFailed("delete", errDelete)
+	})
+	return job, nil
+}
+
+// DeleteRecordsForUser deletes ShortURLs of user by ids in both storages at once, returns ids deleted in primary storage.
+func (repo *DualWriteRepository) DeleteRecordsForUser(
+	ctx context.Context,
+	userID uuid.UUID,
+	ids []string,
+) ([]string, error) {
+	deleted, err := repo.Primary.DeleteRecordsForUser(ctx, userID, ids)
+	if err != nil {
+		return deleted, err
+	}
+	_, err = repo.Secondary.DeleteRecordsForUser(ctx, userID, ids)
+	repo.secondaryFailed("delete", err)
+	return deleted, nil
+}
+
+// GetDeleteJob returns deletion job by its id.
+func (repo *DualWriteRepository) GetDeleteJob(ctx context.Context, id string) (entities.DeleteJob, bool, error) {
+	return repo.Primary.GetDeleteJob(ctx, id)
+}
+
+// Update changes destination of ShortURL user may edit in both storages.
+func (repo *DualWriteRepository) Update(
+	ctx context.Context,
+	userID uuid.UUID,
+	id string,
+	original string,
+) (entities.ShortURL, error) {
+	updated, err := repo.Primary.Update(ctx, userID, id, original)
+	if err != nil {
+		return updated, err
+	}
+	// Update is repeated to keep history of destinations, not yet copied record is copied as it is now.
+	_, err = repo.Secondary.Update(ctx, userID, id, original)
+	if errors.Is(err, shortenerrors.ErrItemNotFound) {
+		repo.mirror(ctx, "update", updated)
+		return updated, nil
+	}
+	repo.secondaryFailed("update", err)
+	return updated, nil
+}
+
+// GetHistory returns previous destinations of ShortURL from primary storage.
+func (repo *DualWriteRepository) GetHistory(ctx context.Context, id string) ([]entities.DestinationChange, error) {
+	history, err := repo.Primary.GetHistory(ctx, id)
+	if repo.fallback(true, err) {
+		return repo.Secondary.GetHistory(ctx, id)
+	}
+	return history, err
+}
+
+// UseClick takes one of clicks left of ShortURL in primary storage, the rest is copied to secondary one.
+func (repo *DualWriteRepository) UseClick(ctx context.Context, id string) (entities.ShortURL, error) {
+	updated, err := repo.Primary.UseClick(ctx, id)
+	if err != nil {
+		return updated, err
+	}
+	repo.mirror(ctx, "use click", updated)
+	return updated, nil
+}
+
+// SetVariants replaces split destinations of ShortURL in both storages.
+func (repo *DualWriteRepository) SetVariants(
+	ctx context.Context,
+	userID uuid.UUID,
+	id string,
+	variants entities.Variants,
+	sticky bool,
+) (entities.ShortURL, error) {
+	updated, err := repo.Primary.SetVariants(ctx, userID, id, variants, sticky)
+	if err != nil {
+		return updated, err
+	}
+	repo.mirror(ctx, "set variants", updated)
+	return updated, nil
+}
+
+// RecordClick records click in both storages.
+func (repo *DualWriteRepository) RecordClick(ctx context.Context, click entities.Click) error {
+	if err := repo.Primary.RecordClick(ctx, click); err != nil {
+		return err
+	}
+	repo.secondaryFailed("record click", repo.Secondary.RecordClick(ctx, click))
+	return nil
+}
+
+// GetVariantClicks returns numbers of clicks of ShortURL by variant id from primary storage.
+func (repo *DualWriteRepository) GetVariantClicks(ctx context.Context, id string) (map[string]int64, error) {
+	clicks, err := repo.Primary.GetVariantClicks(ctx, id)
+	if repo.fallback(true, err) {
+		return repo.Secondary.GetVariantClicks(ctx, id)
+	}
+	return clicks, err
+}
+
+// GetDeletedByUserID returns ShortURLs deleted by user.
+func (repo *DualWriteRepository) GetDeletedByUserID(ctx context.Context, userID uuid.UUID) ([]entities.ShortURL, error) {
+	urls, err := repo.Primary.GetDeletedByUserID(ctx, userID)
+	if repo.fallback(true, err) {
+		return repo.Secondary.GetDeletedByUserID(ctx, userID)
+	}
+	return urls, err
+}
+
+// RestoreRecords restores ShortURLs deleted by user in both storages.
+func (repo *DualWriteRepository) RestoreRecords(
+	ctx context.Context,
+	userID uuid.UUID,
+	ids []string,
+) ([]entities.ShortURL, error) {
+	restored, err := repo.Primary.RestoreRecords(ctx, userID, ids)
+	if err != nil {
+		return restored, err
+	}
+	repo.mirror(ctx, "restore", restored...)
+	return restored, nil
+}
+
+// PurgeDeleted removes ShortURLs deleted before the time from both storages.
+func (repo *DualWriteRepository) PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	purged, err := repo.Primary.PurgeDeleted(ctx, deletedBefore)
+	if err != nil {
+		return purged, err
+	}
+	_, err = repo.Secondary.PurgeDeleted(ctx, deletedBefore)
+	repo.secondaryFailed("purge", err)
+	return purged, nil
+}
+
+// CreateAPIKey creates APIKey in both storages.
+func (repo *DualWriteRepository) CreateAPIKey(ctx context.Context, key entities.APIKey) (entities.APIKey, error) {
+	created, err := repo.Primary.CreateAPIKey(ctx, key)
+	if err != nil {
+		return created, err
+	}
+	_, err = repo.Secondary.CreateAPIKey(ctx, created)
+	repo.secondaryFailed("create api key", err)
+	return created, nil
+}
+
+// GetAPIKeyByHash returns APIKey by hash of its secret.
+func (repo *DualWriteRepository) GetAPIKeyByHash(ctx context.Context, hash string) (entities.APIKey, bool, error) {
+	key, exist, err := repo.Primary.GetAPIKeyByHash(ctx, hash)
+	if repo.fallback(exist, err) {
+		return repo.Secondary.GetAPIKeyByHash(ctx, hash)
+	}
+	return key, exist, err
+}
+
+// GetAPIKeysByUserID returns APIKeys of user.
+func (repo *DualWriteRepository) GetAPIKeysByUserID(ctx context.Context, userID uuid.UUID) ([]entities.APIKey, error) {
+	keys, err := repo.Primary.GetAPIKeysByUserID(ctx, userID)
+	if repo.fallback(true, err) {
+		return repo.Secondary.GetAPIKeysByUserID(ctx, userID)
+	}
+	return keys, err
+}
+
+// RevokeAPIKey revokes APIKey of user in both storages.
+func (repo *DualWriteRepository) RevokeAPIKey(ctx context.Context, userID uuid.UUID, id string) error {
+	if err := repo.Primary.RevokeAPIKey(ctx, userID, id); err != nil {
+		return err
+	}
+	repo.secondaryFailed("revoke api key", repo.Secondary.RevokeAPIKey(ctx, userID, id))
+	return nil
+}
+
+// CreateUser creates User in both storages.
+func (repo *DualWriteRepository) CreateUser(ctx context.Context, user entities.User) (entities.User, error) {
+	created, err := repo.Primary.CreateUser(ctx, user)
+	if err != nil {
+		return created, err
+	}
+	_, err = repo.Secondary.CreateUser(ctx, created)
+	repo.secondaryFailed("create user", err)
+	return created, nil
+}
+
+// CreateUserWithRecords creates User and moves records of another user to it in both storages.
+func (repo *DualWriteRepository) CreateUserWithRecords(
+	ctx context.Context,
+	user entities.User,
+	fromUserID uuid.UUID,
+) (entities.User, int64, error) {
+	created, moved, err := repo.Primary.CreateUserWithRecords(ctx, user, fromUserID)
+	if err != nil {
+		return created, moved, err
+	}
+	_, _, err = repo.Secondary.CreateUserWithRecords(ctx, created, fromUserID)
+	repo.secondaryFailed("create user", err)
+	return created, moved, nil
+}
+
+// GetUserByID returns User by id.
+func (repo *DualWriteRepository) GetUserByID(ctx context.Context, id uuid.UUID) (entities.User, bool, error) {
+	user, exist, err := repo.Primary.GetUserByID(ctx, id)
+	if repo.fallback(exist, err) {
+		return repo.Secondary.GetUserByID(ctx, id)
+	}
+	return user, exist, err
+}
+
+// GetUserByUsername returns User by username.
+func (repo *DualWriteRepository) GetUserByUsername(ctx context.Context, username string) (entities.User, bool, error) {
+	user, exist, err := repo.Primary.GetUserByUsername(ctx, username)
+	if repo.fallback(exist, err) {
+		return repo.Secondary.GetUserByUsername(ctx, username)
+	}
+	return user, exist, err
+}
+
+// ReassignRecords moves ShortURLs of one user to another in both storages.
+func (repo *DualWriteRepository) ReassignRecords(
+	ctx context.Context,
+	fromUserID uuid.UUID,
+	toUserID uuid.UUID,
+) (int64, error) {
+	reassigned, err := repo.Primary.ReassignRecords(ctx, fromUserID, toUserID)
+	if err != nil {
+		return reassigned, err
+	}
+	_, err = repo.Secondary.ReassignRecords(ctx, fromUserID, toUserID)
+	repo.secondaryFailed("reassign", err)
+	return reassigned, nil
+}
+
+// GetByOriginal returns ShortURL by original url within domain and workspace.
+func (repo *DualWriteRepository) GetByOriginal(
+	ctx context.Context,
+	domain string,
+	workspaceID string,
+	original string,
+) (entities.ShortURL, bool, error) {
+	shortURL, exist, err := repo.Primary.GetByOriginal(ctx, domain, workspaceID, original)
+	if repo.fallback(exist, err) {
+		return repo.Secondary.GetByOriginal(ctx, domain, workspaceID, original)
+	}
+	return shortURL, exist, err
+}
+
+// GetAllByUserID returns all ShortURLs of user including inactive ones.
+func (repo *DualWriteRepository) GetAllByUserID(ctx context.Context, userID uuid.UUID) ([]entities.ShortURL, error) {
+	urls, err := repo.Primary.GetAllByUserID(ctx, userID)
+	if repo.fallback(true, err) {
+		return repo.Secondary.GetAllByUserID(ctx, userID)
+	}
+	return urls, err
+}
+
+// SetActive activates or deactivates ShortURL in both storages.
+func (repo *DualWriteRepository) SetActive(ctx context.Context, id string, isActive bool) (entities.ShortURL, error) {
+	updated, err := repo.Primary.SetActive(ctx, id, isActive)
+	if err != nil {
+		return updated, err
+	}
+	repo.mirror(ctx, "set active", updated)
+	return updated, nil
+}
+
+// SetOwner changes owner of ShortURL in both storages.
+func (repo *DualWriteRepository) SetOwner(ctx context.Context, id string, userID uuid.UUID) (entities.ShortURL, error) {
+	updated, err := repo.Primary.SetOwner(ctx, id, userID)
+	if err != nil {
+		return updated, err
+	}
+	repo.mirror(ctx, "set owner", updated)
+	return updated, nil
+}
+
+// WriteAuditLog writes entry to audit log of both storages.
+func (repo *DualWriteRepository) WriteAuditLog(ctx context.Context, entry entities.AuditLogEntry) error {
+	if err := repo.Primary.WriteAuditLog(ctx, entry); err != nil {
+		return err
+	}
+	repo.secondaryFailed("write audit log", repo.Secondary.WriteAuditLog(ctx, entry))
+	return nil
+}
+
+// GetAuditLog returns latest entries of audit log from primary storage.
+func (repo *DualWriteRepository) GetAuditLog(ctx context.Context, limit int) ([]entities.AuditLogEntry, error) {
+	return repo.Primary.GetAuditLog(ctx, limit)
+}
+
+// GetDeadLetterDeleteJobs returns deletion jobs given up after all retries.
+func (repo *DualWriteRepository) GetDeadLetterDeleteJobs(ctx context.Context) ([]entities.DeleteJob, error) {
+	return repo.Primary.GetDeadLetterDeleteJobs(ctx)
+}
+
+// GetExisting returns ShortURLs of primary storage with the same id or original url.
+func (repo *DualWriteRepository) GetExisting(ctx context.Context, urls []entities.ShortURL) ([]entities.ShortURL, error) {
+	return repo.Primary.GetExisting(ctx, urls)
+}
+
+// CreateWorkspace creates Workspace in both storages.
+func (repo *DualWriteRepository) CreateWorkspace(
+	ctx context.Context,
+	workspace entities.Workspace,
+) (entities.Workspace, error) {
+	created, err := repo.Primary.CreateWorkspace(ctx, workspace)
+	if err != nil {
+		return created, err
+	}
+	_, err = repo.Secondary.CreateWorkspace(ctx, created)
+	repo.secondaryFailed("create workspace", err)
+	return created, nil
+}
+
+// GetWorkspacesByUserID returns Workspaces user is member of.
+func (repo *DualWriteRepository) GetWorkspacesByUserID(
+	ctx context.Context,
+	userID uuid.UUID,
+) ([]entities.WorkspaceMembership, error) {
+	workspaces, err := repo.Primary.GetWorkspacesByUserID(ctx, userID)
+	if repo.fallback(true, err) {
+		return repo.Secondary.GetWorkspacesByUserID(ctx, userID)
+	}
+	return workspaces, err
+}
+
+// GetWorkspaceMember returns member of Workspace by user id.
+func (repo *DualWriteRepository) GetWorkspaceMember(
+	ctx context.Context,
+	workspaceID string,
+	userID uuid.UUID,
+) (entities.WorkspaceMember, bool, error) {
+	member, exist, err := repo.Primary.GetWorkspaceMember(ctx, workspaceID, userID)
+	if repo.fallback(exist, err) {
+		return repo.Secondary.GetWorkspaceMember(ctx, workspaceID, userID)
+	}
+	return member, exist, err
+}
+
+// GetWorkspaceMembers returns members of Workspace.
+func (repo *DualWriteRepository) GetWorkspaceMembers(
+	ctx context.Context,
+	workspaceID string,
+) ([]entities.WorkspaceMember, error) {
+	members, err := repo.Primary.GetWorkspaceMembers(ctx, workspaceID)
+	if repo.fallback(true, err) {
+		return repo.Secondary.GetWorkspaceMembers(ctx, workspaceID)
+	}
+	return members, err
+}
+
+// SetWorkspaceMember adds member to Workspace or changes their role in both storages.
+func (repo *DualWriteRepository) SetWorkspaceMember(
+	ctx context.Context,
+	member entities.WorkspaceMember,
+) (entities.WorkspaceMember, error) {
+	stored, err := repo.Primary.SetWorkspaceMember(ctx, member)
+	if err != nil {
+		return stored, err
+	}
+	_, err = repo.Secondary.SetWorkspaceMember(ctx, stored)
+	repo.secondaryFailed("set workspace member", err)
+	return stored, nil
+}
+
+// RemoveWorkspaceMember removes member from Workspace in both storages.
+func (repo *DualWriteRepository) RemoveWorkspaceMember(
+	ctx context.Context,
+	workspaceID string,
+	userID uuid.UUID,
+) error {
+	if err := repo.Primary.RemoveWorkspaceMember(ctx, workspaceID, userID); err != nil {
+		return err
+	}
+	repo.secondaryFailed("remove workspace member", repo.Secondary.RemoveWorkspaceMember(ctx, workspaceID, userID))
+	return nil
+}
+
+// GetByWorkspaceID returns active ShortURLs of Workspace.
+func (repo *DualWriteRepository) GetByWorkspaceID(
+	ctx context.Context,
+	workspaceID string,
+) ([]entities.ShortURL, error) {
+	urls, err := repo.Primary.GetByWorkspaceID(ctx, workspaceID)
+	if repo.fallback(true, err) {
+		return repo.Secondary.GetByWorkspaceID(ctx, workspaceID)
+	}
+	return urls, err
+}
+
+// AddTags tags ShortURLs user may edit in both storages.
+func (repo *DualWriteRepository) AddTags(
+	ctx context.Context,
+	userID uuid.UUID,
+	ids []string,
+	tags []string,
+) ([]string, error) {
+	tagged, err := repo.Primary.AddTags(ctx, userID, ids, tags)
+	if err != nil || len(tagged) == 0 {
+		return tagged, err
+	}
+	_, err = repo.Secondary.AddTags(ctx, userID, tagged, tags)
+	repo.secondaryFailed("add tags", err)
+	return tagged, nil
+}
+
+// RemoveTags removes tags from ShortURLs user may edit in both storages.
+func (repo *DualWriteRepository) RemoveTags(
+	ctx context.Context,
+	userID uuid.UUID,
+	ids []string,
+	tags []string,
+) ([]string, error) {
+	untagged, err := repo.Primary.RemoveTags(ctx, userID, ids, tags)
+	if err != nil || len(untagged) == 0 {
+		return untagged, err
+	}
+	_, err = repo.Secondary.RemoveTags(ctx, userID, untagged, tags)
+	repo.secondaryFailed("remove tags", err)
+	return untagged, nil
+}
+
+// GetTags returns tags of ShortURLs by their ids.
+func (repo *DualWriteRepository) GetTags(ctx context.Context, ids []string) (map[string][]string, error) {
+	tags, err := repo.Primary.GetTags(ctx, ids)
+	if repo.fallback(true, err) {
+		return repo.Secondary.GetTags(ctx, ids)
+	}
+	return tags, err
+}
+
+// GetByTags returns active ShortURLs having every one of tags.
+func (repo *DualWriteRepository) GetByTags(
+	ctx context.Context,
+	userID uuid.UUID,
+	workspaceID string,
+	tags []string,
+) ([]entities.ShortURL, error) {
+	urls, err := repo.Primary.GetByTags(ctx, userID, workspaceID, tags)
+	if repo.fallback(true, err) {
+		return repo.Secondary.GetByTags(ctx, userID, workspaceID, tags)
+	}
+	return urls, err
+}
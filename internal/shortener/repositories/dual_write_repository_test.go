@@ -0,0 +1,226 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDualWriteRepository(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	old := entities.ShortURL{ID: "old", Original: "https://ya.ru", UserID: userID, IsActive: true, CreatedAt: createdAt}
+	copied := entities.ShortURL{ID: "copied", Original: "https://mail.ru", UserID: userID, IsActive: true, CreatedAt: createdAt}
+	conflicting := entities.ShortURL{ID: "conflict", Original: "https://go.dev", UserID: userID, IsActive: true, CreatedAt: createdAt}
+	primary := &InMemoryRepository{Storage: map[string]entities.ShortURL{
+		old.ID:         old,
+		copied.ID:      copied,
+		conflicting.ID: conflicting,
+	}}
+	secondary := &InMemoryRepository{Storage: map[string]entities.ShortURL{
+		copied.ID: copied,
+		"other":   {ID: "other", Original: conflicting.Original, UserID: userID, IsActive: true},
+	}}
+	repo := NewDualWriteRepository(primary, secondary, false)
+	assert.Equal(t, entities.BackfillStatePending, repo.BackfillProgress().State)
+
+	created, err := repo.Create(ctx, entities.ShortURL{ID: "new", Original: "https://example.com", UserID: userID, IsActive: true})
+	require.NoError(t, err)
+	assert.Equal(t, created, secondary.Storage["new"])
+
+	updated, err := repo.Update(ctx, userID, old.ID, "https://ya.ru/updated")
+	require.NoError(t, err)
+	assert.Equal(t, updated, secondary.Storage[old.ID], "record missing in secondary storage is copied on update")
+
+	_, exist, err := repo.GetByID(ctx, "other")
+	require.NoError(t, err)
+	assert.False(t, exist)
+	repo.ReadFallback = true
+	_, exist, err = repo.GetByID(ctx, "other")
+	require.NoError(t, err)
+	assert.True(t, exist)
+
+	require.NoError(t, repo.Backfill(ctx, 2))
+	progress := repo.BackfillProgress()
+	assert.Equal(t, entities.BackfillStateFinished, progress.State)
+	assert.Equal(t, entities.BackfillEntityProgress{Scanned: 4, Skipped: 4}, progress.Entities[entities.BackfillShortURLs])
+	assert.NotNil(t, progress.FinishedAt)
+	assert.NotContains(t, secondary.Storage, conflicting.ID)
+
+	delete(secondary.Storage, copied.ID)
+	require.NoError(t, repo.Backfill(ctx, 0))
+	assert.Equal(t, int64(1), repo.BackfillProgress().Entities[entities.BackfillShortURLs].Copied)
+	assert.Equal(t, copied, secondary.Storage[copied.ID])
+}
+
+func TestDualWriteRepositoryBackfillEntities(t *testing.T) {
+	ctx := context.Background()
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	user := entities.User{ID: uuid.New(), Username: "owner", PasswordHash: "hash", CreatedAt: createdAt}
+	link := entities.ShortURL{ID: "id", Original: "https://ya.ru", UserID: user.ID, IsActive: true, CreatedAt: createdAt}
+	workspace := entities.Workspace{ID: "team", Name: "Team", CreatedBy: user.ID, CreatedAt: createdAt}
+	member := entities.WorkspaceMember{WorkspaceID: workspace.ID, UserID: user.ID, Role: entities.RoleOwner, AddedAt: createdAt}
+	change := entities.DestinationChange{ShortURLID: link.ID, Original: "https://go.dev", ChangedBy: user.ID, ChangedAt: createdAt}
+	key := entities.APIKey{ID: "key", Name: "ci", KeyHash: "hash", UserID: user.ID, CreatedAt: createdAt, IsActive: true}
+	entry := entities.AuditLogEntry{Actor: "admin", Action: "deactivate", Target: link.ID, CreatedAt: createdAt}
+	primary := &InMemoryRepository{
+		Storage:    map[string]entities.ShortURL{link.ID: link},
+		Users:      map[uuid.UUID]entities.User{user.ID: user},
+		Workspaces: map[string]entities.Workspace{workspace.ID: workspace},
+		Members:    map[string]map[uuid.UUID]entities.WorkspaceMember{workspace.ID: {user.ID: member}},
+		History:    map[string][]entities.DestinationChange{link.ID: {change}},
+		Clicks:     map[string]map[string]int64{link.ID: {"": 3}},
+		APIKeys:    map[string]entities.APIKey{key.ID: key},
+		AuditLog:   []entities.AuditLogEntry{entry},
+	}
+	primary.tags.add(link.ID, []string{"go", "promo"})
+	secondary := &FileRepository{Storage: map[string]entities.ShortURL{}, FilePath: filepath.Join(t.TempDir(), "storage.json")}
+	require.NoError(t, secondary.RecordClick(ctx, entities.Click{ShortURLID: link.ID, ClickedAt: createdAt}))
+	repo := NewDualWriteRepository(primary, secondary, false)
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, repo.Backfill(ctx, 1))
+	}
+	progress := repo.BackfillProgress()
+	assert.Equal(t, entities.BackfillStateFinished, progress.State)
+	assert.Equal(t, int64(10), progress.Scanned, "every entity is scanned on repeated backfill")
+	assert.Equal(t, int64(0), progress.Copied, "nothing is copied twice")
+	assert.Equal(t, entities.BackfillEntityProgress{Scanned: 2, Skipped: 2}, progress.Entities[entities.BackfillTags])
+
+	restored := &FileRepository{Storage: map[string]entities.ShortURL{}, FilePath: secondary.FilePath}
+	require.NoError(t, restored.Restore())
+	assert.Equal(t, link, restored.Storage[link.ID])
+	assert.Equal(t, user, restored.Users[user.ID])
+	assert.Equal(t, member, restored.Members[workspace.ID][user.ID])
+	assert.Equal(t, workspace, restored.Workspaces[workspace.ID])
+	assert.Equal(t, key, restored.APIKeys[key.ID])
+	tags, err := restored.GetTags(ctx, []string{link.ID})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"go", "promo"}, tags[link.ID])
+	history, err := restored.GetHistory(ctx, link.ID)
+	require.NoError(t, err)
+	assert.Len(t, history, 1)
+	clicks, err := restored.GetVariantClicks(ctx, link.ID)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"": 3}, clicks, "missing clicks are added to stored ones")
+	auditLog, err := restored.GetAuditLog(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []entities.AuditLogEntry{entry}, auditLog)
+}
+
+func TestDualWriteRepositoryBackfillFailure(t *testing.T) {
+	primary := &InMemoryRepository{Storage: map[string]entities.ShortURL{
+		"id": {ID: "id", Original: "https://ya.ru", IsActive: true},
+	}}
+	secondary := &InMemoryRepository{Storage: map[string]entities.ShortURL{}}
+	repo := NewDualWriteRepository(primary, secondary, false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, repo.Backfill(ctx, 1), context.Canceled)
+	progress := repo.BackfillProgress()
+	assert.Equal(t, entities.BackfillStateFailed, progress.State)
+	assert.NotEmpty(t, progress.Error)
+	assert.Empty(t, secondary.Storage)
+}
+
+func TestDualWriteRepositoryDeleteRecords(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+	link := entities.ShortURL{ID: "id", Original: "https://ya.ru", UserID: userID, IsActive: true}
+	primary := &InMemoryRepository{Storage: map[string]entities.ShortURL{link.ID: link}}
+	secondary := &InMemoryRepository{Storage: map[string]entities.ShortURL{link.ID: link}}
+	repo := NewDualWriteRepository(primary, secondary, false)
+
+	tracked := len(deleteJobs.jobs)
+	job, err := repo.DeleteRecords(ctx, userID, []string{link.ID})
+	require.NoError(t, err)
+	assert.Equal(t, entities.DeleteJobApplied, job.State)
+	assert.Len(t, deleteJobs.jobs, tracked+1, "secondary storage should not start its own job")
+	assert.False(t, primary.Storage[link.ID].IsActive)
+	assert.False(t, secondary.Storage[link.ID].IsActive)
+}
+
+func TestDualWriteRepositoryDeleteRecordsOnceApplied(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+	links := map[string]entities.ShortURL{
+		"ok":      {ID: "ok", Original: "https://ya.ru", UserID: userID, IsActive: true},
+		"failed":  {ID: "failed", Original: "https://go.dev", UserID: userID, IsActive: true},
+		"foreign": {ID: "foreign", Original: "https://mail.ru", UserID: uuid.New(), IsActive: true},
+	}
+	primary := &InMemoryRepository{Storage: make(map[string]entities.ShortURL)}
+	secondary := &InMemoryRepository{Storage: make(map[string]entities.ShortURL)}
+	for id, link := range links {
+		primary.Storage[id], secondary.Storage[id] = link, link
+	}
+	deleteErr := errors.New("connection lost")
+	primary.Batcher = NewDeleteBatcher(func(ctx context.Context, userID uuid.UUID, ids []string) ([]string, error) {
+		if deleteErr != nil {
+			return nil, deleteErr
+		}
+		return primary.DeleteRecordsForUser(ctx, userID, ids)
+	}, time.Hour, 100)
+	repo := NewDualWriteRepository(primary, secondary, false)
+
+	failed, err := repo.DeleteRecords(ctx, userID, []string{"failed"})
+	require.NoError(t, err)
+	assert.True(t, secondary.Storage["failed"].IsActive, "secondary storage waits for primary job")
+	now := time.Now()
+	for attempt := 0; attempt < deleteMaxAttempts; attempt++ {
+		primary.Batcher.Flush(ctx, now)
+		now = now.Add(deleteRetryMaxDelay)
+	}
+	failed, _ = deleteJobs.Get(failed.ID)
+	require.Equal(t, entities.DeleteJobFailed, failed.State)
+	assert.True(t, secondary.Storage["failed"].IsActive, "failed job is not applied to secondary storage")
+
+	deleteErr = nil
+	_, err = repo.DeleteRecords(ctx, userID, []string{"ok", "foreign"})
+	require.NoError(t, err)
+	primary.Batcher.Flush(ctx, now)
+	assert.False(t, primary.Storage["ok"].IsActive)
+	assert.False(t, secondary.Storage["ok"].IsActive, "ids deleted by primary job are deleted in secondary storage")
+	assert.True(t, secondary.Storage["foreign"].IsActive)
+}
+
+func TestDualWriteRepositoryBackfillDatabase(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	user := entities.User{ID: uuid.New(), Username: "owner", PasswordHash: "hash"}
+	primary := &InMemoryRepository{
+		Storage: map[string]entities.ShortURL{
+			"id":    {ID: "id", Original: "https://ya.ru", UserID: user.ID, IsActive: true},
+			"taken": {ID: "taken", Original: "https://go.dev", UserID: user.ID, IsActive: true},
+		},
+		Users: map[uuid.UUID]entities.User{user.ID: user},
+	}
+	mock.ExpectBegin()
+	mock.ExpectPrepare(`INSERT INTO users .* ON CONFLICT DO NOTHING`).
+		ExpectExec().
+		WithArgs(user.ID.String(), user.Username, user.PasswordHash, user.CreatedAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	insertURL := mock.ExpectPrepare(`INSERT INTO short_urls .* ON CONFLICT DO NOTHING`)
+	insertURL.ExpectExec().WillReturnResult(sqlmock.NewResult(0, 1))
+	insertURL.ExpectExec().WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	repo := NewDualWriteRepository(primary, &DatabaseRepository{Storage: db}, false)
+
+	require.NoError(t, repo.Backfill(context.Background(), 10))
+	progress := repo.BackfillProgress()
+	assert.Equal(t, entities.BackfillEntityProgress{Scanned: 1, Copied: 1}, progress.Entities[entities.BackfillUsers])
+	assert.Equal(t, entities.BackfillEntityProgress{Scanned: 2, Copied: 1, Skipped: 1}, progress.Entities[entities.BackfillShortURLs])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -3,9 +3,11 @@ package repositories
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"log"
 	"os"
+	"time"
 
 	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
 	"github.com/google/uuid"
@@ -13,9 +15,33 @@ import (
 
 // FileRepository repository based on file storage.
 type FileRepository struct {
-	Storage  map[string]entities.ShortURL
-	FilePath string
-	ToDelete chan entities.ItemToDelete
+	Storage    map[string]entities.ShortURL
+	APIKeys    map[string]entities.APIKey
+	Users      map[uuid.UUID]entities.User
+	History    map[string][]entities.DestinationChange
+	Workspaces map[string]entities.Workspace
+	Members    map[string]map[uuid.UUID]entities.WorkspaceMember
+	FilePath   string
+	Batcher    *DeleteBatcher
+
+	tags tagIndex
+}
+
+// Suffixes of the files next to FilePath holding entities other than ShortURL.
+const (
+	apiKeysFileSuffix    = ".api_keys"
+	usersFileSuffix      = ".users"
+	historyFileSuffix    = ".history"
+	auditLogFileSuffix   = ".audit"
+	clicksFileSuffix     = ".clicks"
+	workspacesFileSuffix = ".workspaces"
+	tagsFileSuffix       = ".tags"
+)
+
+// workspacesFile content of workspaces file, workspaces are stored together with their members.
+type workspacesFile struct {
+	Workspaces map[string]entities.Workspace                     `json:"workspaces"`
+	Members    map[string]map[uuid.UUID]entities.WorkspaceMember `json:"members"`
 }
 
 // GetByID returns ShortURL by its id.
@@ -26,37 +52,58 @@ func (repo *FileRepository) GetByID(ctx context.Context, id string) (entities.Sh
 	return result, exist, nil
 }
 
-// GetByUserID returns ShortURLs by user id.
+// GetByUserID returns personal ShortURLs by user id, ones created in workspaces are listed by GetByWorkspaceID.
 func (repo *FileRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]entities.ShortURL, error) {
 	result := make([]entities.ShortURL, 0, 8)
 	lock.RLock()
 	defer lock.RUnlock()
 
 	for _, shortURL := range repo.Storage {
-		if shortURL.UserID == userID && shortURL.IsActive {
+		if shortURL.UserID == userID && shortURL.IsActive && shortURL.WorkspaceID == "" {
 			result = append(result, shortURL)
 		}
 	}
 	return result, nil
 }
 
+// IterateByUserID calls fn for every ShortURL of user including inactive ones, stops on first error.
+func (repo *FileRepository) IterateByUserID(
+	ctx context.Context,
+	userID uuid.UUID,
+	fn func(entities.ShortURL) error,
+) error {
+	lock.RLock()
+	records := findAllByUserID(repo.Storage, userID)
+	lock.RUnlock()
+	sortByCreation(records)
+	return iterate(ctx, records, fn)
+}
+
+// IterateAll calls fn for every ShortURL of every user, stops on first error.
+func (repo *FileRepository) IterateAll(ctx context.Context, fn func(entities.ShortURL) error) error {
+	lock.RLock()
+	records := findAll(repo.Storage)
+	lock.RUnlock()
+	sortByCreation(records)
+	return iterate(ctx, records, fn)
+}
+
+// LoadBackup stores ShortURLs as they are, replacing the ones with the same id.
+func (repo *FileRepository) LoadBackup(ctx context.Context, urls []entities.ShortURL) error {
+	lock.Lock()
+	defer lock.Unlock()
+	for _, url := range urls {
+		repo.Storage[url.ID] = url
+	}
+	return repo.writeStorage()
+}
+
 // Create creates ShortURL.
 func (repo *FileRepository) Create(ctx context.Context, shortURL entities.ShortURL) (entities.ShortURL, error) {
 	lock.Lock()
+	defer lock.Unlock()
 	repo.Storage[shortURL.ID] = shortURL
-	lock.Unlock()
-
-	file, err := repo.openStorageFile()
-	if err != nil {
-		return entities.ShortURL{}, err
-	}
-	defer file.Close()
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", " ")
-
-	lock.RLock()
-	defer lock.RUnlock()
-	if err := encoder.Encode(&repo.Storage); err != nil {
+	if err := repo.writeStorage(); err != nil {
 		return entities.ShortURL{}, err
 	}
 	return shortURL, nil
@@ -68,51 +115,234 @@ func (repo *FileRepository) CreateMultiple(
 	urls []entities.ShortURL,
 ) ([]entities.ShortURL, error) {
 	lock.Lock()
+	defer lock.Unlock()
 	for _, url := range urls {
 		repo.Storage[url.ID] = url
 	}
-	lock.Unlock()
-	file, err := repo.openStorageFile()
-	if err != nil {
+	if err := repo.writeStorage(); err != nil {
 		return []entities.ShortURL{}, err
 	}
-	defer file.Close()
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", " ")
+	return urls, nil
+}
+
+// CreateBuffered creates multiple ShortURLs without saving storage to file,
+// they are saved by Flush or by any other write to storage.
+func (repo *FileRepository) CreateBuffered(
+	ctx context.Context,
+	urls []entities.ShortURL,
+) ([]entities.ShortURL, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	for _, url := range urls {
+		repo.Storage[url.ID] = url
+	}
+	return urls, nil
+}
+
+// Flush saves storage to file.
+func (repo *FileRepository) Flush(ctx context.Context) error {
+	lock.Lock()
+	defer lock.Unlock()
+	return repo.writeStorage()
+}
+
+// DeleteRecords deletes ShortURLs by ids, state of returned job is tracked until it is applied.
+func (repo *FileRepository) DeleteRecords(
+	ctx context.Context,
+	userID uuid.UUID,
+	ids []string,
+) (entities.DeleteJob, error) {
+	return deleteRecordsWith(ctx, repo.Batcher, nil, repo.DeleteRecordsForUser, userID, ids)
+}
 
+// DeleteRecordsForUser deletes ShortURLs of user by ids and saves storage to file, returns ids of deleted ones.
+func (repo *FileRepository) DeleteRecordsForUser(
+	ctx context.Context,
+	userID uuid.UUID,
+	ids []string,
+) ([]string, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	deleted := deleteRecords(repo.Storage, repo.Members, userID, ids, time.Now().UTC())
+	if len(deleted) == 0 {
+		return deleted, nil
+	}
+	if err := repo.writeStorage(); err != nil {
+		return nil, err
+	}
+	return deleted, nil
+}
+
+// GetDeleteJob returns deletion job by its id.
+func (repo *FileRepository) GetDeleteJob(ctx context.Context, id string) (entities.DeleteJob, bool, error) {
+	job, exist := deleteJobs.Get(id)
+	return job, exist, nil
+}
+
+// Update changes destination of ShortURL user may edit, previous destination is kept in history.
+func (repo *FileRepository) Update(
+	ctx context.Context,
+	userID uuid.UUID,
+	id string,
+	original string,
+) (entities.ShortURL, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	if repo.History == nil {
+		repo.History = make(map[string][]entities.DestinationChange)
+	}
+	url, err := updateOriginal(repo.Storage, repo.History, repo.Members, userID, id, original, time.Now().UTC())
+	if err != nil {
+		return url, err
+	}
+	if err = writeJSONFile(repo.FilePath+historyFileSuffix, repo.History); err != nil {
+		return entities.ShortURL{}, err
+	}
+	if err = repo.writeStorage(); err != nil {
+		return entities.ShortURL{}, err
+	}
+	return url, nil
+}
+
+// GetHistory returns previous destinations of ShortURL, newest first.
+func (repo *FileRepository) GetHistory(ctx context.Context, id string) ([]entities.DestinationChange, error) {
 	lock.RLock()
 	defer lock.RUnlock()
-	if err := encoder.Encode(&repo.Storage); err != nil {
-		return []entities.ShortURL{}, err
+	return latestDestinationChanges(repo.History[id]), nil
+}
+
+// UseClick takes one of clicks left of active ShortURL and saves storage to file.
+func (repo *FileRepository) UseClick(ctx context.Context, id string) (entities.ShortURL, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	url, err := useClick(repo.Storage, id)
+	if err != nil {
+		return url, err
 	}
-	return urls, nil
+	if err = repo.writeStorage(); err != nil {
+		return entities.ShortURL{}, err
+	}
+	return url, nil
 }
 
-// DeleteRecords deletes ShortURLs by ids.
-func (repo *FileRepository) DeleteRecords(ctx context.Context, userID uuid.UUID, ids []string) error {
+// SetVariants replaces split destinations of ShortURL user may edit.
+func (repo *FileRepository) SetVariants(
+	ctx context.Context,
+	userID uuid.UUID,
+	id string,
+	variants entities.Variants,
+	sticky bool,
+) (entities.ShortURL, error) {
 	lock.Lock()
-	for _, id := range ids {
-		if url, exist := repo.Storage[id]; exist && url.UserID == userID {
-			url.IsActive = false
-			repo.Storage[id] = url
-		}
+	defer lock.Unlock()
+	url, err := setVariants(repo.Storage, repo.Members, userID, id, variants, sticky)
+	if err != nil {
+		return url, err
 	}
-	lock.Unlock()
+	if err = repo.writeStorage(); err != nil {
+		return entities.ShortURL{}, err
+	}
+	return url, nil
+}
 
-	file, err := repo.openStorageFile()
+// RecordClick appends click to clicks file, one json object per line.
+func (repo *FileRepository) RecordClick(ctx context.Context, click entities.Click) error {
+	lock.Lock()
+	defer lock.Unlock()
+	file, err := os.OpenFile(repo.FilePath+clicksFileSuffix, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0777)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", " ")
+	return json.NewEncoder(file).Encode(click)
+}
+
+// GetVariantClicks returns numbers of clicks of ShortURL by variant id counted over clicks file.
+func (repo *FileRepository) GetVariantClicks(ctx context.Context, id string) (map[string]int64, error) {
+	lock.RLock()
+	defer lock.RUnlock()
+	stored, err := readJSONLines[entities.Click](repo.FilePath + clicksFileSuffix)
+	if err != nil {
+		return nil, err
+	}
+	clicks := make(map[string]int64)
+	for _, click := range stored {
+		if click.ShortURLID == id {
+			clicks[click.Variant]++
+		}
+	}
+	return clicks, nil
+}
 
+// GetDeletedByUserID returns ShortURLs deleted by user.
+func (repo *FileRepository) GetDeletedByUserID(ctx context.Context, userID uuid.UUID) ([]entities.ShortURL, error) {
 	lock.RLock()
 	defer lock.RUnlock()
-	if err = encoder.Encode(&repo.Storage); err != nil {
+	return findDeletedByUserID(repo.Storage, userID), nil
+}
+
+// RestoreRecords restores ShortURLs deleted by user, returns restored ones.
+func (repo *FileRepository) RestoreRecords(
+	ctx context.Context,
+	userID uuid.UUID,
+	ids []string,
+) ([]entities.ShortURL, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	restored := restoreRecords(repo.Storage, repo.Members, userID, ids)
+	if len(restored) == 0 {
+		return restored, nil
+	}
+	if err := repo.writeStorage(); err != nil {
+		return nil, err
+	}
+	return restored, nil
+}
+
+// PurgeDeleted removes ShortURLs deleted before the time, returns number of removed ones.
+func (repo *FileRepository) PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	// Clicks are not kept in memory, they are dropped from clicks file below.
+	purged := purgeDeleted(repo.Storage, repo.History, nil, &repo.tags, deletedBefore)
+	if len(purged) == 0 {
+		return 0, nil
+	}
+	if err := repo.writeStorage(); err != nil {
+		return 0, err
+	}
+	if err := writeJSONFile(repo.FilePath+tagsFileSuffix, repo.tags.snapshot()); err != nil {
+		return 0, err
+	}
+	if err := writeJSONFile(repo.FilePath+historyFileSuffix, repo.History); err != nil {
+		return 0, err
+	}
+	if err := repo.dropClicks(purged); err != nil {
+		return 0, err
+	}
+	return int64(len(purged)), nil
+}
+
+// dropClicks removes clicks of ShortURLs from clicks file, has to be called with lock held.
+func (repo *FileRepository) dropClicks(ids []string) error {
+	isDropped := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		isDropped[id] = true
+	}
+	stored, err := readJSONLines[entities.Click](repo.FilePath + clicksFileSuffix)
+	if err != nil {
 		return err
 	}
-	return nil
+	kept := make([]entities.Click, 0, len(stored))
+	for _, click := range stored {
+		if !isDropped[click.ShortURLID] {
+			kept = append(kept, click)
+		}
+	}
+	if len(kept) == len(stored) {
+		return nil
+	}
+	return writeJSONLines(repo.FilePath+clicksFileSuffix, kept, os.O_TRUNC)
 }
 
 // Restore restores storage from file.
@@ -126,12 +356,73 @@ func (repo *FileRepository) Restore() error {
 
 	lock.Lock()
 	defer lock.Unlock()
-	if err := decoder.Decode(&repo.Storage); err == io.EOF {
+	var stored map[string]entities.StoredShortURL
+	if err := decoder.Decode(&stored); err == io.EOF {
 		log.Println("State restored")
 	} else if err != nil {
 		return err
 	}
-	return nil
+	if repo.Storage == nil {
+		repo.Storage = make(map[string]entities.ShortURL, len(stored))
+	}
+	for id, url := range stored {
+		repo.Storage[id] = url.ToShortURL()
+	}
+	if err := readJSONFile(repo.FilePath+apiKeysFileSuffix, &repo.APIKeys); err != nil {
+		return err
+	}
+	if err := readJSONFile(repo.FilePath+usersFileSuffix, &repo.Users); err != nil {
+		return err
+	}
+	var workspaces workspacesFile
+	if err := readJSONFile(repo.FilePath+workspacesFileSuffix, &workspaces); err != nil {
+		return err
+	}
+	repo.Workspaces, repo.Members = workspaces.Workspaces, workspaces.Members
+	var tags map[string][]string
+	if err := readJSONFile(repo.FilePath+tagsFileSuffix, &tags); err != nil {
+		return err
+	}
+	repo.tags.load(tags)
+	return readJSONFile(repo.FilePath+historyFileSuffix, &repo.History)
+}
+
+// CreateAPIKey creates APIKey.
+func (repo *FileRepository) CreateAPIKey(ctx context.Context, key entities.APIKey) (entities.APIKey, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	if repo.APIKeys == nil {
+		repo.APIKeys = make(map[string]entities.APIKey)
+	}
+	repo.APIKeys[key.ID] = key
+	if err := writeJSONFile(repo.FilePath+apiKeysFileSuffix, repo.APIKeys); err != nil {
+		return entities.APIKey{}, err
+	}
+	return key, nil
+}
+
+// GetAPIKeyByHash returns APIKey by hash of its raw value.
+func (repo *FileRepository) GetAPIKeyByHash(ctx context.Context, hash string) (entities.APIKey, bool, error) {
+	lock.RLock()
+	defer lock.RUnlock()
+	return findAPIKeyByHash(repo.APIKeys, hash)
+}
+
+// GetAPIKeysByUserID returns APIKeys by user id.
+func (repo *FileRepository) GetAPIKeysByUserID(ctx context.Context, userID uuid.UUID) ([]entities.APIKey, error) {
+	lock.RLock()
+	defer lock.RUnlock()
+	return findAPIKeysByUserID(repo.APIKeys, userID), nil
+}
+
+// RevokeAPIKey deactivates APIKey owned by user.
+func (repo *FileRepository) RevokeAPIKey(ctx context.Context, userID uuid.UUID, id string) error {
+	lock.Lock()
+	defer lock.Unlock()
+	if err := revokeAPIKey(repo.APIKeys, userID, id); err != nil {
+		return err
+	}
+	return writeJSONFile(repo.FilePath+apiKeysFileSuffix, repo.APIKeys)
 }
 
 // openStorageFile opens storage file.
@@ -142,3 +433,634 @@ func (repo *FileRepository) openStorageFile() (*os.File, error) {
 	}
 	return file, nil
 }
+
+// CreateUser creates User, username has to be unique.
+func (repo *FileRepository) CreateUser(ctx context.Context, user entities.User) (entities.User, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	if repo.Users == nil {
+		repo.Users = make(map[uuid.UUID]entities.User)
+	}
+	user, err := createUser(repo.Users, user)
+	if err != nil {
+		return entities.User{}, err
+	}
+	if err = writeJSONFile(repo.FilePath+usersFileSuffix, repo.Users); err != nil {
+		return entities.User{}, err
+	}
+	return user, nil
+}
+
+// CreateUserWithRecords creates User and moves all records of another user to it at once,
+// returns number of moved ShortURLs.
+func (repo *FileRepository) CreateUserWithRecords(
+	ctx context.Context,
+	user entities.User,
+	fromUserID uuid.UUID,
+) (entities.User, int64, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	if repo.Users == nil {
+		repo.Users = make(map[uuid.UUID]entities.User)
+	}
+	user, err := createUser(repo.Users, user)
+	if err != nil {
+		return entities.User{}, 0, err
+	}
+	moved := reassignRecords(repo.Storage, repo.APIKeys, fromUserID, user.ID)
+	reassignMembers(repo.Members, fromUserID, user.ID)
+	if err = repo.writeStorage(); err != nil {
+		return entities.User{}, 0, err
+	}
+	if err = writeJSONFile(repo.FilePath+apiKeysFileSuffix, repo.APIKeys); err != nil {
+		return entities.User{}, 0, err
+	}
+	if err = repo.writeWorkspaces(); err != nil {
+		return entities.User{}, 0, err
+	}
+	if err = writeJSONFile(repo.FilePath+usersFileSuffix, repo.Users); err != nil {
+		return entities.User{}, 0, err
+	}
+	return user, moved, nil
+}
+
+// GetUserByID returns User by its id.
+func (repo *FileRepository) GetUserByID(ctx context.Context, id uuid.UUID) (entities.User, bool, error) {
+	lock.RLock()
+	result, exist := repo.Users[id]
+	lock.RUnlock()
+	return result, exist, nil
+}
+
+// GetUserByUsername returns User by its username.
+func (repo *FileRepository) GetUserByUsername(ctx context.Context, username string) (entities.User, bool, error) {
+	lock.RLock()
+	defer lock.RUnlock()
+	user, exist := findUserByUsername(repo.Users, username)
+	return user, exist, nil
+}
+
+// ReassignRecords moves all ShortURLs, APIKeys and workspace memberships of one user to another.
+func (repo *FileRepository) ReassignRecords(
+	ctx context.Context,
+	fromUserID uuid.UUID,
+	toUserID uuid.UUID,
+) (int64, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	moved := reassignRecords(repo.Storage, repo.APIKeys, fromUserID, toUserID)
+	reassignMembers(repo.Members, fromUserID, toUserID)
+	if err := repo.writeStorage(); err != nil {
+		return 0, err
+	}
+	if err := writeJSONFile(repo.FilePath+apiKeysFileSuffix, repo.APIKeys); err != nil {
+		return 0, err
+	}
+	if err := repo.writeWorkspaces(); err != nil {
+		return 0, err
+	}
+	return moved, nil
+}
+
+// GetByOriginal returns ShortURL by its original url, original url is unique within domain and workspace.
+func (repo *FileRepository) GetByOriginal(
+	ctx context.Context,
+	domain string,
+	workspaceID string,
+	original string,
+) (entities.ShortURL, bool, error) {
+	lock.RLock()
+	defer lock.RUnlock()
+	result, exist := findByOriginal(repo.Storage, domain, workspaceID, original)
+	return result, exist, nil
+}
+
+// GetAllByUserID returns ShortURLs by user id including inactive ones.
+func (repo *FileRepository) GetAllByUserID(ctx context.Context, userID uuid.UUID) ([]entities.ShortURL, error) {
+	lock.RLock()
+	defer lock.RUnlock()
+	return findAllByUserID(repo.Storage, userID), nil
+}
+
+// SetActive activates or deactivates ShortURL regardless of its owner.
+func (repo *FileRepository) SetActive(ctx context.Context, id string, isActive bool) (entities.ShortURL, error) {
+	return repo.update(id, setActive(isActive))
+}
+
+// SetOwner changes owner of ShortURL.
+func (repo *FileRepository) SetOwner(ctx context.Context, id string, userID uuid.UUID) (entities.ShortURL, error) {
+	return repo.update(id, func(url *entities.ShortURL) { url.UserID = userID })
+}
+
+// WriteAuditLog appends entry to audit log file, one json object per line.
+func (repo *FileRepository) WriteAuditLog(ctx context.Context, entry entities.AuditLogEntry) error {
+	lock.Lock()
+	defer lock.Unlock()
+	file, err := os.OpenFile(repo.FilePath+auditLogFileSuffix, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0777)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return json.NewEncoder(file).Encode(entry)
+}
+
+// GetExisting returns stored ShortURLs having the same id or original url as any of urls.
+func (repo *FileRepository) GetExisting(ctx context.Context, urls []entities.ShortURL) ([]entities.ShortURL, error) {
+	lock.RLock()
+	defer lock.RUnlock()
+	return findExisting(repo.Storage, urls), nil
+}
+
+// GetDeadLetterDeleteJobs returns deletion jobs given up after all retries.
+func (repo *FileRepository) GetDeadLetterDeleteJobs(ctx context.Context) ([]entities.DeleteJob, error) {
+	return deleteJobs.DeadLetters(), nil
+}
+
+// GetAuditLog returns latest audit log entries, newest first.
+func (repo *FileRepository) GetAuditLog(ctx context.Context, limit int) ([]entities.AuditLogEntry, error) {
+	lock.RLock()
+	defer lock.RUnlock()
+	entries, err := readJSONLines[entities.AuditLogEntry](repo.FilePath + auditLogFileSuffix)
+	if err != nil {
+		return nil, err
+	}
+	return latestAuditLogEntries(entries, limit), nil
+}
+
+// CreateWorkspace creates Workspace with its creator as owner.
+func (repo *FileRepository) CreateWorkspace(
+	ctx context.Context,
+	workspace entities.Workspace,
+) (entities.Workspace, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	if repo.Workspaces == nil {
+		repo.Workspaces = make(map[string]entities.Workspace)
+	}
+	if repo.Members == nil {
+		repo.Members = make(map[string]map[uuid.UUID]entities.WorkspaceMember)
+	}
+	workspace = createWorkspace(repo.Workspaces, repo.Members, workspace)
+	if err := repo.writeWorkspaces(); err != nil {
+		return entities.Workspace{}, err
+	}
+	return workspace, nil
+}
+
+// GetWorkspacesByUserID returns Workspaces user is member of with their role.
+func (repo *FileRepository) GetWorkspacesByUserID(
+	ctx context.Context,
+	userID uuid.UUID,
+) ([]entities.WorkspaceMembership, error) {
+	lock.RLock()
+	defer lock.RUnlock()
+	return findWorkspacesByUserID(repo.Workspaces, repo.Members, userID), nil
+}
+
+// GetWorkspaceMember returns member of Workspace by user id.
+func (repo *FileRepository) GetWorkspaceMember(
+	ctx context.Context,
+	workspaceID string,
+	userID uuid.UUID,
+) (entities.WorkspaceMember, bool, error) {
+	lock.RLock()
+	member, exist := repo.Members[workspaceID][userID]
+	lock.RUnlock()
+	return member, exist, nil
+}
+
+// GetWorkspaceMembers returns members of Workspace in order they were added.
+func (repo *FileRepository) GetWorkspaceMembers(
+	ctx context.Context,
+	workspaceID string,
+) ([]entities.WorkspaceMember, error) {
+	lock.RLock()
+	defer lock.RUnlock()
+	return findWorkspaceMembers(repo.Members, workspaceID), nil
+}
+
+// SetWorkspaceMember adds member to Workspace or changes role of existing one.
+func (repo *FileRepository) SetWorkspaceMember(
+	ctx context.Context,
+	member entities.WorkspaceMember,
+) (entities.WorkspaceMember, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	member, err := setWorkspaceMember(repo.Members, member)
+	if err != nil {
+		return member, err
+	}
+	if err = repo.writeWorkspaces(); err != nil {
+		return entities.WorkspaceMember{}, err
+	}
+	return member, nil
+}
+
+// RemoveWorkspaceMember removes member from Workspace.
+func (repo *FileRepository) RemoveWorkspaceMember(
+	ctx context.Context,
+	workspaceID string,
+	userID uuid.UUID,
+) error {
+	lock.Lock()
+	defer lock.Unlock()
+	if err := removeWorkspaceMember(repo.Members, workspaceID, userID); err != nil {
+		return err
+	}
+	return repo.writeWorkspaces()
+}
+
+// GetByWorkspaceID returns active ShortURLs of Workspace.
+func (repo *FileRepository) GetByWorkspaceID(
+	ctx context.Context,
+	workspaceID string,
+) ([]entities.ShortURL, error) {
+	lock.RLock()
+	defer lock.RUnlock()
+	return findByWorkspaceID(repo.Storage, workspaceID), nil
+}
+
+// AddTags tags ShortURLs user may edit and saves tags to file, returns ids of tagged ShortURLs.
+func (repo *FileRepository) AddTags(
+	ctx context.Context,
+	userID uuid.UUID,
+	ids []string,
+	tags []string,
+) ([]string, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	tagged := tagRecords(repo.Storage, repo.Members, userID, ids, func(id string) { repo.tags.add(id, tags) })
+	if len(tagged) == 0 {
+		return tagged, nil
+	}
+	return tagged, writeJSONFile(repo.FilePath+tagsFileSuffix, repo.tags.snapshot())
+}
+
+// RemoveTags removes tags from ShortURLs user may edit and saves tags to file, returns ids of untagged ShortURLs.
+func (repo *FileRepository) RemoveTags(
+	ctx context.Context,
+	userID uuid.UUID,
+	ids []string,
+	tags []string,
+) ([]string, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	untagged := tagRecords(repo.Storage, repo.Members, userID, ids, func(id string) { repo.tags.remove(id, tags) })
+	if len(untagged) == 0 {
+		return untagged, nil
+	}
+	return untagged, writeJSONFile(repo.FilePath+tagsFileSuffix, repo.tags.snapshot())
+}
+
+// GetTags returns sorted tags of ShortURLs by their ids.
+func (repo *FileRepository) GetTags(ctx context.Context, ids []string) (map[string][]string, error) {
+	lock.RLock()
+	defer lock.RUnlock()
+	return findTags(&repo.tags, ids), nil
+}
+
+// GetByTags returns active ShortURLs having every one of tags, personal ones of user
+// or ones of Workspace when workspaceID is given.
+func (repo *FileRepository) GetByTags(
+	ctx context.Context,
+	userID uuid.UUID,
+	workspaceID string,
+	tags []string,
+) ([]entities.ShortURL, error) {
+	lock.RLock()
+	defer lock.RUnlock()
+	return findByTags(repo.Storage, &repo.tags, userID, workspaceID, tags), nil
+}
+
+// writeStorage saves ShortURLs with their password hashes to file, has to be called with lock held.
+func (repo *FileRepository) writeStorage() error {
+	stored := make(map[string]entities.StoredShortURL, len(repo.Storage))
+	for id, url := range repo.Storage {
+		stored[id] = url.ToStored()
+	}
+	return writeJSONFile(repo.FilePath, stored)
+}
+
+// writeWorkspaces saves workspaces and their members to file, has to be called with lock held.
+func (repo *FileRepository) writeWorkspaces() error {
+	return writeJSONFile(
+		repo.FilePath+workspacesFileSuffix,
+		workspacesFile{Workspaces: repo.Workspaces, Members: repo.Members},
+	)
+}
+
+// update applies change to ShortURL and saves storage to file.
+func (repo *FileRepository) update(id string, change func(url *entities.ShortURL)) (entities.ShortURL, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	url, err := updateShortURL(repo.Storage, id, change)
+	if err != nil {
+		return entities.ShortURL{}, err
+	}
+	if err = repo.writeStorage(); err != nil {
+		return entities.ShortURL{}, err
+	}
+	return url, nil
+}
+
+// writeJSONFile replaces content of the file with json representation of value.
+func writeJSONFile(path string, value any) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0777)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", " ")
+	return encoder.Encode(value)
+}
+
+// readJSONFile decodes file content into value, missing or empty file is not an error.
+func readJSONFile(path string, value any) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err = json.NewDecoder(file).Decode(value); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// readJSONLines decodes file holding one json object per line, missing file has no objects.
+func readJSONLines[T any](path string) ([]T, error) {
+	values := make([]T, 0, 16)
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return values, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	for {
+		var value T
+		if err = decoder.Decode(&value); err == io.EOF {
+			return values, nil
+		} else if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+}
+
+// appendJSONLines appends values to file, one json object per line.
+func appendJSONLines[T any](path string, values []T) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return writeJSONLines(path, values, os.O_APPEND)
+}
+
+// writeJSONLines writes values to file opened with mode flag, os.O_APPEND or os.O_TRUNC, one json object per line.
+func writeJSONLines[T any](path string, values []T, mode int) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|mode, 0777)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	encoder := json.NewEncoder(file)
+	for _, value := range values {
+		if err = encoder.Encode(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InsertMissingShortURLs stores ShortURLs having neither stored id nor stored original url in their scope
+// and saves storage to file.
+func (repo *FileRepository) InsertMissingShortURLs(ctx context.Context, urls []entities.ShortURL) (int64, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	inserted := insertMissingShortURLs(repo.Storage, urls)
+	if inserted == 0 {
+		return 0, nil
+	}
+	return inserted, repo.writeStorage()
+}
+
+// IterateUsers calls fn for every User, stops on first error.
+func (repo *FileRepository) IterateUsers(ctx context.Context, fn func(entities.User) error) error {
+	lock.RLock()
+	users := mapValues(repo.Users, func(a, b entities.User) bool { return a.Username < b.Username })
+	lock.RUnlock()
+	return iterate(ctx, users, fn)
+}
+
+// InsertMissingUsers stores Users having neither stored id nor stored username and saves them to file.
+func (repo *FileRepository) InsertMissingUsers(ctx context.Context, users []entities.User) (int64, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	if repo.Users == nil {
+		repo.Users = make(map[uuid.UUID]entities.User)
+	}
+	inserted := insertMissingUsers(repo.Users, users)
+	if inserted == 0 {
+		return 0, nil
+	}
+	return inserted, writeJSONFile(repo.FilePath+usersFileSuffix, repo.Users)
+}
+
+// IterateWorkspaces calls fn for every Workspace, stops on first error.
+func (repo *FileRepository) IterateWorkspaces(ctx context.Context, fn func(entities.Workspace) error) error {
+	lock.RLock()
+	workspaces := mapValues(repo.Workspaces, func(a, b entities.Workspace) bool { return a.ID < b.ID })
+	lock.RUnlock()
+	return iterate(ctx, workspaces, fn)
+}
+
+// InsertMissingWorkspaces stores Workspaces with ids not stored yet and saves them to file.
+func (repo *FileRepository) InsertMissingWorkspaces(
+	ctx context.Context,
+	workspaces []entities.Workspace,
+) (int64, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	if repo.Workspaces == nil {
+		repo.Workspaces = make(map[string]entities.Workspace)
+	}
+	inserted := insertMissingWorkspaces(repo.Workspaces, workspaces)
+	if inserted == 0 {
+		return 0, nil
+	}
+	return inserted, repo.writeWorkspaces()
+}
+
+// IterateWorkspaceMembers calls fn for every member of every Workspace, stops on first error.
+func (repo *FileRepository) IterateWorkspaceMembers(
+	ctx context.Context,
+	fn func(entities.WorkspaceMember) error,
+) error {
+	lock.RLock()
+	members := workspaceMembers(repo.Members)
+	lock.RUnlock()
+	return iterate(ctx, members, fn)
+}
+
+// InsertMissingWorkspaceMembers stores members of stored Workspaces who are not their members yet
+// and saves them to file.
+func (repo *FileRepository) InsertMissingWorkspaceMembers(
+	ctx context.Context,
+	members []entities.WorkspaceMember,
+) (int64, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	if repo.Members == nil {
+		repo.Members = make(map[string]map[uuid.UUID]entities.WorkspaceMember)
+	}
+	inserted := insertMissingWorkspaceMembers(repo.Members, repo.Workspaces, members)
+	if inserted == 0 {
+		return 0, nil
+	}
+	return inserted, repo.writeWorkspaces()
+}
+
+// IterateTags calls fn for every tag of every ShortURL, stops on first error.
+func (repo *FileRepository) IterateTags(ctx context.Context, fn func(entities.ShortURLTag) error) error {
+	lock.RLock()
+	tags := shortURLTags(&repo.tags)
+	lock.RUnlock()
+	return iterate(ctx, tags, fn)
+}
+
+// InsertMissingTags adds tags missing on stored ShortURLs and saves tags to file.
+func (repo *FileRepository) InsertMissingTags(ctx context.Context, tags []entities.ShortURLTag) (int64, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	inserted := insertMissingTags(&repo.tags, repo.Storage, tags)
+	if inserted == 0 {
+		return 0, nil
+	}
+	return inserted, writeJSONFile(repo.FilePath+tagsFileSuffix, repo.tags.snapshot())
+}
+
+// IterateHistory calls fn for every destination change of every ShortURL, stops on first error.
+func (repo *FileRepository) IterateHistory(ctx context.Context, fn func(entities.DestinationChange) error) error {
+	lock.RLock()
+	changes := historyChanges(repo.History)
+	lock.RUnlock()
+	return iterate(ctx, changes, fn)
+}
+
+// InsertMissingHistory adds destination changes of stored ShortURLs missing in their history
+// and saves history to file.
+func (repo *FileRepository) InsertMissingHistory(
+	ctx context.Context,
+	changes []entities.DestinationChange,
+) (int64, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	if repo.History == nil {
+		repo.History = make(map[string][]entities.DestinationChange)
+	}
+	inserted := insertMissingHistory(repo.History, repo.Storage, changes)
+	if inserted == 0 {
+		return 0, nil
+	}
+	return inserted, writeJSONFile(repo.FilePath+historyFileSuffix, repo.History)
+}
+
+// IterateClicks calls fn for number of clicks of every ShortURL variant counted over clicks file,
+// stops on first error.
+func (repo *FileRepository) IterateClicks(ctx context.Context, fn func(entities.ClickCount) error) error {
+	lock.RLock()
+	clicks, err := repo.countClicks()
+	lock.RUnlock()
+	if err != nil {
+		return err
+	}
+	return iterate(ctx, clickCounts(clicks), fn)
+}
+
+// InsertMissingClicks appends clicks of stored ShortURLs to clicks file until their numbers of clicks
+// reach counts, added clicks are dated by now.
+func (repo *FileRepository) InsertMissingClicks(ctx context.Context, counts []entities.ClickCount) (int64, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	clicks, err := repo.countClicks()
+	if err != nil {
+		return 0, err
+	}
+	missing := missingClicks(clicks, repo.Storage, counts)
+	added := make([]entities.Click, 0, len(missing))
+	now := time.Now().UTC()
+	for _, count := range missing {
+		for i := int64(0); i < count.Clicks; i++ {
+			added = append(added, entities.Click{ShortURLID: count.ShortURLID, Variant: count.Variant, ClickedAt: now})
+		}
+	}
+	return int64(len(missing)), appendJSONLines(repo.FilePath+clicksFileSuffix, added)
+}
+
+// countClicks counts clicks of clicks file by ShortURL id and variant id, has to be called with lock held.
+func (repo *FileRepository) countClicks() (map[string]map[string]int64, error) {
+	stored, err := readJSONLines[entities.Click](repo.FilePath + clicksFileSuffix)
+	if err != nil {
+		return nil, err
+	}
+	clicks := make(map[string]map[string]int64)
+	for _, click := range stored {
+		countClick(clicks, click)
+	}
+	return clicks, nil
+}
+
+// IterateAPIKeys calls fn for every APIKey, stops on first error.
+func (repo *FileRepository) IterateAPIKeys(ctx context.Context, fn func(entities.APIKey) error) error {
+	lock.RLock()
+	keys := mapValues(repo.APIKeys, func(a, b entities.APIKey) bool { return a.ID < b.ID })
+	lock.RUnlock()
+	return iterate(ctx, keys, fn)
+}
+
+// InsertMissingAPIKeys stores APIKeys having neither stored id nor stored hash and saves them to file.
+func (repo *FileRepository) InsertMissingAPIKeys(ctx context.Context, keys []entities.APIKey) (int64, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	if repo.APIKeys == nil {
+		repo.APIKeys = make(map[string]entities.APIKey)
+	}
+	inserted := insertMissingAPIKeys(repo.APIKeys, keys)
+	if inserted == 0 {
+		return 0, nil
+	}
+	return inserted, writeJSONFile(repo.FilePath+apiKeysFileSuffix, repo.APIKeys)
+}
+
+// IterateAuditLog calls fn for every entry of audit log file, oldest first, stops on first error.
+func (repo *FileRepository) IterateAuditLog(ctx context.Context, fn func(entities.AuditLogEntry) error) error {
+	lock.RLock()
+	entries, err := readJSONLines[entities.AuditLogEntry](repo.FilePath + auditLogFileSuffix)
+	lock.RUnlock()
+	if err != nil {
+		return err
+	}
+	return iterate(ctx, entries, fn)
+}
+
+// InsertMissingAuditLog appends entries absent in audit log file.
+func (repo *FileRepository) InsertMissingAuditLog(
+	ctx context.Context,
+	entries []entities.AuditLogEntry,
+) (int64, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	stored, err := readJSONLines[entities.AuditLogEntry](repo.FilePath + auditLogFileSuffix)
+	if err != nil {
+		return 0, err
+	}
+	missing := missingAuditLogEntries(stored, entries)
+	return int64(len(missing)), appendJSONLines(repo.FilePath+auditLogFileSuffix, missing)
+}
@@ -2,16 +2,30 @@ package repositories
 
 import (
 	"context"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/shortenerrors"
 	"github.com/google/uuid"
 )
 
 // InMemoryRepository repository based memory storage.
 type InMemoryRepository struct {
 	Storage  map[string]entities.ShortURL
-	ToDelete chan entities.ItemToDelete
+	APIKeys  map[string]entities.APIKey
+	Users    map[uuid.UUID]entities.User
+	History  map[string][]entities.DestinationChange
+	AuditLog []entities.AuditLogEntry
+	// Clicks numbers of clicks by ShortURL id and variant id.
+	Clicks     map[string]map[string]int64
+	Workspaces map[string]entities.Workspace
+	// Members members of workspaces by workspace id and user id.
+	Members map[string]map[uuid.UUID]entities.WorkspaceMember
+	Batcher *DeleteBatcher
+
+	tags tagIndex
 }
 
 // lock mutex for storage.
@@ -25,12 +39,12 @@ func (repo *InMemoryRepository) GetByID(ctx context.Context, id string) (entitie
 	return result, exist, nil
 }
 
-// GetByUserID returns ShortURLs by user id.
+// GetByUserID returns personal ShortURLs by user id, ones created in workspaces are listed by GetByWorkspaceID.
 func (repo *InMemoryRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]entities.ShortURL, error) {
 	result := make([]entities.ShortURL, 0, 8)
 	lock.RLock()
 	for _, shortURL := range repo.Storage {
-		if shortURL.UserID == userID && shortURL.IsActive {
+		if shortURL.UserID == userID && shortURL.IsActive && shortURL.WorkspaceID == "" {
 			result = append(result, shortURL)
 		}
 	}
@@ -38,6 +52,38 @@ func (repo *InMemoryRepository) GetByUserID(ctx context.Context, userID uuid.UUI
 	return result, nil
 }
 
+// IterateByUserID calls fn for every ShortURL of user including inactive ones, stops on first error.
+func (repo *InMemoryRepository) IterateByUserID(
+	ctx context.Context,
+	userID uuid.UUID,
+	fn func(entities.ShortURL) error,
+) error {
+	lock.RLock()
+	records := findAllByUserID(repo.Storage, userID)
+	lock.RUnlock()
+	sortByCreation(records)
+	return iterate(ctx, records, fn)
+}
+
+// IterateAll calls fn for every ShortURL of every user, stops on first error.
+func (repo *InMemoryRepository) IterateAll(ctx context.Context, fn func(entities.ShortURL) error) error {
+	lock.RLock()
+	records := findAll(repo.Storage)
+	lock.RUnlock()
+	sortByCreation(records)
+	return iterate(ctx, records, fn)
+}
+
+// LoadBackup stores ShortURLs as they are, replacing the ones with the same id.
+func (repo *InMemoryRepository) LoadBackup(ctx context.Context, urls []entities.ShortURL) error {
+	lock.Lock()
+	for _, url := range urls {
+		repo.Storage[url.ID] = url
+	}
+	lock.Unlock()
+	return nil
+}
+
 // Create creates ShortURL.
 func (repo *InMemoryRepository) Create(ctx context.Context, shortURL entities.ShortURL) (entities.ShortURL, error) {
 	lock.Lock()
@@ -59,15 +105,1032 @@ func (repo *InMemoryRepository) CreateMultiple(
 	return urls, nil
 }
 
-// DeleteRecords deletes ShortURLs by ids.
-func (repo *InMemoryRepository) DeleteRecords(ctx context.Context, userID uuid.UUID, ids []string) error {
+// DeleteRecords deletes ShortURLs by ids, state of returned job is tracked until it is applied.
+func (repo *InMemoryRepository) DeleteRecords(
+	ctx context.Context,
+	userID uuid.UUID,
+	ids []string,
+) (entities.DeleteJob, error) {
+	return deleteRecordsWith(ctx, repo.Batcher, nil, repo.DeleteRecordsForUser, userID, ids)
+}
+
+// DeleteRecordsForUser deletes ShortURLs of user by ids, returns ids of deleted ones.
+func (repo *InMemoryRepository) DeleteRecordsForUser(
+	ctx context.Context,
+	userID uuid.UUID,
+	ids []string,
+) ([]string, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	return deleteRecords(repo.Storage, repo.Members, userID, ids, time.Now().UTC()), nil
+}
+
+// GetDeleteJob returns deletion job by its id.
+func (repo *InMemoryRepository) GetDeleteJob(ctx context.Context, id string) (entities.DeleteJob, bool, error) {
+	job, exist := deleteJobs.Get(id)
+	return job, exist, nil
+}
+
+// Update changes destination of ShortURL user may edit, previous destination is kept in history.
+func (repo *InMemoryRepository) Update(
+	ctx context.Context,
+	userID uuid.UUID,
+	id string,
+	original string,
+) (entities.ShortURL, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	if repo.History == nil {
+		repo.History = make(map[string][]entities.DestinationChange)
+	}
+	return updateOriginal(repo.Storage, repo.History, repo.Members, userID, id, original, time.Now().UTC())
+}
+
+// GetHistory returns previous destinations of ShortURL, newest first.
+func (repo *InMemoryRepository) GetHistory(ctx context.Context, id string) ([]entities.DestinationChange, error) {
+	lock.RLock()
+	defer lock.RUnlock()
+	return latestDestinationChanges(repo.History[id]), nil
+}
+
+// UseClick takes one of clicks left of active ShortURL.
+func (repo *InMemoryRepository) UseClick(ctx context.Context, id string) (entities.ShortURL, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	return useClick(repo.Storage, id)
+}
+
+// useClick takes one of clicks left of active ShortURL in map storage, has to be called with lock held.
+func useClick(urls map[string]entities.ShortURL, id string) (entities.ShortURL, error) {
+	url, exist := urls[id]
+	if !exist || !url.IsActive || url.ClicksLeft <= 0 {
+		return entities.ShortURL{}, shortenerrors.ErrClicksExhausted
+	}
+	url.ClicksLeft--
+	urls[id] = url
+	return url, nil
+}
+
+// SetVariants replaces split destinations of ShortURL user may edit.
+func (repo *InMemoryRepository) SetVariants(
+	ctx context.Context,
+	userID uuid.UUID,
+	id string,
+	variants entities.Variants,
+	sticky bool,
+) (entities.ShortURL, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	return setVariants(repo.Storage, repo.Members, userID, id, variants, sticky)
+}
+
+// setVariants replaces split destinations of ShortURL user may edit in map storage.
+func setVariants(
+	urls map[string]entities.ShortURL,
+	members map[string]map[uuid.UUID]entities.WorkspaceMember,
+	userID uuid.UUID,
+	id string,
+	variants entities.Variants,
+	sticky bool,
+) (entities.ShortURL, error) {
+	url, exist := urls[id]
+	if !exist || !canEdit(members, userID, url) || !url.IsActive {
+		return entities.ShortURL{}, shortenerrors.ErrItemNotFound
+	}
+	url.Variants = variants
+	url.StickyVariant = sticky
+	urls[id] = url
+	return url, nil
+}
+
+// RecordClick counts click by variant served.
+func (repo *InMemoryRepository) RecordClick(ctx context.Context, click entities.Click) error {
 	lock.Lock()
+	defer lock.Unlock()
+	if repo.Clicks == nil {
+		repo.Clicks = make(map[string]map[string]int64)
+	}
+	countClick(repo.Clicks, click)
+	return nil
+}
+
+// GetVariantClicks returns numbers of clicks of ShortURL by variant id.
+func (repo *InMemoryRepository) GetVariantClicks(ctx context.Context, id string) (map[string]int64, error) {
+	lock.RLock()
+	defer lock.RUnlock()
+	clicks := make(map[string]int64, len(repo.Clicks[id]))
+	for variant, count := range repo.Clicks[id] {
+		clicks[variant] = count
+	}
+	return clicks, nil
+}
+
+// countClick adds click to numbers of clicks by ShortURL id and variant id.
+func countClick(clicks map[string]map[string]int64, click entities.Click) {
+	if clicks[click.ShortURLID] == nil {
+		clicks[click.ShortURLID] = make(map[string]int64)
+	}
+	clicks[click.ShortURLID][click.Variant]++
+}
+
+// updateOriginal changes destination of ShortURL in map storage, same original url can not be shortened twice.
+func updateOriginal(
+	urls map[string]entities.ShortURL,
+	history map[string][]entities.DestinationChange,
+	members map[string]map[uuid.UUID]entities.WorkspaceMember,
+	userID uuid.UUID,
+	id string,
+	original string,
+	changedAt time.Time,
+) (entities.ShortURL, error) {
+	url, exist := urls[id]
+	if !exist || !canEdit(members, userID, url) || !url.IsActive {
+		return entities.ShortURL{}, shortenerrors.ErrItemNotFound
+	}
+	if url.Original == original {
+		return url, nil
+	}
+	if existed, found := findByOriginal(urls, url.Domain, url.WorkspaceID, original); found {
+		return existed, shortenerrors.ErrItemAlreadyExists
+	}
+	history[id] = append(history[id], entities.DestinationChange{
+		ShortURLID: id,
+		Original:   url.Original,
+		ChangedBy:  userID,
+		ChangedAt:  changedAt,
+	})
+	url.Original = original
+	urls[id] = url
+	return url, nil
+}
+
+// latestDestinationChanges returns copy of changes in reverse order.
+func latestDestinationChanges(changes []entities.DestinationChange) []entities.DestinationChange {
+	result := make([]entities.DestinationChange, 0, len(changes))
+	for i := len(changes) - 1; i >= 0; i-- {
+		result = append(result, changes[i])
+	}
+	return result
+}
+
+// GetDeletedByUserID returns ShortURLs deleted by user.
+func (repo *InMemoryRepository) GetDeletedByUserID(ctx context.Context, userID uuid.UUID) ([]entities.ShortURL, error) {
+	lock.RLock()
+	defer lock.RUnlock()
+	return findDeletedByUserID(repo.Storage, userID), nil
+}
+
+// RestoreRecords restores ShortURLs deleted by user, returns restored ones.
+func (repo *InMemoryRepository) RestoreRecords(
+	ctx context.Context,
+	userID uuid.UUID,
+	ids []string,
+) ([]entities.ShortURL, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	return restoreRecords(repo.Storage, repo.Members, userID, ids), nil
+}
+
+// PurgeDeleted removes ShortURLs deleted before the time, returns number of removed ones.
+func (repo *InMemoryRepository) PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	return int64(len(purgeDeleted(repo.Storage, repo.History, repo.Clicks, &repo.tags, deletedBefore))), nil
+}
+
+// deleteRecords marks ShortURLs user may edit as deleted in map storage, returns ids of deleted ones.
+func deleteRecords(
+	urls map[string]entities.ShortURL,
+	members map[string]map[uuid.UUID]entities.WorkspaceMember,
+	userID uuid.UUID,
+	ids []string,
+	deletedAt time.Time,
+) []string {
+	deleted := make([]string, 0, len(ids))
 	for _, id := range ids {
-		if url, exist := repo.Storage[id]; exist && url.UserID == userID {
+		if url, exist := urls[id]; exist && canEdit(members, userID, url) && url.IsActive {
 			url.IsActive = false
-			repo.Storage[id] = url
+			url.DeletedAt = &deletedAt
+			urls[id] = url
+			deleted = append(deleted, id)
+		}
+	}
+	return deleted
+}
+
+// findDeletedByUserID filters ShortURLs deleted by user in map storage.
+func findDeletedByUserID(urls map[string]entities.ShortURL, userID uuid.UUID) []entities.ShortURL {
+	result := make([]entities.ShortURL, 0, 8)
+	for _, url := range urls {
+		if url.UserID == userID && url.IsDeleted() {
+			result = append(result, url)
+		}
+	}
+	return result
+}
+
+// restoreRecords restores deleted ShortURLs user may edit in map storage.
+func restoreRecords(
+	urls map[string]entities.ShortURL,
+	members map[string]map[uuid.UUID]entities.WorkspaceMember,
+	userID uuid.UUID,
+	ids []string,
+) []entities.ShortURL {
+	result := make([]entities.ShortURL, 0, len(ids))
+	for _, id := range ids {
+		if url, exist := urls[id]; exist && canEdit(members, userID, url) && url.IsDeleted() {
+			url.IsActive = true
+			url.DeletedAt = nil
+			urls[id] = url
+			result = append(result, url)
+		}
+	}
+	return result
+}
+
+// purgeDeleted removes ShortURLs deleted before the time, their history and tags from map storage.
+func purgeDeleted(
+	urls map[string]entities.ShortURL,
+	history map[string][]entities.DestinationChange,
+	clicks map[string]map[string]int64,
+	tags *tagIndex,
+	deletedBefore time.Time,
+) []string {
+	purged := make([]string, 0)
+	for id, url := range urls {
+		if url.IsDeleted() && url.DeletedAt.Before(deletedBefore) {
+			delete(urls, id)
+			delete(history, id)
+			delete(clicks, id)
+			tags.drop(id)
+			purged = append(purged, id)
+		}
+	}
+	return purged
+}
+
+// CreateAPIKey creates APIKey.
+func (repo *InMemoryRepository) CreateAPIKey(ctx context.Context, key entities.APIKey) (entities.APIKey, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	if repo.APIKeys == nil {
+		repo.APIKeys = make(map[string]entities.APIKey)
+	}
+	repo.APIKeys[key.ID] = key
+	return key, nil
+}
+
+// GetAPIKeyByHash returns APIKey by hash of its raw value.
+func (repo *InMemoryRepository) GetAPIKeyByHash(ctx context.Context, hash string) (entities.APIKey, bool, error) {
+	lock.RLock()
+	defer lock.RUnlock()
+	return findAPIKeyByHash(repo.APIKeys, hash)
+}
+
+// GetAPIKeysByUserID returns APIKeys by user id.
+func (repo *InMemoryRepository) GetAPIKeysByUserID(ctx context.Context, userID uuid.UUID) ([]entities.APIKey, error) {
+	lock.RLock()
+	defer lock.RUnlock()
+	return findAPIKeysByUserID(repo.APIKeys, userID), nil
+}
+
+// RevokeAPIKey deactivates APIKey owned by user.
+func (repo *InMemoryRepository) RevokeAPIKey(ctx context.Context, userID uuid.UUID, id string) error {
+	lock.Lock()
+	defer lock.Unlock()
+	return revokeAPIKey(repo.APIKeys, userID, id)
+}
+
+// findAPIKeyByHash looks up APIKey by its hash in map storage.
+func findAPIKeyByHash(keys map[string]entities.APIKey, hash string) (entities.APIKey, bool, error) {
+	for _, key := range keys {
+		if key.KeyHash == hash {
+			return key, true, nil
+		}
+	}
+	return entities.APIKey{}, false, nil
+}
+
+// findAPIKeysByUserID filters APIKeys in map storage by user id.
+func findAPIKeysByUserID(keys map[string]entities.APIKey, userID uuid.UUID) []entities.APIKey {
+	result := make([]entities.APIKey, 0, 4)
+	for _, key := range keys {
+		if key.UserID == userID {
+			result = append(result, key)
+		}
+	}
+	return result
+}
+
+// revokeAPIKey deactivates APIKey in map storage.
+func revokeAPIKey(keys map[string]entities.APIKey, userID uuid.UUID, id string) error {
+	key, exist := keys[id]
+	if !exist || key.UserID != userID {
+		return shortenerrors.ErrItemNotFound
+	}
+	key.IsActive = false
+	keys[id] = key
+	return nil
+}
+
+// CreateUser creates User, username has to be unique.
+func (repo *InMemoryRepository) CreateUser(ctx context.Context, user entities.User) (entities.User, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	if repo.Users == nil {
+		repo.Users = make(map[uuid.UUID]entities.User)
+	}
+	return createUser(repo.Users, user)
+}
+
+// CreateUserWithRecords creates User and moves all records of another user to it at once,
+// returns number of moved ShortURLs.
+func (repo *InMemoryRepository) CreateUserWithRecords(
+	ctx context.Context,
+	user entities.User,
+	fromUserID uuid.UUID,
+) (entities.User, int64, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	if repo.Users == nil {
+		repo.Users = make(map[uuid.UUID]entities.User)
+	}
+	user, err := createUser(repo.Users, user)
+	if err != nil {
+		return entities.User{}, 0, err
+	}
+	reassignMembers(repo.Members, fromUserID, user.ID)
+	return user, reassignRecords(repo.Storage, repo.APIKeys, fromUserID, user.ID), nil
+}
+
+// GetUserByID returns User by its id.
+func (repo *InMemoryRepository) GetUserByID(ctx context.Context, id uuid.UUID) (entities.User, bool, error) {
+	lock.RLock()
+	result, exist := repo.Users[id]
+	lock.RUnlock()
+	return result, exist, nil
+}
+
+// GetUserByUsername returns User by its username.
+func (repo *InMemoryRepository) GetUserByUsername(ctx context.Context, username string) (entities.User, bool, error) {
+	lock.RLock()
+	defer lock.RUnlock()
+	user, exist := findUserByUsername(repo.Users, username)
+	return user, exist, nil
+}
+
+// ReassignRecords moves all ShortURLs, APIKeys and workspace memberships of one user to another.
+func (repo *InMemoryRepository) ReassignRecords(
+	ctx context.Context,
+	fromUserID uuid.UUID,
+	toUserID uuid.UUID,
+) (int64, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	reassignMembers(repo.Members, fromUserID, toUserID)
+	return reassignRecords(repo.Storage, repo.APIKeys, fromUserID, toUserID), nil
+}
+
+// createUser stores User in map storage if username is not taken.
+func createUser(users map[uuid.UUID]entities.User, user entities.User) (entities.User, error) {
+	if _, exist := findUserByUsername(users, user.Username); exist {
+		return entities.User{}, shortenerrors.ErrItemAlreadyExists
+	}
+	users[user.ID] = user
+	return user, nil
+}
+
+// findUserByUsername looks up User by username in map storage.
+func findUserByUsername(users map[uuid.UUID]entities.User, username string) (entities.User, bool) {
+	for _, user := range users {
+		if user.Username == username {
+			return user, true
+		}
+	}
+	return entities.User{}, false
+}
+
+// reassignRecords changes owner of ShortURLs and APIKeys in map storage, returns number of moved ShortURLs.
+func reassignRecords(
+	urls map[string]entities.ShortURL,
+	keys map[string]entities.APIKey,
+	fromUserID uuid.UUID,
+	toUserID uuid.UUID,
+) int64 {
+	var moved int64
+	for id, url := range urls {
+		if url.UserID == fromUserID {
+			url.UserID = toUserID
+			urls[id] = url
+			moved++
+		}
+	}
+	for id, key := range keys {
+		if key.UserID == fromUserID {
+			key.UserID = toUserID
+			keys[id] = key
 		}
 	}
+	return moved
+}
+
+// reassignMembers moves workspace memberships of one user to another in map storage,
+// role with more rights is kept when both users are members of the same workspace.
+func reassignMembers(
+	members map[string]map[uuid.UUID]entities.WorkspaceMember,
+	fromUserID uuid.UUID,
+	toUserID uuid.UUID,
+) {
+	for _, workspaceMembers := range members {
+		member, exist := workspaceMembers[fromUserID]
+		if !exist {
+			continue
+		}
+		delete(workspaceMembers, fromUserID)
+		if current, isMember := workspaceMembers[toUserID]; isMember && !member.Outranks(current) {
+			continue
+		}
+		member.UserID = toUserID
+		workspaceMembers[toUserID] = member
+	}
+}
+
+// GetByOriginal returns ShortURL by its original url, original url is unique within domain and workspace.
+func (repo *InMemoryRepository) GetByOriginal(
+	ctx context.Context,
+	domain string,
+	workspaceID string,
+	original string,
+) (entities.ShortURL, bool, error) {
+	lock.RLock()
+	defer lock.RUnlock()
+	result, exist := findByOriginal(repo.Storage, domain, workspaceID, original)
+	return result, exist, nil
+}
+
+// GetAllByUserID returns ShortURLs by user id including inactive ones.
+func (repo *InMemoryRepository) GetAllByUserID(ctx context.Context, userID uuid.UUID) ([]entities.ShortURL, error) {
+	lock.RLock()
+	defer lock.RUnlock()
+	return findAllByUserID(repo.Storage, userID), nil
+}
+
+// SetActive activates or deactivates ShortURL regardless of its owner.
+func (repo *InMemoryRepository) SetActive(ctx context.Context, id string, isActive bool) (entities.ShortURL, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	return updateShortURL(repo.Storage, id, setActive(isActive))
+}
+
+// SetOwner changes owner of ShortURL.
+func (repo *InMemoryRepository) SetOwner(ctx context.Context, id string, userID uuid.UUID) (entities.ShortURL, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	return updateShortURL(repo.Storage, id, func(url *entities.ShortURL) { url.UserID = userID })
+}
+
+// WriteAuditLog appends entry to audit log.
+func (repo *InMemoryRepository) WriteAuditLog(ctx context.Context, entry entities.AuditLogEntry) error {
+	lock.Lock()
+	repo.AuditLog = append(repo.AuditLog, entry)
 	lock.Unlock()
 	return nil
 }
+
+// GetAuditLog returns latest audit log entries, newest first.
+func (repo *InMemoryRepository) GetAuditLog(ctx context.Context, limit int) ([]entities.AuditLogEntry, error) {
+	lock.RLock()
+	defer lock.RUnlock()
+	return latestAuditLogEntries(repo.AuditLog, limit), nil
+}
+
+// GetDeadLetterDeleteJobs returns deletion jobs given up after all retries.
+func (repo *InMemoryRepository) GetDeadLetterDeleteJobs(ctx context.Context) ([]entities.DeleteJob, error) {
+	return deleteJobs.DeadLetters(), nil
+}
+
+// GetExisting returns stored ShortURLs having the same id or original url as any of urls.
+func (repo *InMemoryRepository) GetExisting(ctx context.Context, urls []entities.ShortURL) ([]entities.ShortURL, error) {
+	lock.RLock()
+	defer lock.RUnlock()
+	return findExisting(repo.Storage, urls), nil
+}
+
+// findExisting looks up ShortURLs with the same id or original url in map storage.
+func findExisting(storage map[string]entities.ShortURL, urls []entities.ShortURL) []entities.ShortURL {
+	originals := make(map[string]bool, len(urls))
+	for _, url := range urls {
+		originals[url.Original] = true
+	}
+	result := make([]entities.ShortURL, 0)
+	for _, stored := range storage {
+		if originals[stored.Original] {
+			result = append(result, stored)
+		}
+	}
+	for _, url := range urls {
+		if stored, exist := storage[url.ID]; exist && !originals[stored.Original] {
+			result = append(result, stored)
+		}
+	}
+	return result
+}
+
+// findByOriginal looks up ShortURL by original url within domain and workspace in map storage.
+func findByOriginal(
+	urls map[string]entities.ShortURL,
+	domain string,
+	workspaceID string,
+	original string,
+) (entities.ShortURL, bool) {
+	for _, url := range urls {
+		if url.Original == original && url.Domain == domain && url.WorkspaceID == workspaceID {
+			return url, true
+		}
+	}
+	return entities.ShortURL{}, false
+}
+
+// findAllByUserID filters ShortURLs in map storage by user id.
+func findAllByUserID(urls map[string]entities.ShortURL, userID uuid.UUID) []entities.ShortURL {
+	result := make([]entities.ShortURL, 0, 8)
+	for _, url := range urls {
+		if url.UserID == userID {
+			result = append(result, url)
+		}
+	}
+	return result
+}
+
+// findAll returns all ShortURLs of map storage.
+func findAll(urls map[string]entities.ShortURL) []entities.ShortURL {
+	result := make([]entities.ShortURL, 0, len(urls))
+	for _, url := range urls {
+		result = append(result, url)
+	}
+	return result
+}
+
+// setActive returns change activating or deactivating ShortURL, activated one is no longer deleted.
+func setActive(isActive bool) func(url *entities.ShortURL) {
+	return func(url *entities.ShortURL) {
+		url.IsActive = isActive
+		if isActive {
+			url.DeletedAt = nil
+		}
+	}
+}
+
+// sortByCreation sorts records by creation time and id, the same way database returns them.
+func sortByCreation(records []entities.ShortURL) {
+	sort.Slice(records, func(i, j int) bool {
+		if !records[i].CreatedAt.Equal(records[j].CreatedAt) {
+			return records[i].CreatedAt.Before(records[j].CreatedAt)
+		}
+		return records[i].ID < records[j].ID
+	})
+}
+
+// iterate calls fn for every record until ctx is done or fn fails.
+func iterate[T any](ctx context.Context, records []T, fn func(T) error) error {
+	for _, record := range records {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateShortURL applies change to ShortURL in map storage.
+func updateShortURL(
+	urls map[string]entities.ShortURL,
+	id string,
+	change func(url *entities.ShortURL),
+) (entities.ShortURL, error) {
+	url, exist := urls[id]
+	if !exist {
+		return entities.ShortURL{}, shortenerrors.ErrItemNotFound
+	}
+	change(&url)
+	urls[id] = url
+	return url, nil
+}
+
+// latestAuditLogEntries returns up to limit last entries in reverse order.
+func latestAuditLogEntries(entries []entities.AuditLogEntry, limit int) []entities.AuditLogEntry {
+	if limit <= 0 || limit > len(entries) {
+		limit = len(entries)
+	}
+	result := make([]entities.AuditLogEntry, 0, limit)
+	for i := len(entries) - 1; i >= len(entries)-limit; i-- {
+		result = append(result, entries[i])
+	}
+	return result
+}
+
+// CreateWorkspace creates Workspace with its creator as owner.
+func (repo *InMemoryRepository) CreateWorkspace(
+	ctx context.Context,
+	workspace entities.Workspace,
+) (entities.Workspace, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	if repo.Workspaces == nil {
+		repo.Workspaces = make(map[string]entities.Workspace)
+	}
+	if repo.Members == nil {
+		repo.Members = make(map[string]map[uuid.UUID]entities.WorkspaceMember)
+	}
+	return createWorkspace(repo.Workspaces, repo.Members, workspace), nil
+}
+
+// GetWorkspacesByUserID returns Workspaces user is member of with their role.
+func (repo *InMemoryRepository) GetWorkspacesByUserID(
+	ctx context.Context,
+	userID uuid.UUID,
+) ([]entities.WorkspaceMembership, error) {
+	lock.RLock()
+	defer lock.RUnlock()
+	return findWorkspacesByUserID(repo.Workspaces, repo.Members, userID), nil
+}
+
+// GetWorkspaceMember returns member of Workspace by user id.
+func (repo *InMemoryRepository) GetWorkspaceMember(
+	ctx context.Context,
+	workspaceID string,
+	userID uuid.UUID,
+) (entities.WorkspaceMember, bool, error) {
+	lock.RLock()
+	member, exist := repo.Members[workspaceID][userID]
+	lock.RUnlock()
+	return member, exist, nil
+}
+
+// GetWorkspaceMembers returns members of Workspace in order they were added.
+func (repo *InMemoryRepository) GetWorkspaceMembers(
+	ctx context.Context,
+	workspaceID string,
+) ([]entities.WorkspaceMember, error) {
+	lock.RLock()
+	defer lock.RUnlock()
+	return findWorkspaceMembers(repo.Members, workspaceID), nil
+}
+
+// SetWorkspaceMember adds member to Workspace or changes role of existing one.
+func (repo *InMemoryRepository) SetWorkspaceMember(
+	ctx context.Context,
+	member entities.WorkspaceMember,
+) (entities.WorkspaceMember, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	return setWorkspaceMember(repo.Members, member)
+}
+
+// RemoveWorkspaceMember removes member from Workspace.
+func (repo *InMemoryRepository) RemoveWorkspaceMember(
+	ctx context.Context,
+	workspaceID string,
+	userID uuid.UUID,
+) error {
+	lock.Lock()
+	defer lock.Unlock()
+	return removeWorkspaceMember(repo.Members, workspaceID, userID)
+}
+
+// GetByWorkspaceID returns active ShortURLs of Workspace.
+func (repo *InMemoryRepository) GetByWorkspaceID(
+	ctx context.Context,
+	workspaceID string,
+) ([]entities.ShortURL, error) {
+	lock.RLock()
+	defer lock.RUnlock()
+	return findByWorkspaceID(repo.Storage, workspaceID), nil
+}
+
+// canEdit reports whether user may edit, delete or restore ShortURL, personal one has to be created by the user,
+// one of workspace needs owner or editor role there.
+func canEdit(
+	members map[string]map[uuid.UUID]entities.WorkspaceMember,
+	userID uuid.UUID,
+	url entities.ShortURL,
+) bool {
+	if url.WorkspaceID == "" {
+		return url.UserID == userID
+	}
+	member, exist := members[url.WorkspaceID][userID]
+	return exist && member.CanEdit()
+}
+
+// createWorkspace stores Workspace in map storage with its creator as owner.
+func createWorkspace(
+	workspaces map[string]entities.Workspace,
+	members map[string]map[uuid.UUID]entities.WorkspaceMember,
+	workspace entities.Workspace,
+) entities.Workspace {
+	workspaces[workspace.ID] = workspace
+	members[workspace.ID] = map[uuid.UUID]entities.WorkspaceMember{
+		workspace.CreatedBy: {
+			WorkspaceID: workspace.ID,
+			UserID:      workspace.CreatedBy,
+			Role:        entities.RoleOwner,
+			AddedAt:     workspace.CreatedAt,
+		},
+	}
+	return workspace
+}
+
+// findWorkspacesByUserID looks up Workspaces user is member of in map storage, oldest first.
+func findWorkspacesByUserID(
+	workspaces map[string]entities.Workspace,
+	members map[string]map[uuid.UUID]entities.WorkspaceMember,
+	userID uuid.UUID,
+) []entities.WorkspaceMembership {
+	result := make([]entities.WorkspaceMembership, 0, 4)
+	for id, workspace := range workspaces {
+		if member, exist := members[id][userID]; exist {
+			result = append(result, entities.WorkspaceMembership{Workspace: workspace, Role: member.Role})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].CreatedAt.Before(result[j].CreatedAt)
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// findWorkspaceMembers returns members of Workspace in map storage in order they were added.
+func findWorkspaceMembers(
+	members map[string]map[uuid.UUID]entities.WorkspaceMember,
+	workspaceID string,
+) []entities.WorkspaceMember {
+	result := make([]entities.WorkspaceMember, 0, len(members[workspaceID]))
+	for _, member := range members[workspaceID] {
+		result = append(result, member)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].AddedAt.Equal(result[j].AddedAt) {
+			return result[i].AddedAt.Before(result[j].AddedAt)
+		}
+		return result[i].UserID.String() < result[j].UserID.String()
+	})
+	return result
+}
+
+// setWorkspaceMember adds member to existing Workspace in map storage, existing member keeps time they were added.
+func setWorkspaceMember(
+	members map[string]map[uuid.UUID]entities.WorkspaceMember,
+	member entities.WorkspaceMember,
+) (entities.WorkspaceMember, error) {
+	workspaceMembers, exist := members[member.WorkspaceID]
+	if !exist {
+		return entities.WorkspaceMember{}, shortenerrors.ErrItemNotFound
+	}
+	if existed, found := workspaceMembers[member.UserID]; found {
+		member.AddedAt = existed.AddedAt
+	}
+	workspaceMembers[member.UserID] = member
+	return member, nil
+}
+
+// removeWorkspaceMember removes member of Workspace from map storage.
+func removeWorkspaceMember(
+	members map[string]map[uuid.UUID]entities.WorkspaceMember,
+	workspaceID string,
+	userID uuid.UUID,
+) error {
+	if _, exist := members[workspaceID][userID]; !exist {
+		return shortenerrors.ErrItemNotFound
+	}
+	delete(members[workspaceID], userID)
+	return nil
+}
+
+// findByWorkspaceID filters active ShortURLs of Workspace in map storage.
+func findByWorkspaceID(urls map[string]entities.ShortURL, workspaceID string) []entities.ShortURL {
+	result := make([]entities.ShortURL, 0, 8)
+	for _, url := range urls {
+		if url.WorkspaceID == workspaceID && url.IsActive {
+			result = append(result, url)
+		}
+	}
+	return result
+}
+
+// AddTags tags ShortURLs user may edit, returns ids of them.
+func (repo *InMemoryRepository) AddTags(
+	ctx context.Context,
+	userID uuid.UUID,
+	ids []string,
+	tags []string,
+) ([]string, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	return tagRecords(repo.Storage, repo.Members, userID, ids, func(id string) { repo.tags.add(id, tags) }), nil
+}
+
+// RemoveTags removes tags from ShortURLs user may edit, returns ids of them.
+func (repo *InMemoryRepository) RemoveTags(
+	ctx context.Context,
+	userID uuid.UUID,
+	ids []string,
+	tags []string,
+) ([]string, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	return tagRecords(repo.Storage, repo.Members, userID, ids, func(id string) { repo.tags.remove(id, tags) }), nil
+}
+
+// GetTags returns sorted tags of ShortURLs by their ids.
+func (repo *InMemoryRepository) GetTags(ctx context.Context, ids []string) (map[string][]string, error) {
+	lock.RLock()
+	defer lock.RUnlock()
+	return findTags(&repo.tags, ids), nil
+}
+
+// GetByTags returns active ShortURLs having every one of tags, personal ones of user
+// or ones of Workspace when workspaceID is given.
+func (repo *InMemoryRepository) GetByTags(
+	ctx context.Context,
+	userID uuid.UUID,
+	workspaceID string,
+	tags []string,
+) ([]entities.ShortURL, error) {
+	lock.RLock()
+	defer lock.RUnlock()
+	return findByTags(repo.Storage, &repo.tags, userID, workspaceID, tags), nil
+}
+
+// InsertMissingShortURLs stores ShortURLs having neither stored id nor stored original url in their scope.
+func (repo *InMemoryRepository) InsertMissingShortURLs(ctx context.Context, urls []entities.ShortURL) (int64, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	return insertMissingShortURLs(repo.Storage, urls), nil
+}
+
+// IterateUsers calls fn for every User, stops on first error.
+func (repo *InMemoryRepository) IterateUsers(ctx context.Context, fn func(entities.User) error) error {
+	lock.RLock()
+	users := mapValues(repo.Users, func(a, b entities.User) bool { return a.Username < b.Username })
+	lock.RUnlock()
+	return iterate(ctx, users, fn)
+}
+
+// InsertMissingUsers stores Users having neither stored id nor stored username.
+func (repo *InMemoryRepository) InsertMissingUsers(ctx context.Context, users []entities.User) (int64, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	if repo.Users == nil {
+		repo.Users = make(map[uuid.UUID]entities.User)
+	}
+	return insertMissingUsers(repo.Users, users), nil
+}
+
+// IterateWorkspaces calls fn for every Workspace, stops on first error.
+func (repo *InMemoryRepository) IterateWorkspaces(ctx context.Context, fn func(entities.Workspace) error) error {
+	lock.RLock()
+	workspaces := mapValues(repo.Workspaces, func(a, b entities.Workspace) bool { return a.ID < b.ID })
+	lock.RUnlock()
+	return iterate(ctx, workspaces, fn)
+}
+
+// InsertMissingWorkspaces stores Workspaces with ids not stored yet.
+func (repo *InMemoryRepository) InsertMissingWorkspaces(
+	ctx context.Context,
+	workspaces []entities.Workspace,
+) (int64, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	if repo.Workspaces == nil {
+		repo.Workspaces = make(map[string]entities.Workspace)
+	}
+	return insertMissingWorkspaces(repo.Workspaces, workspaces), nil
+}
+
+// IterateWorkspaceMembers calls fn for every member of every Workspace, stops on first error.
+func (repo *InMemoryRepository) IterateWorkspaceMembers(
+	ctx context.Context,
+	fn func(entities.WorkspaceMember) error,
+) error {
+	lock.RLock()
+	members := workspaceMembers(repo.Members)
+	lock.RUnlock()
+	return iterate(ctx, members, fn)
+}
+
+// InsertMissingWorkspaceMembers stores members of stored Workspaces who are not their members yet.
+func (repo *InMemoryRepository) InsertMissingWorkspaceMembers(
+	ctx context.Context,
+	members []entities.WorkspaceMember,
+) (int64, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	if repo.Members == nil {
+		repo.Members = make(map[string]map[uuid.UUID]entities.WorkspaceMember)
+	}
+	return insertMissingWorkspaceMembers(repo.Members, repo.Workspaces, members), nil
+}
+
+// IterateTags calls fn for every tag of every ShortURL, stops on first error.
+func (repo *InMemoryRepository) IterateTags(ctx context.Context, fn func(entities.ShortURLTag) error) error {
+	lock.RLock()
+	tags := shortURLTags(&repo.tags)
+	lock.RUnlock()
+	return iterate(ctx, tags, fn)
+}
+
+// InsertMissingTags adds tags missing on stored ShortURLs.
+func (repo *InMemoryRepository) InsertMissingTags(ctx context.Context, tags []entities.ShortURLTag) (int64, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	return insertMissingTags(&repo.tags, repo.Storage, tags), nil
+}
+
+// IterateHistory calls fn for every destination change of every ShortURL, stops on first error.
+func (repo *InMemoryRepository) IterateHistory(ctx context.Context, fn func(entities.DestinationChange) error) error {
+	lock.RLock()
+	changes := historyChanges(repo.History)
+	lock.RUnlock()
+	return iterate(ctx, changes, fn)
+}
+
+// InsertMissingHistory adds destination changes of stored ShortURLs missing in their history.
+func (repo *InMemoryRepository) InsertMissingHistory(
+	ctx context.Context,
+	changes []entities.DestinationChange,
+) (int64, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	if repo.History == nil {
+		repo.History = make(map[string][]entities.DestinationChange)
+	}
+	return insertMissingHistory(repo.History, repo.Storage, changes), nil
+}
+
+// IterateClicks calls fn for number of clicks of every ShortURL variant, stops on first error.
+func (repo *InMemoryRepository) IterateClicks(ctx context.Context, fn func(entities.ClickCount) error) error {
+	lock.RLock()
+	counts := clickCounts(repo.Clicks)
+	lock.RUnlock()
+	return iterate(ctx, counts, fn)
+}
+
+// InsertMissingClicks adds clicks of stored ShortURLs until their numbers of clicks reach counts.
+func (repo *InMemoryRepository) InsertMissingClicks(ctx context.Context, counts []entities.ClickCount) (int64, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	if repo.Clicks == nil {
+		repo.Clicks = make(map[string]map[string]int64)
+	}
+	missing := missingClicks(repo.Clicks, repo.Storage, counts)
+	for _, count := range missing {
+		if repo.Clicks[count.ShortURLID] == nil {
+			repo.Clicks[count.ShortURLID] = make(map[string]int64)
+		}
+		repo.Clicks[count.ShortURLID][count.Variant] += count.Clicks
+	}
+	return int64(len(missing)), nil
+}
+
+// IterateAPIKeys calls fn for every APIKey, stops on first error.
+func (repo *InMemoryRepository) IterateAPIKeys(ctx context.Context, fn func(entities.APIKey) error) error {
+	lock.RLock()
+	keys := mapValues(repo.APIKeys, func(a, b entities.APIKey) bool { return a.ID < b.ID })
+	lock.RUnlock()
+	return iterate(ctx, keys, fn)
+}
+
+// InsertMissingAPIKeys stores APIKeys having neither stored id nor stored hash.
+func (repo *InMemoryRepository) InsertMissingAPIKeys(ctx context.Context, keys []entities.APIKey) (int64, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	if repo.APIKeys == nil {
+		repo.APIKeys = make(map[string]entities.APIKey)
+	}
+	return insertMissingAPIKeys(repo.APIKeys, keys), nil
+}
+
+// IterateAuditLog calls fn for every audit log entry, oldest first, stops on first error.
+func (repo *InMemoryRepository) IterateAuditLog(ctx context.Context, fn func(entities.AuditLogEntry) error) error {
+	lock.RLock()
+	entries := make([]entities.AuditLogEntry, len(repo.AuditLog))
+	copy(entries, repo.AuditLog)
+	lock.RUnlock()
+	return iterate(ctx, entries, fn)
+}
+
+// InsertMissingAuditLog appends entries absent in audit log.
+func (repo *InMemoryRepository) InsertMissingAuditLog(
+	ctx context.Context,
+	entries []entities.AuditLogEntry,
+) (int64, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	missing := missingAuditLogEntries(repo.AuditLog, entries)
+	repo.AuditLog = append(repo.AuditLog, missing...)
+	return int64(len(missing)), nil
+}
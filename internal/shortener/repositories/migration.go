@@ -0,0 +1,253 @@
+package repositories
+
+import (
+	"sort"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/google/uuid"
+)
+
+// Helpers of map storages copying their entities to another storage, they have to be used with lock held.
+
+// mapValues returns values of map storage sorted by less, so storage is copied in stable order.
+func mapValues[K comparable, V any](values map[K]V, less func(a, b V) bool) []V {
+	result := make([]V, 0, len(values))
+	for _, value := range values {
+		result = append(result, value)
+	}
+	sort.Slice(result, func(i, j int) bool { return less(result[i], result[j]) })
+	return result
+}
+
+// insertMissingShortURLs stores ShortURLs having neither stored id nor stored original url in their scope.
+func insertMissingShortURLs(storage map[string]entities.ShortURL, urls []entities.ShortURL) int64 {
+	var inserted int64
+	for _, url := range urls {
+		if _, exist := storage[url.ID]; exist {
+			continue
+		}
+		if _, exist := findByOriginal(storage, url.Domain, url.WorkspaceID, url.Original); exist {
+			continue
+		}
+		storage[url.ID] = url
+		inserted++
+	}
+	return inserted
+}
+
+// insertMissingUsers stores Users having neither stored id nor stored username.
+func insertMissingUsers(users map[uuid.UUID]entities.User, missing []entities.User) int64 {
+	var inserted int64
+	for _, user := range missing {
+		if _, exist := users[user.ID]; exist {
+			continue
+		}
+		if _, exist := findUserByUsername(users, user.Username); exist {
+			continue
+		}
+		users[user.ID] = user
+		inserted++
+	}
+	return inserted
+}
+
+// insertMissingWorkspaces stores Workspaces with ids not stored yet.
+func insertMissingWorkspaces(workspaces map[string]entities.Workspace, missing []entities.Workspace) int64 {
+	var inserted int64
+	for _, workspace := range missing {
+		if _, exist := workspaces[workspace.ID]; !exist {
+			workspaces[workspace.ID] = workspace
+			inserted++
+		}
+	}
+	return inserted
+}
+
+// workspaceMembers returns members of every Workspace.
+func workspaceMembers(members map[string]map[uuid.UUID]entities.WorkspaceMember) []entities.WorkspaceMember {
+	result := make([]entities.WorkspaceMember, 0, len(members))
+	for _, workspaceMembers := range members {
+		for _, member := range workspaceMembers {
+			result = append(result, member)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].WorkspaceID != result[j].WorkspaceID {
+			return result[i].WorkspaceID < result[j].WorkspaceID
+		}
+		return result[i].UserID.String() < result[j].UserID.String()
+	})
+	return result
+}
+
+// insertMissingWorkspaceMembers stores members of stored Workspaces who are not their members yet.
+func insertMissingWorkspaceMembers(
+	members map[string]map[uuid.UUID]entities.WorkspaceMember,
+	workspaces map[string]entities.Workspace,
+	missing []entities.WorkspaceMember,
+) int64 {
+	var inserted int64
+	for _, member := range missing {
+		if _, exist := workspaces[member.WorkspaceID]; !exist {
+			continue
+		}
+		if _, exist := members[member.WorkspaceID][member.UserID]; exist {
+			continue
+		}
+		if members[member.WorkspaceID] == nil {
+			members[member.WorkspaceID] = make(map[uuid.UUID]entities.WorkspaceMember)
+		}
+		members[member.WorkspaceID][member.UserID] = member
+		inserted++
+	}
+	return inserted
+}
+
+// shortURLTags returns every tag of every ShortURL.
+func shortURLTags(index *tagIndex) []entities.ShortURLTag {
+	ids := make([]string, 0, len(index.byURL))
+	for id := range index.byURL {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	result := make([]entities.ShortURLTag, 0, len(ids))
+	for _, id := range ids {
+		for _, tag := range index.tagsOf(id) {
+			result = append(result, entities.ShortURLTag{ShortURLID: id, Tag: tag})
+		}
+	}
+	return result
+}
+
+// insertMissingTags adds tags missing on stored ShortURLs.
+func insertMissingTags(index *tagIndex, storage map[string]entities.ShortURL, missing []entities.ShortURLTag) int64 {
+	var inserted int64
+	for _, tag := range missing {
+		if _, exist := storage[tag.ShortURLID]; !exist {
+			continue
+		}
+		if _, exist := index.byURL[tag.ShortURLID][tag.Tag]; exist {
+			continue
+		}
+		index.add(tag.ShortURLID, []string{tag.Tag})
+		inserted++
+	}
+	return inserted
+}
+
+// historyChanges returns destination changes of every ShortURL, oldest first.
+func historyChanges(history map[string][]entities.DestinationChange) []entities.DestinationChange {
+	result := make([]entities.DestinationChange, 0, len(history))
+	for id, changes := range history {
+		for _, change := range changes {
+			change.ShortURLID = id
+			result = append(result, change)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if !result[i].ChangedAt.Equal(result[j].ChangedAt) {
+			return result[i].ChangedAt.Before(result[j].ChangedAt)
+		}
+		return result[i].ShortURLID < result[j].ShortURLID
+	})
+	return result
+}
+
+// insertMissingHistory adds destination changes of stored ShortURLs missing in their history.
+func insertMissingHistory(
+	history map[string][]entities.DestinationChange,
+	storage map[string]entities.ShortURL,
+	missing []entities.DestinationChange,
+) int64 {
+	var inserted int64
+	for _, change := range missing {
+		if _, exist := storage[change.ShortURLID]; !exist || hasChange(history[change.ShortURLID], change) {
+			continue
+		}
+		history[change.ShortURLID] = append(history[change.ShortURLID], change)
+		inserted++
+	}
+	return inserted
+}
+
+// hasChange reports whether changes have the same destination change.
+func hasChange(changes []entities.DestinationChange, change entities.DestinationChange) bool {
+	for _, stored := range changes {
+		if stored.Original == change.Original && stored.ChangedBy == change.ChangedBy &&
+			stored.ChangedAt.Equal(change.ChangedAt) {
+			return true
+		}
+	}
+	return false
+}
+
+// clickCounts returns numbers of clicks by ShortURL id and variant id as list.
+func clickCounts(clicks map[string]map[string]int64) []entities.ClickCount {
+	result := make([]entities.ClickCount, 0, len(clicks))
+	for id, variants := range clicks {
+		for variant, count := range variants {
+			result = append(result, entities.ClickCount{ShortURLID: id, Variant: variant, Clicks: count})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].ShortURLID != result[j].ShortURLID {
+			return result[i].ShortURLID < result[j].ShortURLID
+		}
+		return result[i].Variant < result[j].Variant
+	})
+	return result
+}
+
+// missingClicks returns clicks to be added, so stored numbers of clicks of stored ShortURLs reach counts.
+func missingClicks(
+	clicks map[string]map[string]int64,
+	storage map[string]entities.ShortURL,
+	counts []entities.ClickCount,
+) []entities.ClickCount {
+	result := make([]entities.ClickCount, 0, len(counts))
+	for _, count := range counts {
+		if _, exist := storage[count.ShortURLID]; !exist {
+			continue
+		}
+		if stored := clicks[count.ShortURLID][count.Variant]; stored < count.Clicks {
+			count.Clicks -= stored
+			result = append(result, count)
+		}
+	}
+	return result
+}
+
+// insertMissingAPIKeys stores APIKeys having neither stored id nor stored hash.
+func insertMissingAPIKeys(keys map[string]entities.APIKey, missing []entities.APIKey) int64 {
+	var inserted int64
+	for _, key := range missing {
+		if _, exist := keys[key.ID]; exist {
+			continue
+		}
+		if _, exist, _ := findAPIKeyByHash(keys, key.KeyHash); exist {
+			continue
+		}
+		keys[key.ID] = key
+		inserted++
+	}
+	return inserted
+}
+
+// missingAuditLogEntries returns entries absent in audit log.
+func missingAuditLogEntries(auditLog []entities.AuditLogEntry, entries []entities.AuditLogEntry) []entities.AuditLogEntry {
+	stored := make(map[entities.AuditLogEntry]bool, len(auditLog))
+	for _, entry := range auditLog {
+		entry.CreatedAt = entry.CreatedAt.UTC()
+		stored[entry] = true
+	}
+	result := make([]entities.AuditLogEntry, 0, len(entries))
+	for _, entry := range entries {
+		key := entry
+		key.CreatedAt = key.CreatedAt.UTC()
+		if !stored[key] {
+			stored[key] = true
+			result = append(result, entry)
+		}
+	}
+	return result
+}
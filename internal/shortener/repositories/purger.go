@@ -0,0 +1,28 @@
+package repositories
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// PurgeDeletedPeriodically removes ShortURLs deleted longer than retention ago every interval until ctx is done.
+func PurgeDeletedPeriodically(ctx context.Context, repo IDeletedRepository, retention time.Duration, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := repo.PurgeDeleted(ctx, time.Now().UTC().Add(-retention))
+		if err != nil {
+			log.Printf("Error while purging deleted urls: %v", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d deleted urls", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
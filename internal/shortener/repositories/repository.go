@@ -3,6 +3,7 @@ package repositories
 
 import (
 	"context"
+	"time"
 
 	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
 	"github.com/google/uuid"
@@ -12,7 +13,128 @@ import (
 type IRepository interface {
 	GetByID(ctx context.Context, id string) (entities.ShortURL, bool, error)
 	GetByUserID(ctx context.Context, userID uuid.UUID) ([]entities.ShortURL, error)
+	IterateByUserID(ctx context.Context, userID uuid.UUID, fn func(entities.ShortURL) error) error
 	Create(ctx context.Context, shortURL entities.ShortURL) (entities.ShortURL, error)
 	CreateMultiple(ctx context.Context, urls []entities.ShortURL) ([]entities.ShortURL, error)
-	DeleteRecords(ctx context.Context, userID uuid.UUID, ids []string) error
+	DeleteRecords(ctx context.Context, userID uuid.UUID, ids []string) (entities.DeleteJob, error)
+	DeleteRecordsForUser(ctx context.Context, userID uuid.UUID, ids []string) ([]string, error)
+	GetDeleteJob(ctx context.Context, id string) (entities.DeleteJob, bool, error)
+	Update(ctx context.Context, userID uuid.UUID, id string, original string) (entities.ShortURL, error)
+	GetHistory(ctx context.Context, id string) ([]entities.DestinationChange, error)
+	UseClick(ctx context.Context, id string) (entities.ShortURL, error)
+	SetVariants(
+		ctx context.Context,
+		userID uuid.UUID,
+		id string,
+		variants entities.Variants,
+		sticky bool,
+	) (entities.ShortURL, error)
+	IDeletedRepository
+	IAPIKeyRepository
+	IUserRepository
+	IAdminRepository
+	IBackupRepository
+	IClickRepository
+	IWorkspaceRepository
+	ITagRepository
+}
+
+// IDeletedRepository interface for ShortURLs deleted by their owners.
+type IDeletedRepository interface {
+	GetDeletedByUserID(ctx context.Context, userID uuid.UUID) ([]entities.ShortURL, error)
+	RestoreRecords(ctx context.Context, userID uuid.UUID, ids []string) ([]entities.ShortURL, error)
+	PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int64, error)
+}
+
+// IAPIKeyRepository interface for API keys storage.
+type IAPIKeyRepository interface {
+	CreateAPIKey(ctx context.Context, key entities.APIKey) (entities.APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, hash string) (entities.APIKey, bool, error)
+	GetAPIKeysByUserID(ctx context.Context, userID uuid.UUID) ([]entities.APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID uuid.UUID, id string) error
+}
+
+// IUserRepository interface for registered users storage.
+type IUserRepository interface {
+	CreateUser(ctx context.Context, user entities.User) (entities.User, error)
+	CreateUserWithRecords(ctx context.Context, user entities.User, fromUserID uuid.UUID) (entities.User, int64, error)
+	GetUserByID(ctx context.Context, id uuid.UUID) (entities.User, bool, error)
+	GetUserByUsername(ctx context.Context, username string) (entities.User, bool, error)
+	ReassignRecords(ctx context.Context, fromUserID uuid.UUID, toUserID uuid.UUID) (int64, error)
+}
+
+// IAdminRepository interface for operations available to admins only.
+type IAdminRepository interface {
+	GetByOriginal(ctx context.Context, domain string, workspaceID string, original string) (entities.ShortURL, bool, error)
+	GetAllByUserID(ctx context.Context, userID uuid.UUID) ([]entities.ShortURL, error)
+	SetActive(ctx context.Context, id string, isActive bool) (entities.ShortURL, error)
+	SetOwner(ctx context.Context, id string, userID uuid.UUID) (entities.ShortURL, error)
+	WriteAuditLog(ctx context.Context, entry entities.AuditLogEntry) error
+	GetAuditLog(ctx context.Context, limit int) ([]entities.AuditLogEntry, error)
+	GetDeadLetterDeleteJobs(ctx context.Context) ([]entities.DeleteJob, error)
+	GetExisting(ctx context.Context, urls []entities.ShortURL) ([]entities.ShortURL, error)
+}
+
+// IClickRepository interface for click analytics of ShortURLs.
+type IClickRepository interface {
+	RecordClick(ctx context.Context, click entities.Click) error
+	GetVariantClicks(ctx context.Context, id string) (map[string]int64, error)
+}
+
+// IWorkspaceRepository interface for workspaces and their members.
+type IWorkspaceRepository interface {
+	CreateWorkspace(ctx context.Context, workspace entities.Workspace) (entities.Workspace, error)
+	GetWorkspacesByUserID(ctx context.Context, userID uuid.UUID) ([]entities.WorkspaceMembership, error)
+	GetWorkspaceMember(ctx context.Context, workspaceID string, userID uuid.UUID) (entities.WorkspaceMember, bool, error)
+	GetWorkspaceMembers(ctx context.Context, workspaceID string) ([]entities.WorkspaceMember, error)
+	SetWorkspaceMember(ctx context.Context, member entities.WorkspaceMember) (entities.WorkspaceMember, error)
+	RemoveWorkspaceMember(ctx context.Context, workspaceID string, userID uuid.UUID) error
+	GetByWorkspaceID(ctx context.Context, workspaceID string) ([]entities.ShortURL, error)
+}
+
+// ITagRepository interface for tags grouping ShortURLs, ShortURL may have many tags and tag may group many ShortURLs.
+type ITagRepository interface {
+	AddTags(ctx context.Context, userID uuid.UUID, ids []string, tags []string) ([]string, error)
+	RemoveTags(ctx context.Context, userID uuid.UUID, ids []string, tags []string) ([]string, error)
+	GetTags(ctx context.Context, ids []string) (map[string][]string, error)
+	GetByTags(ctx context.Context, userID uuid.UUID, workspaceID string, tags []string) ([]entities.ShortURL, error)
+}
+
+// IBufferedRepository interface for storages saving the whole storage at once, bulk writes to them
+// are kept in memory and saved by single write instead of one per chunk.
+type IBufferedRepository interface {
+	CreateBuffered(ctx context.Context, urls []entities.ShortURL) ([]entities.ShortURL, error)
+	Flush(ctx context.Context) error
+}
+
+// IBackupRepository interface for dumping the whole storage and loading it back.
+type IBackupRepository interface {
+	IterateAll(ctx context.Context, fn func(entities.ShortURL) error) error
+	LoadBackup(ctx context.Context, urls []entities.ShortURL) error
+}
+
+// IMigrationRepository interface for copying every entity of storage to another one.
+//
+// Iterate methods call fn for every stored entity and stop on first error. Insert methods store entities
+// missing in storage, keep the stored ones untouched and return number of inserted ones, so they may be
+// repeated safely. Clicks are copied as counts, missing clicks are added until stored count reaches given one.
+type IMigrationRepository interface {
+	IterateAll(ctx context.Context, fn func(entities.ShortURL) error) error
+	InsertMissingShortURLs(ctx context.Context, urls []entities.ShortURL) (int64, error)
+	IterateUsers(ctx context.Context, fn func(entities.User) error) error
+	InsertMissingUsers(ctx context.Context, users []entities.User) (int64, error)
+	IterateWorkspaces(ctx context.Context, fn func(entities.Workspace) error) error
+	InsertMissingWorkspaces(ctx context.Context, workspaces []entities.Workspace) (int64, error)
+	IterateWorkspaceMembers(ctx context.Context, fn func(entities.WorkspaceMember) error) error
+	InsertMissingWorkspaceMembers(ctx context.Context, members []entities.WorkspaceMember) (int64, error)
+	IterateTags(ctx context.Context, fn func(entities.ShortURLTag) error) error
+	InsertMissingTags(ctx context.Context, tags []entities.ShortURLTag) (int64, error)
+	IterateHistory(ctx context.Context, fn func(entities.DestinationChange) error) error
+	InsertMissingHistory(ctx context.Context, changes []entities.DestinationChange) (int64, error)
+	IterateClicks(ctx context.Context, fn func(entities.ClickCount) error) error
+	InsertMissingClicks(ctx context.Context, counts []entities.ClickCount) (int64, error)
+	IterateAPIKeys(ctx context.Context, fn func(entities.APIKey) error) error
+	InsertMissingAPIKeys(ctx context.Context, keys []entities.APIKey) (int64, error)
+	IterateAuditLog(ctx context.Context, fn func(entities.AuditLogEntry) error) error
+	InsertMissingAuditLog(ctx context.Context, entries []entities.AuditLogEntry) (int64, error)
 }
@@ -0,0 +1,162 @@
+package repositories
+
+import (
+	"sort"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/google/uuid"
+)
+
+// tagIndex tags of ShortURLs in map storage, ShortURLs are indexed by tag as well,
+// so filtering by tag does not scan the whole storage. It has to be used with lock held.
+type tagIndex struct {
+	byURL map[string]map[string]struct{}
+	byTag map[string]map[string]struct{}
+}
+
+// add tags ShortURL.
+func (index *tagIndex) add(id string, tags []string) {
+	if index.byURL == nil {
+		index.byURL = make(map[string]map[string]struct{})
+		index.byTag = make(map[string]map[string]struct{})
+	}
+	for _, tag := range tags {
+		addToSet(index.byURL, id, tag)
+		addToSet(index.byTag, tag, id)
+	}
+}
+
+// remove removes tags from ShortURL.
+func (index *tagIndex) remove(id string, tags []string) {
+	for _, tag := range tags {
+		removeFromSet(index.byURL, id, tag)
+		removeFromSet(index.byTag, tag, id)
+	}
+}
+
+// drop removes all tags of ShortURL removed from storage.
+func (index *tagIndex) drop(id string) {
+	index.remove(id, index.tagsOf(id))
+}
+
+// tagsOf returns sorted tags of ShortURL.
+func (index *tagIndex) tagsOf(id string) []string {
+	tags := make([]string, 0, len(index.byURL[id]))
+	for tag := range index.byURL[id] {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// tagged returns ids of ShortURLs having every one of tags, starting from the rarest tag.
+func (index *tagIndex) tagged(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	rarest := tags[0]
+	for _, tag := range tags[1:] {
+		if len(index.byTag[tag]) < len(index.byTag[rarest]) {
+			rarest = tag
+		}
+	}
+	ids := make([]string, 0, len(index.byTag[rarest]))
+	for id := range index.byTag[rarest] {
+		hasAll := true
+		for _, tag := range tags {
+			if _, exist := index.byURL[id][tag]; !exist {
+				hasAll = false
+				break
+			}
+		}
+		if hasAll {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// snapshot returns tags by ShortURL id to be saved to file.
+func (index *tagIndex) snapshot() map[string][]string {
+	result := make(map[string][]string, len(index.byURL))
+	for id := range index.byURL {
+		result[id] = index.tagsOf(id)
+	}
+	return result
+}
+
+// load replaces content of index with tags by ShortURL id read from file.
+func (index *tagIndex) load(tags map[string][]string) {
+	index.byURL, index.byTag = nil, nil
+	for id, urlTags := range tags {
+		index.add(id, urlTags)
+	}
+}
+
+// addToSet adds value to set stored by key.
+func addToSet(sets map[string]map[string]struct{}, key string, value string) {
+	if sets[key] == nil {
+		sets[key] = make(map[string]struct{})
+	}
+	sets[key][value] = struct{}{}
+}
+
+// removeFromSet removes value from set stored by key, empty set is removed as well.
+func removeFromSet(sets map[string]map[string]struct{}, key string, value string) {
+	delete(sets[key], value)
+	if len(sets[key]) == 0 {
+		delete(sets, key)
+	}
+}
+
+// tagRecords applies change to tags of ShortURLs user may edit in map storage, returns ids of them.
+func tagRecords(
+	urls map[string]entities.ShortURL,
+	members map[string]map[uuid.UUID]entities.WorkspaceMember,
+	userID uuid.UUID,
+	ids []string,
+	change func(id string),
+) []string {
+	changed := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if url, exist := urls[id]; exist && canEdit(members, userID, url) && !url.IsDeleted() {
+			change(id)
+			changed = append(changed, id)
+		}
+	}
+	return changed
+}
+
+// findTags returns tags of ShortURLs by their ids, ones without tags are left out.
+func findTags(index *tagIndex, ids []string) map[string][]string {
+	result := make(map[string][]string, len(ids))
+	for _, id := range ids {
+		if tags := index.tagsOf(id); len(tags) > 0 {
+			result[id] = tags
+		}
+	}
+	return result
+}
+
+// findByTags filters active ShortURLs having every one of tags in map storage, personal ones of user
+// or ones of workspace when workspaceID is given.
+func findByTags(
+	urls map[string]entities.ShortURL,
+	index *tagIndex,
+	userID uuid.UUID,
+	workspaceID string,
+	tags []string,
+) []entities.ShortURL {
+	result := make([]entities.ShortURL, 0, 8)
+	for _, id := range index.tagged(tags) {
+		url, exist := urls[id]
+		if !exist || !url.IsActive || url.WorkspaceID != workspaceID {
+			continue
+		}
+		if workspaceID == "" && url.UserID != userID {
+			continue
+		}
+		result = append(result, url)
+	}
+	return result
+}
@@ -8,3 +8,9 @@ var ErrItemAlreadyExists = errors.New("item already exists in the database")
 
 // ErrItemNotFound custom error for 404.
 var ErrItemNotFound = errors.New("no url found by id")
+
+// ErrClicksExhausted custom error for ShortURL which has been followed max clicks times.
+var ErrClicksExhausted = errors.New("link has no clicks left")
+
+// ErrInvalidLinkOptions custom error for options of ShortURL given by user which can't be accepted.
+var ErrInvalidLinkOptions = errors.New("invalid link options")
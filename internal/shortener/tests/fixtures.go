@@ -2,9 +2,14 @@
 package tests
 
 import (
+	"database/sql/driver"
 	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
 
 	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/repositories"
 	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/utils"
 	"github.com/google/uuid"
 	"github.com/lithammer/shortuuid"
@@ -38,3 +43,99 @@ var ShortURLFixtureInactive = entities.ShortURL{
 
 // JSONStorageWithOneElement fixture storage.
 var JSONStorageWithOneElement, _ = json.Marshal([]entities.ShortURLResponseDto{ShortURLFixture.ToResponseDto()})
+
+// ShortURLColumns columns of short_urls table in order they are selected by DatabaseRepository.
+var ShortURLColumns = []string{
+	"id",
+	"short_url",
+	"original_url",
+	"user_id",
+	"correlation_id",
+	"is_active",
+	"deleted_at",
+	"created_at",
+	"title",
+	"interstitial",
+	"redirect_type",
+	"passthrough",
+	"utm_source",
+	"utm_medium",
+	"utm_campaign",
+	"utm_term",
+	"utm_content",
+	"password_hash",
+	"max_clicks",
+	"clicks_left",
+	"rules",
+	"variants",
+	"sticky_variant",
+	"domain",
+	"workspace_id",
+}
+
+// ShortURLSelectQuery beginning of the query selecting ShortURLs.
+var ShortURLSelectQuery = "SELECT " + strings.Join(ShortURLColumns, ", ") + " FROM short_urls"
+
+// ShortURLRow returns database row values of ShortURL in order of ShortURLColumns.
+func ShortURLRow(item entities.ShortURL) []driver.Value {
+	var deletedAt driver.Value
+	if item.DeletedAt != nil {
+		deletedAt = *item.DeletedAt
+	}
+	var utm entities.UTM
+	if item.UTM != nil {
+		utm = *item.UTM
+	}
+	rules, _ := item.Rules.Value()
+	variants, _ := item.Variants.Value()
+	return []driver.Value{
+		item.ID,
+		item.Short,
+		item.Original,
+		item.UserID.String(),
+		item.CorrelationID,
+		item.IsActive,
+		deletedAt,
+		item.CreatedAt,
+		item.Title,
+		item.Interstitial,
+		item.RedirectType,
+		item.Passthrough,
+		utm.Source,
+		utm.Medium,
+		utm.Campaign,
+		utm.Term,
+		utm.Content,
+		item.PasswordHash,
+		item.MaxClicks,
+		item.ClicksLeft,
+		rules,
+		variants,
+		item.StickyVariant,
+		item.Domain,
+		item.WorkspaceID,
+	}
+}
+
+// MapRepositories returns constructors of in memory and file repositories by storage name,
+// every constructed repository holds urls, file one is stored in temporary directory of the test.
+func MapRepositories(t testing.TB, urls ...entities.ShortURL) map[string]func() repositories.IRepository {
+	storage := func() map[string]entities.ShortURL {
+		result := make(map[string]entities.ShortURL, len(urls))
+		for _, url := range urls {
+			result[url.ID] = url
+		}
+		return result
+	}
+	return map[string]func() repositories.IRepository{
+		"memory": func() repositories.IRepository {
+			return &repositories.InMemoryRepository{Storage: storage()}
+		},
+		"file": func() repositories.IRepository {
+			return &repositories.FileRepository{
+				Storage:  storage(),
+				FilePath: filepath.Join(t.TempDir(), "storage.json"),
+			}
+		},
+	}
+}
@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/config"
+)
+
+// shortDomain configured short domain, host is stored with ShortURL and base is what its short urls start with.
+type shortDomain struct {
+	host string
+	base string
+}
+
+// shortDomains returns valid domains of SHORT_DOMAINS setting, each of them is given as base url.
+func shortDomains() []shortDomain {
+	domains := make([]shortDomain, 0, len(config.Settings.Domains))
+	for _, raw := range config.Settings.Domains {
+		if domain, ok := parseShortDomain(raw); ok {
+			domains = append(domains, domain)
+		}
+	}
+	return domains
+}
+
+// parseShortDomain parses base url of short domain, false if it is not absolute http(s) url without path.
+func parseShortDomain(raw string) (shortDomain, bool) {
+	base := strings.TrimRight(strings.TrimSpace(raw), "/")
+	parsed, err := url.Parse(base)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Path != "" {
+		return shortDomain{}, false
+	}
+	return shortDomain{host: strings.ToLower(parsed.Host), base: base}, true
+}
+
+// ValidateDomains checks SHORT_DOMAINS setting, links can't be created on domains which fail the check.
+func ValidateDomains() error {
+	for _, raw := range config.Settings.Domains {
+		if _, ok := parseShortDomain(raw); !ok {
+			return fmt.Errorf("SHORT_DOMAINS entry %q has to be http(s) url without path", raw)
+		}
+	}
+	return nil
+}
+
+// defaultDomainHost returns host of BASE_URL.
+func defaultDomainHost() string {
+	parsed, err := url.Parse(config.Settings.BaseURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Host)
+}
+
+// Domains returns hosts short urls may be created on, the first one is host of BASE_URL.
+func Domains() []string {
+	hosts := []string{defaultDomainHost()}
+	for _, domain := range shortDomains() {
+		hosts = append(hosts, domain.host)
+	}
+	return hosts
+}
+
+// NormalizeDomain returns domain chosen by user the way it is stored with ShortURL, empty string stands for
+// host of BASE_URL, false if domain is not configured.
+func NormalizeDomain(domain string) (string, bool) {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	if domain == "" || domain == defaultDomainHost() {
+		return "", true
+	}
+	for _, configured := range shortDomains() {
+		if configured.host == domain {
+			return configured.host, true
+		}
+	}
+	return "", false
+}
+
+// RequestDomain returns short domain request to host is made on, empty string for any host not configured
+// in SHORT_DOMAINS.
+func RequestDomain(host string) string {
+	host = strings.ToLower(host)
+	for _, configured := range shortDomains() {
+		if configured.host == host {
+			return configured.host
+		}
+	}
+	return ""
+}
+
+// domainBaseURL returns base url of short urls on domain, BASE_URL for empty domain.
+func domainBaseURL(domain string) string {
+	if domain == "" {
+		return config.Settings.BaseURL
+	}
+	for _, configured := range shortDomains() {
+		if configured.host == domain {
+			return configured.base
+		}
+	}
+	// Domain has been removed from settings, its links are still reachable if it points to the server.
+	scheme := "https"
+	if parsed, err := url.Parse(config.Settings.BaseURL); err == nil && parsed.Scheme != "" {
+		scheme = parsed.Scheme
+	}
+	return scheme + "://" + domain
+}
@@ -1,8 +1,37 @@
 package utils
 
-import "github.com/RomanAVolodin/go-url-shortener/internal/shortener/config"
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/config"
+)
+
+// APIKeyPrefix prefix of every generated API key.
+const APIKeyPrefix = "sk_"
 
 // GenerateResultURL generates full URL.
 func GenerateResultURL(id string) string {
 	return config.Settings.BaseURL + "/" + id
 }
+
+// GenerateDomainResultURL generates full URL of slug on short domain, empty domain is the one of BASE_URL.
+func GenerateDomainResultURL(domain, slug string) string {
+	return domainBaseURL(domain) + "/" + slug
+}
+
+// GenerateAPIKey generates new random API key.
+func GenerateAPIKey() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return APIKeyPrefix + hex.EncodeToString(buf), nil
+}
+
+// HashAPIKey returns hash of API key to be stored instead of raw value.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,165 @@
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// databaseMigrations statements migrating database schema, position of statement is its version.
+// Statements are applied once in order they are listed, so new ones are only appended, never changed.
+// The first ones were applied on every start before versions were tracked, so they are idempotent.
+var databaseMigrations = []string{
+	`CREATE TABLE IF NOT EXISTS short_urls (
+		id varchar(45) NOT NULL PRIMARY KEY, 
+		short_url varchar(150) NOT NULL, 
+		original_url varchar(255) NOT NULL, 
+		correlation_id varchar(255), 
+		is_active boolean default true, 
+		user_id uuid NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS api_keys (
+		id varchar(45) NOT NULL PRIMARY KEY,
+		name varchar(255) NOT NULL,
+		key_hash varchar(64) NOT NULL UNIQUE,
+		user_id uuid NOT NULL,
+		scopes varchar(64) NOT NULL,
+		created_at timestamptz NOT NULL default now(),
+		is_active boolean default true
+	)`,
+	`CREATE INDEX IF NOT EXISTS api_keys_user_id_idx ON api_keys (user_id)`,
+	`CREATE TABLE IF NOT EXISTS users (
+		id uuid NOT NULL PRIMARY KEY,
+		username varchar(150) NOT NULL UNIQUE,
+		password_hash varchar(100) NOT NULL,
+		created_at timestamptz NOT NULL default now()
+	)`,
+	`CREATE INDEX IF NOT EXISTS short_urls_user_id_idx ON short_urls (user_id)`,
+	`CREATE TABLE IF NOT EXISTS audit_log (
+		id bigserial NOT NULL PRIMARY KEY,
+		actor varchar(100) NOT NULL,
+		action varchar(50) NOT NULL,
+		target varchar(255) NOT NULL,
+		details text NOT NULL default '',
+		created_at timestamptz NOT NULL default now()
+	)`,
+	`ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS deleted_at timestamptz NULL`,
+	`CREATE INDEX IF NOT EXISTS short_urls_deleted_at_idx ON short_urls (deleted_at) WHERE deleted_at IS NOT NULL`,
+	`CREATE TABLE IF NOT EXISTS short_url_history (
+		id bigserial NOT NULL PRIMARY KEY,
+		short_url_id varchar(45) NOT NULL REFERENCES short_urls (id) ON DELETE CASCADE,
+		original_url varchar(255) NOT NULL,
+		changed_by uuid NOT NULL,
+		changed_at timestamptz NOT NULL default now()
+	)`,
+	`CREATE INDEX IF NOT EXISTS short_url_history_short_url_id_idx ON short_url_history (short_url_id)`,
+	`ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS created_at timestamptz NOT NULL default now()`,
+	`ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS title varchar(255) NOT NULL default ''`,
+	`ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS interstitial boolean NOT NULL default false`,
+	`ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS redirect_type smallint NOT NULL default 0`,
+	`ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS passthrough varchar(10) NOT NULL default ''`,
+	`ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS utm_source varchar(255) NOT NULL default ''`,
+	`ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS utm_medium varchar(255) NOT NULL default ''`,
+	`ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS utm_campaign varchar(255) NOT NULL default ''`,
+	`ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS utm_term varchar(255) NOT NULL default ''`,
+	`ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS utm_content varchar(255) NOT NULL default ''`,
+	`CREATE INDEX IF NOT EXISTS short_urls_utm_campaign_idx ON short_urls (user_id, utm_campaign) WHERE utm_campaign <> ''`,
+	`ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS password_hash varchar(60) NOT NULL default ''`,
+	`ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS max_clicks integer NOT NULL default 0`,
+	`ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS clicks_left integer NOT NULL default 0`,
+	`ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS rules jsonb NOT NULL default '[]'`,
+	`ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS variants jsonb NOT NULL default '[]'`,
+	`ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS sticky_variant boolean NOT NULL default false`,
+	`CREATE TABLE IF NOT EXISTS short_url_clicks (
+		id bigserial NOT NULL PRIMARY KEY,
+		short_url_id varchar(45) NOT NULL REFERENCES short_urls (id) ON DELETE CASCADE,
+		variant varchar(32) NOT NULL default '',
+		clicked_at timestamptz NOT NULL default now()
+	)`,
+	`CREATE INDEX IF NOT EXISTS short_url_clicks_short_url_id_idx ON short_url_clicks (short_url_id, variant)`,
+	// Links on custom domains are keyed by slug with domain appended, see entities.ShortURLKey.
+	`ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS domain varchar(255) NOT NULL default ''`,
+	`ALTER TABLE short_urls ALTER COLUMN id TYPE varchar(300)`,
+	`ALTER TABLE short_url_history ALTER COLUMN short_url_id TYPE varchar(300)`,
+	`ALTER TABLE short_url_clicks ALTER COLUMN short_url_id TYPE varchar(300)`,
+	`CREATE TABLE IF NOT EXISTS workspaces (
+		id varchar(45) NOT NULL PRIMARY KEY,
+		name varchar(255) NOT NULL,
+		created_by uuid NOT NULL,
+		created_at timestamptz NOT NULL default now()
+	)`,
+	`CREATE TABLE IF NOT EXISTS workspace_members (
+		workspace_id varchar(45) NOT NULL REFERENCES workspaces (id) ON DELETE CASCADE,
+		user_id uuid NOT NULL,
+		role varchar(10) NOT NULL,
+		added_at timestamptz NOT NULL default now(),
+		PRIMARY KEY (workspace_id, user_id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS workspace_members_user_id_idx ON workspace_members (user_id)`,
+	`ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS workspace_id varchar(45) NOT NULL default ''`,
+	`CREATE INDEX IF NOT EXISTS short_urls_workspace_id_idx ON short_urls (workspace_id) WHERE workspace_id <> ''`,
+	// Original url is unique within domain and workspace, the same url may be shortened on every domain and workspace.
+	`ALTER TABLE short_urls DROP CONSTRAINT IF EXISTS short_urls_original_url_key`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS short_urls_original_url_scope_idx ON short_urls (domain, workspace_id, original_url)`,
+	`CREATE TABLE IF NOT EXISTS tags (
+		id bigserial PRIMARY KEY,
+		name varchar(64) NOT NULL UNIQUE
+	)`,
+	`CREATE TABLE IF NOT EXISTS short_url_tags (
+		short_url_id varchar(300) NOT NULL REFERENCES short_urls (id) ON DELETE CASCADE,
+		tag_id bigint NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+		PRIMARY KEY (short_url_id, tag_id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS short_url_tags_tag_id_idx ON short_url_tags (tag_id)`,
+	`CREATE TABLE IF NOT EXISTS delete_jobs (
+		id varchar(45) PRIMARY KEY,
+		user_id uuid NOT NULL,
+		ids jsonb NOT NULL,
+		state varchar(20) NOT NULL,
+		results jsonb NOT NULL default '{}',
+		attempts integer NOT NULL default 0,
+		last_error text NOT NULL default '',
+		created_at timestamptz NOT NULL,
+		updated_at timestamptz NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS delete_jobs_finished_idx ON delete_jobs (updated_at)
+		WHERE state IN ('applied', 'partially_applied')`,
+	`CREATE INDEX IF NOT EXISTS delete_jobs_failed_idx ON delete_jobs (updated_at) WHERE state = 'failed'`,
+}
+
+// migrationsLockID key of advisory lock taken while migrating, so instances started at once
+// do not apply the same migrations.
+const migrationsLockID = 7241863
+
+// migrateDatabase applies migrations not applied yet in single transaction, versions of applied
+// migrations are kept in schema_migrations table.
+func migrateDatabase(ctx context.Context, db *sql.DB) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("database migration: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1);", migrationsLockID); err != nil {
+		return fmt.Errorf("database migration lock: %w", err)
+	}
+	if _, err = tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version integer NOT NULL PRIMARY KEY,
+		applied_at timestamptz NOT NULL default now()
+	)`); err != nil {
+		return fmt.Errorf("database migration versions: %w", err)
+	}
+	var applied int
+	if err = tx.QueryRowContext(ctx, "SELECT coalesce(max(version), 0) FROM schema_migrations;").Scan(&applied); err != nil {
+		return fmt.Errorf("database migration versions: %w", err)
+	}
+	for version := applied + 1; version <= len(databaseMigrations); version++ {
+		if _, err = tx.ExecContext(ctx, databaseMigrations[version-1]); err != nil {
+			return fmt.Errorf("database migration %d: %w", version, err)
+		}
+		if _, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version) values ($1);", version); err != nil {
+			return fmt.Errorf("database migration %d: %w", version, err)
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateDatabase(t *testing.T) {
+	failure := errors.New("permission denied for table short_urls")
+	tests := []struct {
+		name    string
+		applied int
+		failAt  int
+	}{
+		{
+			name:    "Empty database should be migrated to the latest version",
+			applied: 0,
+		},
+		{
+			name:    "Only migrations appended since last start should be applied",
+			applied: len(databaseMigrations) - 2,
+		},
+		{
+			name:    "Migrated database should be left as it is",
+			applied: len(databaseMigrations),
+		},
+		{
+			name:    "Failed migration should be reported with its error",
+			applied: len(databaseMigrations) - 2,
+			failAt:  len(databaseMigrations) - 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+			mock.ExpectBegin()
+			mock.ExpectExec("SELECT pg_advisory_xact_lock").
+				WithArgs(migrationsLockID).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectQuery("SELECT coalesce\\(max\\(version\\), 0\\) FROM schema_migrations").
+				WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(tt.applied))
+			for version := tt.applied + 1; version <= len(databaseMigrations); version++ {
+				statement := mock.ExpectExec(regexp.QuoteMeta(databaseMigrations[version-1]))
+				if version == tt.failAt {
+					statement.WillReturnError(failure)
+					mock.ExpectRollback()
+					break
+				}
+				statement.WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("INSERT INTO schema_migrations").
+					WithArgs(version).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+			if tt.failAt == 0 {
+				mock.ExpectCommit()
+			}
+
+			err = migrateDatabase(context.Background(), db)
+			if tt.failAt != 0 {
+				assert.ErrorIs(t, err, failure)
+				assert.Contains(t, err.Error(), fmt.Sprintf("database migration %d", tt.failAt))
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+)
+
+// ErrUnsafePath returned when path passed through to destination tries to leave it.
+var ErrUnsafePath = errors.New("path segments . and .. are not allowed")
+
+// PassThrough appends decoded path segments and adds query params of short url to destination,
+// params present in destination are merged according to policy.
+func PassThrough(destination string, segments []string, query url.Values, policy string) (string, error) {
+	result, err := url.Parse(destination)
+	if err != nil {
+		return "", err
+	}
+
+	escaped := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		switch segment {
+		case "":
+			continue
+		case ".", "..":
+			return "", ErrUnsafePath
+		}
+		escaped = append(escaped, url.PathEscape(segment))
+	}
+	if len(escaped) > 0 {
+		rawPath := strings.TrimSuffix(result.EscapedPath(), "/") + "/" + strings.Join(escaped, "/")
+		if result.Path, err = url.PathUnescape(rawPath); err != nil {
+			return "", err
+		}
+		result.RawPath = rawPath
+	}
+
+	if len(query) > 0 {
+		merged := result.Query()
+		for key, values := range query {
+			_, exist := merged[key]
+			switch {
+			case policy == entities.PassthroughOverride || !exist:
+				merged[key] = values
+			case policy == entities.PassthroughAppend:
+				merged[key] = append(merged[key], values...)
+			}
+		}
+		result.RawQuery = merged.Encode()
+	}
+	return result.String(), nil
+}
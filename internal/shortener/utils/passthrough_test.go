@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPassThrough(t *testing.T) {
+	tests := []struct {
+		name        string
+		destination string
+		segments    []string
+		query       string
+		policy      string
+		want        string
+		err         error
+	}{
+		{
+			name:        "nothing to pass",
+			destination: "https://ya.ru/a?b=1&a=2#top",
+			policy:      entities.PassthroughKeep,
+			want:        "https://ya.ru/a?b=1&a=2#top",
+		},
+		{
+			name:        "path segments",
+			destination: "https://shop.ru/catalog/",
+			segments:    []string{"product", "", "42"},
+			policy:      entities.PassthroughKeep,
+			want:        "https://shop.ru/catalog/product/42",
+		},
+		{
+			name:        "segments are escaped",
+			destination: "https://shop.ru/a%2Fb",
+			segments:    []string{"x y", "c/d", "?q"},
+			policy:      entities.PassthroughKeep,
+			want:        "https://shop.ru/a%2Fb/x%20y/c%2Fd/%3Fq",
+		},
+		{
+			name:        "segment leaving destination",
+			destination: "https://shop.ru/catalog",
+			segments:    []string{"..", "admin"},
+			err:         ErrUnsafePath,
+		},
+		{
+			name:        "keep destination params",
+			destination: "https://ya.ru/?utm_source=site#top",
+			query:       "utm_source=mail&utm_medium=email",
+			policy:      entities.PassthroughKeep,
+			want:        "https://ya.ru/?utm_medium=email&utm_source=site#top",
+		},
+		{
+			name:        "override destination params",
+			destination: "https://ya.ru/?utm_source=site",
+			query:       "utm_source=mail&utm_medium=email",
+			policy:      entities.PassthroughOverride,
+			want:        "https://ya.ru/?utm_medium=email&utm_source=mail",
+		},
+		{
+			name:        "append to destination params",
+			destination: "https://ya.ru/?tag=a",
+			query:       "tag=b&tag=c",
+			policy:      entities.PassthroughAppend,
+			want:        "https://ya.ru/?tag=a&tag=b&tag=c",
+		},
+		{
+			name:        "path and query",
+			destination: "https://ya.ru/docs",
+			segments:    []string{"intro"},
+			query:       "lang=en",
+			policy:      entities.PassthroughKeep,
+			want:        "https://ya.ru/docs/intro?lang=en",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			require.NoError(t, err)
+			got, err := PassThrough(tt.destination, tt.segments, query, tt.policy)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// QR code image formats.
+const (
+	QRCodeFormatPNG = "png"
+	QRCodeFormatSVG = "svg"
+)
+
+// QR code image size limits in pixels.
+const (
+	QRCodeDefaultSize = 256
+	QRCodeMinSize     = 64
+	QRCodeMaxSize     = 2048
+)
+
+// ErrInvalidQRCodeOptions returned for unsupported format, size or error correction level of QR code.
+var ErrInvalidQRCodeOptions = errors.New("invalid QR code options")
+
+// qrCodeLevels error correction levels by their names.
+var qrCodeLevels = map[string]qrcode.RecoveryLevel{
+	"L": qrcode.Low,
+	"M": qrcode.Medium,
+	"Q": qrcode.High,
+	"H": qrcode.Highest,
+}
+
+// QRCodeOptions options of rendered QR code.
+type QRCodeOptions struct {
+	Format string
+	Size   int
+	ECC    string
+}
+
+// Normalize sets defaults for empty options and checks them.
+func (options *QRCodeOptions) Normalize() error {
+	options.Format = strings.ToLower(options.Format)
+	if options.Format == "" {
+		options.Format = QRCodeFormatPNG
+	}
+	options.ECC = strings.ToUpper(options.ECC)
+	if options.ECC == "" {
+		options.ECC = "M"
+	}
+	if options.Size == 0 {
+		options.Size = QRCodeDefaultSize
+	}
+	if options.Format != QRCodeFormatPNG && options.Format != QRCodeFormatSVG {
+		return fmt.Errorf("%w: format has to be png or svg", ErrInvalidQRCodeOptions)
+	}
+	if options.Size < QRCodeMinSize || options.Size > QRCodeMaxSize {
+		return fmt.Errorf("%w: size has to be from %d to %d", ErrInvalidQRCodeOptions, QRCodeMinSize, QRCodeMaxSize)
+	}
+	if _, ok := qrCodeLevels[options.ECC]; !ok {
+		return fmt.Errorf("%w: ecc has to be one of L, M, Q, H", ErrInvalidQRCodeOptions)
+	}
+	return nil
+}
+
+// GenerateQRCode renders QR code encoding content as PNG or SVG image, options have to be normalized.
+func GenerateQRCode(content string, options QRCodeOptions) ([]byte, error) {
+	code, err := qrcode.New(content, qrCodeLevels[options.ECC])
+	if err != nil {
+		return nil, err
+	}
+	if options.Format == QRCodeFormatSVG {
+		return renderQRCodeSVG(code.Bitmap(), options.Size), nil
+	}
+	return code.PNG(options.Size)
+}
+
+// renderQRCodeSVG draws every dark module of bitmap as a unit square of single path.
+func renderQRCodeSVG(bitmap [][]bool, size int) []byte {
+	var svg bytes.Buffer
+	fmt.Fprintf(
+		&svg,
+		`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		size, size, len(bitmap), len(bitmap),
+	)
+	fmt.Fprintf(&svg, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, len(bitmap), len(bitmap))
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&svg, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+	svg.WriteString(`"/></svg>`)
+	return svg.Bytes()
+}
@@ -14,50 +14,47 @@ import (
 )
 
 // SetRepository is the main method to set type of database to use in application.
+//
+// Writes are duplicated to secondary storage when it is set, so data can be migrated between storages.
 func SetRepository() repositories.IRepository {
-	if config.Settings.DatabaseDSN != "" {
-		db, err := sql.Open("pgx", config.Settings.DatabaseDSN)
-		if err != nil {
-			log.Fatal(config.NoConnectionToDatabase)
-		}
-		// defer db.Close()
-		// note, we haven't deffered db.Close() at the init function since the connection will close after init.
-		// you could close it at main or ommit it
-
-		db.SetMaxOpenConns(20)
-		db.SetMaxIdleConns(20)
-		db.SetConnMaxIdleTime(time.Second * 30)
-		db.SetConnMaxLifetime(time.Minute * 2)
+	primary := primaryStorage()
+	repo := openRepository(primary)
+	secondary := config.Settings.SecondaryStorage
+	if secondary == "" {
+		return repo
+	}
+	if secondary == primary {
+		log.Fatalf("Secondary storage has to differ from primary %s storage", primary)
+	}
+	log.Printf("Writes are duplicated to %s storage", secondary)
+	return repositories.NewDualWriteRepository(repo, openRepository(secondary), config.Settings.ReadFallback)
+}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
-		defer cancel()
+// primaryStorage returns kind of primary storage, database is preferred to file unless chosen explicitly.
+func primaryStorage() string {
+	switch {
+	case config.Settings.PrimaryStorage != "":
+		return config.Settings.PrimaryStorage
+	case config.Settings.DatabaseDSN != "":
+		return config.StorageDatabase
+	case config.Settings.FileStoragePath != "":
+		return config.StorageFile
+	}
+	return config.StorageMemory
+}
 
-		if err = db.PingContext(ctx); err != nil {
-			log.Fatal(config.NoConnectionToDatabase)
-		}
-		_, err = db.ExecContext(
-			ctx,
-			`CREATE TABLE IF NOT EXISTS short_urls (
-				id varchar(45) NOT NULL PRIMARY KEY, 
-				short_url varchar(150) NOT NULL, 
-				original_url varchar(255) NOT NULL UNIQUE, 
-				correlation_id varchar(255), 
-				is_active boolean default true, 
-				user_id uuid NOT NULL
-            )`,
-		)
-		if err != nil {
+// openRepository opens storage of the kind, in memory one is used if file storage can't be restored.
+func openRepository(storage string) repositories.IRepository {
+	switch storage {
+	case config.StorageDatabase:
+		if config.Settings.DatabaseDSN == "" {
 			log.Fatal(config.NoConnectionToDatabase)
 		}
-		log.Println("Postgres storage`s been  chosen")
-		repo := &repositories.DatabaseRepository{
-			Storage:  db,
-			ToDelete: make(chan *entities.ItemToDelete, 16),
+		return openDatabaseRepository()
+	case config.StorageFile:
+		if config.Settings.FileStoragePath == "" && config.Settings.SecondaryStorage != "" {
+			log.Fatal("File storage path is required to migrate file storage")
 		}
-		go repo.AccumulateRecordsToDelete()
-		return repo
-	}
-	if config.Settings.FileStoragePath != "" {
 		repo := repositories.FileRepository{
 			Storage:  make(map[string]entities.ShortURL),
 			FilePath: config.Settings.FileStoragePath,
@@ -65,13 +62,68 @@ func SetRepository() repositories.IRepository {
 
 		if err := repo.Restore(); err == nil {
 			log.Println("File storage`s been  chosen")
+			repo.Batcher = startDeleteBatcher(repo.DeleteRecordsForUser)
 			return &repo
 		}
 		log.Println("Error while choosing file storage")
+	case config.StorageMemory:
+	default:
+		log.Fatalf("Unknown storage %q", storage)
 	}
 
 	repo := repositories.InMemoryRepository{Storage: make(map[string]entities.ShortURL)}
+	repo.Batcher = startDeleteBatcher(repo.DeleteRecordsForUser)
 
 	log.Println("In memory storage`s been chosen")
 	return &repo
 }
+
+// openDatabaseRepository connects to database and creates schema.
+func openDatabaseRepository() repositories.IRepository {
+	db, err := sql.Open("pgx", config.Settings.DatabaseDSN)
+	if err != nil {
+		log.Fatal(config.NoConnectionToDatabase)
+	}
+	// defer db.Close()
+	// note, we haven't deffered db.Close() at the init function since the connection will close after init.
+	// you could close it at main or ommit it
+
+	db.SetMaxOpenConns(20)
+	db.SetMaxIdleConns(20)
+	db.SetConnMaxIdleTime(time.Second * 30)
+	db.SetConnMaxLifetime(time.Minute * 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	if err = db.PingContext(ctx); err != nil {
+		log.Fatal(config.NoConnectionToDatabase)
+	}
+	migrationCtx, cancelMigration := context.WithTimeout(context.Background(), config.Settings.MigrationTimeout)
+	defer cancelMigration()
+	if err = migrateDatabase(migrationCtx, db); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Postgres storage`s been  chosen")
+	repo := &repositories.DatabaseRepository{Storage: db}
+	repo.Batcher = startDeleteBatcher(repo.DeleteRecordsForUser)
+	resumed, err := repo.ResumeDeleteJobs(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if resumed > 0 {
+		log.Printf("%d deletion jobs left queued are resumed", resumed)
+	}
+	return repo
+}
+
+// startDeleteBatcher starts background DeleteBatcher configured with application settings.
+func startDeleteBatcher(deleter repositories.RecordsDeleter) *repositories.DeleteBatcher {
+	batcher := repositories.NewDeleteBatcher(
+		deleter,
+		config.Settings.DeleteFlushInterval,
+		config.Settings.DeleteMaxBatchSize,
+	)
+	go batcher.Run(context.Background())
+	return batcher
+}
@@ -23,6 +23,8 @@ func TestSetRepositories(t *testing.T) {
 		name        string
 		filepath    string
 		databaseDSN string
+		primary     string
+		secondary   string
 		want        string
 	}{
 		{
@@ -35,11 +37,20 @@ func TestSetRepositories(t *testing.T) {
 			filepath: "test.json",
 			want:     reflect.TypeOf(&repositories.FileRepository{}).String(),
 		},
+		{
+			name:      "Check if dual write repo",
+			filepath:  "test.json",
+			primary:   "memory",
+			secondary: "file",
+			want:      reflect.TypeOf(&repositories.DualWriteRepository{}).String(),
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			config.Settings.FileStoragePath = tt.filepath
 			config.Settings.DatabaseDSN = tt.databaseDSN
+			config.Settings.PrimaryStorage = tt.primary
+			config.Settings.SecondaryStorage = tt.secondary
 			got := SetRepository()
 			assert.Equal(t, reflect.TypeOf(got).String(), tt.want)
 		})
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// DetectPlatform returns platform of client by its User-Agent, empty if it is not recognized.
+func DetectPlatform(userAgent string) string {
+	switch {
+	// iOS browsers also claim to be "like Mac OS X", so they are checked first.
+	case strings.Contains(userAgent, "iPhone"), strings.Contains(userAgent, "iPad"), strings.Contains(userAgent, "iPod"):
+		return entities.PlatformIOS
+	// Android browsers also claim to be Linux.
+	case strings.Contains(userAgent, "Android"):
+		return entities.PlatformAndroid
+	case strings.Contains(userAgent, "Windows"):
+		return entities.PlatformWindows
+	case strings.Contains(userAgent, "Macintosh"), strings.Contains(userAgent, "Mac OS X"):
+		return entities.PlatformMacOS
+	case strings.Contains(userAgent, "Linux"):
+		return entities.PlatformLinux
+	default:
+		return ""
+	}
+}
+
+// PreferredLanguage returns language with the highest weight in Accept-Language header, empty if there is none.
+func PreferredLanguage(acceptLanguage string) string {
+	type weighted struct {
+		language string
+		weight   float64
+	}
+	languages := make([]weighted, 0, 4)
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		language, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		language = strings.TrimSpace(language)
+		if language == "" || language == "*" {
+			continue
+		}
+		weight := 1.0
+		if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") {
+			parsed, err := strconv.ParseFloat(strings.TrimPrefix(params, "q="), 64)
+			if err != nil {
+				continue
+			}
+			weight = parsed
+		}
+		if weight > 0 {
+			languages = append(languages, weighted{language: language, weight: weight})
+		}
+	}
+	if len(languages) == 0 {
+		return ""
+	}
+	sort.SliceStable(languages, func(i, j int) bool { return languages[i].weight > languages[j].weight })
+	return languages[0].language
+}
+
+// CountryResolver resolves ISO 3166 country code of IP address.
+type CountryResolver interface {
+	Country(ip net.IP) (string, error)
+}
+
+// GeoIPDatabase CountryResolver reading local database file in MaxMind format, e.g. GeoLite2-Country.
+type GeoIPDatabase struct {
+	reader *maxminddb.Reader
+}
+
+// geoIPRecord part of MaxMind country and city database records holding country.
+type geoIPRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+}
+
+// OpenGeoIPDatabase opens MaxMind format database file.
+func OpenGeoIPDatabase(path string) (*GeoIPDatabase, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &GeoIPDatabase{reader: reader}, nil
+}
+
+// Country returns country code of IP address, empty if database does not know it.
+func (db *GeoIPDatabase) Country(ip net.IP) (string, error) {
+	if ip == nil {
+		return "", errors.New("invalid IP address")
+	}
+	var record geoIPRecord
+	if err := db.reader.Lookup(ip, &record); err != nil {
+		return "", err
+	}
+	return record.Country.ISOCode, nil
+}
+
+// Close closes database file.
+func (db *GeoIPDatabase) Close() error {
+	return db.reader.Close()
+}
@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectPlatform(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		want      string
+	}{
+		{
+			name:      "iPhone",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148",
+			want:      entities.PlatformIOS,
+		},
+		{
+			name:      "iPad",
+			userAgent: "Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148",
+			want:      entities.PlatformIOS,
+		},
+		{
+			name:      "Android",
+			userAgent: "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 Chrome/123.0 Mobile Safari/537.36",
+			want:      entities.PlatformAndroid,
+		},
+		{
+			name:      "Windows",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/123.0 Safari/537.36",
+			want:      entities.PlatformWindows,
+		},
+		{
+			name:      "macOS",
+			userAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 Version/17.4 Safari/605.1.15",
+			want:      entities.PlatformMacOS,
+		},
+		{
+			name:      "Linux",
+			userAgent: "Mozilla/5.0 (X11; Linux x86_64; rv:124.0) Gecko/20100101 Firefox/124.0",
+			want:      entities.PlatformLinux,
+		},
+		{
+			name:      "unknown",
+			userAgent: "curl/8.5.0",
+			want:      "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, DetectPlatform(tt.userAgent))
+		})
+	}
+}
+
+func TestPreferredLanguage(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		want           string
+	}{
+		{name: "empty", acceptLanguage: "", want: ""},
+		{name: "single", acceptLanguage: "de-DE", want: "de-DE"},
+		{name: "first of equal weights", acceptLanguage: "fr-CH, fr;q=0.9, en;q=0.8", want: "fr-CH"},
+		{name: "highest weight", acceptLanguage: "en;q=0.5, ru;q=0.9, *;q=1", want: "ru"},
+		{name: "zero weight is refused", acceptLanguage: "en;q=0, de;q=0.1", want: "de"},
+		{name: "invalid weight is skipped", acceptLanguage: "en;q=high, de;q=0.1", want: "de"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, PreferredLanguage(tt.acceptLanguage))
+		})
+	}
+}
+
+func TestOpenGeoIPDatabase(t *testing.T) {
+	_, err := OpenGeoIPDatabase(filepath.Join(t.TempDir(), "missing.mmdb"))
+	assert.Error(t, err)
+}
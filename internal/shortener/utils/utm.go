@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"net/url"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+)
+
+// AddUTM sets UTM params in query of destination, params of destination with the same names are replaced.
+func AddUTM(destination string, utm *entities.UTM) (string, error) {
+	if utm.IsEmpty() {
+		return destination, nil
+	}
+	result, err := url.Parse(destination)
+	if err != nil {
+		return "", err
+	}
+	query := result.Query()
+	for key, values := range utm.Params() {
+		query[key] = values
+	}
+	result.RawQuery = query.Encode()
+	return result.String(), nil
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/RomanAVolodin/go-url-shortener/internal/shortener/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddUTM(t *testing.T) {
+	tests := []struct {
+		name        string
+		destination string
+		utm         *entities.UTM
+		want        string
+	}{
+		{
+			name:        "no utm",
+			destination: "https://ya.ru/?b=2&a=1",
+			want:        "https://ya.ru/?b=2&a=1",
+		},
+		{
+			name:        "empty utm",
+			destination: "https://ya.ru/?b=2&a=1",
+			utm:         &entities.UTM{},
+			want:        "https://ya.ru/?b=2&a=1",
+		},
+		{
+			name:        "all params",
+			destination: "https://ya.ru/page#top",
+			utm: &entities.UTM{
+				Source: "mail", Medium: "email", Campaign: "spring sale", Term: "shoes", Content: "button",
+			},
+			want: "https://ya.ru/page?utm_campaign=spring+sale&utm_content=button&utm_medium=email&utm_source=mail&utm_term=shoes#top",
+		},
+		{
+			name:        "replaces params of destination",
+			destination: "https://ya.ru/?utm_source=site&id=1",
+			utm:         &entities.UTM{Source: "mail"},
+			want:        "https://ya.ru/?id=1&utm_source=mail",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := AddUTM(tt.destination, tt.utm)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"net/url"
+	"strings"
+)
+
+// MaxURLLength maximal length of original url, matches short_urls.original_url column.
+const MaxURLLength = 255
+
+// IsValidURL reports whether raw is an absolute http(s) url which may be shortened.
+func IsValidURL(raw string) bool {
+	if raw == "" || len(raw) > MaxURLLength || strings.TrimSpace(raw) != raw {
+		return false
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	return (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// IsAllowedDomain reports whether host of raw url is one of domains or their subdomain.
+func IsAllowedDomain(raw string, domains []string) bool {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(parsed.Hostname())
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if domain != "" && (host == domain || strings.HasSuffix(host, "."+domain)) {
+			return true
+		}
+	}
+	return false
+}
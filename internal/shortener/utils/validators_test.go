@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsValidURL(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want bool
+	}{
+		{name: "https url", raw: "https://ya.ru/some/path?q=1", want: true},
+		{name: "http url", raw: "http://localhost:8080", want: true},
+		{name: "empty string", raw: "", want: false},
+		{name: "no scheme", raw: "ya.ru", want: false},
+		{name: "not http scheme", raw: "javascript:alert(1)", want: false},
+		{name: "no host", raw: "https://", want: false},
+		{name: "surrounding spaces", raw: " https://ya.ru", want: false},
+		{name: "too long", raw: "https://ya.ru/" + strings.Repeat("a", MaxURLLength), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsValidURL(tt.raw))
+		})
+	}
+}
+
+func TestIsAllowedDomain(t *testing.T) {
+	domains := []string{"ya.ru", " Example.COM "}
+	tests := []struct {
+		name string
+		raw  string
+		want bool
+	}{
+		{name: "same domain", raw: "https://ya.ru/path", want: true},
+		{name: "subdomain", raw: "https://mail.ya.ru", want: true},
+		{name: "case and port", raw: "https://WWW.example.com:8443/", want: true},
+		{name: "other domain", raw: "https://evil.ru", want: false},
+		{name: "domain as suffix of other", raw: "https://notya.ru", want: false},
+		{name: "domain in path", raw: "https://evil.ru/ya.ru", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsAllowedDomain(tt.raw, domains))
+		})
+	}
+}